@@ -0,0 +1,56 @@
+package nsjail
+
+import (
+	"sync"
+)
+
+// argvCache holds the flags built for a configuration, so building it again, e.g. Args or Exec per
+// request, reuses them until a builder method changes the configuration: each one invalidates the cache of
+// its NsJail, see changed. Clones get an empty cache of their own, as the package modifies clones directly.
+// Configurations whose options depend on the host, see hostDependent, are built on every call. The
+// configuration is still resolved and validated on every call, so validation that depends on the host,
+// e.g. of StrictMounts, is not skipped.
+type argvCache struct {
+	mu    sync.Mutex
+	valid bool
+	flags []string
+}
+
+// optionBufs recycles the option slices built by newLaunch.
+var optionBufs = sync.Pool{New: func() any { return new([]option) }}
+
+// changed invalidates the flags cached for n and returns n. Builder methods return through it.
+func (n *NsJail) changed() *NsJail {
+	if c := n.argv; c != nil {
+		c.mu.Lock()
+		c.valid, c.flags = false, nil
+		c.mu.Unlock()
+	}
+	return n
+}
+
+// hostDependent reports whether the options of n depend on the host rather than only on the configuration,
+// so that they cannot be cached.
+func (n *NsJail) hostDependent() bool {
+	return n.macvlanAuto || len(n.envFiles) > 0 || len(n.envPatterns) > 0 || n.cgroupAuto ||
+		len(n.binaryDeps) > 0 || n.compat != CompatOff && n.binaryCaps == nil
+}
+
+// get returns the flags cached by c, or else those that build returns, which it caches. The result is
+// shared and must not be modified; its capacity equals its length, so appending to it copies.
+func (c *argvCache) get(build func() ([]string, error)) ([]string, error) {
+	if c == nil {
+		return build()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.valid {
+		return c.flags, nil
+	}
+	flags, err := build()
+	if err != nil {
+		return nil, err
+	}
+	c.valid, c.flags = true, flags
+	return flags, nil
+}
@@ -0,0 +1,108 @@
+package nsjail
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func benchmarkJail() *NsJail {
+	return New("/usr/bin/python3", "main.py").WithChroot("/").WithTimeLimit(10).AddEnv("LANG", "C").
+		AddBindRO("/usr", "/usr").AddBindRO("/lib", "/lib").AddTmpfsMount("/tmp")
+}
+
+// BenchmarkArgs compares building the command line without the cache, with a builder method invalidating
+// it before every call, and with the flags cached.
+func BenchmarkArgs(b *testing.B) {
+	b.Run("uncached", func(b *testing.B) {
+		n := benchmarkJail()
+		n.argv = nil
+		b.ReportAllocs()
+		for b.Loop() {
+			n.Args()
+		}
+	})
+	b.Run("miss", func(b *testing.B) {
+		n := benchmarkJail()
+		b.ReportAllocs()
+		for b.Loop() {
+			n.WithTimeLimit(10)
+			n.Args()
+		}
+	})
+	b.Run("hit", func(b *testing.B) {
+		n := benchmarkJail()
+		b.ReportAllocs()
+		for b.Loop() {
+			n.Args()
+		}
+	})
+}
+
+func TestArgvCache(t *testing.T) {
+	n := benchmarkJail()
+	first, err := n.Args()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !n.argv.valid {
+		t.Fatalf("Args did not cache the flags")
+	}
+	again, _ := n.Args()
+	if !slices.Equal(again, first) {
+		t.Errorf("Args = %q, want %q", again, first)
+	}
+	c := n.Clone().WithTimeLimit(20)
+	if n.argv == c.argv {
+		t.Errorf("clone shares the cache")
+	}
+	if got, _ := n.Args(); !slices.Equal(got, first) {
+		t.Errorf("Args after changing a clone = %q, want %q", got, first)
+	}
+	n.WithTimeLimit(30)
+	if got, _ := n.Args(); !slices.Contains(got, "30") {
+		t.Errorf("Args after WithTimeLimit(30) = %q, want the new limit", got)
+	}
+}
+
+// TestBuildersInvalidateArgv calls every builder method with zero arguments, or pointers to zero values,
+// on a jail with cached flags, which must invalidate them.
+func TestBuildersInvalidateArgv(t *testing.T) {
+	typ := reflect.TypeFor[*NsJail]()
+	for i := range typ.NumMethod() {
+		m := typ.Method(i)
+		if m.Name == "Clone" || m.Type.NumOut() != 1 || m.Type.Out(0) != typ {
+			continue
+		}
+		t.Run(m.Name, func(t *testing.T) {
+			n := New("/bin/true")
+			if _, err := n.Args(); err != nil {
+				t.Fatal(err)
+			}
+			args := []reflect.Value{reflect.ValueOf(n)}
+			for j := 1; j < m.Type.NumIn(); j++ {
+				if m.Type.IsVariadic() && j == m.Type.NumIn()-1 {
+					break
+				}
+				in := m.Type.In(j)
+				if in.Kind() == reflect.Pointer {
+					args = append(args, reflect.New(in.Elem()))
+				} else {
+					args = append(args, reflect.Zero(in))
+				}
+			}
+			func() {
+				// Some need a usable argument, e.g. WithTemplate a created template.
+				defer func() {
+					if r := recover(); r != nil {
+						t.Skipf("panics on zero arguments: %v", r)
+					}
+				}()
+				m.Func.Call(args)
+			}()
+			if n.argv.valid {
+				t.Errorf("%s kept the cached flags", m.Name)
+			}
+		})
+	}
+}
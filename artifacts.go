@@ -0,0 +1,326 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultArtifactFileSize  = 16 << 20
+	defaultArtifactTotalSize = 64 << 20
+)
+
+// ArtifactOptions configures how CollectFiles gathers files.
+type ArtifactOptions struct {
+	// MaxFileSize skips files larger than it. Defaults to 16MiB.
+	MaxFileSize int64
+	// MaxTotalSize skips files once the collected files would exceed it in total. Defaults to 64MiB.
+	MaxTotalSize int64
+	// Dir makes the files be copied into a new directory created in Dir instead of being read into memory,
+	// see ArtifactSet.Dir.
+	Dir string
+}
+
+// Artifact is a file collected from the jail.
+type Artifact struct {
+	// Path is the path of the file in the jail.
+	Path string
+	Size int64
+	Mode fs.FileMode
+	// Data is the content of the file, unless ArtifactOptions.Dir is set.
+	Data []byte
+	// File is the host path of the copy of the file if ArtifactOptions.Dir is set.
+	File string
+}
+
+// ArtifactSet is the set of files collected from a jail, see CollectFiles.
+type ArtifactSet struct {
+	// Files are the collected files, sorted by Path.
+	Files []Artifact
+	// Skipped lists the paths of matching files that exceeded ArtifactOptions.MaxFileSize or MaxTotalSize.
+	Skipped []string
+	// Dir is the directory holding the copies of the files if ArtifactOptions.Dir is set. It is removed by
+	// Remove.
+	Dir string
+}
+
+// Get returns the collected file at path in the jail.
+func (s *ArtifactSet) Get(path string) (*Artifact, bool) {
+	i, ok := slices.BinarySearchFunc(s.Files, path, func(a Artifact, p string) int { return strings.Compare(a.Path, p) })
+	if !ok {
+		return nil, false
+	}
+	return &s.Files[i], true
+}
+
+// Remove removes the copies of the files made with ArtifactOptions.Dir.
+func (s *ArtifactSet) Remove() error {
+	if s.Dir == "" {
+		return nil
+	}
+	return os.RemoveAll(s.Dir)
+}
+
+// CollectFiles makes Start and Run gather the regular files matching patterns from the writable mounts of
+// the jail once it exited, into Result.Artifacts. Patterns are absolute paths in the jail using the syntax
+// of path.Match, e.g. "/workspace/*.out". Only files the jail can write to and the host can read afterwards
+// are found: read-write bind mounts (including WithWorkspace) and the upper directory of an overlay root.
+// Symbolic links never resolve outside the mount they are in; they and other file types that are not
+// regular files are ignored. Limits are set with WithArtifactOptions.
+func (n *NsJail) CollectFiles(patterns ...string) *NsJail {
+	n.artifactPatterns = append(n.artifactPatterns, patterns...)
+	return n.changed()
+}
+
+// WithArtifactOptions sets the limits of CollectFiles and where it stores the files.
+func (n *NsJail) WithArtifactOptions(opts ArtifactOptions) *NsJail {
+	n.artifactOptions = opts
+	return n.changed()
+}
+
+// writableMount is a host directory or file the jail writes to at dst.
+type writableMount struct {
+	src, dst string
+}
+
+// writableMounts returns the mounts of n whose changes are visible on the host.
+func (n *NsJail) writableMounts() []writableMount {
+	var mounts []writableMount
+	if n.overlay != nil && n.overlay.upper != "" {
+		mounts = append(mounts, writableMount{src: n.overlay.upper, dst: "/"})
+	}
+	for _, spec := range n.bindMountsRW {
+		src, dst, _ := strings.Cut(spec, ":")
+		if dst == "" {
+			dst = src
+		}
+		mounts = append(mounts, writableMount{src: src, dst: path.Clean(dst)})
+	}
+	return mounts
+}
+
+// useArtifacts collects the artifacts of n into the result of the jail once it exited. It must be registered
+// after the mounts it reads are created, so it runs before they are removed.
+func (j *Jail) useArtifacts(n *NsJail) error {
+	for _, p := range n.artifactPatterns {
+		if !path.IsAbs(p) {
+			return fmt.Errorf("nsjail: artifact pattern %q is not absolute", p)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("nsjail: artifact pattern %q: %w", p, err)
+		}
+	}
+	c := &artifactCollector{
+		patterns: slices.Clone(n.artifactPatterns),
+		mounts:   n.writableMounts(),
+		opts:     n.artifactOptions,
+	}
+	if c.opts.MaxFileSize <= 0 {
+		c.opts.MaxFileSize = defaultArtifactFileSize
+	}
+	if c.opts.MaxTotalSize <= 0 {
+		c.opts.MaxTotalSize = defaultArtifactTotalSize
+	}
+	j.onClose(func() {
+		// Nothing to collect if nsjail never ran.
+		if j.proc == nil {
+			return
+		}
+		set, err := c.collect()
+		j.artifacts = set
+		if err != nil {
+			j.waitErr = errors.Join(j.waitErr, fmt.Errorf("nsjail: collecting artifacts: %w", err))
+		}
+	})
+	return nil
+}
+
+type artifactCollector struct {
+	patterns []string
+	mounts   []writableMount
+	opts     ArtifactOptions
+
+	set   *ArtifactSet
+	seen  map[string]bool
+	total int64
+}
+
+func (c *artifactCollector) collect() (*ArtifactSet, error) {
+	c.set = &ArtifactSet{}
+	c.seen = make(map[string]bool)
+	if c.opts.Dir != "" {
+		dir, err := os.MkdirTemp(c.opts.Dir, "nsjail-artifacts-*")
+		if err != nil {
+			return c.set, err
+		}
+		c.set.Dir = dir
+	}
+	var errs []error
+	for _, m := range c.mounts {
+		if err := c.collectMount(m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	slices.SortFunc(c.set.Files, func(a, b Artifact) int { return strings.Compare(a.Path, b.Path) })
+	slices.Sort(c.set.Skipped)
+	return c.set, errors.Join(errs...)
+}
+
+// owner returns the mount a path in the jail is on, the one with the longest destination containing it.
+func (c *artifactCollector) owner(p string) writableMount {
+	var best writableMount
+	for _, m := range c.mounts {
+		if (m.dst == "/" || p == m.dst || strings.HasPrefix(p, m.dst+"/")) && len(m.dst) >= len(best.dst) {
+			best = m
+		}
+	}
+	return best
+}
+
+func (c *artifactCollector) collectMount(m writableMount) error {
+	fi, err := os.Stat(m.src)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		// A bind-mounted file.
+		if c.matches(m.dst) && c.owner(m.dst) == m {
+			f, err := os.Open(m.src)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return c.add(m.dst, f)
+		}
+		return nil
+	}
+
+	root, err := os.OpenRoot(m.src)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	var errs []error
+	for _, p := range c.patterns {
+		rel, ok := relativePattern(p, m.dst)
+		if !ok {
+			continue
+		}
+		// The root keeps symbolic links from resolving outside the mount.
+		matches, err := fs.Glob(root.FS(), rel)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, name := range matches {
+			p := path.Join(m.dst, name)
+			if c.seen[p] || c.owner(p) != m {
+				continue
+			}
+			if err := c.addFromRoot(root, name, p); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// relativePattern returns the part of pattern below the mount destination dst, if the leading elements of
+// pattern match dst.
+func relativePattern(pattern, dst string) (string, bool) {
+	pe := strings.Split(strings.Trim(path.Clean(pattern), "/"), "/")
+	var de []string
+	if dst != "/" {
+		de = strings.Split(strings.Trim(dst, "/"), "/")
+	}
+	if len(de) >= len(pe) {
+		return "", false
+	}
+	for i, d := range de {
+		if ok, _ := path.Match(pe[i], d); !ok {
+			return "", false
+		}
+	}
+	return path.Join(pe[len(de):]...), true
+}
+
+func (c *artifactCollector) matches(p string) bool {
+	for _, pattern := range c.patterns {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *artifactCollector) addFromRoot(root *os.Root, name, p string) error {
+	fi, err := root.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	f, err := root.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.add(p, f)
+}
+
+// add collects the file f at p in the jail, unless it exceeds the limits.
+func (c *artifactCollector) add(p string, f *os.File) error {
+	c.seen[p] = true
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	size := fi.Size()
+	if size > c.opts.MaxFileSize || c.total+size > c.opts.MaxTotalSize {
+		c.set.Skipped = append(c.set.Skipped, p)
+		return nil
+	}
+	a := Artifact{Path: p, Size: size, Mode: fi.Mode()}
+	// Read at most the size that was checked, in case the file is still growing.
+	r := io.LimitReader(f, size)
+	if c.set.Dir == "" {
+		if a.Data, err = io.ReadAll(r); err != nil {
+			return err
+		}
+		a.Size = int64(len(a.Data))
+	} else {
+		a.File = filepath.Join(c.set.Dir, filepath.FromSlash(p))
+		if a.Size, err = copyArtifact(a.File, r); err != nil {
+			return err
+		}
+	}
+	c.total += a.Size
+	c.set.Files = append(c.set.Files, a)
+	return nil
+}
+
+func copyArtifact(file string, r io.Reader) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
@@ -0,0 +1,187 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// Severity ranks the risk of an audit finding.
+type Severity int
+
+const (
+	// SeverityLow weakens defense in depth, e.g. running without a seccomp policy.
+	SeverityLow Severity = iota
+	// SeverityMedium exposes the host to the jail, e.g. its network.
+	SeverityMedium
+	// SeverityHigh gives the jail a known path to escalate or to affect other processes.
+	SeverityHigh
+	// SeverityCritical lets the jail modify the host.
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityLow:
+		return "low"
+	case SeverityMedium:
+		return "medium"
+	case SeverityHigh:
+		return "high"
+	case SeverityCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// AuditFinding is a risky setting found by AuditConfig.
+type AuditFinding struct {
+	// Rule identifies the check: "no_new_privs", "proc_rw", "rw_root", "keep_caps", "caps", "pid_namespace",
+	// "user_namespace", "mount_namespace", "net_namespace", "ipc_namespace", "root_mapping",
+	// "sensitive_mount", "keep_env" or "seccomp".
+	Rule     string
+	Severity Severity
+	// Detail describes the setting.
+	Detail string
+	// Fix suggests how to remove the risk.
+	Fix string
+}
+
+// AuditReport lists the findings of AuditConfig, most severe first.
+type AuditReport struct {
+	Findings []AuditFinding
+}
+
+// Max returns the highest severity of the findings, or -1 if there are none.
+func (r *AuditReport) Max() Severity {
+	if len(r.Findings) == 0 {
+		return -1
+	}
+	return r.Findings[0].Severity
+}
+
+// Err returns an error describing the findings of severity min or higher, or nil. CI can gate
+// configurations on it, e.g. n.AuditConfig().Err(nsjail.SeverityHigh).
+func (r *AuditReport) Err(min Severity) error {
+	var errs []error
+	for _, f := range r.Findings {
+		if f.Severity >= min {
+			errs = append(errs, fmt.Errorf("nsjail: audit %s (%v): %s; %s", f.Rule, f.Severity, f.Detail, f.Fix))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// String formats the report as a table.
+func (r *AuditReport) String() string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tSEVERITY\tDETAIL\tFIX")
+	for _, f := range r.Findings {
+		fmt.Fprintf(tw, "%s\t%v\t%s\t%s\n", f.Rule, f.Severity, f.Detail, f.Fix)
+	}
+	tw.Flush()
+	return sb.String()
+}
+
+// riskyCaps are the capabilities that let a process escape or take over the jail, or affect the host.
+var riskyCaps = []Capability{
+	CapSysAdmin, CapSysPtrace, CapSysModule, CapSysRawio, CapSysBoot, CapDacReadSearch, CapDacOverride,
+	CapSetuid, CapSetgid, CapSetpcap, CapSetfcap, CapNetAdmin, CapBpf, CapPerfmon, CapMknod,
+}
+
+// sensitiveMounts are host paths whose contents let the jail control the host when writable.
+var sensitiveMounts = []string{
+	"/proc", "/sys", "/dev", "/etc", "/boot", "/root", "/var/run", "/run", "/var/lib/docker",
+	"/run/docker.sock", "/var/run/docker.sock",
+}
+
+// AuditConfig checks the configuration for settings that weaken the isolation of the jail, such as
+// DisableNoNewPrivs, MountProcRW, a writable host root, KeepCaps without a list of capabilities or a
+// disabled PID namespace, and returns them with their severities. It only inspects the configuration: it
+// does not read the config file of WithConfigFile, and does not check the host.
+func (n *NsJail) AuditConfig() *AuditReport {
+	r := &AuditReport{}
+	add := func(rule string, sev Severity, detail, fix string) {
+		r.Findings = append(r.Findings, AuditFinding{Rule: rule, Severity: sev, Detail: detail, Fix: fix})
+	}
+
+	if n.disableNoNewPrivs {
+		add("no_new_privs", SeverityHigh, "no_new_privs is disabled, so set-uid binaries in the jail gain privileges",
+			"remove DisableNoNewPrivs")
+	}
+	if n.procRw {
+		add("proc_rw", SeverityHigh, "/proc is mounted read-write, exposing writable kernel settings",
+			"remove MountProcRW")
+	}
+	if n.rwChroot && path.Clean(n.chroot) == "/" {
+		add("rw_root", SeverityCritical, "the host root is the read-write root of the jail",
+			"remove MountChrootRW or use another chroot")
+	}
+	for _, spec := range n.bindMountsRW {
+		src, _, _ := strings.Cut(spec, ":")
+		src = path.Clean(src)
+		switch {
+		case src == "/":
+			add("rw_root", SeverityCritical, "the host root is bind-mounted read-write",
+				"mount it read-only with AddBindRO")
+		case slices.ContainsFunc(sensitiveMounts, func(p string) bool {
+			return src == p || strings.HasPrefix(src, p+"/")
+		}):
+			add("sensitive_mount", SeverityHigh, fmt.Sprintf("%s is bind-mounted read-write", src),
+				"mount it read-only or leave it out")
+		}
+	}
+
+	if n.keepCaps && len(n.caps) == 0 {
+		add("keep_caps", SeverityHigh, "all capabilities are kept", "retain only the needed ones with AddCap")
+	}
+	for _, c := range n.caps {
+		if slices.Contains(riskyCaps, Capability(c)) {
+			add("caps", SeverityHigh, fmt.Sprintf("%s is kept", c), "drop it unless the command needs it")
+		}
+	}
+
+	if n.cloneNewPidDisabled {
+		add("pid_namespace", SeverityHigh,
+			"the PID namespace is disabled, so the jail sees and can signal host processes", "remove DisableCloneNewPid")
+	}
+	if n.cloneNewUserDisabled {
+		add("user_namespace", SeverityHigh, "the user namespace is disabled, so root in the jail is root on the host",
+			"remove DisableCloneNewUser")
+	}
+	if n.cloneNewNsDisabled {
+		add("mount_namespace", SeverityHigh, "the mount namespace is disabled, so the jail sees the host's mounts",
+			"remove DisableCloneNewNs")
+	}
+	if n.cloneNewNetDisabled {
+		add("net_namespace", SeverityMedium,
+			"the network namespace is disabled, so the jail shares the host's network",
+			"remove DisableCloneNewNet, and use WithVeth or WithSlirp4netns for network access")
+	}
+	if n.cloneNewIpcDisabled {
+		add("ipc_namespace", SeverityLow, "the IPC namespace is disabled, so the jail shares the host's SysV IPC",
+			"remove DisableCloneNewIpc")
+	}
+	for _, m := range slices.Concat(n.uidMappings, n.gidMappings) {
+		if mapsHostRoot(m) {
+			add("root_mapping", SeverityHigh, fmt.Sprintf("mapping %q maps host root into the jail", m),
+				"map an unprivileged id instead")
+		}
+	}
+
+	if n.keepEnv || slices.Contains(n.envPatterns, "*") {
+		add("keep_env", SeverityLow,
+			"the whole environment is passed to the jail, including any secrets in it",
+			"pass only the needed variables with PassEnv")
+	}
+	if n.seccompPolicy == "" && n.seccompString == "" {
+		add("seccomp", SeverityLow, "no seccomp policy is set", "set one, e.g. WithSeccompString(PresetSeccompPolicy)")
+	}
+
+	slices.SortStableFunc(r.Findings, func(a, b AuditFinding) int { return int(b.Severity - a.Severity) })
+	return r
+}
@@ -0,0 +1,69 @@
+package nsjail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuditConfig(t *testing.T) {
+	secure := func() *NsJail { return New("/bin/true").WithSeccompString("KILL { ptrace } DEFAULT ALLOW") }
+	tests := []struct {
+		name     string
+		jail     *NsJail
+		rule     string
+		severity Severity
+	}{
+		{"rw root chroot", secure().WithChroot("/").MountChrootRW(), "rw_root", SeverityCritical},
+		{"rw root bind", secure().AddBindRW("/", "/host"), "rw_root", SeverityCritical},
+		{"no_new_privs", secure().DisableNoNewPrivs(), "no_new_privs", SeverityHigh},
+		{"proc rw", secure().MountProcRW(), "proc_rw", SeverityHigh},
+		{"sensitive mount", secure().AddBindRW("/var/run/docker.sock", ""), "sensitive_mount", SeverityHigh},
+		{"keep caps", secure().KeepCaps(), "keep_caps", SeverityHigh},
+		{"risky cap", secure().AddCap(CapSysAdmin), "caps", SeverityHigh},
+		{"pid namespace", secure().DisableCloneNewPid(), "pid_namespace", SeverityHigh},
+		{"user namespace", secure().DisableCloneNewUser(), "user_namespace", SeverityHigh},
+		{"mount namespace", secure().DisableCloneNewNs(), "mount_namespace", SeverityHigh},
+		{"root mapping", secure().AddUidMapping("0:0:1"), "root_mapping", SeverityHigh},
+		{"net namespace", secure().DisableCloneNewNet(), "net_namespace", SeverityMedium},
+		{"ipc namespace", secure().DisableCloneNewIpc(), "ipc_namespace", SeverityLow},
+		{"keep env", secure().KeepEnv(), "keep_env", SeverityLow},
+		{"inherit all env", secure().InheritEnvMatching("*"), "keep_env", SeverityLow},
+		{"seccomp", New("/bin/true"), "seccomp", SeverityLow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.jail.AuditConfig()
+			if len(r.Findings) != 1 {
+				t.Fatalf("AuditConfig found %d findings, want 1:\n%v", len(r.Findings), r)
+			}
+			if f := r.Findings[0]; f.Rule != tt.rule || f.Severity != tt.severity {
+				t.Errorf("AuditConfig = %s (%v), want %s (%v)", f.Rule, f.Severity, tt.rule, tt.severity)
+			}
+			if r.Max() != tt.severity {
+				t.Errorf("Max = %v, want %v", r.Max(), tt.severity)
+			}
+		})
+	}
+
+	t.Run("clean", func(t *testing.T) {
+		r := secure().AddBindRW("/srv/data", "/data").AddCap(CapNetBindService).AuditConfig()
+		if len(r.Findings) != 0 || r.Max() != -1 || r.Err(SeverityLow) != nil {
+			t.Errorf("AuditConfig of a clean configuration = %v", r)
+		}
+	})
+
+	t.Run("order and Err", func(t *testing.T) {
+		r := New("/bin/true").KeepEnv().DisableCloneNewNet().DisableNoNewPrivs().AuditConfig()
+		var rules []string
+		for _, f := range r.Findings {
+			rules = append(rules, f.Rule)
+		}
+		if got, want := strings.Join(rules, " "), "no_new_privs net_namespace keep_env seccomp"; got != want {
+			t.Errorf("rules = %s, want %s", got, want)
+		}
+		err := r.Err(SeverityMedium)
+		if err == nil || !strings.Contains(err.Error(), "net_namespace") || strings.Contains(err.Error(), "seccomp") {
+			t.Errorf("Err(SeverityMedium) = %v, want the high and medium findings", err)
+		}
+	})
+}
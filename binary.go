@@ -0,0 +1,140 @@
+package nsjail
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBinaryNotFound is returned by DetectBinary when no nsjail binary is installed.
+var ErrBinaryNotFound = errors.New("nsjail: binary not found")
+
+// ErrVersionUnknown is returned by Version when the binary does not report its version.
+var ErrVersionUnknown = errors.New("nsjail: version unknown")
+
+// commonPaths are checked by DetectBinary when nsjail is not on PATH.
+var commonPaths = []string{
+	"/usr/bin/nsjail",
+	"/usr/local/bin/nsjail",
+	"/usr/sbin/nsjail",
+	"/usr/local/sbin/nsjail",
+	"/opt/nsjail/nsjail",
+	"/bin/nsjail",
+}
+
+// probeTimeout bounds how long the nsjail binary may take to print its help or version.
+const probeTimeout = 5 * time.Second
+
+// DetectBinary locates the nsjail binary on PATH or in common install locations.
+func DetectBinary() (string, error) {
+	if path, err := exec.LookPath("nsjail"); err == nil {
+		return path, nil
+	}
+	for _, path := range commonPaths {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return path, nil
+		}
+	}
+	return "", ErrBinaryNotFound
+}
+
+var versionRe = regexp.MustCompile(`(?i)version:?\s+v?(\d+(?:\.\d+)+)`)
+
+// Version runs the nsjail binary at path and parses its version, e.g. "3.4".
+// Builds that do not report a version yield ErrVersionUnknown; use DetectCapabilities to check for flags.
+func Version(path string) (string, error) {
+	for _, arg := range []string{"--version", "--help"} {
+		out, err := probe(path, arg)
+		if err != nil {
+			return "", err
+		}
+		if m := versionRe.FindSubmatch(out); m != nil {
+			return string(m[1]), nil
+		}
+	}
+	return "", ErrVersionUnknown
+}
+
+// probe runs the binary with arg and returns its combined output. nsjail exits with an error code for
+// unknown flags, which is not an error here.
+func probe(path, arg string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, arg).CombinedOutput()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Capabilities lists what an installed nsjail binary supports, parsed from its --help output.
+type Capabilities struct {
+	// Path is the probed binary.
+	Path string
+	// Version is the reported version, or "" if the binary does not report one.
+	Version string
+	// Flags holds the long option names the binary understands, without dashes, e.g. "disable_tsc".
+	Flags map[string]bool
+	// Short maps short option letters to their long names, e.g. "M" to "mode".
+	Short map[string]string
+
+	// Convenience fields derived from Flags.
+	CgroupV2       bool // --use_cgroupv2, --cgroupv2_mount
+	DetectCgroupV2 bool // --detect_cgroupv2
+	DisableTsc     bool // --disable_tsc
+	CloneNewTime   bool // --enable_clone_newtime
+	SeccompLog     bool // --seccomp_log
+	ForwardSignals bool // --forward_signals
+	ExecuteFd      bool // --execute_fd
+	NiceLevel      bool // --nice_level
+}
+
+// Supports reports whether the binary understands an option, given as "--name", "-x" or "name".
+func (c *Capabilities) Supports(flag string) bool {
+	if len(flag) == 2 && flag[0] == '-' && flag[1] != '-' {
+		flag = c.Short[flag[1:]]
+	}
+	return c.Flags[strings.TrimLeft(flag, "-")]
+}
+
+var helpFlagRe = regexp.MustCompile(`(?m)^\s*--([a-z0-9_]+)(?:\|-([A-Za-z0-9]))?`)
+
+var capabilitiesCache sync.Map // path -> *Capabilities
+
+// DetectCapabilities runs nsjail --help at path and reports the flags it supports. Results are cached per path.
+func DetectCapabilities(path string) (*Capabilities, error) {
+	if c, ok := capabilitiesCache.Load(path); ok {
+		return c.(*Capabilities), nil
+	}
+	out, err := probe(path, "--help")
+	if err != nil {
+		return nil, err
+	}
+	c := &Capabilities{Path: path, Flags: make(map[string]bool), Short: make(map[string]string)}
+	for _, m := range helpFlagRe.FindAllSubmatch(out, -1) {
+		c.Flags[string(m[1])] = true
+		if len(m[2]) > 0 {
+			c.Short[string(m[2])] = string(m[1])
+		}
+	}
+	if len(c.Flags) == 0 {
+		return nil, errors.New("nsjail: " + path + " printed no options with --help")
+	}
+	c.Version, _ = Version(path)
+	c.CgroupV2 = c.Flags["use_cgroupv2"]
+	c.DetectCgroupV2 = c.Flags["detect_cgroupv2"]
+	c.DisableTsc = c.Flags["disable_tsc"]
+	c.CloneNewTime = c.Flags["enable_clone_newtime"]
+	c.SeccompLog = c.Flags["seccomp_log"]
+	c.ForwardSignals = c.Flags["forward_signals"]
+	c.ExecuteFd = c.Flags["execute_fd"]
+	c.NiceLevel = c.Flags["nice_level"]
+	capabilitiesCache.Store(path, c)
+	return c, nil
+}
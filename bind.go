@@ -0,0 +1,55 @@
+package nsjail
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// AddBindRO bind-mounts src on the host read-only at dst in the jail (-R src:dst). An empty dst mounts
+// src at the same path. dst must be absolute.
+func (n *NsJail) AddBindRO(src, dst string) *NsJail { return n.AddBindMountRO(bindSpec(src, dst)) }
+
+// AddBindRW bind-mounts src on the host read-write at dst in the jail (-B src:dst). An empty dst mounts
+// src at the same path. dst must be absolute.
+func (n *NsJail) AddBindRW(src, dst string) *NsJail { return n.AddBindMountRW(bindSpec(src, dst)) }
+
+// StrictMounts makes Exec, Build, Start and Run fail when the source of a bind mount does not exist on the
+// host, instead of nsjail failing when it sets up the jail.
+func (n *NsJail) StrictMounts() *NsJail { n.strictMounts = true; return n.changed() }
+
+func bindSpec(src, dst string) string {
+	if dst == "" {
+		return src
+	}
+	return src + ":" + dst
+}
+
+// validateMounts checks that bind mount destinations are absolute and, with StrictMounts, that their
+// sources exist.
+func (n *NsJail) validateMounts() error {
+	for _, set := range []struct {
+		flag  string
+		specs []string
+	}{
+		{"-R", n.bindMountsRO},
+		{"-B", n.bindMountsRW},
+	} {
+		for _, spec := range set.specs {
+			src, dst, hasDst := strings.Cut(spec, ":")
+			if src == "" {
+				return fmt.Errorf("nsjail: bind mount %s %q has no source", set.flag, spec)
+			}
+			if hasDst && !path.IsAbs(dst) {
+				return fmt.Errorf("nsjail: bind mount %s %q: destination must be an absolute path", set.flag, spec)
+			}
+			if n.strictMounts {
+				if _, err := os.Stat(src); err != nil {
+					return fmt.Errorf("nsjail: bind mount %s %q: %w", set.flag, spec, err)
+				}
+			}
+		}
+	}
+	return nil
+}
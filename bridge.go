@@ -0,0 +1,136 @@
+package nsjail
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ConnFdEnv names the variable holding the connection's descriptor when ConnBridge.PassFd is set.
+const ConnFdEnv = "NSJAIL_CONN_FD"
+
+// ConnBridge serves connections accepted by a Go listener, each in a fresh jail. Unlike ModeListenTCP it lets
+// the caller authenticate, log and rate limit connections before the sandbox sees the socket.
+type ConnBridge struct {
+	// Jail is the template, cloned for every connection. ModeListenTCP is replaced by ModeOnce.
+	Jail *NsJail
+	// Accept is called for every connection with its jail configuration, which it may adjust.
+	// Returning an error closes the connection without starting a jail. Nil accepts everything.
+	Accept func(conn net.Conn, jail *NsJail) error
+	// Done is called once the connection's jail exited, before the connection is closed.
+	Done func(conn net.Conn, res *Result, err error)
+	// PassFd hands the socket to the jail as an extra descriptor (--pass_fd) named by NSJAIL_CONN_FD.
+	// By default the socket becomes the jail's stdin and stdout, as in ModeListenTCP.
+	PassFd bool
+	// MaxConcurrent limits the number of jails running at once. Further connections wait in the listen backlog.
+	// Zero means no limit.
+	MaxConcurrent int
+}
+
+// Serve accepts connections on ln until it fails or ctx is done, then closes ln and waits for running jails,
+// which are killed with ctx. Connections must be backed by a descriptor, like *net.TCPConn or *net.UnixConn.
+// It returns ctx's error, or the error of Accept.
+func (b *ConnBridge) Serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	var sem chan struct{}
+	if b.MaxConcurrent > 0 {
+		sem = make(chan struct{}, b.MaxConcurrent)
+	}
+	for {
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sem != nil {
+				defer func() { <-sem }()
+			}
+			defer conn.Close()
+			res, err := b.serveConn(ctx, conn)
+			if b.Done != nil && !errors.Is(err, errConnRejected) {
+				b.Done(conn, res, err)
+			}
+		}()
+	}
+}
+
+var errConnRejected = errors.New("nsjail: connection rejected")
+
+func (b *ConnBridge) serveConn(ctx context.Context, conn net.Conn) (*Result, error) {
+	n := b.Jail.Clone()
+	if n.mode == ModeListenTCP {
+		n.mode = ModeOnce
+	}
+	if b.Accept != nil {
+		if err := b.Accept(conn, n); err != nil {
+			return nil, errConnRejected
+		}
+	}
+	fc, ok := conn.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, errors.New("nsjail: connection has no file descriptor")
+	}
+	f, err := fc.File()
+	if err != nil {
+		return nil, err
+	}
+	if b.PassFd {
+		n.connFile = f
+	} else {
+		n.stdin, n.stdout = f, f
+	}
+	j, err := n.Start(ctx)
+	// nsjail holds its own copy of the socket now.
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return j.Wait()
+}
+
+// ControlConn creates a connected pair of Unix stream sockets, passes one end to the jail with WithExtraFile
+// and returns the other, along with the descriptor number of the jail's end. It gives the host a channel to
+// the sandboxed code, e.g. for requests and results, without a network namespace or a socket in the jail's
+// file system; as a *net.UnixConn it can also pass descriptors. Start closes this process's copy of the
+// jail's end, so reads see EOF once the jail exited: configure a separate NsJail, e.g. with Clone, for each
+// run.
+func (n *NsJail) ControlConn() (*net.UnixConn, int, error) {
+	host, jail, err := socketPair()
+	if err != nil {
+		return nil, 0, err
+	}
+	conn, err := net.FileConn(host)
+	// FileConn holds its own copy of the socket.
+	host.Close()
+	if err != nil {
+		jail.Close()
+		return nil, 0, err
+	}
+	n.closeAfterStart = append(n.closeAfterStart, jail)
+	return conn.(*net.UnixConn), n.WithExtraFile(jail), nil
+}
+
+// passConn hands the connection of a ConnBridge to the jail.
+func (l *launch) passConn(f *os.File) {
+	fd := l.passFile(f)
+	l.flags = append(l.flags, "-E", ConnFdEnv+"="+strconv.Itoa(fd))
+}
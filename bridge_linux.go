@@ -0,0 +1,16 @@
+package nsjail
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// socketPair returns the two ends of a connected pair of Unix stream sockets.
+func socketPair() (*os.File, *os.File, error) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("socketpair", err)
+	}
+	return os.NewFile(uintptr(fds[0]), "nsjail-control"), os.NewFile(uintptr(fds[1]), "nsjail-control"), nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package nsjail
+
+import (
+	"errors"
+	"os"
+)
+
+func socketPair() (*os.File, *os.File, error) {
+	return nil, nil, errors.New("nsjail: control sockets require linux")
+}
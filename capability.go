@@ -0,0 +1,77 @@
+package nsjail
+
+import "fmt"
+
+// Capability is a Linux capability, as retained in the jail with AddCap.
+type Capability string
+
+// Linux capabilities, see capabilities(7).
+const (
+	CapChown             Capability = "CAP_CHOWN"
+	CapDacOverride       Capability = "CAP_DAC_OVERRIDE"
+	CapDacReadSearch     Capability = "CAP_DAC_READ_SEARCH"
+	CapFowner            Capability = "CAP_FOWNER"
+	CapFsetid            Capability = "CAP_FSETID"
+	CapKill              Capability = "CAP_KILL"
+	CapSetgid            Capability = "CAP_SETGID"
+	CapSetuid            Capability = "CAP_SETUID"
+	CapSetpcap           Capability = "CAP_SETPCAP"
+	CapLinuxImmutable    Capability = "CAP_LINUX_IMMUTABLE"
+	CapNetBindService    Capability = "CAP_NET_BIND_SERVICE"
+	CapNetBroadcast      Capability = "CAP_NET_BROADCAST"
+	CapNetAdmin          Capability = "CAP_NET_ADMIN"
+	CapNetRaw            Capability = "CAP_NET_RAW"
+	CapIpcLock           Capability = "CAP_IPC_LOCK"
+	CapIpcOwner          Capability = "CAP_IPC_OWNER"
+	CapSysModule         Capability = "CAP_SYS_MODULE"
+	CapSysRawio          Capability = "CAP_SYS_RAWIO"
+	CapSysChroot         Capability = "CAP_SYS_CHROOT"
+	CapSysPtrace         Capability = "CAP_SYS_PTRACE"
+	CapSysPacct          Capability = "CAP_SYS_PACCT"
+	CapSysAdmin          Capability = "CAP_SYS_ADMIN"
+	CapSysBoot           Capability = "CAP_SYS_BOOT"
+	CapSysNice           Capability = "CAP_SYS_NICE"
+	CapSysResource       Capability = "CAP_SYS_RESOURCE"
+	CapSysTime           Capability = "CAP_SYS_TIME"
+	CapSysTtyConfig      Capability = "CAP_SYS_TTY_CONFIG"
+	CapMknod             Capability = "CAP_MKNOD"
+	CapLease             Capability = "CAP_LEASE"
+	CapAuditWrite        Capability = "CAP_AUDIT_WRITE"
+	CapAuditControl      Capability = "CAP_AUDIT_CONTROL"
+	CapSetfcap           Capability = "CAP_SETFCAP"
+	CapMacOverride       Capability = "CAP_MAC_OVERRIDE"
+	CapMacAdmin          Capability = "CAP_MAC_ADMIN"
+	CapSyslog            Capability = "CAP_SYSLOG"
+	CapWakeAlarm         Capability = "CAP_WAKE_ALARM"
+	CapBlockSuspend      Capability = "CAP_BLOCK_SUSPEND"
+	CapAuditRead         Capability = "CAP_AUDIT_READ"
+	CapPerfmon           Capability = "CAP_PERFMON"
+	CapBpf               Capability = "CAP_BPF"
+	CapCheckpointRestore Capability = "CAP_CHECKPOINT_RESTORE"
+)
+
+// knownCapabilities holds the capabilities nsjail accepts.
+var knownCapabilities = map[Capability]bool{
+	CapChown: true, CapDacOverride: true, CapDacReadSearch: true, CapFowner: true, CapFsetid: true,
+	CapKill: true, CapSetgid: true, CapSetuid: true, CapSetpcap: true, CapLinuxImmutable: true,
+	CapNetBindService: true, CapNetBroadcast: true, CapNetAdmin: true, CapNetRaw: true, CapIpcLock: true,
+	CapIpcOwner: true, CapSysModule: true, CapSysRawio: true, CapSysChroot: true, CapSysPtrace: true,
+	CapSysPacct: true, CapSysAdmin: true, CapSysBoot: true, CapSysNice: true, CapSysResource: true,
+	CapSysTime: true, CapSysTtyConfig: true, CapMknod: true, CapLease: true, CapAuditWrite: true,
+	CapAuditControl: true, CapSetfcap: true, CapMacOverride: true, CapMacAdmin: true, CapSyslog: true,
+	CapWakeAlarm: true, CapBlockSuspend: true, CapAuditRead: true, CapPerfmon: true, CapBpf: true,
+	CapCheckpointRestore: true,
+}
+
+// Valid reports whether c is a known capability. nsjail only accepts the exact names of the constants.
+func (c Capability) Valid() bool { return knownCapabilities[c] }
+
+// validateCaps checks the capabilities retained with AddCap, including those of a loaded configuration.
+func (n *NsJail) validateCaps() error {
+	for _, c := range n.caps {
+		if !Capability(c).Valid() {
+			return fmt.Errorf("nsjail: unknown capability %q", c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package nsjail
+
+import (
+	"context"
+	"sync"
+)
+
+// OutputCapture is an io.Writer that keeps at most a fixed number of bytes and silently
+// discards the rest, so a flooding process never blocks on its pipe nor exhausts host memory.
+type OutputCapture struct {
+	mu    sync.Mutex
+	limit int64
+	buf   []byte
+	total int64
+}
+
+// NewOutputCapture returns a capture keeping at most limit bytes. A limit <= 0 keeps nothing.
+func NewOutputCapture(limit int64) *OutputCapture {
+	return &OutputCapture{limit: max(limit, 0)}
+}
+
+// Write stores as much of p as fits and always reports success.
+func (c *OutputCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total += int64(len(p))
+	if room := c.limit - int64(len(c.buf)); room > 0 {
+		c.buf = append(c.buf, p[:min(int64(len(p)), room)]...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns the captured output.
+func (c *OutputCapture) Bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf
+}
+
+// Total returns the number of bytes written, including discarded ones.
+func (c *OutputCapture) Total() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// Truncated reports whether any output was discarded.
+func (c *OutputCapture) Truncated() bool { return c.Total() > c.limit }
+
+// RunCaptured runs the jail like Run, capturing at most maxStdout and maxStderr bytes of its output into
+// Result.Stdout and Result.Stderr. Output beyond the caps is discarded and flagged with StdoutTruncated
+// and StderrTruncated. Streams set with WithStdio are replaced for this run.
+func (n *NsJail) RunCaptured(ctx context.Context, maxStdout, maxStderr int64) (*Result, error) {
+	stdout, stderr := NewOutputCapture(maxStdout), NewOutputCapture(maxStderr)
+	j, err := n.start(ctx, stdout, stderr)
+	if err != nil {
+		return nil, err
+	}
+	res, err := j.Wait()
+	if res != nil {
+		res.Stdout, res.StdoutTruncated = stdout.Bytes(), stdout.Truncated()
+		res.Stderr, res.StderrTruncated = stderr.Bytes(), stderr.Truncated()
+	}
+	return res, err
+}
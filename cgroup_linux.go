@@ -0,0 +1,139 @@
+package nsjail
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// userHz is the unit of cpuacct.stat on cgroup v1.
+const userHz = 100
+
+// readCgroupUsage reads the usage of the cgroups nsjail created for pid. Controllers whose cgroup is not
+// one of nsjail's (NSJAIL.<pid>) are skipped, as they would include unrelated processes.
+func readCgroupUsage(pid int) (*ResourceUsage, error) {
+	f, err := os.Open("/proc/" + strconv.Itoa(pid) + "/cgroup")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	u := &ResourceUsage{Sampled: time.Now()}
+	found := false
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(sc.Text(), ":", 3)
+		if len(parts) != 3 || !strings.HasPrefix(filepath.Base(parts[2]), "NSJAIL.") {
+			continue
+		}
+		found = true
+		if parts[0] == "0" && parts[1] == "" {
+			readCgroupV2(u, filepath.Join(cgroup2Root(), parts[2]))
+			continue
+		}
+		dir := filepath.Join("/sys/fs/cgroup", parts[1], parts[2])
+		for _, c := range strings.Split(parts[1], ",") {
+			readCgroupV1(u, c, dir)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNoCgroup
+	}
+	return u, nil
+}
+
+// cgroup2Root returns where the cgroup v2 hierarchy is mounted, e.g. /sys/fs/cgroup/unified on hosts
+// that also mount cgroup v1 controllers.
+func cgroup2Root() string {
+	data, _ := os.ReadFile("/proc/self/mountinfo")
+	for _, line := range strings.Split(string(data), "\n") {
+		// id parent major:minor root mount-point options [optional fields] - fstype source super-options
+		fields := strings.Fields(line)
+		if i := slices.Index(fields, "-"); i > 4 && i+1 < len(fields) && fields[i+1] == "cgroup2" {
+			return fields[4]
+		}
+	}
+	return defaultCgroupV2Mount
+}
+
+func readCgroupV2(u *ResourceUsage, dir string) {
+	u.MemoryCurrent, _ = readUint(filepath.Join(dir, "memory.current"))
+	u.MemoryPeak, _ = readUint(filepath.Join(dir, "memory.peak"))
+	u.MemoryPeak = max(u.MemoryPeak, u.MemoryCurrent)
+	u.PidsCurrent, _ = readUint(filepath.Join(dir, "pids.current"))
+	u.PidsPeak, _ = readUint(filepath.Join(dir, "pids.peak"))
+	u.PidsPeak = max(u.PidsPeak, u.PidsCurrent)
+	cpu := readKeyed(filepath.Join(dir, "cpu.stat"))
+	u.CPU = time.Duration(cpu["usage_usec"]) * time.Microsecond
+	u.CPUUser = time.Duration(cpu["user_usec"]) * time.Microsecond
+	u.CPUSystem = time.Duration(cpu["system_usec"]) * time.Microsecond
+	u.OOMKills = readKeyed(filepath.Join(dir, "memory.events"))["oom_kill"]
+}
+
+func readCgroupV1(u *ResourceUsage, controller, dir string) {
+	switch controller {
+	case "memory":
+		u.MemoryCurrent, _ = readUint(filepath.Join(dir, "memory.usage_in_bytes"))
+		u.MemoryPeak, _ = readUint(filepath.Join(dir, "memory.max_usage_in_bytes"))
+	case "pids":
+		u.PidsCurrent, _ = readUint(filepath.Join(dir, "pids.current"))
+		u.PidsPeak = u.PidsCurrent
+	case "cpuacct":
+		ns, _ := readUint(filepath.Join(dir, "cpuacct.usage"))
+		u.CPU = time.Duration(ns)
+		stat := readKeyed(filepath.Join(dir, "cpuacct.stat"))
+		u.CPUUser = time.Duration(stat["user"]) * time.Second / userHz
+		u.CPUSystem = time.Duration(stat["system"]) * time.Second / userHz
+	}
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(bytes.TrimSpace(data)), 10, 64)
+}
+
+// readKeyed parses a flat keyed file such as cpu.stat ("key value" per line).
+func readKeyed(path string) map[string]uint64 {
+	data, _ := os.ReadFile(path)
+	m := make(map[string]uint64)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if fields := bytes.Fields(line); len(fields) == 2 {
+			if v, err := strconv.ParseUint(string(fields[1]), 10, 64); err == nil {
+				m[string(fields[0])] = v
+			}
+		}
+	}
+	return m
+}
+
+// blockDevice returns the "major:minor" number of a block device given as a path or already as a number.
+func blockDevice(dev string) (string, error) {
+	if strings.Contains(dev, ":") {
+		return dev, nil
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(dev, &st); err != nil {
+		return "", &os.PathError{Op: "stat", Path: dev, Err: err}
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFBLK {
+		return "", fmt.Errorf("%s is not a block device", dev)
+	}
+	rdev := uint64(st.Rdev)
+	major := (rdev>>8)&0xfff | (rdev>>32)&^0xfff
+	minor := rdev&0xff | (rdev>>12)&^0xff
+	return fmt.Sprintf("%d:%d", major, minor), nil
+}
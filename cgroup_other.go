@@ -0,0 +1,13 @@
+//go:build !linux
+
+package nsjail
+
+import "errors"
+
+func readCgroupUsage(pid int) (*ResourceUsage, error) { return nil, ErrNoCgroup }
+
+func cgroup2Root() string { return defaultCgroupV2Mount }
+
+func blockDevice(dev string) (string, error) {
+	return "", errors.New("nsjail: cgroups are only supported on Linux")
+}
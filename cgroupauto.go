@@ -0,0 +1,80 @@
+package nsjail
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// WithMemoryLimit limits the memory of the jail to bytes with a cgroup (--cgroup_mem_max). Like
+// WithPidsLimit and WithCpuPercent, it picks the cgroup hierarchy of the host when the jail is built: cgroup
+// v2 (--use_cgroupv2) where the host runs it with the controllers the limits need, v1 otherwise. Explicit
+// UseCgroupV2, DetectAndUseCgroupV2 and WithCgroupV2* limits take precedence over the detection.
+func (n *NsJail) WithMemoryLimit(bytes uint64) *NsJail {
+	n.cgroupMemMax, n.cgroupAuto = bytes, true
+	return n.changed()
+}
+
+// WithPidsLimit limits the number of processes and threads of the jail with a cgroup (--cgroup_pids_max),
+// see WithMemoryLimit.
+func (n *NsJail) WithPidsLimit(max uint) *NsJail {
+	n.cgroupPidsMax, n.cgroupAuto = max, true
+	return n.changed()
+}
+
+// WithCpuPercent limits the CPU time of the jail to percent of one CPU with a cgroup, e.g. 50 for half a CPU
+// or 200 for two (--cgroup_cpu_ms_per_sec), see WithMemoryLimit.
+func (n *NsJail) WithCpuPercent(percent uint) *NsJail {
+	if percent == 0 {
+		n.fail("WithCpuPercent", "percent must be positive")
+		return n.changed()
+	}
+	n.cgroupCpuMsPerSec, n.cgroupAuto = percent*10, true
+	return n.changed()
+}
+
+// autoCgroupV2 reports whether the limits of WithMemoryLimit, WithPidsLimit and WithCpuPercent are set on
+// cgroup v2, and where its hierarchy is mounted.
+func (n *NsJail) autoCgroupV2() (string, bool) {
+	if !n.cgroupAuto || n.useCgroupv2 || n.detectCgroupv2 || n.cgroupV2 != nil {
+		return "", false
+	}
+	mount := n.cgroupv2Mount
+	if mount == "" {
+		mount = cgroup2Root()
+	}
+	data, err := os.ReadFile(filepath.Join(mount, "cgroup.controllers"))
+	if err != nil {
+		return "", false
+	}
+	// Hosts mounting both hierarchies keep the controllers on v1.
+	controllers := strings.Fields(string(data))
+	for _, c := range []struct {
+		used bool
+		name string
+	}{
+		{n.cgroupMemMax > 0 || n.cgroupMemMemswMax > 0 || n.cgroupMemSwapMax != "", "memory"},
+		{n.cgroupPidsMax > 0, "pids"},
+		{n.cgroupCpuMsPerSec > 0, "cpu"},
+	} {
+		if c.used && !slices.Contains(controllers, c.name) {
+			return "", false
+		}
+	}
+	return mount, true
+}
+
+// resolveCgroupAuto returns a copy of n using cgroup v2 if the host runs it, see WithMemoryLimit.
+func (n *NsJail) resolveCgroupAuto() *NsJail {
+	mount, ok := n.autoCgroupV2()
+	if !ok {
+		return n
+	}
+	r := n.Clone()
+	r.useCgroupv2 = true
+	if mount != defaultCgroupV2Mount {
+		r.cgroupv2Mount = mount
+	}
+	return r
+}
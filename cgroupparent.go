@@ -0,0 +1,91 @@
+package nsjail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WithCgroupAutoParent makes Start and Run create a cgroup of the jail's own, named NSJAIL-<random hex>,
+// below each configured cgroup parent before launch, let nsjail create its cgroups inside it and remove it
+// once the jail exited. With a parent delegated to the current user this replaces creating a directory
+// for each jail by hand. Missing cgroup v1 parents, "NSJAIL" by default, are created and left in place.
+// It only has an effect when a cgroup limit is set.
+func (n *NsJail) WithCgroupAutoParent() *NsJail { n.cgroupAutoParent = true; return n.changed() }
+
+// cgroupName returns a unique name for a cgroup created by the wrapper, after the identifier of the run if
+// it has one.
+func (n *NsJail) cgroupName() string {
+	if n.runID != "" {
+		return "NSJAIL-" + n.runID
+	}
+	return uniqueName("NSJAIL-", 39)
+}
+
+// usesCgroupV2 reports whether nsjail sets the limits of the jail on cgroup v2.
+func (n *NsJail) usesCgroupV2() bool {
+	if n.useCgroupv2 || n.cgroupV2 != nil {
+		return true
+	}
+	if !n.detectCgroupv2 {
+		return false
+	}
+	mount := n.cgroupv2Mount
+	if mount == "" {
+		mount = defaultCgroupV2Mount
+	}
+	_, err := os.Stat(filepath.Join(mount, "cgroup.controllers"))
+	return err == nil
+}
+
+// createCgroups creates the cgroups for WithCgroupAutoParent and for the cgroup v2 limits nsjail cannot
+// set, and returns a copy of n using them along with a function removing them.
+func (n *NsJail) createCgroups() (*NsJail, func(), error) {
+	if n.cgroupAuto {
+		n = n.resolveCgroupAuto()
+	}
+	if n.usesCgroupV2() {
+		return n.createCgroupV2()
+	}
+	r := n.Clone()
+	var dirs []string
+	remove := func() {
+		for _, dir := range dirs {
+			removeCgroup(dir)
+		}
+	}
+	name := n.cgroupName()
+	for _, c := range []struct {
+		used          bool
+		mount, parent *string
+		defaultMount  string
+	}{
+		{r.cgroupMemMax > 0 || r.cgroupMemMemswMax > 0 || r.cgroupMemSwapMax != "", &r.cgroupMemMount, &r.cgroupMemParent, "/sys/fs/cgroup/memory"},
+		{r.cgroupPidsMax > 0, &r.cgroupPidsMount, &r.cgroupPidsParent, "/sys/fs/cgroup/pids"},
+		{r.cgroupNetClsClassid > 0, &r.cgroupNetClsMount, &r.cgroupNetClsParent, "/sys/fs/cgroup/net_cls"},
+		{r.cgroupCpuMsPerSec > 0, &r.cgroupCpuMount, &r.cgroupCpuParent, "/sys/fs/cgroup/cpu"},
+	} {
+		if !c.used {
+			continue
+		}
+		mount, parent := *c.mount, *c.parent
+		if mount == "" {
+			mount = c.defaultMount
+		}
+		if parent == "" {
+			parent = "NSJAIL"
+		}
+		if err := os.MkdirAll(filepath.Join(mount, parent), 0o755); err != nil {
+			remove()
+			return nil, nil, fmt.Errorf("nsjail: creating cgroup parent: %w", err)
+		}
+		dir := filepath.Join(mount, parent, name)
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			remove()
+			return nil, nil, fmt.Errorf("nsjail: creating cgroup: %w", err)
+		}
+		dirs = append(dirs, dir)
+		*c.parent = filepath.Join(parent, name)
+	}
+	return r, remove, nil
+}
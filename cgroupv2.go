@@ -0,0 +1,277 @@
+package nsjail
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCgroupV2Mount is where nsjail creates its cgroups unless WithCgroupV2Mount sets another directory.
+const defaultCgroupV2Mount = "/sys/fs/cgroup"
+
+// pidsMaxLimit is the highest pid count the kernel supports, which never limits a jail.
+const pidsMaxLimit = 4194304
+
+// IoMax limits the I/O of the jail on one block device (io.max). Zero fields are unlimited.
+type IoMax struct {
+	// Device is the block device, as a path like /dev/sda or as "major:minor".
+	Device string `json:"device" yaml:"device"`
+	// ReadBps and WriteBps are in bytes per second.
+	ReadBps  uint64 `json:"rbps,omitempty" yaml:"rbps,omitempty"`
+	WriteBps uint64 `json:"wbps,omitempty" yaml:"wbps,omitempty"`
+	// ReadIops and WriteIops are in operations per second.
+	ReadIops  uint64 `json:"riops,omitempty" yaml:"riops,omitempty"`
+	WriteIops uint64 `json:"wiops,omitempty" yaml:"wiops,omitempty"`
+}
+
+// cgroupV2 holds the limits set with the WithCgroupV2* methods.
+type cgroupV2 struct {
+	memoryMax     uint64
+	memorySwapMax *uint64
+	cpuQuota      time.Duration
+	cpuPeriod     time.Duration
+	pidsMax       uint
+	io            []IoMax
+	// cpus and mems are the cpuset of WithCpuSet, moved here when it is set on cgroup v2.
+	cpus, mems []int
+	// dir is the cgroup created by Start and Run for the limits nsjail cannot set itself.
+	dir string
+}
+
+func (n *NsJail) cgroupV2Limits() *cgroupV2 {
+	if n.cgroupV2 == nil {
+		n.cgroupV2 = &cgroupV2{}
+	}
+	return n.cgroupV2
+}
+
+// WithCgroupV2MemoryMax limits the memory of the jail to bytes (memory.max). Like all WithCgroupV2* limits it
+// enables cgroup v2 (--use_cgroupv2) unless DetectAndUseCgroupV2 is set, and takes precedence over the
+// equivalent nsjail flag, here --cgroup_mem_max.
+func (n *NsJail) WithCgroupV2MemoryMax(bytes uint64) *NsJail {
+	n.cgroupV2Limits().memoryMax = bytes
+	return n.changed()
+}
+
+// WithCgroupV2MemorySwapMax limits the swap of the jail to bytes (memory.swap.max). Zero disables swap.
+func (n *NsJail) WithCgroupV2MemorySwapMax(bytes uint64) *NsJail {
+	n.cgroupV2Limits().memorySwapMax = &bytes
+	return n.changed()
+}
+
+// WithCgroupV2CpuMax limits the jail to quota of CPU time per period (cpu.max), e.g. 500ms per 1s for half a
+// CPU. A period of one second is set with --cgroup_cpu_ms_per_sec; other periods are written by the wrapper
+// into a cgroup it creates, see AddCgroupV2IoMax.
+func (n *NsJail) WithCgroupV2CpuMax(quota, period time.Duration) *NsJail {
+	if quota < 0 || period < 0 {
+		n.fail("WithCgroupV2CpuMax", "negative quota or period")
+		return n.changed()
+	}
+	c := n.cgroupV2Limits()
+	c.cpuQuota, c.cpuPeriod = quota, period
+	return n.changed()
+}
+
+// WithCgroupV2PidsMax limits the number of tasks in the jail (pids.max).
+func (n *NsJail) WithCgroupV2PidsMax(max uint) *NsJail {
+	n.cgroupV2Limits().pidsMax = max
+	return n.changed()
+}
+
+// AddCgroupV2IoMax limits the I/O of the jail on a block device (io.max). nsjail has no flag for it, so Start
+// and Run create a cgroup for the jail below the cgroup v2 directory (see WithCgroupV2Mount), write the
+// limit into it, point nsjail at it and remove it once the jail exited. The directory must be writable
+// and its controllers delegated. At least one of the limits must be set. Can be called multiple times.
+func (n *NsJail) AddCgroupV2IoMax(limit IoMax) *NsJail {
+	if limit.Device == "" {
+		n.fail("AddCgroupV2IoMax", "empty device")
+		return n.changed()
+	}
+	if limit == (IoMax{Device: limit.Device}) {
+		n.fail("AddCgroupV2IoMax", "no limit set for %s", limit.Device)
+		return n.changed()
+	}
+	c := n.cgroupV2Limits()
+	c.io = append(c.io, limit)
+	return n.changed()
+}
+
+// cpuMsPerSec returns the cpu.max limit as --cgroup_cpu_ms_per_sec, if it can be expressed that way.
+func (c *cgroupV2) cpuMsPerSec() (uint, bool) {
+	if c.cpuQuota <= 0 || c.cpuPeriod != time.Second || c.cpuQuota%time.Millisecond != 0 {
+		return 0, false
+	}
+	return uint(c.cpuQuota / time.Millisecond), true
+}
+
+// needsCgroup reports whether the limits need a cgroup created by the wrapper.
+func (c *cgroupV2) needsCgroup() bool {
+	_, ok := c.cpuMsPerSec()
+	return len(c.io) > 0 || len(c.cpus) > 0 || len(c.mems) > 0 || (c.cpuQuota > 0 && !ok)
+}
+
+// resolveCgroupV2 returns a copy of n with the WithCgroupV2* limits turned into nsjail flags.
+func (n *NsJail) resolveCgroupV2() (*NsJail, error) {
+	c := n.cgroupV2
+	if c.needsCgroup() && c.dir == "" {
+		return nil, errors.New("nsjail: io.max, cpusets and cpu.max periods other than 1s are only applied by Start and Run")
+	}
+	r := n.Clone()
+	if !r.detectCgroupv2 {
+		r.useCgroupv2 = true
+	}
+	if c.memoryMax > 0 {
+		r.cgroupMemMax = c.memoryMax
+	}
+	if c.memorySwapMax != nil {
+		r.cgroupMemSwapMax = strconv.FormatUint(*c.memorySwapMax, 10)
+	}
+	if c.pidsMax > 0 {
+		r.cgroupPidsMax = c.pidsMax
+	}
+	if ms, ok := c.cpuMsPerSec(); ok {
+		r.cgroupCpuMsPerSec = ms
+	}
+	if c.dir != "" {
+		if r.cgroupMemMax == 0 && r.cgroupMemMemswMax == 0 && r.cgroupPidsMax == 0 && r.cgroupCpuMsPerSec == 0 {
+			// nsjail only moves the jailed process into a cgroup below ours when it sets a limit itself.
+			r.cgroupPidsMax = pidsMaxLimit
+		}
+	}
+	return r, nil
+}
+
+// createCgroupV2 creates a cgroup for the jail below the cgroup v2 directory, with the limits nsjail
+// cannot set written into it, and returns a copy of n creating its cgroups there, along with a function
+// removing it.
+func (n *NsJail) createCgroupV2() (*NsJail, func(), error) {
+	parent := n.cgroupv2Mount
+	if parent == "" {
+		parent = defaultCgroupV2Mount
+	}
+	c := n.cgroupV2
+	if c == nil {
+		c = &cgroupV2{}
+	}
+	files := make(map[string]string)
+	if c.cpuQuota > 0 {
+		period := max(c.cpuPeriod, time.Millisecond)
+		files["cpu.max"] = fmt.Sprintf("%d %d", c.cpuQuota.Microseconds(), period.Microseconds())
+	}
+	if len(c.cpus) > 0 {
+		files["cpuset.cpus"] = cpuList(c.cpus)
+	}
+	if len(c.mems) > 0 {
+		files["cpuset.mems"] = cpuList(c.mems)
+	}
+	for _, limit := range c.io {
+		dev, err := blockDevice(limit.Device)
+		if err != nil {
+			return nil, nil, fmt.Errorf("nsjail: io.max device: %w", err)
+		}
+		line := dev
+		for _, kv := range []struct {
+			key string
+			val uint64
+		}{{"rbps", limit.ReadBps}, {"wbps", limit.WriteBps}, {"riops", limit.ReadIops}, {"wiops", limit.WriteIops}} {
+			if kv.val > 0 {
+				line += fmt.Sprintf(" %s=%d", kv.key, kv.val)
+			}
+		}
+		// io.max takes one device per write.
+		files["io.max"] += line + "\n"
+	}
+
+	// The controllers of the files written here, and of the limits nsjail sets in its cgroup below ours.
+	ctrls := make(map[string]bool)
+	for name := range files {
+		ctrl, _, _ := strings.Cut(name, ".")
+		ctrls[ctrl] = true
+	}
+	own := n.nsjailCgroupV2Controllers()
+	for _, ctrl := range own {
+		ctrls[ctrl] = true
+	}
+	// Controllers must be enabled in the parent for the files to exist.
+	for _, ctrl := range sortedKeys(ctrls) {
+		if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+"+ctrl), 0); err != nil {
+			return nil, nil, fmt.Errorf("nsjail: enabling the %s controller in %s (is it delegated?): %w", ctrl,
+				parent, err)
+		}
+	}
+	dir := filepath.Join(parent, n.cgroupName())
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("nsjail: creating cgroup: %w", err)
+	}
+	remove := func() { removeCgroup(dir) }
+	for _, name := range sortedKeys(files) {
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(files[name], "\n"), "\n") {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(line), 0); err != nil {
+				remove()
+				return nil, nil, fmt.Errorf("nsjail: setting %s in %s: %w", name, dir, err)
+			}
+		}
+	}
+	// Hand the controllers of nsjail's limits on to the cgroup it creates below ours.
+	for _, ctrl := range own {
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+"+ctrl), 0); err != nil {
+			remove()
+			return nil, nil, fmt.Errorf("nsjail: enabling the %s controller in %s: %w", ctrl, dir, err)
+		}
+	}
+	r := n.Clone()
+	r.cgroupv2Mount = dir
+	if r.cgroupV2 != nil {
+		r.cgroupV2.dir = dir
+	}
+	return r, remove, nil
+}
+
+// nsjailCgroupV2Controllers returns the controllers of the limits nsjail sets in the cgroup it creates for
+// the jail, see resolveCgroupV2, which always sets a pids limit if there is no other.
+func (n *NsJail) nsjailCgroupV2Controllers() []string {
+	c := cmp.Or(n.cgroupV2, &cgroupV2{})
+	_, cpu := c.cpuMsPerSec()
+	cpu = cpu || n.cgroupCpuMsPerSec > 0
+	memMax := c.memoryMax > 0 || n.cgroupMemMax > 0 || n.cgroupMemMemswMax > 0
+	memory := memMax || c.memorySwapMax != nil || n.cgroupMemSwapMax != ""
+	pids := c.pidsMax > 0 || n.cgroupPidsMax > 0 || !cpu && !memMax
+	var ctrls []string
+	for _, ctrl := range []struct {
+		name string
+		used bool
+	}{{"cpu", cpu}, {"memory", memory}, {"pids", pids}} {
+		if ctrl.used {
+			ctrls = append(ctrls, ctrl.name)
+		}
+	}
+	return ctrls
+}
+
+// removeCgroup removes the cgroup dir and the cgroups below it. A cgroup can only be removed once its
+// processes are gone, which may take a moment after nsjail exited.
+func removeCgroup(dir string) {
+	for range 50 {
+		var dirs []string
+		filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+			if err == nil && d.IsDir() {
+				dirs = append(dirs, p)
+			}
+			return nil
+		})
+		slices.Reverse(dirs)
+		for _, d := range dirs {
+			os.Remove(d)
+		}
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
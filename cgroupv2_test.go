@@ -0,0 +1,46 @@
+package nsjail
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCgroupV2IoMaxErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit IoMax
+		want  string
+	}{
+		{"no limit", IoMax{Device: "8:0"}, "AddCgroupV2IoMax: no limit set for 8:0"},
+		{"no device", IoMax{ReadBps: 1}, "AddCgroupV2IoMax: empty device"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New("/bin/true").AddCgroupV2IoMax(tt.limit).Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNsjailCgroupV2Controllers(t *testing.T) {
+	tests := []struct {
+		name string
+		jail *NsJail
+		want []string
+	}{
+		{"none", New("/bin/true"), []string{"pids"}},
+		{"memory", New("/bin/true").WithCgroupV2MemoryMax(1 << 20), []string{"memory"}},
+		{"swap only", New("/bin/true").WithCgroupMemSwapMax("0"), []string{"memory", "pids"}},
+		{"cpu and pids", New("/bin/true").WithCgroupCpuMsPerSec(100).WithCgroupPidsMax(10), []string{"cpu", "pids"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.jail.nsjailCgroupV2Controllers(); !slices.Equal(got, tt.want) {
+				t.Errorf("controllers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package nsjail
+
+import "time"
+
+// ClockReading is a timestamp taken from several host clocks at the same moment.
+type ClockReading struct {
+	// Wall is the wall-clock time in UTC, without Go's monotonic reading.
+	Wall time.Time
+	// Monotonic and Boottime are CLOCK_MONOTONIC and CLOCK_BOOTTIME, which are not affected by wall-clock
+	// adjustments. Boottime also counts time the host was suspended. They are zero where unavailable.
+	Monotonic time.Duration
+	Boottime  time.Duration
+}
+
+// ClockSync is the synchronization state of the host clock as reported by adjtimex(2).
+type ClockSync struct {
+	// Known reports whether the state could be read. The other fields are zero otherwise.
+	Known bool
+	// Synchronized reports that the kernel considers the clock synchronized, e.g. by an NTP daemon.
+	Synchronized bool
+	// MaxError and EstError are the maximum and estimated error of the wall clock.
+	MaxError time.Duration
+	EstError time.Duration
+}
+
+// ClockProvenance records how the timing of a run was measured, so disputes about time limits can be
+// settled after the fact: durations in a Result are measured on the monotonic clock, which wall-clock
+// steps do not affect.
+type ClockProvenance struct {
+	Start ClockReading
+	End   ClockReading
+	// Timezone and UTCOffset describe the host's local time zone at the start.
+	Timezone  string
+	UTCOffset time.Duration
+	// ClockSource is the kernel clocksource, e.g. "tsc" or "kvm-clock", if known.
+	ClockSource string
+	Sync        ClockSync
+}
+
+func readClocks() ClockReading {
+	r := ClockReading{Wall: time.Now().UTC().Round(0)}
+	r.Monotonic, r.Boottime = monotonicClocks()
+	return r
+}
+
+// startProvenance records the clocks and host clock state at the start of a run.
+func startProvenance() ClockProvenance {
+	p := ClockProvenance{Start: readClocks(), ClockSource: clockSource(), Sync: clockSync()}
+	name, offset := time.Now().Zone()
+	p.Timezone, p.UTCOffset = name, time.Duration(offset)*time.Second
+	return p
+}
@@ -0,0 +1,54 @@
+package nsjail
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	clockMonotonic = 1
+	clockBoottime  = 7
+
+	// staUnsync is STA_UNSYNC from <sys/timex.h>.
+	staUnsync = 0x40
+	// timeError is TIME_ERROR, returned by adjtimex when the clock is not synchronized.
+	timeError = 5
+)
+
+func monotonicClocks() (mono, boot time.Duration) {
+	return clockGettime(clockMonotonic), clockGettime(clockBoottime)
+}
+
+func clockGettime(clock uintptr) time.Duration {
+	var ts syscall.Timespec
+	if _, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clock, uintptr(unsafe.Pointer(&ts)), 0); errno != 0 {
+		return 0
+	}
+	return time.Duration(ts.Nano())
+}
+
+func clockSync() ClockSync {
+	// Modes 0 only reads the state and needs no privileges.
+	var tx syscall.Timex
+	state, err := syscall.Adjtimex(&tx)
+	if err != nil {
+		return ClockSync{}
+	}
+	return ClockSync{
+		Known:        true,
+		Synchronized: state != timeError && tx.Status&staUnsync == 0,
+		MaxError:     time.Duration(tx.Maxerror) * time.Microsecond,
+		EstError:     time.Duration(tx.Esterror) * time.Microsecond,
+	}
+}
+
+func clockSource() string {
+	data, err := os.ReadFile("/sys/devices/system/clocksource/clocksource0/current_clocksource")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package nsjail
+
+import "time"
+
+func monotonicClocks() (mono, boot time.Duration) { return 0, 0 }
+
+func clockSync() ClockSync { return ClockSync{} }
+
+func clockSource() string { return "" }
@@ -0,0 +1,90 @@
+package nsjail
+
+import (
+	"maps"
+	"slices"
+)
+
+// Clone returns a deep copy of the configuration. A base template can be built once and cloned per
+// request; mutating the clone (adding mounts, env vars, changing the command) never affects the original.
+// Streams set with WithStdio, files of WithExtraFile and callbacks are shared, not copied. So is the reader
+// of WithStdinReader, which can only feed one jail: starting another from the template or a clone fails.
+func (n *NsJail) Clone() *NsJail {
+	c := *n
+	c.argv = new(argvCache)
+	c.args = slices.Clone(n.args)
+	c.envVars = slices.Clone(n.envVars)
+	c.envPatterns = slices.Clone(n.envPatterns)
+	c.envDeny = slices.Clone(n.envDeny)
+	c.envFiles = slices.Clone(n.envFiles)
+	c.caps = slices.Clone(n.caps)
+	c.passFds = slices.Clone(n.passFds)
+	c.extraFiles = slices.Clone(n.extraFiles)
+	c.closeAfterStart = slices.Clone(n.closeAfterStart)
+	c.uidMappings = slices.Clone(n.uidMappings)
+	c.gidMappings = slices.Clone(n.gidMappings)
+	c.bindMountsRO = slices.Clone(n.bindMountsRO)
+	c.bindMountsRW = slices.Clone(n.bindMountsRW)
+	c.binaryDeps = slices.Clone(n.binaryDeps)
+	c.tmpfsMounts = slices.Clone(n.tmpfsMounts)
+	c.mounts = slices.Clone(n.mounts)
+	c.symlinks = slices.Clone(n.symlinks)
+	c.ifaceOwn = slices.Clone(n.ifaceOwn)
+	c.portForwards = slices.Clone(n.portForwards)
+	c.hostsEntries = slices.Clone(n.hostsEntries)
+	for i := range c.hostsEntries {
+		c.hostsEntries[i].Names = slices.Clone(c.hostsEntries[i].Names)
+	}
+	c.watches = slices.Clone(n.watches)
+	c.logEvents = slices.Clone(n.logEvents)
+	c.artifactPatterns = slices.Clone(n.artifactPatterns)
+	c.errs = slices.Clone(n.errs)
+	c.secrets = slices.Clone(n.secrets)
+	c.fileLimits = slices.Clone(n.fileLimits)
+	c.cpuSet = slices.Clone(n.cpuSet)
+	c.cpuSetMems = slices.Clone(n.cpuSetMems)
+	if n.cgroupV2 != nil {
+		v2 := *n.cgroupV2
+		v2.io = slices.Clone(v2.io)
+		v2.cpus, v2.mems = slices.Clone(v2.cpus), slices.Clone(v2.mems)
+		c.cgroupV2 = &v2
+	}
+	c.overlay, c.veth, c.slirp = clonePtr(n.overlay), clonePtr(n.veth), clonePtr(n.slirp)
+	c.resolvConf, c.dns, c.httpCapture = clonePtr(n.resolvConf), clonePtr(n.dns), clonePtr(n.httpCapture)
+	c.workspace, c.watchdog = clonePtr(n.workspace), clonePtr(n.watchdog)
+	c.streamBuffering = clonePtr(n.streamBuffering)
+	if r := c.resolvConf; r != nil {
+		r.servers, r.search = slices.Clone(r.servers), slices.Clone(r.search)
+	}
+	if p := clonePtr(n.netPolicy); p != nil {
+		p.AllowCIDRs, p.AllowDomains = slices.Clone(p.AllowCIDRs), slices.Clone(p.AllowDomains)
+		p.Ports = slices.Clone(p.Ports)
+		c.netPolicy = p
+	}
+	if f := clonePtr(n.fileAccess); f != nil {
+		f.Read, f.Write, f.Exec = slices.Clone(f.Read), slices.Clone(f.Write), slices.Clone(f.Exec)
+		f.Scratch, f.Links = slices.Clone(f.Scratch), maps.Clone(f.Links)
+		c.fileAccess = f
+	}
+	if w := clonePtr(n.wireGuard); w != nil {
+		w.Addresses, w.Peers = slices.Clone(w.Addresses), slices.Clone(w.Peers)
+		for i := range w.Peers {
+			w.Peers[i].AllowedIPs = slices.Clone(w.Peers[i].AllowedIPs)
+		}
+		c.wireGuard = w
+	}
+	if b := clonePtr(n.binaryCaps); b != nil {
+		b.Flags, b.Short = maps.Clone(b.Flags), maps.Clone(b.Short)
+		c.binaryCaps = b
+	}
+	return &c
+}
+
+// clonePtr returns a pointer to a copy of *p, or nil if p is nil.
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
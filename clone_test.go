@@ -0,0 +1,111 @@
+package nsjail
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fullJail returns a jail with every slice, map and pointer setting that Clone copies populated.
+func fullJail() *NsJail {
+	n := New("/bin/sh", "-c", "true").
+		AddEnv("A", "1").InheritEnvMatching("LC_*").AddEnvFile("/etc/env").AddCap(CapNetBindService).
+		AddPassFd(3).AddUidMapping("0:1000:1").AddGidMapping("0:1000:1").WithCpuSet([]int{0}).
+		WithCpuSetMems([]int{0}).AddBindMountRO("/lib").AddBindMountRW("/tmp").AddTmpfsMount("/run").
+		AddMount("none", "/dev/shm", "tmpfs", "").AddSymlink("/bin", "/usr/bin").
+		WithOverlay("/lower", "/upper", "/work").ForwardPort(8080, 80).
+		WithVeth(VethConfig{Iface: "eth0"}).WithSlirp4netns(SlirpConfig{Path: "slirp4netns"}).
+		WithNetworkPolicy(NetworkPolicy{AllowCIDRs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			AllowDomains: []string{"example.com"}, Ports: []uint16{443}}).
+		WithResolvConf([]string{"1.1.1.1"}, []string{"example.com"}).
+		WithHostsEntries(HostsEntry{Addr: netip.MustParseAddr("10.0.0.1"), Names: []string{"db"}}).
+		WithDNSInterceptor(DNSConfig{Upstream: "1.1.1.1:53"}).WithHTTPCapture(HTTPCaptureConfig{MaxBody: 1}).
+		WithWireGuard(WireGuardConfig{Addresses: []netip.Prefix{netip.MustParsePrefix("10.1.0.2/32")},
+			Peers: []WireGuardPeer{{AllowedIPs: []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")}}}}).
+		WithWorkspace(WorkspaceOptions{Size: 1 << 20}).WithWatchdog(Watchdog{MaxRSS: 1 << 30}).
+		WithFileAccessPolicy(FileAccessPolicy{Read: []string{"/etc"}, Write: []string{"/tmp"},
+			Exec: []string{"/bin"}, Scratch: []string{"/scratch"}, Links: map[string]string{"/sbin": "bin"}}).
+		WithStreamBuffering(StreamConfig{BufferSize: 1024}).CollectFiles("*.log").
+		WithFileLimits("/tmp", FileLimits{MaxFiles: 10}).AddCgroupV2IoMax(IoMax{Device: "8:0", ReadBps: 1}).
+		WithSecretFd("TOKEN", []byte("secret"))
+	n.envDeny = []string{"SECRET_*"}
+	n.binaryDeps = []string{"/bin/sh"}
+	n.ifaceOwn = []string{"eth1"}
+	n.binaryCaps = &Capabilities{Flags: map[string]bool{"mode": true}, Short: map[string]string{"M": "mode"}}
+	return n
+}
+
+func TestCloneIsDeep(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(c *NsJail)
+	}{
+		{"args", func(c *NsJail) { c.args[0] = "x" }},
+		{"env", func(c *NsJail) { c.envVars[0] = "x" }},
+		{"env patterns", func(c *NsJail) { c.envPatterns[0] = "x" }},
+		{"env deny", func(c *NsJail) { c.envDeny[0] = "x" }},
+		{"env files", func(c *NsJail) { c.envFiles[0] = "x" }},
+		{"caps", func(c *NsJail) { c.caps[0] = "x" }},
+		{"pass fds", func(c *NsJail) { c.passFds[0] = 9 }},
+		{"id mappings", func(c *NsJail) { c.uidMappings[0], c.gidMappings[0] = "x", "x" }},
+		{"cpu set", func(c *NsJail) { c.cpuSet[0], c.cpuSetMems[0] = 9, 9 }},
+		{"bind mounts", func(c *NsJail) { c.bindMountsRO[0], c.bindMountsRW[0] = "x", "x" }},
+		{"binary deps", func(c *NsJail) { c.binaryDeps[0] = "x" }},
+		{"tmpfs mounts", func(c *NsJail) { c.tmpfsMounts[0] = "x" }},
+		{"mounts", func(c *NsJail) { c.mounts[0].Dst = "x" }},
+		{"symlinks", func(c *NsJail) { c.symlinks[0].Dst = "x" }},
+		{"overlay", func(c *NsJail) { c.overlay.lower = "x" }},
+		{"iface own", func(c *NsJail) { c.ifaceOwn[0] = "x" }},
+		{"port forwards", func(c *NsJail) { c.portForwards[0].jailPort = 9 }},
+		{"veth", func(c *NsJail) { c.veth.Iface = "x" }},
+		{"slirp", func(c *NsJail) { c.slirp.Path = "x" }},
+		{"network policy", func(c *NsJail) {
+			c.netPolicy.AllowCIDRs[0], c.netPolicy.AllowDomains[0], c.netPolicy.Ports[0] = netip.Prefix{}, "x", 9
+		}},
+		{"resolv.conf", func(c *NsJail) { c.resolvConf.servers[0], c.resolvConf.search[0] = "x", "x" }},
+		{"hosts entries", func(c *NsJail) { c.hostsEntries[0].Names[0] = "x" }},
+		{"dns", func(c *NsJail) { c.dns.Upstream = "x" }},
+		{"http capture", func(c *NsJail) { c.httpCapture.MaxBody = 9 }},
+		{"wireguard", func(c *NsJail) {
+			c.wireGuard.Addresses[0], c.wireGuard.Peers[0].AllowedIPs[0] = netip.Prefix{}, netip.Prefix{}
+		}},
+		{"workspace", func(c *NsJail) { c.workspace.Path = "x" }},
+		{"watchdog", func(c *NsJail) { c.watchdog.MaxRSS = 9 }},
+		{"file access", func(c *NsJail) { c.fileAccess.Exec[0], c.fileAccess.Write[0] = "x", "x" }},
+		{"stream buffering", func(c *NsJail) { c.streamBuffering.BufferSize = 9 }},
+		{"artifacts", func(c *NsJail) { c.artifactPatterns[0] = "x" }},
+		{"file limits", func(c *NsJail) { c.fileLimits[0].MaxFiles = 9 }},
+		{"cgroup v2", func(c *NsJail) { c.cgroupV2.io[0].ReadBps = 9 }},
+		{"secrets", func(c *NsJail) { c.secrets[0].name = "x" }},
+		{"binary caps", func(c *NsJail) { c.binaryCaps.Flags["mode"], c.binaryCaps.Short["M"] = false, "x" }},
+		{"appending", func(c *NsJail) { c.AppendArgs("x").AddEnv("B", "2").AddBindMountRO("/usr") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig, want := fullJail(), fullJail()
+			tt.mutate(orig.Clone())
+			if !reflect.DeepEqual(orig, want) {
+				t.Errorf("mutating the clone changed the original")
+			}
+		})
+	}
+}
+
+func TestCloneStdinReaderFeedsOnce(t *testing.T) {
+	n := New("/bin/cat").WithStdinReader(strings.NewReader("x"), 1).WithStdinDeadline(time.Second)
+	c := n.Clone()
+	if _, err := n.Exec(); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if _, err := c.Exec(); err == nil {
+		t.Errorf("Exec of a clone sharing the reader succeeded, want an error")
+	}
+	b := New("/bin/cat").WithStdinBytes([]byte("x"))
+	for range 2 {
+		if _, err := b.Clone().Exec(); err != nil {
+			t.Errorf("Exec of a clone fed from bytes: %v", err)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build linux
+
+// Command nsjail-init is the init shim enabled with NsJail.WithInitShim. Build it as a static binary:
+//
+//	CGO_ENABLED=0 go build ./cmd/nsjail-init
+package main
+
+import "github.com/OptimusePrime/nsjail-go/initshim"
+
+func main() { initshim.Main() }
@@ -0,0 +1,148 @@
+// Package codeexec compiles and runs source code in jails, as online judges and code playgrounds do:
+//
+//	r := &codeexec.Runner{}
+//	res, err := r.Execute(ctx, codeexec.C, []byte(src), []byte("input"))
+//	if res.CompileFailed() {
+//		fmt.Print(res.Diagnostics)
+//	}
+//
+// The source is written to a workspace on the host. The compiler runs in one jail with the workspace
+// mounted read-write, the program in another with it mounted read-only, so the program cannot tamper with
+// the compiled binary or the source.
+package codeexec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	nsjail "github.com/OptimusePrime/nsjail-go"
+)
+
+// WorkDir is where the workspace is mounted in both jails, and their working directory.
+const WorkDir = "/workspace"
+
+// SourceName is the base name of the source file in the workspace; the Extension of the language follows.
+const SourceName = "main"
+
+// Language describes how to build and run programs of a language. Command lines run in WorkDir, so they
+// refer to the source as SourceName plus Extension, e.g. "main.c", and to build outputs by relative paths.
+type Language struct {
+	Name string
+	// Extension is the extension of the source file, e.g. ".c".
+	Extension string
+	// Compile is the compiler command line, or nil for interpreted languages.
+	Compile []string
+	// Run is the command line running the program.
+	Run []string
+}
+
+// SourceFile returns the name of the source file in the workspace.
+func (l Language) SourceFile() string { return SourceName + l.Extension }
+
+// Languages with the toolchains at their usual paths. The toolchains must be visible in the jails, which
+// they are with the default templates of Runner.
+var (
+	C = Language{Name: "c", Extension: ".c",
+		Compile: []string{"/usr/bin/gcc", "-O2", "-o", "main", "main.c", "-lm"}, Run: []string{"./main"}}
+	CPP = Language{Name: "c++", Extension: ".cpp",
+		Compile: []string{"/usr/bin/g++", "-O2", "-o", "main", "main.cpp"}, Run: []string{"./main"}}
+	Python3 = Language{Name: "python3", Extension: ".py", Run: []string{"/usr/bin/python3", "main.py"}}
+)
+
+// defaultMaxOutput caps the output captured of each step unless Runner.MaxOutput is set.
+const defaultMaxOutput = 64 << 10
+
+// Runner runs submissions. Its fields must not be changed while Execute runs; it is otherwise safe for
+// concurrent use.
+type Runner struct {
+	// Compile and Run are the templates of the jails of each step, cloned for every submission, which sets
+	// the command, mounts the workspace at WorkDir and changes into it. Nil uses nsjail.NewHardened, with
+	// the host's root mounted read-only. Limits, such as WithDeadline, belong here.
+	Compile *nsjail.NsJail
+	Run     *nsjail.NsJail
+	// MaxOutput caps the captured stdout and stderr of each step in bytes. Defaults to 64 KiB.
+	MaxOutput int64
+	// Dir is the host directory workspaces are created in. Defaults to os.TempDir().
+	Dir string
+}
+
+// Result is the outcome of a submission.
+type Result struct {
+	// Compile is the result of the compile step, or nil for interpreted languages.
+	Compile *nsjail.Result
+	// Diagnostics holds the compiler's output, stderr after stdout.
+	Diagnostics string
+	// Run is the result of running the program, or nil if compiling failed. Its Stdout and Stderr hold the
+	// output of the program.
+	Run *nsjail.Result
+	// Verdict classifies the run, see nsjail.NsJail.Verdict. It is only set with Run.
+	Verdict nsjail.Verdict
+}
+
+// CompileFailed reports whether the compile step failed, so the program did not run. Compile.Status tells
+// the compiler rejecting the source, with StatusExited, from it being killed, e.g. by a time limit.
+func (r *Result) CompileFailed() bool { return r.Compile != nil && r.Run == nil }
+
+// Execute writes source to a new workspace, compiles it if lang has a compile step, and runs it with stdin
+// as its input. A compile step that fails is reported in the result, not as an error; errors are left for
+// jails that cannot be started. The workspace is removed before Execute returns.
+func (r *Runner) Execute(ctx context.Context, lang Language, source, stdin []byte) (*Result, error) {
+	if len(lang.Run) == 0 {
+		return nil, fmt.Errorf("codeexec: language %q has no run command", lang.Name)
+	}
+	dir, err := os.MkdirTemp(r.Dir, "codeexec-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	// The jailed user may be mapped to another host user.
+	if err := os.Chmod(dir, 0o777); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, lang.SourceFile()), source, 0o644); err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	if len(lang.Compile) > 0 {
+		n := r.jail(r.Compile, lang.Compile).AddBindRW(dir, WorkDir)
+		compiled, err := n.RunCaptured(ctx, r.maxOutput(), r.maxOutput())
+		if err != nil {
+			return nil, fmt.Errorf("codeexec: compile: %w", err)
+		}
+		res.Compile = compiled
+		res.Diagnostics = string(compiled.Stdout) + string(compiled.Stderr)
+		if compiled.Status != nsjail.StatusExited || compiled.NormalizedCode != 0 {
+			return res, nil
+		}
+	}
+
+	n := r.jail(r.Run, lang.Run).AddBindRO(dir, WorkDir).WithStdinBytes(stdin)
+	ran, err := n.RunCaptured(ctx, r.maxOutput(), r.maxOutput())
+	if err != nil {
+		return nil, fmt.Errorf("codeexec: run: %w", err)
+	}
+	res.Run = ran
+	res.Verdict = n.Verdict(ran)
+	return res, nil
+}
+
+// jail returns the jail of a step running cmd.
+func (r *Runner) jail(template *nsjail.NsJail, cmd []string) *nsjail.NsJail {
+	var n *nsjail.NsJail
+	if template != nil {
+		n = template.Clone().WithCommand(cmd[0], cmd[1:]...)
+	} else {
+		n = nsjail.NewHardened(cmd[0], cmd[1:]...)
+	}
+	return n.WithCwd(WorkDir)
+}
+
+func (r *Runner) maxOutput() int64 {
+	if r.MaxOutput > 0 {
+		return r.MaxOutput
+	}
+	return defaultMaxOutput
+}
@@ -0,0 +1,130 @@
+package nsjail
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CompatPolicy decides what happens to options the installed nsjail binary does not understand.
+type CompatPolicy uint8
+
+const (
+	// CompatOff passes all options unchecked. This is the default.
+	CompatOff CompatPolicy = iota
+	// CompatStrict fails Exec, Args and Start when an option is not supported.
+	CompatStrict
+	// CompatDowngrade rewrites options to an equivalent the binary supports, e.g. a renamed flag, and
+	// fails when no equivalent exists.
+	CompatDowngrade
+	// CompatDrop rewrites options like CompatDowngrade and silently drops the ones without equivalent.
+	CompatDrop
+)
+
+// CompatIssue is an option the binary does not support as given.
+type CompatIssue struct {
+	// Flag is the option as built from the configuration.
+	Flag string
+	// Replacement is the equivalent option used instead, or "" if there is none.
+	Replacement string
+	// Harmless reports that leaving the option out does not change behaviour.
+	Harmless bool
+}
+
+func (i CompatIssue) String() string {
+	switch {
+	case i.Replacement != "":
+		return fmt.Sprintf("%s: downgraded to %s", i.Flag, i.Replacement)
+	case i.Harmless:
+		return fmt.Sprintf("%s: dropped, default behaviour is equivalent", i.Flag)
+	}
+	return fmt.Sprintf("%s: not supported", i.Flag)
+}
+
+// compatRenames maps current option names to their names in older nsjail releases.
+var compatRenames = map[string]string{
+	"--macvlan_vs_ip": "--iface_vs_ip",
+	"--macvlan_vs_nm": "--iface_vs_nm",
+	"--macvlan_vs_gw": "--iface_vs_gw",
+	"--macvlan_vs_ma": "--iface_vs_ma",
+	"--macvlan_vs_mo": "--iface_vs_mo",
+}
+
+// compatDefaults lists options that only request what older releases did by default, so they can be
+// dropped without changing behaviour.
+var compatDefaults = map[string]bool{
+	// Older releases did not create cgroup namespaces at all.
+	"--disable_clone_newcgroup": true,
+}
+
+// WithCompatibility checks options against the flags supported by the nsjail binary when the jail is built,
+// and handles unsupported ones according to policy. The binary is probed with DetectCapabilities unless
+// capabilities are set with WithCapabilities.
+func (n *NsJail) WithCompatibility(policy CompatPolicy) *NsJail {
+	n.compat = policy
+	return n.changed()
+}
+
+// WithCapabilities sets the capabilities of the nsjail binary used by WithCompatibility, e.g. from a
+// previous DetectCapabilities call on the target host.
+func (n *NsJail) WithCapabilities(c *Capabilities) *NsJail { n.binaryCaps = c; return n.changed() }
+
+// CheckCompatibility lists the options of the configuration that the nsjail binary does not support as
+// given, regardless of the compatibility policy.
+func (n *NsJail) CheckCompatibility() ([]CompatIssue, error) {
+	caps, err := n.capabilities()
+	if err != nil {
+		return nil, err
+	}
+	_, issues := adaptOptions(n.options(), caps)
+	return issues, nil
+}
+
+func (n *NsJail) capabilities() (*Capabilities, error) {
+	if n.binaryCaps != nil {
+		return n.binaryCaps, nil
+	}
+	return DetectCapabilities(n.path)
+}
+
+// applyCompat adapts opts to the nsjail binary according to the compatibility policy.
+func (n *NsJail) applyCompat(opts []option) ([]option, error) {
+	if n.compat == CompatOff {
+		return opts, nil
+	}
+	caps, err := n.capabilities()
+	if err != nil {
+		return nil, fmt.Errorf("nsjail: checking compatibility: %w", err)
+	}
+	adapted, issues := adaptOptions(opts, caps)
+	for _, issue := range issues {
+		unresolved := issue.Replacement == "" && !issue.Harmless
+		if n.compat == CompatStrict || (n.compat == CompatDowngrade && unresolved) {
+			return nil, fmt.Errorf("nsjail: %s does not support %s", caps.Path, issue.Flag)
+		}
+	}
+	return adapted, nil
+}
+
+// adaptOptions returns opts with renamed options rewritten and all other unsupported ones left out,
+// along with the issues found.
+func adaptOptions(opts []option, caps *Capabilities) ([]option, []CompatIssue) {
+	adapted := make([]option, 0, len(opts))
+	var issues []CompatIssue
+	for _, o := range opts {
+		// Short options have been stable since the first releases.
+		if !strings.HasPrefix(o.flag, "--") || caps.Supports(o.flag) {
+			adapted = append(adapted, o)
+			continue
+		}
+		issue := CompatIssue{Flag: o.flag}
+		if old, ok := compatRenames[o.flag]; ok && caps.Supports(old) {
+			issue.Replacement = old
+			o.flag = old
+			adapted = append(adapted, o)
+		} else if compatDefaults[o.flag] {
+			issue.Harmless = true
+		}
+		issues = append(issues, issue)
+	}
+	return adapted, issues
+}
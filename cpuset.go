@@ -0,0 +1,98 @@
+package nsjail
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// WithCpuSet pins the jail to the given CPUs, e.g. to keep benchmarks on dedicated cores. Unlike
+// WithMaxCpus, which lets nsjail pick CPUs at random, the set is fixed. Where the jail's limits are set on
+// cgroup v2, or the host runs it without other cgroup limits configured, Start and Run write the set into
+// cpuset.cpus of a cgroup they create for the jail, like AddCgroupV2IoMax, and the jail cannot leave it.
+// Otherwise, and always for Exec, Args, Build and dry runs, so their command line does not depend on the
+// host, nsjail is run under taskset, or numactl with WithCpuSetMems, whose CPU affinity the jail inherits
+// but may widen again with sched_setaffinity. Calling it without CPUs removes the pinning.
+func (n *NsJail) WithCpuSet(cpus []int) *NsJail {
+	if slices.ContainsFunc(cpus, func(c int) bool { return c < 0 }) {
+		n.fail("WithCpuSet", "negative CPU in %v", cpus)
+		return n.changed()
+	}
+	n.cpuSet = slices.Clone(cpus)
+	return n.changed()
+}
+
+// WithCpuSetMems restricts the memory of the jail to the given NUMA nodes (cpuset.mems), e.g. the node of
+// the CPUs given to WithCpuSet. Where Start and Run set the cpuset on cgroup v2, see WithCpuSet, they write
+// the nodes into cpuset.mems. Otherwise, and always for Exec, Args, Build and dry runs, nsjail is run under
+// numactl --membind, which also binds the CPUs of WithCpuSet in place of taskset; the jail inherits the
+// memory policy but may change it again with set_mempolicy. Calling it without nodes removes the binding.
+func (n *NsJail) WithCpuSetMems(nodes []int) *NsJail {
+	if slices.ContainsFunc(nodes, func(c int) bool { return c < 0 }) {
+		n.fail("WithCpuSetMems", "negative NUMA node in %v", nodes)
+		return n.changed()
+	}
+	n.cpuSetMems = slices.Clone(nodes)
+	return n.changed()
+}
+
+// cpusetOnCgroupV2 reports whether the cpuset of the jail is set on cgroup v2 rather than with taskset.
+func (n *NsJail) cpusetOnCgroupV2() (mount string, ok bool) {
+	if n.usesCgroupV2() {
+		return n.cgroupv2Mount, true
+	}
+	if n.hasCgroupLimits() {
+		// The limits are set on cgroup v1.
+		return "", false
+	}
+	mount = n.cgroupv2Mount
+	if mount == "" {
+		mount = cgroup2Root()
+	}
+	data, err := os.ReadFile(filepath.Join(mount, "cgroup.controllers"))
+	if err != nil || !slices.Contains(strings.Fields(string(data)), "cpuset") {
+		return "", false
+	}
+	return mount, true
+}
+
+// resolveCpuSet returns a copy of n with the cpuset moved to its cgroup v2 limits when it is set there, for
+// Start and Run. A cpuset left on n is applied with taskset or numactl.
+func (n *NsJail) resolveCpuSet() *NsJail {
+	if n.cgroupAuto {
+		n = n.resolveCgroupAuto()
+	}
+	mount, ok := n.cpusetOnCgroupV2()
+	if !ok {
+		return n
+	}
+	r := n.Clone()
+	if mount != "" && mount != defaultCgroupV2Mount {
+		r.cgroupv2Mount = mount
+	}
+	c := r.cgroupV2Limits()
+	c.cpus, c.mems = r.cpuSet, r.cpuSetMems
+	r.cpuSet, r.cpuSetMems = nil, nil
+	return r
+}
+
+// cpuList formats ids as a cpuset list, e.g. "0-3,8".
+func cpuList(ids []int) string {
+	ids = slices.Compact(slices.Sorted(slices.Values(ids)))
+	var parts []string
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		part := strconv.Itoa(ids[i])
+		if j > i {
+			part += "-" + strconv.Itoa(ids[j])
+		}
+		parts = append(parts, part)
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,69 @@
+package nsjail
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCpuList(t *testing.T) {
+	tests := []struct {
+		ids  []int
+		want string
+	}{
+		{[]int{0}, "0"},
+		{[]int{0, 1, 2, 3}, "0-3"},
+		{[]int{8, 0, 2, 1, 3}, "0-3,8"},
+		{[]int{1, 1, 3, 5, 6}, "1,3,5-6"},
+	}
+	for _, tt := range tests {
+		if got := cpuList(tt.ids); got != tt.want {
+			t.Errorf("cpuList(%v) = %q, want %q", tt.ids, got, tt.want)
+		}
+	}
+}
+
+func TestCpuSetWrapper(t *testing.T) {
+	tests := []struct {
+		name string
+		jail *NsJail
+		want []string
+	}{
+		{"none", New("/bin/true"), []string{"nsjail"}},
+		{"cpus", New("/bin/true").WithCpuSet([]int{0, 1}), []string{"taskset", "--cpu-list", "0-1", "nsjail"}},
+		{"mems", New("/bin/true").WithCpuSetMems([]int{0}), []string{"numactl", "--membind=0", "nsjail"}},
+		{"cpus and mems", New("/bin/true").WithCpuSet([]int{2, 3}).WithCpuSetMems([]int{1}),
+			[]string{"numactl", "--membind=1", "--physcpubind=2-3", "nsjail"}},
+		{"removed", New("/bin/true").WithCpuSet([]int{0}).WithCpuSet(nil), []string{"nsjail"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.jail.Args()
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+			if got := args[:len(tt.want)]; !slices.Equal(got, tt.want) {
+				t.Errorf("Args = %q, want a prefix %q", args, tt.want)
+			}
+			if _, err := tt.jail.Exec(); err != nil {
+				t.Errorf("Exec: %v", err)
+			}
+			if err := tt.jail.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		})
+	}
+}
+
+func TestCpuSetMemsDryRun(t *testing.T) {
+	var log bytes.Buffer
+	r, err := New("/bin/true").WithCpuSetMems([]int{0}).DryRun(&log).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !r.DryRun || !strings.Contains(log.String(), "numactl --membind=0") {
+		t.Errorf("dry run logged %q, want the numactl command", log.String())
+	}
+}
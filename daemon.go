@@ -0,0 +1,119 @@
+package nsjail
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultListenPort is the port nsjail listens on in ModeListenTCP unless WithPort is set.
+const defaultListenPort = 31337
+
+// ErrNotDaemon is returned by WaitReady for a jail not started with Daemonize.
+var ErrNotDaemon = errors.New("nsjail: WaitReady requires Daemonize")
+
+// daemonWatch tracks the daemon of a jail started with Daemonize, see WaitReady.
+type daemonWatch struct {
+	// port is the port the daemon listens on in ModeListenTCP, or 0 in the other modes.
+	port uint16
+	// err is why readiness cannot be observed, if it cannot.
+	err error
+	// started is closed once the daemon logged executing the jailed process.
+	started     chan struct{}
+	startedOnce sync.Once
+	// pidFile is written with the pid of the daemon once it is ready, see WithPidFile.
+	pidFile      string
+	pidFileOnce  sync.Once
+	pidFileError error
+}
+
+// watchDaemon prepares WaitReady for a jail started with Daemonize.
+func (j *Jail) watchDaemon(n *NsJail) {
+	w := &daemonWatch{started: make(chan struct{})}
+	j.daemon = w
+	if n.logFile != "" || n.logFd != -1 {
+		w.err = errors.New("nsjail: WaitReady cannot read the nsjail log when WithLogFile or WithLogFd is set")
+		return
+	}
+	if n.mode == ModeListenTCP {
+		w.port = cmp.Or(n.port, defaultListenPort)
+		// Registering a handler makes Start read the log, which tells the daemon's pid.
+		j.onLogLine(func(string) {})
+		return
+	}
+	j.onLogLine(func(line string) {
+		if ParseLogLine(line).Kind == LogProcessStarted {
+			w.startedOnce.Do(func() { close(w.started) })
+		}
+	})
+}
+
+// WaitReady waits until the daemon of a jail started with Daemonize is ready and returns its pid, e.g. to
+// signal it later. nsjail -d forks the daemon and exits, so Wait returns at once and the daemon is not a
+// child of this process. The daemon is ready once it logged that it executes the command, or in
+// ModeListenTCP once a socket listens on its port. WaitReady reads the nsjail log, so it cannot be combined
+// with WithLogFile or WithLogFd. It fails if the daemon exits before it is ready, or when ctx is done.
+func (j *Jail) WaitReady(ctx context.Context) (int, error) {
+	w := j.daemon
+	if w == nil {
+		return 0, ErrNotDaemon
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	t := time.NewTicker(10 * time.Millisecond)
+	defer t.Stop()
+	for {
+		ready := false
+		if w.port != 0 {
+			var err error
+			if ready, err = listeningOn(w.port); err != nil {
+				return 0, err
+			}
+		} else {
+			select {
+			case <-w.started:
+				ready = true
+			default:
+			}
+		}
+		if ready {
+			pid, err := j.daemonPid()
+			if err != nil {
+				return 0, err
+			}
+			if pid != 0 {
+				if w.pidFile != "" {
+					w.pidFileOnce.Do(func() { w.pidFileError = writePidFile(w.pidFile, pid) })
+				}
+				return pid, w.pidFileError
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-j.logDone:
+			return 0, errors.New("nsjail: the daemon exited before it was ready")
+		case <-t.C:
+		}
+	}
+}
+
+// daemonPid returns the pid of the daemon forked by nsjail -d, found as the process holding the write end
+// of the log pipe that is not a child of another one, or 0 if there is none yet.
+func (j *Jail) daemonPid() (int, error) {
+	pids, err := pipeHolders(j.logPipe)
+	if err != nil {
+		return 0, err
+	}
+	pids = slices.DeleteFunc(pids, func(pid int) bool { return pid == j.Pid() })
+	for _, pid := range pids {
+		if ppid, err := parentPid(pid); err == nil && !slices.Contains(pids, ppid) {
+			return pid, nil
+		}
+	}
+	return 0, nil
+}
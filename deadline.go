@@ -0,0 +1,79 @@
+package nsjail
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// WithDeadline limits the jail to wall of wall-clock time (-t) and, unless cpu is 0, each of its processes to
+// cpu of CPU time (--rlimit_cpu), both rounded up to whole seconds. It sets no CPU quota; WithDeadlineQuota
+// adds one. A CPU quota set with WithCpuPercent, WithCgroupCpuMsPerSec or WithCgroupV2CpuMax slows down how
+// fast CPU time accrues, so it is taken into account: the configuration is rejected when a single-threaded program could not use its CPU time
+// before the wall-clock limit, i.e. when wall is below cpu divided by the share of a CPU the jail gets.
+// Leave some slack above that for I/O and start-up, e.g. a wall-clock limit of twice the CPU time.
+func (n *NsJail) WithDeadline(wall, cpu time.Duration) *NsJail {
+	if wall <= 0 || cpu < 0 {
+		n.fail("WithDeadline", "wall-clock limit %v and CPU limit %v must be positive", wall, cpu)
+		return n.changed()
+	}
+	n.timeLimit = uint64(ceilDuration(wall, time.Second))
+	if cpu > 0 {
+		n.rlimitCpu = strconv.FormatInt(ceilDuration(cpu, time.Second), 10)
+	}
+	n.deadline = true
+	return n.changed()
+}
+
+// WithDeadlineQuota sets the limits of WithDeadline together with a cgroup CPU quota of percent of one CPU,
+// like WithCpuPercent. The quota is part of the check: wall must leave room for cpu at percent of a CPU,
+// e.g. WithDeadlineQuota(4*time.Second, time.Second, 25) is the tightest fit for a quarter of a CPU.
+func (n *NsJail) WithDeadlineQuota(wall, cpu time.Duration, percent uint) *NsJail {
+	if percent == 0 {
+		n.fail("WithDeadlineQuota", "percent must be positive")
+		return n.changed()
+	}
+	return n.WithDeadline(wall, cpu).WithCpuPercent(percent)
+}
+
+func ceilDuration(d, unit time.Duration) int64 {
+	return int64((d + unit - 1) / unit)
+}
+
+// cpuShare returns the share of a CPU the CPU quota of the jail allows, which is above 1 for more than one
+// CPU, or 0 if there is no quota.
+func (n *NsJail) cpuShare() float64 {
+	if c := n.cgroupV2; c != nil && c.cpuQuota > 0 {
+		return float64(c.cpuQuota) / float64(max(c.cpuPeriod, time.Millisecond))
+	}
+	if n.cgroupCpuMsPerSec > 0 {
+		return float64(n.cgroupCpuMsPerSec) / 1000
+	}
+	return 0
+}
+
+// validateDeadline checks that the limits set with WithDeadline still fit together after later changes.
+func (n *NsJail) validateDeadline() error {
+	if !n.deadline {
+		return nil
+	}
+	wall := n.timeLimitDuration()
+	secs, err := strconv.ParseUint(n.rlimitCpu, 10, 64)
+	if err != nil || wall == 0 {
+		// The CPU limit was replaced by a special value.
+		return nil
+	}
+	cpu := time.Duration(secs) * time.Second
+	need := cpu
+	if share := n.cpuShare(); share > 0 && share < 1 {
+		need = time.Duration(float64(cpu) / share)
+	}
+	if wall < need {
+		if need == cpu {
+			return fmt.Errorf("nsjail: deadline: the wall-clock limit %v is below the CPU limit %v", wall, cpu)
+		}
+		return fmt.Errorf("nsjail: deadline: the wall-clock limit %v is below the %v the CPU limit %v takes at "+
+			"%.0f%% of a CPU", wall, need, cpu, n.cpuShare()*100)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package nsjail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadline(t *testing.T) {
+	tests := []struct {
+		name    string
+		jail    *NsJail
+		wantErr string
+	}{
+		{"wall and cpu", New("/bin/true").WithDeadline(2*time.Second, time.Second), ""},
+		{"wall only", New("/bin/true").WithDeadline(time.Second, 0), ""},
+		{"cpu above wall", New("/bin/true").WithDeadline(time.Second, 2*time.Second), "below the CPU limit"},
+		{"no wall", New("/bin/true").WithDeadline(0, time.Second), "must be positive"},
+		{"quota fits", New("/bin/true").WithDeadlineQuota(4*time.Second, time.Second, 25), ""},
+		{"quota too tight", New("/bin/true").WithDeadlineQuota(3*time.Second, time.Second, 25), "at 25% of a CPU"},
+		{"quota of several CPUs", New("/bin/true").WithDeadlineQuota(time.Second, time.Second, 200), ""},
+		{"zero quota", New("/bin/true").WithDeadlineQuota(time.Second, time.Second, 0), "percent must be positive"},
+		{"later quota", New("/bin/true").WithDeadline(time.Second, time.Second).WithCpuPercent(50), "at 50% of a CPU"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.jail.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeadlineQuotaLimits(t *testing.T) {
+	n := New("/bin/true").WithDeadlineQuota(8500*time.Millisecond, 1100*time.Millisecond, 25)
+	if n.timeLimit != 9 || n.rlimitCpu != "2" || n.cgroupCpuMsPerSec != 250 {
+		t.Errorf("time limit %d, RLIMIT_CPU %s, cpu ms/s %d, want 9, 2, 250", n.timeLimit, n.rlimitCpu,
+			n.cgroupCpuMsPerSec)
+	}
+}
@@ -0,0 +1,29 @@
+package nsjail
+
+import "sync"
+
+var (
+	defaultsMu sync.RWMutex
+	defaults   []Option
+)
+
+// SetDefaults sets options that New applies to every new configuration, e.g. an organization-wide nsjail
+// path, hardening profile or logger, so they do not have to be threaded through a factory. Builder calls
+// and options applied afterwards override them per instance. Configurations decoded with UnmarshalJSON
+// start from the defaults too, and keys present in the encoding override them.
+//
+// SetDefaults replaces the defaults set before; calling it without options clears them. It is meant to be
+// called during program initialization and is safe for concurrent use. Options must not call New.
+func SetDefaults(opts ...Option) {
+	defaultsMu.Lock()
+	defaults = append([]Option(nil), opts...)
+	defaultsMu.Unlock()
+}
+
+// applyDefaults applies the options set with SetDefaults to n.
+func applyDefaults(n *NsJail) *NsJail {
+	defaultsMu.RLock()
+	opts := defaults
+	defaultsMu.RUnlock()
+	return n.Apply(opts...)
+}
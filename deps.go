@@ -0,0 +1,236 @@
+package nsjail
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultLibDirs are searched for shared libraries after DT_RPATH, DT_RUNPATH and /etc/ld.so.conf.
+var defaultLibDirs = []string{"/lib64", "/usr/lib64", "/lib", "/usr/lib"}
+
+// ldSoCache is mounted along with libraries so the loader in the jail finds those outside its default
+// directories.
+const ldSoCache = "/etc/ld.so.cache"
+
+// AddBinaryWithDeps bind-mounts the binary at path read-only, together with its ELF interpreter and
+// all shared libraries it needs, directly or through other libraries (-R). Dependencies are resolved
+// when the jail is built, like ldd but without running the binary, so dynamically linked programs run
+// in an otherwise empty chroot. Libraries loaded with dlopen are not found; add them with AddBindMountRO.
+func (n *NsJail) AddBinaryWithDeps(path string) *NsJail {
+	n.binaryDeps = append(n.binaryDeps, path)
+	return n.changed()
+}
+
+// BinaryDeps returns the binary at path, its ELF interpreter and the shared libraries it needs, as
+// absolute host paths, including the targets of symlinks among them. Statically linked binaries have no
+// dependencies. The result can be used as RootfsSpec.Files.
+func BinaryDeps(path string) ([]string, error) {
+	r := depResolver{seen: make(map[string]bool)}
+	if err := r.resolve(path); err != nil {
+		return nil, fmt.Errorf("nsjail: resolving dependencies of %s: %w", path, err)
+	}
+	return r.paths, nil
+}
+
+// resolveBinaryDeps returns a copy of n with the files added by AddBinaryWithDeps bind-mounted read-only.
+func (n *NsJail) resolveBinaryDeps() (*NsJail, error) {
+	c := n.Clone()
+	mounted := make(map[string]bool)
+	for _, spec := range c.bindMountsRO {
+		src, dst, _ := strings.Cut(spec, ":")
+		if dst == "" || dst == src {
+			mounted[src] = true
+		}
+	}
+	libs := false
+	for _, bin := range n.binaryDeps {
+		paths, err := BinaryDeps(bin)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range paths {
+			if !mounted[p] {
+				mounted[p] = true
+				c.bindMountsRO = append(c.bindMountsRO, p)
+			}
+			libs = libs || i > 0
+		}
+	}
+	if _, err := os.Stat(ldSoCache); err == nil && libs && !mounted[ldSoCache] {
+		c.bindMountsRO = append(c.bindMountsRO, ldSoCache)
+	}
+	return c, nil
+}
+
+// depResolver walks the dependency graph of ELF binaries.
+type depResolver struct {
+	seen  map[string]bool
+	paths []string
+	dirs  []string // from /etc/ld.so.conf, loaded on first use
+}
+
+func (r *depResolver) resolve(file string) error {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	if r.seen[file] {
+		return nil
+	}
+	r.seen[file] = true
+	r.paths = append(r.paths, file)
+	if err := r.addLinkTargets(file); err != nil {
+		return err
+	}
+
+	f, err := elf.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_INTERP {
+			continue
+		}
+		buf := make([]byte, p.Filesz)
+		if _, err := p.ReadAt(buf, 0); err != nil {
+			return fmt.Errorf("reading interpreter of %s: %w", file, err)
+		}
+		if err := r.resolve(strings.TrimRight(string(buf), "\x00")); err != nil {
+			return err
+		}
+	}
+	needed, err := f.ImportedLibraries()
+	if err != nil {
+		// Not dynamically linked.
+		return nil
+	}
+	search, err := r.searchDirs(f, file)
+	if err != nil {
+		return err
+	}
+	for _, lib := range needed {
+		p, err := findLib(lib, search, f)
+		if err != nil {
+			return fmt.Errorf("%s needed by %s: %w", lib, file, err)
+		}
+		if err := r.resolve(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addLinkTargets adds the files a symlink at file points to, so the tree is complete when files are
+// copied rather than bind-mounted.
+func (r *depResolver) addLinkTargets(file string) error {
+	for i := 0; i < 40; i++ {
+		info, err := os.Lstat(file)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		target, err := os.Readlink(file)
+		if err != nil {
+			return err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(file), target)
+		}
+		if file = filepath.Clean(target); r.seen[file] {
+			return nil
+		}
+		r.seen[file] = true
+		r.paths = append(r.paths, file)
+	}
+	return fmt.Errorf("%s: too many levels of symbolic links", file)
+}
+
+// searchDirs returns the directories searched for the libraries of f, in the order of the dynamic loader.
+func (r *depResolver) searchDirs(f *elf.File, file string) ([]string, error) {
+	expand := func(list []string) []string {
+		var dirs []string
+		for _, s := range list {
+			for _, d := range strings.Split(s, ":") {
+				d = strings.ReplaceAll(d, "${ORIGIN}", "$ORIGIN")
+				dirs = append(dirs, strings.ReplaceAll(d, "$ORIGIN", filepath.Dir(file)))
+			}
+		}
+		return dirs
+	}
+	runpath, _ := f.DynString(elf.DT_RUNPATH)
+	var dirs []string
+	if len(runpath) == 0 {
+		rpath, _ := f.DynString(elf.DT_RPATH)
+		dirs = expand(rpath)
+	}
+	dirs = append(dirs, expand(runpath)...)
+	if r.dirs == nil {
+		r.dirs = []string{}
+		if err := readLdSoConf("/etc/ld.so.conf", &r.dirs, 0); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		r.dirs = append(r.dirs, defaultLibDirs...)
+	}
+	return append(dirs, r.dirs...), nil
+}
+
+// findLib finds the library lib in dirs, skipping files built for another architecture than f.
+func findLib(lib string, dirs []string, f *elf.File) (string, error) {
+	if strings.Contains(lib, "/") {
+		return lib, nil
+	}
+	for _, dir := range dirs {
+		p := filepath.Join(dir, lib)
+		lf, err := elf.Open(p)
+		if err != nil {
+			continue
+		}
+		ok := lf.Class == f.Class && lf.Machine == f.Machine
+		lf.Close()
+		if ok {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("not found in %s", strings.Join(dirs, ":"))
+}
+
+// readLdSoConf appends the directories listed in the ld.so.conf file at name, following include lines.
+func readLdSoConf(name string, dirs *[]string, depth int) error {
+	if depth > 8 {
+		return fmt.Errorf("%s: includes nested too deeply", name)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+		if pattern, ok := strings.CutPrefix(line, "include"); ok && (pattern == "" || pattern[0] == ' ' || pattern[0] == '\t') {
+			pattern = strings.TrimSpace(pattern)
+			if !path.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(name), pattern)
+			}
+			matches, _ := filepath.Glob(pattern)
+			slices.Sort(matches)
+			for _, m := range matches {
+				if err := readLdSoConf(m, dirs, depth+1); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if line != "" && !slices.Contains(*dirs, line) {
+			*dirs = append(*dirs, line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package nsjail
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ConfigChange is an option that differs between two configurations, see Diff.
+type ConfigChange struct {
+	// Key names the option like the JSON encoding of a configuration, with nested keys joined by dots,
+	// e.g. "bindmount_ro", "rlimit.as" or "overlay.upper".
+	Key string
+	// A and B are the values in each configuration, or nil where the option is not set.
+	A, B any
+	// Added and Removed list the elements of a list option that are only in B or only in A respectively.
+	// Both are empty for a list whose elements were only reordered.
+	Added, Removed []string
+}
+
+func (c ConfigChange) String() string {
+	if c.Added != nil || c.Removed != nil {
+		if len(c.Added) == 0 && len(c.Removed) == 0 {
+			return c.Key + ": reordered"
+		}
+		var parts []string
+		for _, e := range c.Removed {
+			parts = append(parts, "-"+e)
+		}
+		for _, e := range c.Added {
+			parts = append(parts, "+"+e)
+		}
+		return fmt.Sprintf("%s: %s", c.Key, strings.Join(parts, " "))
+	}
+	format := func(v any) string {
+		if v == nil {
+			return "(unset)"
+		}
+		return fmt.Sprintf("%v", v)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Key, format(c.A), format(c.B))
+}
+
+// Diff returns the options that differ between a and b, in the order of their JSON encoding, e.g. to review
+// a change to a jail or to find why two environments run it differently. It compares what MarshalJSON
+// encodes, then whether the settings MarshalJSON cannot encode, such as WithDNSInterceptor, are set, with
+// the name of the setting as the key and true as the value where it is. Their values, and plumbing such as
+// streams and callbacks, are not compared.
+func Diff(a, b *NsJail) []ConfigChange {
+	var changes []ConfigChange
+	diffValues(&changes, "", reflect.ValueOf(a.config()).Elem(), reflect.ValueOf(b.config()).Elem())
+	inA, inB := a.opaqueSettings(), b.opaqueSettings()
+	for _, s := range slices.Compact(slices.Sorted(slices.Values(slices.Concat(inA, inB)))) {
+		if x, y := slices.Contains(inA, s), slices.Contains(inB, s); x != y {
+			changes = append(changes, ConfigChange{Key: s, A: setOrNil(x), B: setOrNil(y)})
+		}
+	}
+	return changes
+}
+
+// setOrNil returns true if set, or nil for an unset value of a ConfigChange.
+func setOrNil(set bool) any {
+	if set {
+		return true
+	}
+	return nil
+}
+
+func diffValues(changes *[]ConfigChange, key string, a, b reflect.Value) {
+	join := func(name string) string {
+		if key == "" {
+			return name
+		}
+		return key + "." + name
+	}
+	switch a.Kind() {
+	case reflect.Struct:
+		t := a.Type()
+		for i := range t.NumField() {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			diffValues(changes, join(name), a.Field(i), b.Field(i))
+		}
+		return
+	case reflect.Pointer:
+		if a.Type().Elem().Kind() == reflect.Struct && !(a.IsNil() && b.IsNil()) {
+			diffValues(changes, key, derefOrZero(a), derefOrZero(b))
+			return
+		}
+	case reflect.Map:
+		keys := slices.Concat(a.MapKeys(), b.MapKeys())
+		slices.SortFunc(keys, func(x, y reflect.Value) int { return cmp.Compare(fmt.Sprint(x), fmt.Sprint(y)) })
+		keys = slices.CompactFunc(keys, func(x, y reflect.Value) bool { return x.Equal(y) })
+		for _, k := range keys {
+			diffValues(changes, join(fmt.Sprint(k)), mapIndexOrZero(a, k), mapIndexOrZero(b, k))
+		}
+		return
+	}
+
+	va, vb := configValue(a), configValue(b)
+	if reflect.DeepEqual(va, vb) {
+		return
+	}
+	c := ConfigChange{Key: key, A: va, B: vb}
+	if a.Kind() == reflect.Slice {
+		c.Added, c.Removed = listChanges(b, a), listChanges(a, b)
+		if c.Added == nil && c.Removed == nil {
+			c.Added, c.Removed = []string{}, []string{}
+		}
+	}
+	*changes = append(*changes, c)
+}
+
+// configValue returns the value of an encoded option, or nil if it is not set.
+func configValue(v reflect.Value) any {
+	if v.IsZero() || (v.Kind() == reflect.Slice && v.Len() == 0) {
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+func derefOrZero(v reflect.Value) reflect.Value {
+	if v.IsNil() {
+		return reflect.Zero(v.Type().Elem())
+	}
+	return v.Elem()
+}
+
+func mapIndexOrZero(m, k reflect.Value) reflect.Value {
+	if v := m.MapIndex(k); v.IsValid() {
+		return v
+	}
+	return reflect.Zero(m.Type().Elem())
+}
+
+// listChanges returns the elements of the slice x that are not in y, counting duplicates.
+func listChanges(x, y reflect.Value) []string {
+	count := make(map[string]int)
+	for i := range y.Len() {
+		count[fmt.Sprint(y.Index(i).Interface())]++
+	}
+	var only []string
+	for i := range x.Len() {
+		e := fmt.Sprint(x.Index(i).Interface())
+		if count[e] > 0 {
+			count[e]--
+			continue
+		}
+		only = append(only, e)
+	}
+	return only
+}
@@ -0,0 +1,42 @@
+package nsjail
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	base := func() *NsJail { return New("/bin/sh").AddBindMountRO("/lib").AddBindMountRO("/usr") }
+	tests := []struct {
+		name string
+		a, b *NsJail
+		want []string
+	}{
+		{"identical", base(), base(), nil},
+		{"scalar", base(), base().WithHostname("box"), []string{"hostname: (unset) -> box"}},
+		{"unset", base().WithHostname("box"), base(), []string{"hostname: box -> (unset)"}},
+		{"list", base(), base().AddBindMountRO("/etc"), []string{"bindmount_ro: +/etc"}},
+		{"list removed", base(), New("/bin/sh").AddBindMountRO("/usr"), []string{"bindmount_ro: -/lib"}},
+		{"reordered", base(), New("/bin/sh").AddBindMountRO("/usr").AddBindMountRO("/lib"),
+			[]string{"bindmount_ro: reordered"}},
+		{"map", base().WithRlimitAs("512"), base().WithRlimitAs("1024"), []string{"rlimit.as: 512 -> 1024"}},
+		{"nested", base().WithOverlay("/lower", "/a", "/work"), base().WithOverlay("/lower", "/b", "/work"),
+			[]string{"overlay.upper: /a -> /b"}},
+		{"order of the encoding", base(), base().WithChroot("/srv").WithCommand("/bin/bash"),
+			[]string{"command: /bin/sh -> /bin/bash", "chroot: (unset) -> /srv"}},
+		{"opaque", base(), base().WithDNSInterceptor(DNSConfig{}), []string{"WithDNSInterceptor: (unset) -> true"}},
+		{"opaque values", base().WithDNSInterceptor(DNSConfig{Upstream: "1.1.1.1:53"}),
+			base().WithDNSInterceptor(DNSConfig{Upstream: "8.8.8.8:53"}), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range Diff(tt.a, tt.b) {
+				got = append(got, c.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Diff = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package nsjail
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// DNSQuery is a name resolution performed inside a jail.
+type DNSQuery struct {
+	Time time.Time
+	// Name is the queried name in lower case, without the trailing dot.
+	Name string
+	// Type is the query type, e.g. 1 for A, 28 for AAAA.
+	Type uint16
+	// Blocked reports that the query was answered with REFUSED instead of being forwarded.
+	Blocked bool
+}
+
+// DNSConfig configures the DNS forwarder enabled with WithDNSInterceptor.
+type DNSConfig struct {
+	// Upstream is the host:port queries are forwarded to. Defaults to the first nameserver in the host's
+	// /etc/resolv.conf.
+	Upstream string
+	// Allow decides whether a query is forwarded. A nil Allow forwards everything.
+	Allow func(name string, qtype uint16) bool
+	// OnQuery is called for every query. All queries are also listed in Result.DNSQueries.
+	OnQuery func(DNSQuery)
+}
+
+// WithDNSInterceptor runs a DNS forwarder on 127.0.0.1:53 inside the jail's network namespace and points the
+// jail's /etc/resolv.conf at it. Every query is logged, and filtered with cfg.Allow. Queries are forwarded
+// from the host's network namespace, so a jail without other network access can still resolve allowed names.
+// The forwarder is up shortly after the jail starts. Requires a network namespace (no DisableCloneNewNet).
+func (n *NsJail) WithDNSInterceptor(cfg DNSConfig) *NsJail { n.dns = &cfg; return n.changed() }
+
+// AllowDomains returns a DNSConfig.Allow function permitting the given domains and their subdomains.
+func AllowDomains(domains ...string) func(name string, qtype uint16) bool {
+	return func(name string, _ uint16) bool {
+		for _, d := range domains {
+			d = strings.ToLower(strings.TrimSuffix(d, "."))
+			if name == d || strings.HasSuffix(name, "."+d) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// dnsTimeout bounds each forwarded query.
+const dnsTimeout = 5 * time.Second
+
+// useDNS mounts a resolv.conf pointing at the jail's loopback and starts the forwarder once the jail runs.
+func (j *Jail) useDNS(n *NsJail, l *launch) error {
+	if n.cloneNewNetDisabled {
+		return errors.New("nsjail: DNS interception requires a network namespace")
+	}
+	cfg := *n.dns
+	if cfg.Upstream == "" {
+		ns, err := hostNameserver()
+		if err != nil {
+			return err
+		}
+		cfg.Upstream = net.JoinHostPort(ns, "53")
+	}
+
+	if err := j.mountTempFile(l, "nsjail-resolv-*.conf", "nameserver 127.0.0.1\n", "/etc/resolv.conf"); err != nil {
+		return err
+	}
+
+	j.onStarted(func() error {
+		pid, err := j.jailPid(5 * time.Second)
+		if err != nil {
+			return err
+		}
+		pc, ln, err := listenInNetns(pid, "127.0.0.1:53")
+		if err != nil {
+			return err
+		}
+		j.onClose(func() { pc.Close(); ln.Close() })
+		go j.serveDNSPackets(&cfg, pc)
+		go j.serveDNSStreams(&cfg, ln)
+		return nil
+	})
+	return nil
+}
+
+// hostNameserver returns the first nameserver of the host's /etc/resolv.conf.
+func hostNameserver() (string, error) {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if fields := strings.Fields(sc.Text()); len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1], nil
+		}
+	}
+	return "", errors.New("nsjail: no nameserver in /etc/resolv.conf")
+}
+
+func (j *Jail) serveDNSPackets(cfg *DNSConfig, pc net.PacketConn) {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		msg := append([]byte(nil), buf[:n]...)
+		go func() {
+			if resp := j.resolveDNS(cfg, "udp", msg); resp != nil {
+				pc.WriteTo(resp, addr)
+			}
+		}()
+	}
+}
+
+func (j *Jail) serveDNSStreams(cfg *DNSConfig, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				conn.SetDeadline(time.Now().Add(dnsTimeout))
+				msg, err := readDNSStream(conn)
+				if err != nil {
+					return
+				}
+				resp := j.resolveDNS(cfg, "tcp", msg)
+				if resp == nil || writeDNSStream(conn, resp) != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// resolveDNS logs and filters one query and returns the response to send, or nil to drop it.
+func (j *Jail) resolveDNS(cfg *DNSConfig, network string, msg []byte) []byte {
+	name, qtype, end, ok := parseDNSQuestion(msg)
+	if !ok {
+		return nil
+	}
+	q := DNSQuery{Time: time.Now(), Name: name, Type: qtype}
+	q.Blocked = cfg.Allow != nil && !cfg.Allow(name, qtype)
+	j.mu.Lock()
+	j.dnsQueries = append(j.dnsQueries, q)
+	j.mu.Unlock()
+	if cfg.OnQuery != nil {
+		cfg.OnQuery(q)
+	}
+	if q.Blocked {
+		return dnsReply(msg[:end], dnsRcodeRefused)
+	}
+	resp, err := exchangeDNS(network, cfg.Upstream, msg)
+	if err != nil {
+		return dnsReply(msg[:end], dnsRcodeServFail)
+	}
+	return resp
+}
+
+func exchangeDNS(network, upstream string, msg []byte) ([]byte, error) {
+	conn, err := net.DialTimeout(network, upstream, dnsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dnsTimeout))
+	if network == "tcp" {
+		if err := writeDNSStream(conn, msg); err != nil {
+			return nil, err
+		}
+		return readDNSStream(conn)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func readDNSStream(r io.Reader) ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(size[:]))
+	_, err := io.ReadFull(r, msg)
+	return msg, err
+}
+
+func writeDNSStream(w io.Writer, msg []byte) error {
+	_, err := w.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(msg))), msg...))
+	return err
+}
+
+const (
+	dnsRcodeServFail = 2
+	dnsRcodeRefused  = 5
+)
+
+// parseDNSQuestion returns the first question of a query and the offset just past it.
+func parseDNSQuestion(msg []byte) (name string, qtype uint16, end int, ok bool) {
+	if len(msg) < 12 || msg[2]&0x80 != 0 || binary.BigEndian.Uint16(msg[4:]) == 0 {
+		return "", 0, 0, false
+	}
+	var labels []string
+	i := 12
+	for {
+		if i >= len(msg) {
+			return "", 0, 0, false
+		}
+		size := int(msg[i])
+		i++
+		if size == 0 {
+			break
+		}
+		// Queries never use compression.
+		if size&0xc0 != 0 || i+size > len(msg) {
+			return "", 0, 0, false
+		}
+		labels = append(labels, string(msg[i:i+size]))
+		i += size
+	}
+	if i+4 > len(msg) {
+		return "", 0, 0, false
+	}
+	return strings.ToLower(strings.Join(labels, ".")), binary.BigEndian.Uint16(msg[i:]), i + 4, true
+}
+
+// dnsReply answers the query header and question in query with an empty response carrying rcode.
+func dnsReply(query []byte, rcode byte) []byte {
+	resp := append([]byte(nil), query...)
+	resp[2] = 0x80 | query[2]&0x79 // QR, keeping opcode and RD
+	resp[3] = 0x80 | rcode         // RA
+	binary.BigEndian.PutUint16(resp[4:], 1)
+	clear(resp[6:12])
+	return resp
+}
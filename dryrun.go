@@ -0,0 +1,42 @@
+package nsjail
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DryRun makes Start and Run write the shell-quoted nsjail command line to w instead of executing it.
+// The returned jail finishes immediately with Result.DryRun set.
+func (n *NsJail) DryRun(w io.Writer) *NsJail { n.dryRun = w; return n.changed() }
+
+// finishDryRun logs the command of j and completes it without starting a process.
+func (j *Jail) finishDryRun(w io.Writer) {
+	fmt.Fprintln(w, quoteArgs(append([]string{j.command.Path}, j.command.Args...)))
+	j.close()
+	j.clock.End = readClocks()
+	j.result = &Result{DryRun: true, Clock: j.clock}
+	close(j.done)
+}
+
+// quoteArgs joins args into a command line that a POSIX shell splits back into args.
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_@%+=:,./-", r)
+	}
+	if strings.IndexFunc(s, func(r rune) bool { return !safe(r) }) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
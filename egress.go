@@ -0,0 +1,51 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrEgressLimit is recorded in Result.Aborted when a jail sent more than allowed by WithEgressByteLimit.
+var ErrEgressLimit = errors.New("nsjail: egress byte limit exceeded")
+
+// egressInterval is how often the traffic counters of a jail with an egress limit are read.
+const egressInterval = 100 * time.Millisecond
+
+// WithEgressByteLimit kills the jail once it sent more than limit bytes on its network interfaces, loopback
+// excluded. Counters are polled, so a fast sender may overshoot by what it sends in 100ms.
+// The bytes sent are reported in Result.EgressBytes. Requires a network namespace (no DisableCloneNewNet);
+// traffic relayed by WithDNSInterceptor and WithHTTPCapture crosses loopback and is not counted.
+func (n *NsJail) WithEgressByteLimit(limit uint64) *NsJail { n.egressLimit = limit; return n.changed() }
+
+// enforceEgressLimit polls the interface counters of the jail's network namespace.
+func (j *Jail) enforceEgressLimit(limit uint64) {
+	pid, err := j.jailPid(5 * time.Second)
+	if err != nil {
+		select {
+		case <-j.done:
+		default:
+			j.Abort(err)
+		}
+		return
+	}
+	t := time.NewTicker(egressInterval)
+	defer t.Stop()
+	for {
+		sent, err := egressBytes(pid)
+		if err != nil {
+			// The namespace is gone with the jailed process.
+			return
+		}
+		j.egress.Store(sent)
+		if sent > limit {
+			j.Abort(fmt.Errorf("%w: sent %d bytes, limit %d", ErrEgressLimit, sent, limit))
+			return
+		}
+		select {
+		case <-j.done:
+			return
+		case <-t.C:
+		}
+	}
+}
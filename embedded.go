@@ -0,0 +1,32 @@
+package nsjail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// RunEmbeddedBinary runs the executable data, e.g. a program compiled in memory, in place of the configured
+// command, passing args. The binary never touches a file system: it is written to a memfd, sealed against
+// changes, inherited by nsjail and executed in the jail with execveat (--execute_fd), so it needs neither a
+// mount nor exec permissions in the jail. The jailed process sees it as /proc/self/fd/N, also its argv[0].
+// A dynamically linked binary still needs its interpreter and libraries in the jail. Requires Linux with
+// memfds.
+func (n *NsJail) RunEmbeddedBinary(ctx context.Context, data []byte, args ...string) (*Result, error) {
+	f, err := execMemfd(data)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil, fmt.Errorf("nsjail: embedded binaries require memfds: %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("nsjail: embedded binary: %w", err)
+	}
+	defer f.Close()
+	c := n.Clone()
+	fd := c.WithExtraFile(f)
+	// nsjail opens the memfd through its own descriptor before entering the jail.
+	bin := "/proc/self/fd/" + strconv.Itoa(fd)
+	c.execFile, c.executeFd = bin, true
+	c.execCmd, c.args = bin, args
+	return c.Run(ctx)
+}
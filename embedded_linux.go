@@ -0,0 +1,21 @@
+package nsjail
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const embeddedMemfdName = "nsjail-embedded"
+
+// execMemfd returns a sealed, executable memfd holding the binary data, or an error wrapping
+// errors.ErrUnsupported if the kernel has no memfds.
+func execMemfd(data []byte) (*os.File, error) {
+	// Hosts with vm.memfd_noexec set need MFD_EXEC, which kernels before 6.3 reject.
+	f, err := sealedMemfd(embeddedMemfdName, data, unix.MFD_EXEC)
+	if err != nil && errors.Is(err, unix.EINVAL) {
+		f, err = sealedMemfd(embeddedMemfdName, data, 0)
+	}
+	return f, err
+}
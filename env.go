@@ -0,0 +1,175 @@
+package nsjail
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// InheritEnvMatching passes the host environment variables whose names match any of the path.Match
+// patterns into the jail, e.g. InheritEnvMatching("LC_*", "GO*"), as a selective alternative to KeepEnv.
+// Names are matched against the environment when the command is built and passed with -E NAME, so their
+// values are inherited from nsjail's environment instead of showing up in its argv. Variables set with
+// AddEnv take precedence. Can be called multiple times.
+func (n *NsJail) InheritEnvMatching(patterns ...string) *NsJail {
+	n.envPatterns = append(n.envPatterns, patterns...)
+	return n.changed()
+}
+
+// PassEnv passes only the named host environment variables into the jail, those that are set when the
+// command is built, like InheritEnvMatching with literal names. Can be called multiple times.
+func (n *NsJail) PassEnv(keys ...string) *NsJail {
+	for _, k := range keys {
+		n.envPatterns = append(n.envPatterns, escapeEnvPattern(k))
+	}
+	return n.changed()
+}
+
+// KeepEnvExcept passes all host environment variables into the jail except the named ones, like KeepEnv
+// with a denylist. The variables are enumerated when the command is built and passed with -E NAME, so it
+// cannot be combined with KeepEnv. The denylist also applies to InheritEnvMatching and PassEnv. Can be
+// called multiple times.
+func (n *NsJail) KeepEnvExcept(keys ...string) *NsJail {
+	if !slices.Contains(n.envPatterns, "*") {
+		n.envPatterns = append(n.envPatterns, "*")
+	}
+	n.envDeny = append(n.envDeny, keys...)
+	return n.changed()
+}
+
+// AddEnvFile sets the variables listed in the file at path, read when the command is built. Each line is
+// KEY=VALUE, optionally prefixed with "export "; blank lines and lines starting with # are ignored, and a
+// value in double quotes is unquoted like a Go string, in single quotes taken literally. Like AddEnv the
+// values are passed in nsjail's argv. Variables set with AddEnv take precedence, then those of later files.
+// Can be called multiple times.
+func (n *NsJail) AddEnvFile(path string) *NsJail {
+	n.envFiles = append(n.envFiles, path)
+	return n.changed()
+}
+
+// escapeEnvPattern returns the path.Match pattern matching exactly name.
+func escapeEnvPattern(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// resolveEnvFiles returns a copy of n with the variables of the files added with AddEnvFile.
+func (n *NsJail) resolveEnvFiles() (*NsJail, error) {
+	set := make(map[string]bool, len(n.envVars))
+	for _, kv := range n.envVars {
+		name, _, _ := strings.Cut(kv, "=")
+		set[name] = true
+	}
+	var vars []string
+	// Later files take precedence, so read them first.
+	for _, file := range slices.Backward(n.envFiles) {
+		kvs, err := readEnvFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range kvs {
+			name, _, _ := strings.Cut(kv, "=")
+			if !set[name] {
+				vars = append(vars, kv)
+				set[name] = true
+			}
+		}
+	}
+	c := n.Clone()
+	c.envVars = append(c.envVars, vars...)
+	return c, nil
+}
+
+// readEnvFile parses the KEY=VALUE lines of an env file.
+func readEnvFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("nsjail: env file: %w", err)
+	}
+	defer f.Close()
+	var vars []string
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		name, value, ok := strings.Cut(text, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("nsjail: env file %s:%d: want KEY=VALUE", file, line)
+		}
+		value, err := unquoteEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("nsjail: env file %s:%d: %w", file, line, err)
+		}
+		vars = append(vars, name+"="+value)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("nsjail: env file %s: %w", file, err)
+	}
+	return vars, nil
+}
+
+func unquoteEnvValue(v string) (string, error) {
+	switch {
+	case len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"':
+		return strconv.Unquote(v)
+	case len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'':
+		return v[1 : len(v)-1], nil
+	case strings.HasPrefix(v, "\"") || strings.HasPrefix(v, "'"):
+		return "", errors.New("unterminated quote")
+	}
+	return v, nil
+}
+
+// resolveEnvPatterns returns a copy of n with the host variables matching InheritEnvMatching added.
+func (n *NsJail) resolveEnvPatterns() (*NsJail, error) {
+	for _, p := range n.envPatterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("nsjail: invalid environment pattern %q: %w", p, err)
+		}
+	}
+	if n.keepEnv && len(n.envDeny) > 0 {
+		return nil, errors.New("nsjail: KeepEnvExcept cannot be combined with KeepEnv")
+	}
+	set := make(map[string]bool, len(n.envVars)+len(n.envDeny))
+	for _, kv := range n.envVars {
+		name, _, _ := strings.Cut(kv, "=")
+		set[name] = true
+	}
+	// Denied names are treated as already set.
+	for _, name := range n.envDeny {
+		set[name] = true
+	}
+	var names []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if name == "" || set[name] {
+			continue
+		}
+		for _, p := range n.envPatterns {
+			if ok, _ := path.Match(p, name); ok {
+				names = append(names, name)
+				set[name] = true
+				break
+			}
+		}
+	}
+	slices.Sort(names)
+	c := n.Clone()
+	c.envVars = append(c.envVars, names...)
+	return c, nil
+}
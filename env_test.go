@@ -0,0 +1,63 @@
+package nsjail
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInheritEnvMatching(t *testing.T) {
+	for _, kv := range []string{"NSJT_LC_ALL=C", "NSJT_LC_TIME=C", "NSJT_GOPATH=/go", "NSJT_GO=1", "NSJT_X1=a",
+		"NSJT_XY=b", "NSJT_*=star", "NSJT_SECRET=s"} {
+		name, value, _ := strings.Cut(kv, "=")
+		t.Setenv(name, value)
+	}
+	tests := []struct {
+		name string
+		jail *NsJail
+		want []string
+	}{
+		{"prefix", New("/bin/true").InheritEnvMatching("NSJT_LC_*"), []string{"NSJT_LC_ALL", "NSJT_LC_TIME"}},
+		{"several patterns", New("/bin/true").InheritEnvMatching("NSJT_GO*", "NSJT_LC_T*"),
+			[]string{"NSJT_GO", "NSJT_GOPATH", "NSJT_LC_TIME"}},
+		{"single character", New("/bin/true").InheritEnvMatching("NSJT_X?"), []string{"NSJT_X1", "NSJT_XY"}},
+		{"character class", New("/bin/true").InheritEnvMatching("NSJT_X[0-9]"), []string{"NSJT_X1"}},
+		{"literal", New("/bin/true").InheritEnvMatching("NSJT_GO"), []string{"NSJT_GO"}},
+		{"no match", New("/bin/true").InheritEnvMatching("NSJT_NONE*"), nil},
+		{"PassEnv escapes", New("/bin/true").PassEnv("NSJT_*"), []string{"NSJT_*"}},
+		{"AddEnv takes precedence", New("/bin/true").AddEnv("NSJT_LC_ALL", "en").InheritEnvMatching("NSJT_LC_*"),
+			[]string{"NSJT_LC_ALL=en", "NSJT_LC_TIME"}},
+		{"denylist", New("/bin/true").InheritEnvMatching("NSJT_S*").KeepEnvExcept("NSJT_SECRET"),
+			[]string{"NSJT_*", "NSJT_GO", "NSJT_GOPATH", "NSJT_LC_ALL", "NSJT_LC_TIME", "NSJT_X1", "NSJT_XY"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := tt.jail.resolveEnvPatterns()
+			if err != nil {
+				t.Fatalf("resolveEnvPatterns: %v", err)
+			}
+			got := slices.DeleteFunc(r.envVars, func(kv string) bool { return !strings.HasPrefix(kv, "NSJT_") })
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("env = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInheritEnvMatchingErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		jail *NsJail
+		want string
+	}{
+		{"invalid pattern", New("/bin/true").InheritEnvMatching("NSJT_["), "invalid environment pattern"},
+		{"KeepEnv", New("/bin/true").KeepEnv().KeepEnvExcept("SECRET"), "cannot be combined with KeepEnv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.jail.Args(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Args = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
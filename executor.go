@@ -0,0 +1,196 @@
+package nsjail
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// Command is a fully built nsjail invocation, as handed to an Executor.
+type Command struct {
+	// Path is the nsjail binary, or a host command nsjail is run under such as taskset, and Args its
+	// arguments, not including Path.
+	Path string
+	Args []string
+	// ExtraFiles are inherited by nsjail as descriptors 3, 4, ...
+	ExtraFiles []*os.File
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// DrainTimeout bounds how long output is still copied once nsjail exited, in case processes that
+	// escaped the kill keep its streams open. Zero waits until the streams are closed.
+	DrainTimeout time.Duration
+}
+
+// Exit describes how a Process exited.
+type Exit struct {
+	// Code is the exit code, or -1 if the process was killed by a signal.
+	Code int
+	// Signal is the signal that killed the process, or 0.
+	Signal syscall.Signal
+	// State is the raw process state. Executors that do not run real processes leave it nil.
+	State *os.ProcessState
+	// DrainTimedOut reports that the output streams were still open after Command.DrainTimeout and were
+	// closed, so output written after that point is lost.
+	DrainTimedOut bool
+}
+
+// Process is an nsjail process started by an Executor.
+type Process interface {
+	Pid() int
+	Signal(sig os.Signal) error
+	// Wait waits for the process to exit and its output to be copied. Exiting with a non-zero code is not an error.
+	Wait() (Exit, error)
+}
+
+// Executor starts the commands built by Start and Run. It is the seam for testing code that runs jails
+// without an nsjail binary; package nsjailtest provides a fake.
+type Executor interface {
+	Start(c *Command) (Process, error)
+}
+
+// OSExecutor runs commands as real processes. It is used unless WithExecutor sets another Executor.
+var OSExecutor Executor = osExecutor{}
+
+// WithExecutor sets the Executor used by Start and Run.
+func (n *NsJail) WithExecutor(e Executor) *NsJail { n.executor = e; return n.changed() }
+
+// Build returns the command Start would run, without any runtime features (watchers, init shim, ...).
+func (n *NsJail) Build() (*Command, error) {
+	l, err := n.newLaunch()
+	if err != nil {
+		return nil, err
+	}
+	return l.build(n.path), nil
+}
+
+// cmd converts c into an exec.Cmd.
+func (c *Command) cmd() *exec.Cmd {
+	cmd := exec.Command(c.Path, c.Args...)
+	cmd.ExtraFiles = c.ExtraFiles
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = c.Stdin, c.Stdout, c.Stderr
+	return cmd
+}
+
+type osExecutor struct{}
+
+func (osExecutor) Start(c *Command) (Process, error) {
+	cmd := c.cmd()
+	var drains []*drain
+	if c.DrainTimeout > 0 {
+		// Copy output ourselves rather than through exec, which cannot tell whether a copy was cut short.
+		var err error
+		if drains, err = drainOutput(cmd); err != nil {
+			return nil, err
+		}
+	}
+	err := cmd.Start()
+	for _, d := range drains {
+		d.w.Close()
+	}
+	if err != nil {
+		for _, d := range drains {
+			d.r.Close()
+		}
+		return nil, err
+	}
+	for _, d := range drains {
+		go d.copy()
+	}
+	return &osProcess{cmd: cmd, drains: drains, drainTimeout: c.DrainTimeout}, nil
+}
+
+type osProcess struct {
+	cmd          *exec.Cmd
+	drains       []*drain
+	drainTimeout time.Duration
+}
+
+func (p *osProcess) Pid() int { return p.cmd.Process.Pid }
+
+func (p *osProcess) Signal(sig os.Signal) error { return p.cmd.Process.Signal(sig) }
+
+func (p *osProcess) Wait() (Exit, error) {
+	err := p.cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		err = nil
+	}
+	exit := Exit{Code: p.cmd.ProcessState.ExitCode(), State: p.cmd.ProcessState}
+	if exit.State != nil {
+		if ws, ok := exit.State.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			exit.Signal = ws.Signal()
+		}
+	}
+
+	deadline := time.Now().Add(p.drainTimeout)
+	for _, d := range p.drains {
+		select {
+		case <-d.done:
+		case <-time.After(time.Until(deadline)):
+			exit.DrainTimedOut = true
+		}
+		// Unblocks the copy if it is still waiting for a writer that escaped the kill.
+		d.r.Close()
+		<-d.done
+	}
+	return exit, err
+}
+
+// drain copies one output stream of nsjail through a pipe owned by the wrapper.
+type drain struct {
+	r, w *os.File
+	dst  io.Writer
+	done chan struct{}
+}
+
+func (d *drain) copy() {
+	io.Copy(d.dst, d.r)
+	close(d.done)
+}
+
+// drainOutput replaces the non-file output writers of cmd with pipes. A writer shared by stdout and stderr
+// gets a single pipe, as with exec.
+func drainOutput(cmd *exec.Cmd) ([]*drain, error) {
+	var drains []*drain
+	pipe := func(dst io.Writer) (*os.File, error) {
+		if dst == nil {
+			return nil, nil
+		}
+		if f, ok := dst.(*os.File); ok {
+			return f, nil
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		drains = append(drains, &drain{r: r, w: w, dst: dst, done: make(chan struct{})})
+		return w, nil
+	}
+	stdout, err := pipe(cmd.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	stderr := stdout
+	if cmd.Stderr != cmd.Stdout {
+		if stderr, err = pipe(cmd.Stderr); err != nil {
+			for _, d := range drains {
+				d.r.Close()
+				d.w.Close()
+			}
+			return nil, err
+		}
+	}
+	// Keep nil streams connected to the null device.
+	if cmd.Stdout != nil {
+		cmd.Stdout = stdout
+	}
+	if cmd.Stderr != nil {
+		cmd.Stderr = stderr
+	}
+	return drains, nil
+}
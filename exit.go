@@ -0,0 +1,128 @@
+package nsjail
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Status classifies how a jail ended, independently of how the installed nsjail version reports it.
+type Status int
+
+const (
+	// StatusExited means the program exited on its own. Result.NormalizedCode is its exit code.
+	StatusExited Status = iota
+	// StatusSignaled means the program, or nsjail itself, was killed by a signal other than for a time
+	// limit or by the wrapper.
+	StatusSignaled
+	// StatusTimeLimit means nsjail killed the program when it reached its time limit (-t).
+	StatusTimeLimit
+	// StatusAborted means the wrapper killed the jail, see Result.Aborted.
+	StatusAborted
+	// StatusSetupFailed means nsjail failed before the program ran, e.g. on an invalid mount.
+	StatusSetupFailed
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusExited:
+		return "exited"
+	case StatusSignaled:
+		return "signaled"
+	case StatusTimeLimit:
+		return "time limit"
+	case StatusAborted:
+		return "aborted"
+	case StatusSetupFailed:
+		return "setup failed"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// Normalized exit codes, as stored in Result.NormalizedCode. The scheme is stable across nsjail versions
+// and releases of this package:
+//
+//	0-255     the exit code of the program (StatusExited)
+//	128+N     the program was killed by signal N, as in POSIX shells (StatusSignaled)
+//	ExitTimeLimit, ExitAborted, ExitSetupFailed
+//	          the jail ended for a reason of its own, outside the range of exit codes
+//
+// nsjail reports a program killed by a signal as exit code 128+N and exits with 255 when setting up the
+// jail fails, so without the init shim a program exiting with 129-192 counts as StatusSignaled and one
+// exiting with 255 as StatusSetupFailed. The exit status reported by the shim (see WithInitShim) is exact.
+const (
+	ExitTimeLimit   = 256
+	ExitAborted     = 257
+	ExitSetupFailed = 258
+)
+
+// nsjailFailure is the exit code of nsjail when it cannot set up the jail.
+const nsjailFailure = 255
+
+// defaultTimeLimit is the time limit nsjail applies unless -t is given.
+const defaultTimeLimit = 600 * time.Second
+
+// WithKillSignal makes the wrapper stop the jail with sig instead of SIGKILL when it aborts it, e.g. when
+// the context is done or a limit is exceeded, and send SIGKILL if the jail is still running after grace.
+// nsjail reacts to SIGTERM, SIGINT and SIGQUIT by killing the program, unless ForwardSignals lets the program
+// handle them. nsjail's own time limit (-t) always uses SIGKILL.
+func (n *NsJail) WithKillSignal(sig syscall.Signal, grace time.Duration) *NsJail {
+	n.killSignal, n.killGrace = sig, grace
+	return n.changed()
+}
+
+// TimeLimit returns the time limit nsjail enforces on the jail (-t), including its default of 600s, or 0
+// for none.
+func (n *NsJail) TimeLimit() time.Duration { return n.timeLimitDuration() }
+
+// timeLimitDuration returns the time limit nsjail enforces, or 0 for none.
+func (n *NsJail) timeLimitDuration() time.Duration {
+	switch {
+	case n.timeLimit > 0:
+		return time.Duration(n.timeLimit) * time.Second
+	case n.sessionAgent:
+		// Sessions run with --time_limit 0.
+		return 0
+	}
+	return defaultTimeLimit
+}
+
+// kill stops the jail with the configured kill signal, escalating to SIGKILL after the grace period.
+func (j *Jail) kill() {
+	if j.killSignal == 0 || j.killSignal == syscall.SIGKILL {
+		j.proc.Signal(os.Kill)
+		return
+	}
+	j.proc.Signal(j.killSignal)
+	go func() {
+		select {
+		case <-j.done:
+		case <-time.After(j.killGrace):
+			j.proc.Signal(os.Kill)
+		}
+	}()
+}
+
+// normalize fills in r.Status and r.NormalizedCode.
+func (j *Jail) normalize(r *Result) {
+	code, sig := r.ExitCode, r.Signal
+	exact := false
+	if rep := r.Shim; rep != nil {
+		code, sig, exact = rep.ExitCode, syscall.Signal(rep.Signal), true
+	} else if sig == 0 && code > 128 && code <= 128+64 {
+		sig = syscall.Signal(code - 128)
+	}
+	switch {
+	case r.Aborted != nil:
+		r.Status, r.NormalizedCode = StatusAborted, ExitAborted
+	case sig == syscall.SIGKILL && j.timeLimit > 0 && r.Duration >= j.timeLimit:
+		r.Status, r.NormalizedCode = StatusTimeLimit, ExitTimeLimit
+	case sig != 0:
+		r.Status, r.NormalizedCode = StatusSignaled, 128+int(sig)
+	case code == nsjailFailure && !exact, code < 0:
+		r.Status, r.NormalizedCode = StatusSetupFailed, ExitSetupFailed
+	default:
+		r.Status, r.NormalizedCode = StatusExited, code
+	}
+}
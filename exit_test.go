@@ -0,0 +1,58 @@
+package nsjail
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/OptimusePrime/nsjail-go/initshim"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Result
+		status Status
+		code   int
+	}{
+		{"exit 0", Result{}, StatusExited, 0},
+		{"exit 1", Result{ExitCode: 1}, StatusExited, 1},
+		{"exit 128", Result{ExitCode: 128}, StatusExited, 128},
+		{"signal from nsjail", Result{ExitCode: 128 + 11}, StatusSignaled, 128 + 11},
+		{"signal of nsjail", Result{ExitCode: -1, Signal: syscall.SIGTERM}, StatusSignaled, 128 + 15},
+		{"exit 193", Result{ExitCode: 193}, StatusExited, 193},
+		{"setup failed", Result{ExitCode: 255}, StatusSetupFailed, ExitSetupFailed},
+		{"not started", Result{ExitCode: -1}, StatusSetupFailed, ExitSetupFailed},
+		{"time limit", Result{ExitCode: 128 + 9, Duration: 2 * time.Second}, StatusTimeLimit, ExitTimeLimit},
+		{"SIGKILL before time limit", Result{ExitCode: 128 + 9, Duration: time.Second}, StatusSignaled, 128 + 9},
+		{"aborted", Result{ExitCode: 128 + 9, Duration: 2 * time.Second, Aborted: errors.New("watchdog")},
+			StatusAborted, ExitAborted},
+		{"shim exit 255", Result{ExitCode: 255, Shim: &initshim.Report{ExitCode: 255}}, StatusExited, 255},
+		{"shim exit 130", Result{ExitCode: 130, Shim: &initshim.Report{ExitCode: 130}}, StatusExited, 130},
+		{"shim signal", Result{ExitCode: 1, Shim: &initshim.Report{ExitCode: -1, Signal: 6}}, StatusSignaled, 128 + 6},
+	}
+	j := &Jail{timeLimit: 2 * time.Second}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.result
+			j.normalize(&r)
+			if r.Status != tt.status || r.NormalizedCode != tt.code {
+				t.Errorf("normalize = %v, %d, want %v, %d", r.Status, r.NormalizedCode, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestNormalizedCodesOutsideExitCodes(t *testing.T) {
+	codes := []int{ExitTimeLimit, ExitAborted, ExitSetupFailed}
+	want := []int{256, 257, 258}
+	for i, code := range codes {
+		if code != want[i] {
+			t.Errorf("normalized code %d = %d, want %d", i, code, want[i])
+		}
+		if code <= 255 {
+			t.Errorf("normalized code %d lies in the range of exit codes", code)
+		}
+	}
+}
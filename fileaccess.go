@@ -0,0 +1,123 @@
+package nsjail
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// FileAccessPolicy declares in one place which host paths a jail may read, write and execute, in the
+// manner of Landlock rules. WithFileAccessPolicy compiles it into bind mounts, tmpfs mounts and symlinks;
+// everything else of the host stays out of the jail. Paths are absolute and mounted at the same path in
+// the jail. A path listed more than once gets the widest access.
+type FileAccessPolicy struct {
+	// Read lists host paths the jail may read, mounted read-only.
+	Read []string
+	// Write lists host paths the jail may read and write, mounted read-write.
+	Write []string
+	// Exec lists host paths of programs and libraries the jail may run, mounted read-only. The command of
+	// the jail must lie below one of them or below a Write path.
+	Exec []string
+	// Scratch lists paths in the jail backed by an empty tmpfs, writable and discarded with the jail, e.g.
+	// "/tmp".
+	Scratch []string
+	// Links maps paths in the jail to the targets of symlinks created there, e.g. "/bin" to "usr/bin".
+	Links map[string]string
+}
+
+// WithFileAccessPolicy exposes the host file system to the jail as declared by p: Read and Exec paths are
+// bind-mounted read-only (-R), Write paths read-write (-B), Scratch paths are tmpfs mounts (-T) and Links
+// symlinks (-s). nsjail mounts read-only binds first, then read-write ones, then tmpfs, and creates
+// symlinks last, so a path cannot lie inside one mounted after it, e.g. a Read path inside a Write path,
+// and symlinks cannot lie inside bind mounts, where they would be created read-only or on the host. Such
+// policies are rejected. Bind mounts do not prevent executing files, so the split between Read and Exec
+// is only enforced for the command of the jail, which Exec, Build, Start and Run check.
+func (n *NsJail) WithFileAccessPolicy(p FileAccessPolicy) *NsJail {
+	const method = "WithFileAccessPolicy"
+	for _, paths := range [][]string{p.Read, p.Write, p.Exec, p.Scratch, sortedKeys(p.Links)} {
+		for _, p := range paths {
+			if !path.IsAbs(p) {
+				n.fail(method, "path %q is not absolute", p)
+				return n.changed()
+			}
+		}
+	}
+	cleanAll := func(paths ...[]string) []string {
+		var cleaned []string
+		for _, p := range slices.Concat(paths...) {
+			cleaned = append(cleaned, path.Clean(p))
+		}
+		slices.Sort(cleaned)
+		return slices.Compact(cleaned)
+	}
+	rw := cleanAll(p.Write)
+	ro := slices.DeleteFunc(cleanAll(p.Read, p.Exec), func(p string) bool {
+		_, found := slices.BinarySearch(rw, p)
+		return found
+	})
+	scratch := cleanAll(p.Scratch)
+	links := make(map[string]string, len(p.Links))
+	for link, target := range p.Links {
+		links[path.Clean(link)] = target
+	}
+
+	// A path mounted later shadows what was mounted inside it before.
+	for _, c := range []struct {
+		kind, reason string
+		inner, outer []string
+	}{
+		{"read-only path", "mounted after it", ro, slices.Concat(rw, scratch)},
+		{"writable path", "mounted after it", rw, scratch},
+		{"symlink", "mounted before it is created", sortedKeys(links), slices.Concat(ro, rw, scratch)},
+	} {
+		for _, inner := range c.inner {
+			for _, outer := range c.outer {
+				// Symlinks may be created inside a tmpfs, which is theirs alone.
+				inScratch := slices.Contains(scratch, outer) && inner != outer
+				if within(inner, outer) && !(c.kind == "symlink" && inScratch) {
+					n.fail(method, "%s %s lies inside %s, which is %s", c.kind, inner, outer, c.reason)
+					return n.changed()
+				}
+			}
+		}
+	}
+
+	for _, p := range ro {
+		n.AddBindMountRO(p)
+	}
+	for _, p := range rw {
+		n.AddBindMountRW(p)
+	}
+	for _, p := range scratch {
+		n.AddTmpfsMount(p)
+	}
+	for _, link := range sortedKeys(links) {
+		n.AddSymlink(links[link], link)
+	}
+	n.fileAccess = &FileAccessPolicy{Exec: slices.Clone(p.Exec), Write: slices.Clone(p.Write)}
+	return n.changed()
+}
+
+// within reports whether the clean absolute path p is dir or lies below it.
+func within(p, dir string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+// validateFileAccess checks that the command lies below an Exec or Write path of WithFileAccessPolicy.
+func (n *NsJail) validateFileAccess() error {
+	if n.fileAccess == nil || n.executeFd {
+		return nil
+	}
+	cmd := cmp.Or(n.execFile, n.execCmd)
+	if !path.IsAbs(cmd) {
+		return nil
+	}
+	for _, dir := range slices.Concat(n.fileAccess.Exec, n.fileAccess.Write) {
+		if within(path.Clean(cmd), path.Clean(dir)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("nsjail: the command %s is not in an Exec path of the file access policy", cmd)
+}
@@ -0,0 +1,124 @@
+package nsjail
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// portForward forwards a TCP port of the host to a port of the jail, see ForwardPort.
+type portForward struct {
+	hostPort, jailPort uint16
+}
+
+// ForwardPort makes Start and Run forward TCP connections to hostPort on the host's loopback interface
+// to jailPort on the loopback interface of the jail, so a server in the jail can be reached from the
+// host. The connections are proxied by the wrapper, which dials into the jail's network namespace, so no
+// privileges or interfaces are needed; connections made before the server listens are closed. A hostPort
+// of 0 picks a free port, see Jail.HostPort. Forwarding stops when the jail exits. Can be called multiple
+// times.
+func (n *NsJail) ForwardPort(hostPort, jailPort uint16) *NsJail {
+	if jailPort == 0 {
+		n.fail("ForwardPort", "jail port 0")
+		return n.changed()
+	}
+	n.portForwards = append(n.portForwards, portForward{hostPort: hostPort, jailPort: jailPort})
+	return n.changed()
+}
+
+// HostPort returns the host port forwarded to jailPort with ForwardPort, or 0.
+func (j *Jail) HostPort(jailPort uint16) uint16 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.hostPorts[jailPort]
+}
+
+// useForwards listens on the host ports, so conflicts fail Start, and serves them once the jail runs.
+func (j *Jail) useForwards(forwards []portForward) error {
+	var lns []net.Listener
+	closeAll := func() {
+		for _, ln := range lns {
+			ln.Close()
+		}
+	}
+	j.hostPorts = make(map[uint16]uint16, len(forwards))
+	for _, f := range forwards {
+		ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(f.hostPort))))
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("nsjail: forwarding port %d: %w", f.hostPort, err)
+		}
+		lns = append(lns, ln)
+		j.hostPorts[f.jailPort] = uint16(ln.Addr().(*net.TCPAddr).Port)
+	}
+
+	var mu sync.Mutex
+	conns := make(map[net.Conn]bool)
+	track := func(c net.Conn) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if conns == nil {
+			return false
+		}
+		conns[c] = true
+		return true
+	}
+	j.onClose(func() {
+		closeAll()
+		mu.Lock()
+		for c := range conns {
+			c.Close()
+		}
+		conns = nil
+		mu.Unlock()
+	})
+	j.onStarted(func() error {
+		pid, err := j.jailPid(5 * time.Second)
+		if err != nil {
+			return fmt.Errorf("nsjail: forwarding ports: %w", err)
+		}
+		for i, ln := range lns {
+			addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(int(forwards[i].jailPort)))
+			go func() {
+				for {
+					c, err := ln.Accept()
+					if err != nil {
+						return
+					}
+					go forwardConn(c, pid, addr, track)
+				}
+			}()
+		}
+		return nil
+	})
+	return nil
+}
+
+// forwardConn proxies c to addr in the network namespace of pid.
+func forwardConn(c net.Conn, pid int, addr string, track func(net.Conn) bool) {
+	defer c.Close()
+	up, err := dialInNetns(pid, addr)
+	if err != nil {
+		return
+	}
+	defer up.Close()
+	if !track(c) || !track(up) {
+		return
+	}
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		// Pass on the end of the stream while the other direction may still carry data.
+		if tc, ok := dst.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+	go pipe(up, c)
+	go pipe(c, up)
+	<-done
+	<-done
+}
@@ -0,0 +1,52 @@
+package nsjail
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Config is an immutable jail configuration, made with NsJail.Freeze. Unlike an NsJail it is safe for
+// concurrent use: goroutines sharing a template each customize their own copy with New, or run it as is.
+//
+//	base := nsjail.New("/usr/bin/python3", "main.py").WithChroot(rootfs).WithTimeLimit(10).Freeze()
+//	// In each request:
+//	res, err := base.New().AddEnv("REQUEST_ID", id).Run(ctx)
+type Config struct {
+	n *NsJail
+}
+
+// Freeze returns an immutable copy of the configuration. Later changes to n do not affect it.
+func (n *NsJail) Freeze() *Config {
+	return &Config{n: n.Clone()}
+}
+
+// New returns a copy of the configuration with opts applied, to be customized and run by a single
+// goroutine.
+func (c *Config) New(opts ...Option) *NsJail {
+	return c.n.Clone().Apply(opts...)
+}
+
+// Exec builds the command of the configuration, see NsJail.Exec.
+func (c *Config) Exec() (*exec.Cmd, error) {
+	return c.n.Exec()
+}
+
+// Args returns the argv of the configuration, see NsJail.Args.
+func (c *Config) Args() ([]string, error) {
+	return c.n.Args()
+}
+
+// Start starts a jail with the configuration, see NsJail.Start.
+func (c *Config) Start(ctx context.Context) (*Jail, error) {
+	return c.n.Start(ctx)
+}
+
+// Run runs a jail with the configuration, see NsJail.Run.
+func (c *Config) Run(ctx context.Context) (*Result, error) {
+	return c.n.Run(ctx)
+}
+
+// String returns the command line of the configuration, see NsJail.String.
+func (c *Config) String() string {
+	return c.n.String()
+}
@@ -0,0 +1,105 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// ErrTooManyFiles reports that a directory watched with WithFileLimits holds too many entries.
+	ErrTooManyFiles = errors.New("nsjail: file count limit exceeded")
+	// ErrFileTooLarge reports that a file below a directory watched with WithFileLimits is too large.
+	ErrFileTooLarge = errors.New("nsjail: file size limit exceeded")
+)
+
+// FileLimits bounds what the jailed process may store in a writable host directory.
+// RLIMIT_FSIZE only bounds single writes, so these limits are enforced by periodically scanning the directory.
+type FileLimits struct {
+	// MaxFiles is the maximum number of entries (files, directories, symlinks) below the directory. 0 means unlimited.
+	MaxFiles int
+	// MaxFileSize is the maximum size in bytes of a single file. 0 means unlimited.
+	MaxFileSize int64
+	// Interval between scans. Defaults to 250ms.
+	Interval time.Duration
+	// FlagOnly records violations in Result.Violations instead of killing the jail.
+	FlagOnly bool
+}
+
+type fileLimit struct {
+	dir string
+	FileLimits
+}
+
+// WithFileLimits enforces limits on the host directory dir while the jail started with Start is running.
+// The directory is scanned once more after the jail exits, so violations are never missed. Can be called multiple times.
+func (n *NsJail) WithFileLimits(dir string, limits FileLimits) *NsJail {
+	n.fileLimits = append(n.fileLimits, fileLimit{dir: dir, FileLimits: limits})
+	return n.changed()
+}
+
+// check scans the directory and returns the first violated limit.
+func (l *fileLimit) check() error {
+	count := 0
+	return filepath.WalkDir(l.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == l.dir {
+			// Entries disappearing during the scan are expected.
+			return nil
+		}
+		count++
+		if l.MaxFiles > 0 && count > l.MaxFiles {
+			return fmt.Errorf("%w: more than %d entries in %s", ErrTooManyFiles, l.MaxFiles, l.dir)
+		}
+		if l.MaxFileSize > 0 && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil && info.Size() > l.MaxFileSize {
+				return fmt.Errorf("%w: %s is %d bytes, limit %d", ErrFileTooLarge, path, info.Size(), l.MaxFileSize)
+			}
+		}
+		return nil
+	})
+}
+
+// enforce scans the directory until stop is closed or a limit is violated, and reports which happened.
+func (l *fileLimit) enforce(j *Jail, stop <-chan struct{}) (violated bool) {
+	interval := l.Interval
+	if interval <= 0 {
+		interval = 250 * time.Millisecond
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-t.C:
+		}
+		if err := l.check(); err != nil {
+			if l.FlagOnly {
+				j.flag(err)
+			} else {
+				j.Abort(err)
+			}
+			return true
+		}
+	}
+}
+
+func (j *Jail) startFileLimits(limits []fileLimit) {
+	for i := range limits {
+		l := &limits[i]
+		stop := make(chan struct{})
+		violated := make(chan bool, 1)
+		go func() { violated <- l.enforce(j, stop) }()
+		j.onClose(func() {
+			close(stop)
+			if <-violated {
+				return
+			}
+			if err := l.check(); err != nil {
+				j.flag(err)
+			}
+		})
+	}
+}
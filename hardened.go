@@ -0,0 +1,63 @@
+package nsjail
+
+import (
+	"slices"
+	"time"
+)
+
+// NewHardened creates a new NsJail configuration for the given command and arguments starting from a
+// restrictive baseline rather than nsjail's defaults:
+//
+//   - the host's root is mounted read-only (-c /), with an empty writable tmpfs /tmp as working directory;
+//   - the network namespace has no interfaces but loopback, and the environment is empty;
+//   - no capabilities are kept, and PresetSeccompPolicy denies debugging, namespaces, mounts and BPF;
+//   - every resource limit is set low: 512 MiB of address space, 10 seconds of CPU time and a 60 second
+//     time limit, 64 MiB files, 64 open files, 64 processes, 8 MiB of stack, no core dumps, locked memory,
+//     real-time priority or message queues.
+//
+// Each of these is loosened explicitly with the builder methods, e.g. WithRlimitAsBytes or AddEnv. Like
+// New, options set with SetDefaults are applied first; the baseline then overrides them, including
+// environment variables, capabilities, network settings, read-write bind mounts, id mappings of host root
+// and every namespace or other setting they disable or loosen that AuditConfig reports. Should the baseline
+// still have an AuditConfig finding of SeverityHigh or above, it is recorded as an error of NewHardened.
+func NewHardened(cmd string, args ...string) *NsJail {
+	n := New(cmd, args...)
+	n.keepEnv, n.envVars, n.envPatterns, n.envDeny, n.envFiles = false, nil, nil, nil, nil
+	n.keepCaps, n.caps = false, nil
+	n.cloneNewNetDisabled, n.ifaceOwn, n.macvlanIface, n.macvlanAuto = false, nil, "", false
+	n.veth, n.slirp, n.wireGuard, n.portForwards = nil, nil, nil, nil
+	n.cloneNewUserDisabled, n.cloneNewNsDisabled, n.cloneNewPidDisabled = false, false, false
+	n.cloneNewIpcDisabled, n.cloneNewUtsDisabled, n.cloneNewCgroupDisabled = false, false, false
+	n.disableNoNewPrivs, n.procRw, n.rwChroot, n.bindMountsRW = false, false, false, nil
+	n.uidMappings = slices.DeleteFunc(n.uidMappings, mapsHostRoot)
+	n.gidMappings = slices.DeleteFunc(n.gidMappings, mapsHostRoot)
+	n.
+		WithChroot("/").
+		AddTmpfsMount("/tmp").
+		WithCwd("/tmp").
+		WithHostname("jail").
+		WithSeccompString(PresetSeccompPolicy).
+		WithTimeLimit(60).
+		WithRlimitAsBytes(512 * mib).
+		WithRlimitCpuDuration(10 * time.Second).
+		WithRlimitFsizeBytes(64 * mib).
+		WithRlimitNofileCount(64).
+		WithRlimitNprocCount(64).
+		WithRlimitStackBytes(8 * mib).
+		WithRlimitCoreBytes(0).
+		WithRlimitMemlockBytes(0).
+		WithRlimitRtprioLevel(0).
+		WithRlimitMsgqueueBytes(0)
+	for _, f := range n.AuditConfig().Findings {
+		if f.Severity >= SeverityHigh {
+			n.fail("NewHardened", "the baseline is weakened by %s (%v): %s", f.Rule, f.Severity, f.Detail)
+		}
+	}
+	return n
+}
+
+// mapsHostRoot reports whether the id mapping m maps host root into the jail.
+func mapsHostRoot(m string) bool {
+	ids, err := parseIDMap(m)
+	return err == nil && ids[1] == 0
+}
@@ -0,0 +1,355 @@
+package nsjail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// HTTPExchange is an HTTP request made by a jailed program and the response it got.
+type HTTPExchange struct {
+	Time     time.Time
+	Duration time.Duration
+
+	Method string
+	// URL is absolute; requests tunneled with CONNECT have the https scheme.
+	URL           string
+	RequestHeader http.Header
+	RequestBody   []byte
+
+	// StatusCode is 0 if the request failed, see Err.
+	StatusCode     int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	// BodyTruncated reports that a body exceeded HTTPCaptureConfig.MaxBody and was recorded partially.
+	BodyTruncated bool
+	Err           error
+}
+
+// Request rebuilds the recorded request, e.g. to replay it with an http.Client.
+func (e *HTTPExchange) Request(ctx context.Context) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, e.Method, e.URL, bytes.NewReader(e.RequestBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = e.RequestHeader.Clone()
+	return req, nil
+}
+
+// HTTPCaptureConfig configures the capture proxy enabled with WithHTTPCapture.
+type HTTPCaptureConfig struct {
+	// MaxBody caps each recorded request and response body. Defaults to 1 MiB. Bodies are always
+	// forwarded in full.
+	MaxBody int64
+	// OnExchange is called for every exchange. All exchanges are also listed in Result.HTTPExchanges.
+	OnExchange func(HTTPExchange)
+	// TrustStorePath is where the CA bundle including the capture CA is mounted in the jail.
+	// Defaults to /etc/ssl/certs/ca-certificates.crt.
+	TrustStorePath string
+	// HostTrustStore is the host bundle the capture CA is appended to. Defaults to the host's TrustStorePath.
+	HostTrustStore string
+}
+
+// captureProxyAddr is where the capture proxy listens inside the jail's network namespace.
+const captureProxyAddr = "127.0.0.1:3128"
+
+// WithHTTPCapture runs a recording HTTP(S) proxy inside the jail's network namespace and points the
+// HTTP_PROXY and HTTPS_PROXY variables at it. HTTPS is intercepted with a CA generated per run, which is
+// added to the CA bundle mounted at cfg.TrustStorePath and named by SSL_CERT_FILE and similar variables.
+// Programs that ignore proxy variables are not captured; without other network access they cannot
+// connect at all. Requests are forwarded from the host's network namespace.
+// Requires a network namespace (no DisableCloneNewNet).
+func (n *NsJail) WithHTTPCapture(cfg HTTPCaptureConfig) *NsJail {
+	n.httpCapture = &cfg
+	return n.changed()
+}
+
+// useHTTPCapture mounts the CA bundle, sets the proxy variables and starts the proxy once the jail runs.
+func (j *Jail) useHTTPCapture(n *NsJail, l *launch) error {
+	if n.cloneNewNetDisabled {
+		return errors.New("nsjail: HTTP capture requires a network namespace")
+	}
+	cfg := *n.httpCapture
+	if cfg.MaxBody <= 0 {
+		cfg.MaxBody = 1 << 20
+	}
+	if cfg.TrustStorePath == "" {
+		cfg.TrustStorePath = "/etc/ssl/certs/ca-certificates.crt"
+	}
+	if cfg.HostTrustStore == "" {
+		cfg.HostTrustStore = cfg.TrustStorePath
+	}
+
+	ca, err := newCaptureCA()
+	if err != nil {
+		return err
+	}
+	bundle, err := os.ReadFile(cfg.HostTrustStore)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	bundle = append(append(bundle, '\n'), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...)
+	f, err := os.CreateTemp("", "nsjail-ca-*.pem")
+	if err != nil {
+		return err
+	}
+	j.onClose(func() { os.Remove(f.Name()) })
+	_, err = f.Write(bundle)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	os.Chmod(f.Name(), 0o644)
+	l.flags = append(l.flags, "-R", f.Name()+":"+cfg.TrustStorePath)
+
+	proxy := "http://" + captureProxyAddr
+	for _, env := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		l.flags = append(l.flags, "-E", env+"="+proxy)
+	}
+	for _, env := range []string{"SSL_CERT_FILE", "CURL_CA_BUNDLE", "REQUESTS_CA_BUNDLE", "NODE_EXTRA_CA_CERTS"} {
+		l.flags = append(l.flags, "-E", env+"="+cfg.TrustStorePath)
+	}
+
+	j.onStarted(func() error {
+		pid, err := j.jailPid(5 * time.Second)
+		if err != nil {
+			return err
+		}
+		ln, err := listenTCPInNetns(pid, captureProxyAddr)
+		if err != nil {
+			return err
+		}
+		p := &captureProxy{j: j, cfg: &cfg, ca: ca, transport: &http.Transport{TLSHandshakeTimeout: 10 * time.Second}}
+		srv := &http.Server{Handler: p, ReadHeaderTimeout: 30 * time.Second}
+		j.onClose(func() { srv.Close(); p.transport.CloseIdleConnections() })
+		go srv.Serve(ln)
+		return nil
+	})
+	return nil
+}
+
+type captureProxy struct {
+	j         *Jail
+	cfg       *HTTPCaptureConfig
+	ca        *captureCA
+	transport *http.Transport
+}
+
+func (p *captureProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "nsjail capture proxy: absolute URL required", http.StatusBadRequest)
+		return
+	}
+	resp, finish := p.roundTrip(r)
+	if resp == nil {
+		http.Error(w, "nsjail capture proxy: upstream request failed", http.StatusBadGateway)
+		finish()
+		return
+	}
+	defer resp.Body.Close()
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+	finish()
+}
+
+// tunnel intercepts a CONNECT request with TLS and serves the HTTP requests inside it.
+func (p *captureProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "nsjail capture proxy: hijacking unsupported", http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	tlsConn := tls.Server(conn, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := hello.ServerName
+			if name == "" {
+				name = host
+			}
+			return p.ca.leaf(name)
+		},
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return
+	}
+	br := bufio.NewReader(tlsConn)
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		req.URL.Scheme, req.URL.Host = "https", r.Host
+		req.RequestURI = ""
+		resp, finish := p.roundTrip(req)
+		if resp == nil {
+			io.WriteString(tlsConn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+			finish()
+			return
+		}
+		err = resp.Write(tlsConn)
+		resp.Body.Close()
+		finish()
+		if err != nil || req.Close || resp.Close {
+			return
+		}
+	}
+}
+
+// roundTrip forwards r upstream, recording its body and the response body as they are read.
+// finish records the exchange and must be called once the response body was consumed.
+func (p *captureProxy) roundTrip(r *http.Request) (*http.Response, func()) {
+	ex := HTTPExchange{Time: time.Now(), Method: r.Method, URL: r.URL.String(), RequestHeader: r.Header.Clone()}
+	reqBody := NewOutputCapture(p.cfg.MaxBody)
+	if r.Body != nil {
+		r.Body = readCloser{io.TeeReader(r.Body, reqBody), r.Body}
+	}
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	removeHopHeaders(out.Header)
+
+	resp, err := p.transport.RoundTrip(out)
+	respBody := NewOutputCapture(p.cfg.MaxBody)
+	if err != nil {
+		ex.Err = err
+		resp = nil
+	} else {
+		ex.StatusCode, ex.ResponseHeader = resp.StatusCode, resp.Header.Clone()
+		resp.Body = readCloser{io.TeeReader(resp.Body, respBody), resp.Body}
+	}
+	return resp, func() {
+		ex.Duration = time.Since(ex.Time)
+		ex.RequestBody, ex.ResponseBody = reqBody.Bytes(), respBody.Bytes()
+		ex.BodyTruncated = reqBody.Truncated() || respBody.Truncated()
+		p.j.mu.Lock()
+		p.j.httpExchanges = append(p.j.httpExchanges, ex)
+		p.j.mu.Unlock()
+		if p.cfg.OnExchange != nil {
+			p.cfg.OnExchange(ex)
+		}
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+}
+
+// captureCA issues leaf certificates for intercepted hosts.
+type captureCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+
+	mu     sync.Mutex
+	leaves map[string]*tls.Certificate
+}
+
+func newCaptureCA() (*captureCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{CommonName: "nsjail capture CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(7 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &captureCA{cert: cert, key: key, leaves: make(map[string]*tls.Certificate)}, nil
+}
+
+func (ca *captureCA) leaf(host string) (*tls.Certificate, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if c, ok := ca.leaves[host]; ok {
+		return c, nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    ca.cert.NotBefore,
+		NotAfter:     ca.cert.NotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else {
+		tmpl.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	c := &tls.Certificate{Certificate: [][]byte{der, ca.cert.Raw}, PrivateKey: key}
+	ca.leaves[host] = c
+	return c, nil
+}
+
+func randomSerial() *big.Int {
+	n, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	return n
+}
@@ -0,0 +1,19 @@
+package nsjail
+
+// WithRandomIdentity gives every run started by Start or Run an identifier of its own, 16 random hex
+// digits, to correlate it across the service, the logs and the host: the jail's hostname becomes
+// "jail-<id>", replacing WithHostname, the workspace of WithWorkspace is named "nsjail-workspace-<id>" and
+// the cgroups the wrapper creates "NSJAIL-<id>". The wrapper's log messages about the run carry it as
+// jail_id, and Jail.ID and Result.ID return it.
+func (n *NsJail) WithRandomIdentity() *NsJail { n.randomIdentity = true; return n.changed() }
+
+// withRunID returns a copy of n with a new identifier for its run, see WithRandomIdentity.
+func (n *NsJail) withRunID() *NsJail {
+	r := n.Clone()
+	r.runID = uniqueName("", 16)
+	r.hostname = "jail-" + r.runID
+	return r
+}
+
+// ID returns the identifier of the run, see NsJail.WithRandomIdentity, or "" without it.
+func (j *Jail) ID() string { return j.id }
@@ -0,0 +1,95 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// AddUidMap maps count uids starting at outside on the host to inside in the jail (-U).
+// Mapping more than one id as an unprivileged user requires the set-uid newuidmap helper.
+func (n *NsJail) AddUidMap(inside, outside, count uint32) *NsJail {
+	if count == 0 {
+		n.fail("AddUidMap", "count must be positive")
+		return n.changed()
+	}
+	n.uidMappings = append(n.uidMappings, formatIDMap(inside, outside, count))
+	return n.changed()
+}
+
+// AddGidMap maps count gids starting at outside on the host to inside in the jail (-G).
+// Mapping more than one id as an unprivileged user requires the set-uid newgidmap helper.
+func (n *NsJail) AddGidMap(inside, outside, count uint32) *NsJail {
+	if count == 0 {
+		n.fail("AddGidMap", "count must be positive")
+		return n.changed()
+	}
+	n.gidMappings = append(n.gidMappings, formatIDMap(inside, outside, count))
+	return n.changed()
+}
+
+// MapCurrentUser maps the uid and gid of the calling process to themselves inside the jail.
+func (n *NsJail) MapCurrentUser() *NsJail {
+	uid, gid := uint32(os.Geteuid()), uint32(os.Getegid())
+	return n.AddUidMap(uid, uid, 1).AddGidMap(gid, gid, 1)
+}
+
+// MapRoot maps root inside the jail to the uid and gid of the calling process and runs the command as root
+// (-U 0:uid:1, -G 0:gid:1, -u 0, -g 0).
+func (n *NsJail) MapRoot() *NsJail {
+	uid, gid := uint32(os.Geteuid()), uint32(os.Getegid())
+	return n.AddUidMap(0, uid, 1).AddGidMap(0, gid, 1).WithUser("0").WithGroup("0")
+}
+
+func formatIDMap(inside, outside, count uint32) string {
+	return fmt.Sprintf("%d:%d:%d", inside, outside, count)
+}
+
+// parseIDMap parses a mapping of the form "inside:outside:count".
+func parseIDMap(m string) ([3]uint64, error) {
+	var ids [3]uint64
+	parts := strings.Split(m, ":")
+	if len(parts) != 3 {
+		return ids, errors.New("want inside:outside:count")
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return ids, err
+		}
+		ids[i] = v
+	}
+	if ids[2] == 0 {
+		return ids, errors.New("count must be positive")
+	}
+	return ids, nil
+}
+
+// validateIDMaps checks the uid and gid mappings, and that the helpers nsjail needs to install mappings of
+// more than one id are available when not running as root.
+func (n *NsJail) validateIDMaps() error {
+	for _, set := range []struct {
+		flag, helper string
+		mappings     []string
+	}{
+		{"-U", "newuidmap", n.uidMappings},
+		{"-G", "newgidmap", n.gidMappings},
+	} {
+		for _, m := range set.mappings {
+			ids, err := parseIDMap(m)
+			if err != nil {
+				return fmt.Errorf("nsjail: invalid %s mapping %q: %w", set.flag, m, err)
+			}
+			if ids[2] > 1 && os.Geteuid() != 0 {
+				if _, err := exec.LookPath(set.helper); err != nil {
+					return fmt.Errorf("nsjail: %s mapping %q maps %d ids, which requires %s when not running as root: %w",
+						set.flag, m, ids[2], set.helper, err)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package nsjail
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseIDMap(t *testing.T) {
+	tests := []struct {
+		m       string
+		want    [3]uint64
+		wantErr string
+	}{
+		{"0:1000:1", [3]uint64{0, 1000, 1}, ""},
+		{"1000:100000:65536", [3]uint64{1000, 100000, 65536}, ""},
+		{"4294967295:0:1", [3]uint64{4294967295, 0, 1}, ""},
+		{"0:1000", [3]uint64{}, "want inside:outside:count"},
+		{"0:1000:1:1", [3]uint64{}, "want inside:outside:count"},
+		{"0 1000 1", [3]uint64{}, "want inside:outside:count"},
+		{"a:1000:1", [3]uint64{}, "invalid syntax"},
+		{"-1:1000:1", [3]uint64{}, "invalid syntax"},
+		{"4294967296:0:1", [3]uint64{}, "out of range"},
+		{"0:1000:0", [3]uint64{}, "count must be positive"},
+	}
+	for _, tt := range tests {
+		got, err := parseIDMap(tt.m)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("parseIDMap(%q): %v", tt.m, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("parseIDMap(%q) = %v, want an error containing %q", tt.m, err, tt.wantErr)
+		case err == nil && got != tt.want:
+			t.Errorf("parseIDMap(%q) = %v, want %v", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestIDMapArgs(t *testing.T) {
+	uid, gid := os.Geteuid(), os.Getegid()
+	tests := []struct {
+		name string
+		jail *NsJail
+		want []string
+	}{
+		{"AddUidMap", New("/bin/true").AddUidMap(0, 1000, 1), []string{"-U", "0:1000:1"}},
+		{"AddGidMap", New("/bin/true").AddGidMap(5, 2000, 1), []string{"-G", "5:2000:1"}},
+		{"string mappings", New("/bin/true").AddUidMapping("1:1001:1").AddGidMapping("1:1001:1"),
+			[]string{"-U", "1:1001:1", "-G", "1:1001:1"}},
+		{"MapCurrentUser", New("/bin/true").MapCurrentUser(),
+			[]string{"-U", fmt.Sprintf("%d:%d:1", uid, uid), "-G", fmt.Sprintf("%d:%d:1", gid, gid)}},
+		{"MapRoot", New("/bin/true").MapRoot(), []string{"-u", "0", "-g", "0",
+			"-U", fmt.Sprintf("0:%d:1", uid), "-G", fmt.Sprintf("0:%d:1", gid)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.jail.Args()
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+			var got []string
+			for i := 0; i < len(args)-1 && args[i] != "--"; i++ {
+				if slices.Contains([]string{"-U", "-G", "-u", "-g"}, args[i]) {
+					got = append(got, args[i], args[i+1])
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("id flags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIDMapErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		jail *NsJail
+		want string
+	}{
+		{"zero uid count", New("/bin/true").AddUidMap(0, 1000, 0), "AddUidMap: count must be positive"},
+		{"zero gid count", New("/bin/true").AddGidMap(0, 1000, 0), "AddGidMap: count must be positive"},
+		{"invalid string", New("/bin/true").AddUidMapping("0:x:1"), "AddUidMapping: invalid mapping"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.jail.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.want)
+			}
+			if _, err := tt.jail.Args(); err == nil {
+				t.Errorf("Args succeeded with an invalid mapping")
+			}
+		})
+	}
+}
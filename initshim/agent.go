@@ -0,0 +1,75 @@
+package initshim
+
+import (
+	"sync"
+	"time"
+)
+
+// Request asks the agent started with -agent to run a command, or to cancel the running one.
+// Requests are written as JSON lines to the agent's input descriptor.
+type Request struct {
+	ID     uint64 `json:"id"`
+	Cancel bool   `json:"cancel,omitempty"`
+
+	Args []string `json:"args,omitempty"`
+	// Env replaces the agent's environment if non-nil.
+	Env   []string `json:"env,omitempty"`
+	Dir   string   `json:"dir,omitempty"`
+	Stdin []byte   `json:"stdin,omitempty"`
+	// MaxOutput caps each of stdout and stderr. Zero keeps 1 MiB.
+	MaxOutput int64 `json:"max_output,omitempty"`
+	// Timeout kills the command and its process group after this long. Zero means no timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// Resource limits applied to the command with setrlimit. Zero means the session's limits.
+	CPUTime      time.Duration `json:"cpu_time,omitempty"`      // RLIMIT_CPU, rounded up to seconds
+	AddressSpace uint64        `json:"address_space,omitempty"` // RLIMIT_AS in bytes
+	FileSize     uint64        `json:"file_size,omitempty"`     // RLIMIT_FSIZE in bytes
+}
+
+// Response describes a command run by the agent. It is written as a JSON line to the agent's output
+// descriptor.
+type Response struct {
+	ID uint64 `json:"id"`
+	// ExitCode is the exit code of the command, or -1 if it was killed by a signal.
+	ExitCode int `json:"exit_code"`
+	Signal   int `json:"signal,omitempty"`
+
+	Stdout    []byte `json:"stdout,omitempty"`
+	Stderr    []byte `json:"stderr,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+
+	Runtime  time.Duration `json:"runtime"`
+	TimedOut bool          `json:"timed_out,omitempty"`
+	Canceled bool          `json:"canceled,omitempty"`
+	// Error is set if the command could not be started.
+	Error string `json:"error,omitempty"`
+}
+
+// cappedBuffer keeps at most limit bytes written to it.
+type cappedBuffer struct {
+	mu        sync.Mutex
+	limit     int64
+	buf       []byte
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(p)
+	room := b.limit - int64(len(b.buf))
+	if int64(len(p)) > room {
+		b.truncated = true
+		p = p[:max(room, 0)]
+	}
+	b.buf = append(b.buf, p...)
+	// Discarded output still counts as written, so the command never blocks on a full pipe.
+	return n, nil
+}
+
+func (b *cappedBuffer) bytes() ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf...), b.truncated
+}
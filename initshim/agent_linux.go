@@ -0,0 +1,224 @@
+package initshim
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// drainTimeout bounds how long output is still read after a command exited, in case a background process
+// it started keeps its streams open.
+const drainTimeout = time.Second
+
+// runAgent serves requests read from in until it is closed, writing responses to out.
+func runAgent(in, out *os.File) int {
+	reqs := make(chan Request)
+	var (
+		mu      sync.Mutex
+		current *Request
+		cancel  = make(chan struct{}, 1)
+	)
+	go func() {
+		defer close(reqs)
+		dec := json.NewDecoder(bufio.NewReader(in))
+		for {
+			var req Request
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			if !req.Cancel {
+				reqs <- req
+				continue
+			}
+			mu.Lock()
+			if current != nil && current.ID == req.ID {
+				select {
+				case cancel <- struct{}{}:
+				default:
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	enc := json.NewEncoder(out)
+	for req := range reqs {
+		mu.Lock()
+		current = &req
+		mu.Unlock()
+		resp := execute(req, cancel)
+		mu.Lock()
+		current = nil
+		mu.Unlock()
+		// Drop a cancellation that raced with the command's exit.
+		select {
+		case <-cancel:
+		default:
+		}
+		if err := enc.Encode(resp); err != nil {
+			return 1
+		}
+		reapOrphans()
+	}
+	return 0
+}
+
+// execute runs one command in its own process group, reaping orphans while it runs.
+func execute(req Request, cancel <-chan struct{}) Response {
+	resp := Response{ID: req.ID}
+	if len(req.Args) == 0 {
+		resp.Error = "no command given"
+		return resp
+	}
+	path := req.Args[0]
+	if !strings.Contains(path, "/") {
+		lp, err := exec.LookPath(path)
+		if err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		path = lp
+	}
+	argv := req.Args
+	if req.CPUTime > 0 || req.AddressSpace > 0 || req.FileSize > 0 {
+		// Re-execute the shim, which applies the limits to itself before executing the command.
+		cpu := (req.CPUTime + time.Second - 1) / time.Second
+		argv = append([]string{os.Args[0], "-exec",
+			"-rlimit-cpu", strconv.FormatInt(int64(cpu), 10),
+			"-rlimit-as", strconv.FormatUint(req.AddressSpace, 10),
+			"-rlimit-fsize", strconv.FormatUint(req.FileSize, 10),
+			"--", path}, req.Args[1:]...)
+		path = os.Args[0]
+	}
+	env := req.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	limit := req.MaxOutput
+	if limit <= 0 {
+		limit = 1 << 20
+	}
+
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+
+	start := time.Now()
+	child, err := os.StartProcess(path, argv, &os.ProcAttr{
+		Dir:   req.Dir,
+		Env:   env,
+		Files: []*os.File{stdinR, outW, errW},
+		Sys:   &syscall.SysProcAttr{Setpgid: true},
+	})
+	stdinR.Close()
+	outW.Close()
+	errW.Close()
+	if err != nil {
+		stdinW.Close()
+		outR.Close()
+		errR.Close()
+		resp.Error = err.Error()
+		return resp
+	}
+
+	go func() {
+		stdinW.Write(req.Stdin)
+		stdinW.Close()
+	}()
+	stdout, stderr := &cappedBuffer{limit: limit}, &cappedBuffer{limit: limit}
+	copied := make(chan struct{}, 2)
+	go func() { io.Copy(stdout, outR); copied <- struct{}{} }()
+	go func() { io.Copy(stderr, errR); copied <- struct{}{} }()
+
+	exited := make(chan struct{})
+	var timedOut, canceled bool
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		var timeout <-chan time.Time
+		if req.Timeout > 0 {
+			t := time.NewTimer(req.Timeout)
+			defer t.Stop()
+			timeout = t.C
+		}
+		select {
+		case <-exited:
+			return
+		case <-timeout:
+			timedOut = true
+		case <-cancel:
+			canceled = true
+		}
+		syscall.Kill(-child.Pid, syscall.SIGKILL)
+	}()
+
+	for {
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			resp.Error = "wait4: " + err.Error()
+			break
+		}
+		if pid != child.Pid {
+			continue
+		}
+		resp.Runtime = time.Since(start)
+		resp.ExitCode = ws.ExitStatus()
+		if ws.Signaled() {
+			resp.Signal = int(ws.Signal())
+		}
+		break
+	}
+	close(exited)
+	<-watched
+	resp.TimedOut, resp.Canceled = timedOut, canceled
+
+	deadline := time.After(drainTimeout)
+	for range 2 {
+		select {
+		case <-copied:
+		case <-deadline:
+		}
+	}
+	outR.Close()
+	errR.Close()
+
+	var outTrunc, errTrunc bool
+	resp.Stdout, outTrunc = stdout.bytes()
+	resp.Stderr, errTrunc = stderr.bytes()
+	resp.Truncated = outTrunc || errTrunc
+	return resp
+}
+
+// reapOrphans collects exited processes left behind by earlier commands, without blocking.
+func reapOrphans() {
+	for {
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG, nil)
+		if pid <= 0 || err != nil {
+			return
+		}
+	}
+}
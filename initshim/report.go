@@ -0,0 +1,50 @@
+// Package initshim implements a tiny init process that runs as PID 1 inside a jail. It starts the
+// jailed command, forwards signals to it, reaps orphaned processes, and reports precise timing and
+// resource usage of the command to the host over an inherited file descriptor.
+//
+// With -agent the shim instead runs many commands one after another, as requested by the host over a pair
+// of descriptors (see Request and Response). This backs NsJail.StartSession.
+//
+// The shim is built as a static binary from cmd/nsjail-init and enabled with NsJail.WithInitShim.
+package initshim
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Report describes the finished command. It is written as JSON to the report descriptor.
+type Report struct {
+	// Start and End are the wall-clock times just before the command was executed and when it was reaped.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Runtime is End - Start measured on the monotonic clock.
+	Runtime time.Duration `json:"runtime"`
+
+	// ExitCode is the exit code of the command, or -1 if it was killed by a signal.
+	ExitCode int `json:"exit_code"`
+	// Signal is the signal that killed the command, or 0.
+	Signal int `json:"signal,omitempty"`
+
+	// Resource usage of the command and its waited-for descendants, from wait4(2).
+	UserTime               time.Duration `json:"user_time"`
+	SystemTime             time.Duration `json:"system_time"`
+	MaxRSS                 int64         `json:"max_rss"` // In bytes
+	MinorFaults            int64         `json:"minor_faults"`
+	MajorFaults            int64         `json:"major_faults"`
+	VoluntaryCtxSwitches   int64         `json:"voluntary_ctx_switches"`
+	InvoluntaryCtxSwitches int64         `json:"involuntary_ctx_switches"`
+
+	// Reaped counts orphaned processes reaped by the shim while the command was running.
+	Reaped int `json:"reaped"`
+}
+
+// ReadReport decodes a report written by the shim.
+func ReadReport(r io.Reader) (*Report, error) {
+	var rep Report
+	if err := json.NewDecoder(r).Decode(&rep); err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
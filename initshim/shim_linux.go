@@ -0,0 +1,193 @@
+package initshim
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// prSetChildSubreaper is PR_SET_CHILD_SUBREAPER from <linux/prctl.h>.
+const prSetChildSubreaper = 36
+
+// Main runs the shim with the process arguments and exits with the command's exit code.
+// Usage: nsjail-init [-wait-fd N] [-report-fd N] -- command [args...]
+//
+//	or: nsjail-init [-wait-fd N] -agent -in-fd N -out-fd M
+//	or: nsjail-init -exec [-rlimit-cpu S] [-rlimit-as B] [-rlimit-fsize B] -- /path/to/command [args...]
+func Main() {
+	os.Exit(Run(os.Args[1:]))
+}
+
+// execLimited lowers the given resource limits and replaces the shim with argv. Zero limits are left alone.
+// The agent runs commands through it, as Go cannot set limits between fork and exec.
+func execLimited(argv []string, limits map[int]uint64) int {
+	if len(argv) == 0 {
+		fmt.Fprintln(os.Stderr, "nsjail-init: no command given")
+		return 127
+	}
+	for res, v := range limits {
+		if v == 0 {
+			continue
+		}
+		lim := syscall.Rlimit{Cur: v, Max: v}
+		if res == syscall.RLIMIT_CPU {
+			// SIGXCPU at the soft limit, SIGKILL a second later if it is ignored.
+			lim.Max = v + 1
+		}
+		if err := syscall.Setrlimit(res, &lim); err != nil {
+			fmt.Fprintf(os.Stderr, "nsjail-init: setrlimit: %v\n", err)
+			return 127
+		}
+	}
+	err := syscall.Exec(argv[0], argv, os.Environ())
+	fmt.Fprintf(os.Stderr, "nsjail-init: %v\n", err)
+	return 127
+}
+
+// awaitGoAhead blocks until the host writes a byte to fd, e.g. once it has set up the jail's firewall, and
+// reports whether it did, rather than close fd. fd is closed either way.
+func awaitGoAhead(fd int) bool {
+	f := os.NewFile(uintptr(fd), "go-ahead")
+	defer f.Close()
+	var b [1]byte
+	n, _ := f.Read(b[:])
+	return n == 1
+}
+
+// Run starts the command described by args, waits for it and returns the exit code to use for the shim.
+// Commands killed by a signal yield 128+signal, like a shell.
+func Run(args []string) int {
+	fs := flag.NewFlagSet("nsjail-init", flag.ContinueOnError)
+	reportFd := fs.Int("report-fd", -1, "descriptor to write the JSON report to")
+	waitFd := fs.Int("wait-fd", -1, "descriptor to read a byte from before running anything")
+	agent := fs.Bool("agent", false, "run commands requested on -in-fd until it is closed")
+	inFd := fs.Int("in-fd", -1, "descriptor to read agent requests from")
+	outFd := fs.Int("out-fd", -1, "descriptor to write agent responses to")
+	execOnly := fs.Bool("exec", false, "apply the -rlimit flags and execute the command in place")
+	cpu := fs.Uint64("rlimit-cpu", 0, "CPU time limit in seconds")
+	as := fs.Uint64("rlimit-as", 0, "address space limit in bytes")
+	fsize := fs.Uint64("rlimit-fsize", 0, "file size limit in bytes")
+	if err := fs.Parse(args); err != nil {
+		return 127
+	}
+	if *waitFd >= 0 && !awaitGoAhead(*waitFd) {
+		fmt.Fprintln(os.Stderr, "nsjail-init: the host did not let the command run")
+		return 127
+	}
+	if *execOnly {
+		return execLimited(fs.Args(), map[int]uint64{
+			syscall.RLIMIT_CPU:   *cpu,
+			syscall.RLIMIT_AS:    *as,
+			syscall.RLIMIT_FSIZE: *fsize,
+		})
+	}
+	if *agent {
+		if *inFd < 0 || *outFd < 0 {
+			fmt.Fprintln(os.Stderr, "nsjail-init: -agent requires -in-fd and -out-fd")
+			return 127
+		}
+		// Commands must not be able to talk to the host in the agent's name.
+		syscall.CloseOnExec(*inFd)
+		syscall.CloseOnExec(*outFd)
+		if os.Getpid() != 1 {
+			syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0)
+		}
+		return runAgent(os.NewFile(uintptr(*inFd), "requests"), os.NewFile(uintptr(*outFd), "responses"))
+	}
+	argv := fs.Args()
+	if len(argv) == 0 {
+		fmt.Fprintln(os.Stderr, "nsjail-init: no command given")
+		return 127
+	}
+
+	var report *os.File
+	if *reportFd >= 0 {
+		// The jailed command must not be able to forge the report.
+		syscall.CloseOnExec(*reportFd)
+		report = os.NewFile(uintptr(*reportFd), "report")
+	}
+
+	// Outside a new PID namespace we are not PID 1, so ask to inherit orphans anyway.
+	if os.Getpid() != 1 {
+		syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0)
+	}
+
+	path := argv[0]
+	if !strings.Contains(path, "/") {
+		if lp, err := exec.LookPath(path); err == nil {
+			path = lp
+		}
+	}
+
+	sigs := make(chan os.Signal, 16)
+	signal.Notify(sigs)
+
+	start := time.Now()
+	child, err := os.StartProcess(path, argv, &os.ProcAttr{
+		Env:   os.Environ(),
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "nsjail-init: %v\n", err)
+		return 127
+	}
+
+	go func() {
+		for sig := range sigs {
+			// SIGURG is used internally by the Go runtime.
+			if sig == syscall.SIGCHLD || sig == syscall.SIGURG {
+				continue
+			}
+			child.Signal(sig)
+		}
+	}()
+
+	// Round(0) strips the monotonic reading, which is meaningless to the host.
+	rep := Report{Start: start.Round(0)}
+	for {
+		var ws syscall.WaitStatus
+		var ru syscall.Rusage
+		pid, err := syscall.Wait4(-1, &ws, 0, &ru)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "nsjail-init: wait4: %v\n", err)
+			return 127
+		}
+		if pid != child.Pid {
+			rep.Reaped++
+			continue
+		}
+		end := time.Now()
+		rep.End = end.Round(0)
+		rep.Runtime = end.Sub(start)
+		rep.ExitCode = ws.ExitStatus()
+		if ws.Signaled() {
+			rep.Signal = int(ws.Signal())
+		}
+		rep.UserTime = time.Duration(ru.Utime.Nano())
+		rep.SystemTime = time.Duration(ru.Stime.Nano())
+		rep.MaxRSS = int64(ru.Maxrss) * 1024
+		rep.MinorFaults = int64(ru.Minflt)
+		rep.MajorFaults = int64(ru.Majflt)
+		rep.VoluntaryCtxSwitches = int64(ru.Nvcsw)
+		rep.InvoluntaryCtxSwitches = int64(ru.Nivcsw)
+		break
+	}
+
+	if report != nil {
+		json.NewEncoder(report).Encode(rep)
+		report.Close()
+	}
+	if rep.Signal != 0 {
+		return 128 + rep.Signal
+	}
+	return rep.ExitCode
+}
@@ -0,0 +1,68 @@
+package nsjail
+
+import "strings"
+
+// IsolationWarning reports a protection that was requested but that nsjail logged as skipped or failed,
+// e.g. a cgroup limit that could not be set up while the jail kept running.
+type IsolationWarning struct {
+	// Area is the kind of protection affected: "cgroup", "rlimit", "seccomp", "mount", "user",
+	// "capabilities", "network", "personality" or "other".
+	Area string
+	// Message is the nsjail log line without its level, timestamp and source location.
+	Message string
+}
+
+func (w IsolationWarning) String() string { return w.Area + ": " + w.Message }
+
+// isolationAreas classifies log messages by keywords, checked in order.
+var isolationAreas = []struct {
+	area     string
+	keywords []string
+}{
+	{"cgroup", []string{"cgroup"}},
+	{"seccomp", []string{"seccomp", "kafel"}},
+	{"rlimit", []string{"rlimit"}},
+	{"user", []string{"uid_map", "gid_map", "setgroups", "newuidmap", "newgidmap", "setresuid", "setresgid"}},
+	{"capabilities", []string{"cap_", "capab", "keepcaps", "securebits"}},
+	{"mount", []string{"mount", "pivot_root", "chroot", "tmpfs", "symlink"}},
+	{"network", []string{"macvlan", "iface", "loopback", "netlink", "clone_newnet"}},
+	{"personality", []string{"personality", "pr_set_tsc"}},
+}
+
+// CollectIsolationWarnings reads the nsjail log while the jail runs and lists every warning and error nsjail
+// logged about its setup in Result.IsolationWarnings, so callers can refuse to trust results produced under
+// weaker isolation than requested. The log is still copied to stderr. Cannot be combined with WithLogFile
+// or WithLogFd.
+func (n *NsJail) CollectIsolationWarnings() *NsJail { n.isolationWarnings = true; return n.changed() }
+
+// collectIsolationWarnings records warnings from the nsjail log.
+func (j *Jail) collectIsolationWarnings() {
+	j.onLogLine(func(line string) {
+		if w, ok := parseIsolationWarning(line); ok {
+			j.mu.Lock()
+			j.warnings = append(j.warnings, w)
+			j.mu.Unlock()
+		}
+	})
+}
+
+// parseIsolationWarning parses a log line of nsjail like
+// "[W][2024-05-01T10:00:00+0000][42] void cgroup2::setup():120 Could not ...".
+// Only warnings, errors and fatal errors are reported.
+func parseIsolationWarning(line string) (IsolationWarning, bool) {
+	ev := ParseLogLine(line)
+	if ev.Level != "W" && ev.Level != "E" && ev.Level != "F" {
+		return IsolationWarning{}, false
+	}
+	w := IsolationWarning{Area: "other", Message: ev.Message}
+	lower := strings.ToLower(line)
+	for _, a := range isolationAreas {
+		for _, kw := range a.keywords {
+			if strings.Contains(lower, kw) {
+				w.Area = a.area
+				return w, true
+			}
+		}
+	}
+	return w, true
+}
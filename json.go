@@ -0,0 +1,735 @@
+package nsjail
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// jailConfig is the serialized form of an NsJail. The keys follow the names of nsjail's long flags.
+type jailConfig struct {
+	Path         string   `json:"path,omitempty" yaml:"path,omitempty"`
+	PathChecksum string   `json:"path_sha256,omitempty" yaml:"path_sha256,omitempty"`
+	Command      string   `json:"command" yaml:"command"`
+	Args         []string `json:"args,omitempty" yaml:"args,omitempty"`
+
+	Mode       Mode   `json:"mode,omitempty" yaml:"mode,omitempty"`
+	ConfigFile string `json:"config,omitempty" yaml:"config,omitempty"`
+	ExecFile   string `json:"exec_file,omitempty" yaml:"exec_file,omitempty"`
+	ExecuteFd  bool   `json:"execute_fd,omitempty" yaml:"execute_fd,omitempty"`
+
+	Chroot            string   `json:"chroot,omitempty" yaml:"chroot,omitempty"`
+	NoPivotRoot       bool     `json:"no_pivotroot,omitempty" yaml:"no_pivotroot,omitempty"`
+	RWChroot          bool     `json:"rw,omitempty" yaml:"rw,omitempty"`
+	User              string   `json:"user,omitempty" yaml:"user,omitempty"`
+	Group             string   `json:"group,omitempty" yaml:"group,omitempty"`
+	Hostname          string   `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	RandomIdentity    bool     `json:"random_identity,omitempty" yaml:"random_identity,omitempty"`
+	Cwd               string   `json:"cwd,omitempty" yaml:"cwd,omitempty"`
+	KeepEnv           bool     `json:"keep_env,omitempty" yaml:"keep_env,omitempty"`
+	Env               []string `json:"env,omitempty" yaml:"env,omitempty"`
+	InheritEnv        []string `json:"inherit_env,omitempty" yaml:"inherit_env,omitempty"`
+	DenyEnv           []string `json:"deny_env,omitempty" yaml:"deny_env,omitempty"`
+	EnvFiles          []string `json:"env_file,omitempty" yaml:"env_file,omitempty"`
+	KeepCaps          bool     `json:"keep_caps,omitempty" yaml:"keep_caps,omitempty"`
+	Caps              []string `json:"cap,omitempty" yaml:"cap,omitempty"`
+	Silent            bool     `json:"silent,omitempty" yaml:"silent,omitempty"`
+	StderrToNull      bool     `json:"stderr_to_null,omitempty" yaml:"stderr_to_null,omitempty"`
+	SkipSetsid        bool     `json:"skip_setsid,omitempty" yaml:"skip_setsid,omitempty"`
+	PassFds           []int    `json:"pass_fd,omitempty" yaml:"pass_fd,omitempty"`
+	DisableNoNewPrivs bool     `json:"disable_no_new_privs,omitempty" yaml:"disable_no_new_privs,omitempty"`
+
+	DisableCloneNewNet    bool     `json:"disable_clone_newnet,omitempty" yaml:"disable_clone_newnet,omitempty"`
+	DisableCloneNewUser   bool     `json:"disable_clone_newuser,omitempty" yaml:"disable_clone_newuser,omitempty"`
+	DisableCloneNewNs     bool     `json:"disable_clone_newns,omitempty" yaml:"disable_clone_newns,omitempty"`
+	DisableCloneNewPid    bool     `json:"disable_clone_newpid,omitempty" yaml:"disable_clone_newpid,omitempty"`
+	DisableCloneNewIpc    bool     `json:"disable_clone_newipc,omitempty" yaml:"disable_clone_newipc,omitempty"`
+	DisableCloneNewUts    bool     `json:"disable_clone_newuts,omitempty" yaml:"disable_clone_newuts,omitempty"`
+	DisableCloneNewCgroup bool     `json:"disable_clone_newcgroup,omitempty" yaml:"disable_clone_newcgroup,omitempty"`
+	EnableCloneNewTime    bool     `json:"enable_clone_newtime,omitempty" yaml:"enable_clone_newtime,omitempty"`
+	UIDMappings           []string `json:"uid_mapping,omitempty" yaml:"uid_mapping,omitempty"`
+	GIDMappings           []string `json:"gid_mapping,omitempty" yaml:"gid_mapping,omitempty"`
+
+	TimeLimit      uint64                    `json:"time_limit,omitempty" yaml:"time_limit,omitempty"`
+	Deadline       bool                      `json:"deadline,omitempty" yaml:"deadline,omitempty"`
+	MaxCpus        uint                      `json:"max_cpus,omitempty" yaml:"max_cpus,omitempty"`
+	CpuSet         []int                     `json:"cpuset,omitempty" yaml:"cpuset,omitempty"`
+	CpuSetMems     []int                     `json:"cpuset_mems,omitempty" yaml:"cpuset_mems,omitempty"`
+	Rlimits        map[RlimitResource]string `json:"rlimit,omitempty" yaml:"rlimit,omitempty"`
+	DisableRlimits bool                      `json:"disable_rlimits,omitempty" yaml:"disable_rlimits,omitempty"`
+
+	PersonaAddrCompatLayout bool `json:"persona_addr_compat_layout,omitempty" yaml:"persona_addr_compat_layout,omitempty"`
+	PersonaMmapPageZero     bool `json:"persona_mmap_page_zero,omitempty" yaml:"persona_mmap_page_zero,omitempty"`
+	PersonaReadImpliesExec  bool `json:"persona_read_implies_exec,omitempty" yaml:"persona_read_implies_exec,omitempty"`
+	PersonaAddrLimit3gb     bool `json:"persona_addr_limit_3gb,omitempty" yaml:"persona_addr_limit_3gb,omitempty"`
+	PersonaAddrNoRandomize  bool `json:"persona_addr_no_randomize,omitempty" yaml:"persona_addr_no_randomize,omitempty"`
+
+	BindMountsRO []string       `json:"bindmount_ro,omitempty" yaml:"bindmount_ro,omitempty"`
+	BindMountsRW []string       `json:"bindmount,omitempty" yaml:"bindmount,omitempty"`
+	StrictMounts bool           `json:"strict_mounts,omitempty" yaml:"strict_mounts,omitempty"`
+	BinaryDeps   []string       `json:"binary_deps,omitempty" yaml:"binary_deps,omitempty"`
+	Overlay      *overlayConfig `json:"overlay,omitempty" yaml:"overlay,omitempty"`
+	TmpfsMounts  []string       `json:"tmpfsmount,omitempty" yaml:"tmpfsmount,omitempty"`
+	Mounts       []Mount        `json:"mount,omitempty" yaml:"mount,omitempty"`
+	Symlinks     []Symlink      `json:"symlink,omitempty" yaml:"symlink,omitempty"`
+	DisableProc  bool           `json:"disable_proc,omitempty" yaml:"disable_proc,omitempty"`
+	ProcPath     string         `json:"proc_path,omitempty" yaml:"proc_path,omitempty"`
+	ProcRW       bool           `json:"proc_rw,omitempty" yaml:"proc_rw,omitempty"`
+
+	Port          uint16   `json:"port,omitempty" yaml:"port,omitempty"`
+	Bindhost      string   `json:"bindhost,omitempty" yaml:"bindhost,omitempty"`
+	MaxConns      uint     `json:"max_conns,omitempty" yaml:"max_conns,omitempty"`
+	MaxConnsPerIP uint     `json:"max_conns_per_ip,omitempty" yaml:"max_conns_per_ip,omitempty"`
+	IfaceNoLo     bool     `json:"iface_no_lo,omitempty" yaml:"iface_no_lo,omitempty"`
+	IfaceOwn      []string `json:"iface_own,omitempty" yaml:"iface_own,omitempty"`
+
+	MacvlanIface string      `json:"macvlan_iface,omitempty" yaml:"macvlan_iface,omitempty"`
+	MacvlanVsIP  string      `json:"macvlan_vs_ip,omitempty" yaml:"macvlan_vs_ip,omitempty"`
+	MacvlanVsNM  string      `json:"macvlan_vs_nm,omitempty" yaml:"macvlan_vs_nm,omitempty"`
+	MacvlanVsGW  string      `json:"macvlan_vs_gw,omitempty" yaml:"macvlan_vs_gw,omitempty"`
+	MacvlanVsMA  string      `json:"macvlan_vs_ma,omitempty" yaml:"macvlan_vs_ma,omitempty"`
+	MacvlanVsMO  MacVlanMode `json:"macvlan_vs_mo,omitempty" yaml:"macvlan_vs_mo,omitempty"`
+	MacvlanAuto  bool        `json:"macvlan_auto,omitempty" yaml:"macvlan_auto,omitempty"`
+	EgressLimit  uint64      `json:"egress_limit,omitempty" yaml:"egress_limit,omitempty"`
+
+	Veth          *vethConfig          `json:"veth,omitempty" yaml:"veth,omitempty"`
+	Slirp         *slirpConfig         `json:"slirp4netns,omitempty" yaml:"slirp4netns,omitempty"`
+	PortForwards  []string             `json:"port_forward,omitempty" yaml:"port_forward,omitempty"`
+	NetworkPolicy *networkPolicyConfig `json:"network_policy,omitempty" yaml:"network_policy,omitempty"`
+	ResolvConf    *resolvConfConfig    `json:"resolv_conf,omitempty" yaml:"resolv_conf,omitempty"`
+	HostsEntries  []hostsEntryConfig   `json:"hosts_entry,omitempty" yaml:"hosts_entry,omitempty"`
+
+	SeccompPolicy string `json:"seccomp_policy,omitempty" yaml:"seccomp_policy,omitempty"`
+	SeccompString string `json:"seccomp_string,omitempty" yaml:"seccomp_string,omitempty"`
+	SeccompLog    bool   `json:"seccomp_log,omitempty" yaml:"seccomp_log,omitempty"`
+
+	SeccompViolations string `json:"seccomp_violations,omitempty" yaml:"seccomp_violations,omitempty"`
+
+	CgroupMemMax        uint64 `json:"cgroup_mem_max,omitempty" yaml:"cgroup_mem_max,omitempty"`
+	CgroupMemMemswMax   uint64 `json:"cgroup_mem_memsw_max,omitempty" yaml:"cgroup_mem_memsw_max,omitempty"`
+	CgroupMemSwapMax    string `json:"cgroup_mem_swap_max,omitempty" yaml:"cgroup_mem_swap_max,omitempty"`
+	CgroupMemMount      string `json:"cgroup_mem_mount,omitempty" yaml:"cgroup_mem_mount,omitempty"`
+	CgroupMemParent     string `json:"cgroup_mem_parent,omitempty" yaml:"cgroup_mem_parent,omitempty"`
+	CgroupPidsMax       uint   `json:"cgroup_pids_max,omitempty" yaml:"cgroup_pids_max,omitempty"`
+	CgroupPidsMount     string `json:"cgroup_pids_mount,omitempty" yaml:"cgroup_pids_mount,omitempty"`
+	CgroupPidsParent    string `json:"cgroup_pids_parent,omitempty" yaml:"cgroup_pids_parent,omitempty"`
+	CgroupNetClsClassid uint32 `json:"cgroup_net_cls_classid,omitempty" yaml:"cgroup_net_cls_classid,omitempty"`
+	CgroupNetClsMount   string `json:"cgroup_net_cls_mount,omitempty" yaml:"cgroup_net_cls_mount,omitempty"`
+	CgroupNetClsParent  string `json:"cgroup_net_cls_parent,omitempty" yaml:"cgroup_net_cls_parent,omitempty"`
+	CgroupCpuMsPerSec   uint   `json:"cgroup_cpu_ms_per_sec,omitempty" yaml:"cgroup_cpu_ms_per_sec,omitempty"`
+	CgroupCpuMount      string `json:"cgroup_cpu_mount,omitempty" yaml:"cgroup_cpu_mount,omitempty"`
+	CgroupCpuParent     string `json:"cgroup_cpu_parent,omitempty" yaml:"cgroup_cpu_parent,omitempty"`
+	Cgroupv2Mount       string `json:"cgroupv2_mount,omitempty" yaml:"cgroupv2_mount,omitempty"`
+	UseCgroupv2         bool   `json:"use_cgroupv2,omitempty" yaml:"use_cgroupv2,omitempty"`
+	DetectCgroupv2      bool   `json:"detect_cgroupv2,omitempty" yaml:"detect_cgroupv2,omitempty"`
+
+	CgroupV2         *cgroupV2Config `json:"cgroupv2,omitempty" yaml:"cgroupv2,omitempty"`
+	CgroupAuto       bool            `json:"cgroup_auto,omitempty" yaml:"cgroup_auto,omitempty"`
+	CgroupAutoParent bool            `json:"cgroup_auto_parent,omitempty" yaml:"cgroup_auto_parent,omitempty"`
+
+	LogFile        string `json:"log,omitempty" yaml:"log,omitempty"`
+	LogFd          *int   `json:"log_fd,omitempty" yaml:"log_fd,omitempty"`
+	Daemon         bool   `json:"daemon,omitempty" yaml:"daemon,omitempty"`
+	Verbose        bool   `json:"verbose,omitempty" yaml:"verbose,omitempty"`
+	Quiet          bool   `json:"quiet,omitempty" yaml:"quiet,omitempty"`
+	ReallyQuiet    bool   `json:"really_quiet,omitempty" yaml:"really_quiet,omitempty"`
+	Nice           *int   `json:"nice_level,omitempty" yaml:"nice_level,omitempty"`
+	DisableTsc     bool   `json:"disable_tsc,omitempty" yaml:"disable_tsc,omitempty"`
+	ForwardSignals bool   `json:"forward_signals,omitempty" yaml:"forward_signals,omitempty"`
+
+	IsolationWarnings bool `json:"isolation_warnings,omitempty" yaml:"isolation_warnings,omitempty"`
+
+	InitShim       string            `json:"init_shim,omitempty" yaml:"init_shim,omitempty"`
+	Workspace      *workspaceConfig  `json:"workspace,omitempty" yaml:"workspace,omitempty"`
+	FileAccess     *fileAccessConfig `json:"file_access,omitempty" yaml:"file_access,omitempty"`
+	PidFile        string            `json:"pid_file,omitempty" yaml:"pid_file,omitempty"`
+	KillSignal     int               `json:"kill_signal,omitempty" yaml:"kill_signal,omitempty"`
+	KillGraceUs    int64             `json:"kill_grace_us,omitempty" yaml:"kill_grace_us,omitempty"`
+	ConnDeadlineUs int64             `json:"conn_deadline_us,omitempty" yaml:"conn_deadline_us,omitempty"`
+	ExitAfterConns uint              `json:"exit_after_conns,omitempty" yaml:"exit_after_conns,omitempty"`
+	RestartLimit   uint              `json:"restart_limit,omitempty" yaml:"restart_limit,omitempty"`
+
+	StdinDeadlineUs int64         `json:"stdin_deadline_us,omitempty" yaml:"stdin_deadline_us,omitempty"`
+	DrainTimeoutUs  int64         `json:"drain_timeout_us,omitempty" yaml:"drain_timeout_us,omitempty"`
+	StreamBuffering *streamConfig `json:"stream_buffering,omitempty" yaml:"stream_buffering,omitempty"`
+
+	CollectFiles []string          `json:"collect_files,omitempty" yaml:"collect_files,omitempty"`
+	Artifacts    *artifactsConfig  `json:"artifacts,omitempty" yaml:"artifacts,omitempty"`
+	FileLimits   []fileLimitConfig `json:"file_limits,omitempty" yaml:"file_limits,omitempty"`
+	Watchdog     *watchdogConfig   `json:"watchdog,omitempty" yaml:"watchdog,omitempty"`
+	Compat       CompatPolicy      `json:"compat,omitempty" yaml:"compat,omitempty"`
+}
+
+type overlayConfig struct {
+	Lower     string `json:"lower" yaml:"lower"`
+	Upper     string `json:"upper,omitempty" yaml:"upper,omitempty"`
+	Work      string `json:"work,omitempty" yaml:"work,omitempty"`
+	Ephemeral bool   `json:"ephemeral,omitempty" yaml:"ephemeral,omitempty"`
+}
+
+type cgroupV2Config struct {
+	MemoryMax     uint64  `json:"memory_max,omitempty" yaml:"memory_max,omitempty"`
+	MemorySwapMax *uint64 `json:"memory_swap_max,omitempty" yaml:"memory_swap_max,omitempty"`
+	CpuQuotaUs    int64   `json:"cpu_quota_us,omitempty" yaml:"cpu_quota_us,omitempty"`
+	CpuPeriodUs   int64   `json:"cpu_period_us,omitempty" yaml:"cpu_period_us,omitempty"`
+	PidsMax       uint    `json:"pids_max,omitempty" yaml:"pids_max,omitempty"`
+	IoMax         []IoMax `json:"io_max,omitempty" yaml:"io_max,omitempty"`
+}
+
+type vethConfig struct {
+	HostAddr string `json:"host_addr" yaml:"host_addr"`
+	JailAddr string `json:"jail_addr" yaml:"jail_addr"`
+	Iface    string `json:"iface,omitempty" yaml:"iface,omitempty"`
+	NAT      bool   `json:"nat,omitempty" yaml:"nat,omitempty"`
+	MTU      int    `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+}
+
+type slirpConfig struct {
+	Path              string `json:"path,omitempty" yaml:"path,omitempty"`
+	MTU               int    `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+	CIDR              string `json:"cidr,omitempty" yaml:"cidr,omitempty"`
+	AllowHostLoopback bool   `json:"allow_host_loopback,omitempty" yaml:"allow_host_loopback,omitempty"`
+	IPv6              bool   `json:"ipv6,omitempty" yaml:"ipv6,omitempty"`
+	Sandbox           bool   `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
+}
+
+type networkPolicyConfig struct {
+	AllowCIDRs   []string `json:"allow_cidr,omitempty" yaml:"allow_cidr,omitempty"`
+	AllowDomains []string `json:"allow_domain,omitempty" yaml:"allow_domain,omitempty"`
+	Ports        []uint16 `json:"port,omitempty" yaml:"port,omitempty"`
+	AllowDNS     bool     `json:"allow_dns,omitempty" yaml:"allow_dns,omitempty"`
+}
+
+type resolvConfConfig struct {
+	Nameservers []string `json:"nameserver,omitempty" yaml:"nameserver,omitempty"`
+	Search      []string `json:"search,omitempty" yaml:"search,omitempty"`
+}
+
+type hostsEntryConfig struct {
+	Addr  string   `json:"addr" yaml:"addr"`
+	Names []string `json:"names" yaml:"names"`
+}
+
+type workspaceConfig struct {
+	Path       string         `json:"path,omitempty" yaml:"path,omitempty"`
+	Dir        string         `json:"dir,omitempty" yaml:"dir,omitempty"`
+	Size       uint64         `json:"size,omitempty" yaml:"size,omitempty"`
+	Quota      WorkspaceQuota `json:"quota,omitempty" yaml:"quota,omitempty"`
+	Filesystem string         `json:"filesystem,omitempty" yaml:"filesystem,omitempty"`
+	ImageDir   string         `json:"image_dir,omitempty" yaml:"image_dir,omitempty"`
+}
+
+// fileAccessConfig holds the paths the command of WithFileAccessPolicy is checked against. The mounts of
+// the policy are encoded with the others.
+type fileAccessConfig struct {
+	Exec  []string `json:"exec,omitempty" yaml:"exec,omitempty"`
+	Write []string `json:"write,omitempty" yaml:"write,omitempty"`
+}
+
+type artifactsConfig struct {
+	MaxFileSize  int64  `json:"max_file_size,omitempty" yaml:"max_file_size,omitempty"`
+	MaxTotalSize int64  `json:"max_total_size,omitempty" yaml:"max_total_size,omitempty"`
+	Dir          string `json:"dir,omitempty" yaml:"dir,omitempty"`
+}
+
+type fileLimitConfig struct {
+	Dir         string `json:"dir" yaml:"dir"`
+	MaxFiles    int    `json:"max_files,omitempty" yaml:"max_files,omitempty"`
+	MaxFileSize int64  `json:"max_file_size,omitempty" yaml:"max_file_size,omitempty"`
+	IntervalUs  int64  `json:"interval_us,omitempty" yaml:"interval_us,omitempty"`
+	FlagOnly    bool   `json:"flag_only,omitempty" yaml:"flag_only,omitempty"`
+}
+
+type watchdogConfig struct {
+	MaxRSS           uint64 `json:"max_rss,omitempty" yaml:"max_rss,omitempty"`
+	MaxProcesses     int    `json:"max_processes,omitempty" yaml:"max_processes,omitempty"`
+	MaxCPUUs         int64  `json:"max_cpu_us,omitempty" yaml:"max_cpu_us,omitempty"`
+	MaxDiskWrite     uint64 `json:"max_disk_write,omitempty" yaml:"max_disk_write,omitempty"`
+	MaxWorkspaceSize uint64 `json:"max_workspace_size,omitempty" yaml:"max_workspace_size,omitempty"`
+	MaxOutput        uint64 `json:"max_output,omitempty" yaml:"max_output,omitempty"`
+	IntervalUs       int64  `json:"interval_us,omitempty" yaml:"interval_us,omitempty"`
+	FlagOnly         bool   `json:"flag_only,omitempty" yaml:"flag_only,omitempty"`
+}
+
+type streamConfig struct {
+	BufferSize     int          `json:"buffer_size,omitempty" yaml:"buffer_size,omitempty"`
+	Policy         StreamPolicy `json:"policy,omitempty" yaml:"policy,omitempty"`
+	PauseTimeoutUs int64        `json:"pause_timeout_us,omitempty" yaml:"pause_timeout_us,omitempty"`
+	FlushTimeoutUs int64        `json:"flush_timeout_us,omitempty" yaml:"flush_timeout_us,omitempty"`
+}
+
+// prefixString formats p, or returns "" if it is not set.
+func prefixString(p netip.Prefix) string {
+	if !p.IsValid() {
+		return ""
+	}
+	return p.String()
+}
+
+// parsePrefix parses s, the value of key, leaving the prefix unset if s is empty or invalid, which is
+// recorded on j.
+func parsePrefix(j *NsJail, key, s string) netip.Prefix {
+	if s == "" {
+		return netip.Prefix{}
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		j.fail(key, "%v", err)
+	}
+	return p
+}
+
+// opaqueSettings returns the settings of n that hold values of the program, such as callbacks or open
+// files, which the encoding cannot describe.
+func (n *NsJail) opaqueSettings() []string {
+	var settings []string
+	for _, s := range []struct {
+		name string
+		set  bool
+	}{
+		{"WithExtraFile", len(n.extraFiles) > 0},
+		{"WithSecretFd", len(n.secrets) > 0},
+		{"WithWireGuard", n.wireGuard != nil},
+		{"WithDNSInterceptor", n.dns != nil},
+		{"WithHTTPCapture", n.httpCapture != nil},
+		{"WorkspaceOptions.Collect", n.workspace != nil && n.workspace.Collect != nil},
+		{"Watchdog.Check", n.watchdog != nil && n.watchdog.Check != nil},
+		{"WithStdinBytes or WithStdinReader", n.stdinFeed != nil},
+		{"OnLogEvent", len(n.logEvents) > 0},
+		{"WatchDir", len(n.watches) > 0},
+		{"WithExecutor", n.executor != nil},
+		{"WithLogger", n.logger != nil},
+		{"ControlConn", len(n.closeAfterStart) > 0},
+	} {
+		if s.set {
+			settings = append(settings, s.name)
+		}
+	}
+	return settings
+}
+
+// unencodable returns an error naming the settings of n the encoding cannot describe, if any.
+func (n *NsJail) unencodable() error {
+	if settings := n.opaqueSettings(); len(settings) > 0 {
+		return fmt.Errorf("nsjail: %s cannot be encoded", strings.Join(settings, ", "))
+	}
+	return nil
+}
+
+// config returns the serialized form of n.
+func (n *NsJail) config() *jailConfig {
+	c := &jailConfig{
+		Path: n.path, PathChecksum: n.pathChecksum, Command: n.execCmd, Args: n.args,
+		Mode: n.mode, ConfigFile: n.configFile, ExecFile: n.execFile, ExecuteFd: n.executeFd,
+
+		Chroot: n.chroot, NoPivotRoot: n.noPivotRoot, RWChroot: n.rwChroot,
+		User: n.user, Group: n.group, Hostname: n.hostname, RandomIdentity: n.randomIdentity, Cwd: n.cwd,
+		KeepEnv: n.keepEnv, Env: n.envVars, InheritEnv: n.envPatterns,
+		DenyEnv: n.envDeny, EnvFiles: n.envFiles, KeepCaps: n.keepCaps, Caps: n.caps,
+		Silent: n.silent, StderrToNull: n.stderrToNull, SkipSetsid: n.skipSetsid,
+		PassFds: n.passFds, DisableNoNewPrivs: n.disableNoNewPrivs,
+
+		DisableCloneNewNet: n.cloneNewNetDisabled, DisableCloneNewUser: n.cloneNewUserDisabled,
+		DisableCloneNewNs: n.cloneNewNsDisabled, DisableCloneNewPid: n.cloneNewPidDisabled,
+		DisableCloneNewIpc: n.cloneNewIpcDisabled, DisableCloneNewUts: n.cloneNewUtsDisabled,
+		DisableCloneNewCgroup: n.cloneNewCgroupDisabled, EnableCloneNewTime: n.cloneNewTimeEnabled,
+		UIDMappings: n.uidMappings, GIDMappings: n.gidMappings,
+
+		TimeLimit: n.timeLimit, Deadline: n.deadline, MaxCpus: n.maxCpus, DisableRlimits: n.disableRlimits,
+		CpuSet: n.cpuSet, CpuSetMems: n.cpuSetMems,
+
+		PersonaAddrCompatLayout: n.personaAddrCompatLayout, PersonaMmapPageZero: n.personaMmapPageZero,
+		PersonaReadImpliesExec: n.personaReadImpliesExec, PersonaAddrLimit3gb: n.personaAddrLimit3gb,
+		PersonaAddrNoRandomize: n.personaAddrNoRandomize,
+
+		BindMountsRO: n.bindMountsRO, BindMountsRW: n.bindMountsRW, StrictMounts: n.strictMounts,
+		BinaryDeps: n.binaryDeps, TmpfsMounts: n.tmpfsMounts, Mounts: n.mounts, Symlinks: n.symlinks,
+		DisableProc: n.procMountDisabled, ProcPath: n.procPath, ProcRW: n.procRw,
+
+		Port: n.port, Bindhost: n.bindhost, MaxConns: n.maxConns, MaxConnsPerIP: n.maxConnsPerIp,
+		IfaceNoLo: n.ifaceNoLo, IfaceOwn: n.ifaceOwn,
+
+		MacvlanIface: n.macvlanIface, MacvlanVsIP: n.macvlanVsIp, MacvlanVsNM: n.macvlanVsNm,
+		MacvlanVsGW: n.macvlanVsGw, MacvlanVsMA: n.macvlanVsMa, MacvlanVsMO: n.macvlanVsMo,
+		MacvlanAuto: n.macvlanAuto, EgressLimit: n.egressLimit,
+
+		SeccompPolicy: n.seccompPolicy, SeccompString: n.seccompString, SeccompLog: n.seccompLog,
+
+		CgroupMemMax: n.cgroupMemMax, CgroupMemMemswMax: n.cgroupMemMemswMax, CgroupMemSwapMax: n.cgroupMemSwapMax,
+		CgroupMemMount: n.cgroupMemMount, CgroupMemParent: n.cgroupMemParent,
+		CgroupPidsMax: n.cgroupPidsMax, CgroupPidsMount: n.cgroupPidsMount, CgroupPidsParent: n.cgroupPidsParent,
+		CgroupNetClsClassid: n.cgroupNetClsClassid, CgroupNetClsMount: n.cgroupNetClsMount,
+		CgroupNetClsParent: n.cgroupNetClsParent, CgroupCpuMsPerSec: n.cgroupCpuMsPerSec,
+		CgroupCpuMount: n.cgroupCpuMount, CgroupCpuParent: n.cgroupCpuParent,
+		Cgroupv2Mount: n.cgroupv2Mount, UseCgroupv2: n.useCgroupv2, DetectCgroupv2: n.detectCgroupv2,
+
+		LogFile: n.logFile, Daemon: n.daemon, Verbose: n.verbose, Quiet: n.quiet, ReallyQuiet: n.reallyQuiet,
+		DisableTsc: n.disableTsc, ForwardSignals: n.forwardSignals,
+
+		IsolationWarnings: n.isolationWarnings,
+	}
+	for _, res := range []RlimitResource{ResourceAs, ResourceCore, ResourceCpu, ResourceFsize, ResourceNofile,
+		ResourceNproc, ResourceStack, ResourceMemlock, ResourceRtprio, ResourceMsgqueue} {
+		if v := *n.rlimit(res); v != "" {
+			if c.Rlimits == nil {
+				c.Rlimits = make(map[RlimitResource]string)
+			}
+			c.Rlimits[res] = v
+		}
+	}
+	if o := n.overlay; o != nil {
+		c.Overlay = &overlayConfig{Lower: o.lower, Upper: o.upper, Work: o.work, Ephemeral: o.ephemeral}
+	}
+	c.CgroupAuto, c.CgroupAutoParent = n.cgroupAuto, n.cgroupAutoParent
+	if v2 := n.cgroupV2; v2 != nil {
+		c.CgroupV2 = &cgroupV2Config{MemoryMax: v2.memoryMax, MemorySwapMax: v2.memorySwapMax,
+			CpuQuotaUs: v2.cpuQuota.Microseconds(), CpuPeriodUs: v2.cpuPeriod.Microseconds(),
+			PidsMax: v2.pidsMax, IoMax: v2.io}
+	}
+	if n.logFd >= 0 {
+		c.LogFd = &n.logFd
+	}
+	if n.niceLevel != -256 {
+		c.Nice = &n.niceLevel
+	}
+
+	if v := n.veth; v != nil {
+		c.Veth = &vethConfig{HostAddr: prefixString(v.HostAddr), JailAddr: prefixString(v.JailAddr),
+			Iface: v.Iface, NAT: v.NAT, MTU: v.MTU}
+	}
+	if s := n.slirp; s != nil {
+		c.Slirp = &slirpConfig{Path: s.Path, MTU: s.MTU, CIDR: prefixString(s.CIDR),
+			AllowHostLoopback: s.AllowHostLoopback, IPv6: s.IPv6, Sandbox: s.Sandbox}
+	}
+	for _, f := range n.portForwards {
+		c.PortForwards = append(c.PortForwards, fmt.Sprintf("%d:%d", f.hostPort, f.jailPort))
+	}
+	if p := n.netPolicy; p != nil {
+		c.NetworkPolicy = &networkPolicyConfig{AllowDomains: p.AllowDomains, Ports: p.Ports, AllowDNS: p.AllowDNS}
+		for _, cidr := range p.AllowCIDRs {
+			c.NetworkPolicy.AllowCIDRs = append(c.NetworkPolicy.AllowCIDRs, cidr.String())
+		}
+	}
+	if r := n.resolvConf; r != nil {
+		c.ResolvConf = &resolvConfConfig{Nameservers: r.servers, Search: r.search}
+	}
+	for _, e := range n.hostsEntries {
+		c.HostsEntries = append(c.HostsEntries, hostsEntryConfig{Addr: e.Addr.String(), Names: e.Names})
+	}
+	c.SeccompViolations = n.seccompAudit
+
+	c.InitShim, c.PidFile = n.initShim, n.pidFile
+	if w := n.workspace; w != nil {
+		c.Workspace = &workspaceConfig{Path: w.Path, Dir: w.Dir, Size: w.Size, Quota: w.Quota,
+			Filesystem: w.Filesystem, ImageDir: w.ImageDir}
+	}
+	if f := n.fileAccess; f != nil {
+		c.FileAccess = &fileAccessConfig{Exec: f.Exec, Write: f.Write}
+	}
+	c.KillSignal, c.KillGraceUs = int(n.killSignal), n.killGrace.Microseconds()
+	c.ConnDeadlineUs, c.ExitAfterConns = n.connDeadline.Microseconds(), n.exitAfterConns
+	c.RestartLimit = n.restartLimit
+	c.StdinDeadlineUs, c.DrainTimeoutUs = n.stdinDeadline.Microseconds(), n.drainTimeout.Microseconds()
+	if b := n.streamBuffering; b != nil {
+		c.StreamBuffering = &streamConfig{BufferSize: b.BufferSize, Policy: b.Policy,
+			PauseTimeoutUs: b.PauseTimeout.Microseconds(), FlushTimeoutUs: b.FlushTimeout.Microseconds()}
+	}
+	c.CollectFiles = n.artifactPatterns
+	if a := n.artifactOptions; a != (ArtifactOptions{}) {
+		c.Artifacts = &artifactsConfig{MaxFileSize: a.MaxFileSize, MaxTotalSize: a.MaxTotalSize, Dir: a.Dir}
+	}
+	for _, l := range n.fileLimits {
+		c.FileLimits = append(c.FileLimits, fileLimitConfig{Dir: l.dir, MaxFiles: l.MaxFiles,
+			MaxFileSize: l.MaxFileSize, IntervalUs: l.Interval.Microseconds(), FlagOnly: l.FlagOnly})
+	}
+	if w := n.watchdog; w != nil {
+		c.Watchdog = &watchdogConfig{MaxRSS: w.MaxRSS, MaxProcesses: w.MaxProcesses,
+			MaxCPUUs: w.MaxCPU.Microseconds(), MaxDiskWrite: w.MaxDiskWrite, MaxWorkspaceSize: w.MaxWorkspaceSize,
+			MaxOutput: w.MaxOutput, IntervalUs: w.Interval.Microseconds(), FlagOnly: w.FlagOnly}
+	}
+	c.Compat = n.compat
+	return c
+}
+
+// apply replaces the encoded settings of n with those of c, keeping the settings the encoding leaves out,
+// such as stdio and callbacks. Values that builder methods check are set through them, so invalid ones
+// are recorded like invalid arguments and reported by Validate.
+func (n *NsJail) apply(c *jailConfig) {
+	j := newBare(c.Command, c.Args)
+	if c.PathChecksum != "" {
+		j.WithPathChecksum(cmp.Or(c.Path, j.path), c.PathChecksum)
+	} else if c.Path != "" {
+		j.path = c.Path
+	}
+	if c.Mode != "" {
+		j.WithMode(c.Mode)
+	}
+	j.configFile, j.execFile, j.executeFd = c.ConfigFile, c.ExecFile, c.ExecuteFd
+
+	j.chroot, j.noPivotRoot, j.rwChroot = c.Chroot, c.NoPivotRoot, c.RWChroot
+	j.user, j.group, j.hostname, j.cwd = c.User, c.Group, c.Hostname, c.Cwd
+	j.randomIdentity = c.RandomIdentity
+	j.keepEnv, j.keepCaps = c.KeepEnv, c.KeepCaps
+	for _, e := range c.Env {
+		if key, _, _ := strings.Cut(e, "="); key == "" || strings.ContainsRune(key, 0) {
+			j.fail("env", "invalid variable name %q", key)
+			continue
+		}
+		j.envVars = append(j.envVars, e)
+	}
+	j.envPatterns, j.envDeny, j.envFiles = c.InheritEnv, c.DenyEnv, c.EnvFiles
+	for _, c := range c.Caps {
+		j.AddCap(Capability(c))
+	}
+	j.silent, j.stderrToNull, j.skipSetsid = c.Silent, c.StderrToNull, c.SkipSetsid
+	for _, fd := range c.PassFds {
+		j.AddPassFd(fd)
+	}
+	j.disableNoNewPrivs = c.DisableNoNewPrivs
+
+	j.cloneNewNetDisabled, j.cloneNewUserDisabled = c.DisableCloneNewNet, c.DisableCloneNewUser
+	j.cloneNewNsDisabled, j.cloneNewPidDisabled = c.DisableCloneNewNs, c.DisableCloneNewPid
+	j.cloneNewIpcDisabled, j.cloneNewUtsDisabled = c.DisableCloneNewIpc, c.DisableCloneNewUts
+	j.cloneNewCgroupDisabled, j.cloneNewTimeEnabled = c.DisableCloneNewCgroup, c.EnableCloneNewTime
+	for _, m := range c.UIDMappings {
+		j.AddUidMapping(m)
+	}
+	for _, m := range c.GIDMappings {
+		j.AddGidMapping(m)
+	}
+
+	j.timeLimit, j.deadline, j.maxCpus, j.disableRlimits = c.TimeLimit, c.Deadline, c.MaxCpus, c.DisableRlimits
+	if len(c.CpuSet) > 0 {
+		j.WithCpuSet(c.CpuSet)
+	}
+	if len(c.CpuSetMems) > 0 {
+		j.WithCpuSetMems(c.CpuSetMems)
+	}
+	for _, res := range slices.Sorted(maps.Keys(c.Rlimits)) {
+		p := j.rlimit(res)
+		if p == nil {
+			j.fail("rlimit", "unknown resource %q", res)
+			continue
+		}
+		j.setRlimit("rlimit."+string(res), p, c.Rlimits[res])
+	}
+
+	j.personaAddrCompatLayout, j.personaMmapPageZero = c.PersonaAddrCompatLayout, c.PersonaMmapPageZero
+	j.personaReadImpliesExec, j.personaAddrLimit3gb = c.PersonaReadImpliesExec, c.PersonaAddrLimit3gb
+	j.personaAddrNoRandomize = c.PersonaAddrNoRandomize
+
+	for _, m := range c.BindMountsRO {
+		j.AddBindMountRO(m)
+	}
+	for _, m := range c.BindMountsRW {
+		j.AddBindMountRW(m)
+	}
+	j.strictMounts, j.binaryDeps = c.StrictMounts, c.BinaryDeps
+	for _, m := range c.TmpfsMounts {
+		j.AddTmpfsMount(m)
+	}
+	for _, m := range c.Mounts {
+		j.AddMount(m.Src, m.Dst, m.FsType, m.Opts)
+	}
+	for _, l := range c.Symlinks {
+		j.AddSymlink(l.Src, l.Dst)
+	}
+	j.procMountDisabled, j.procPath, j.procRw = c.DisableProc, c.ProcPath, c.ProcRW
+	if o := c.Overlay; o != nil {
+		j.overlay = &overlay{lower: o.Lower, upper: o.Upper, work: o.Work, ephemeral: o.Ephemeral}
+	}
+
+	j.port, j.bindhost, j.maxConns, j.maxConnsPerIp = c.Port, c.Bindhost, c.MaxConns, c.MaxConnsPerIP
+	j.ifaceNoLo, j.ifaceOwn = c.IfaceNoLo, c.IfaceOwn
+
+	j.macvlanIface, j.macvlanVsIp, j.macvlanVsNm = c.MacvlanIface, c.MacvlanVsIP, c.MacvlanVsNM
+	j.macvlanVsGw, j.macvlanVsMa, j.macvlanVsMo = c.MacvlanVsGW, c.MacvlanVsMA, c.MacvlanVsMO
+	j.macvlanAuto, j.egressLimit = c.MacvlanAuto, c.EgressLimit
+	applyNetwork(j, c)
+
+	j.seccompPolicy, j.seccompString, j.seccompLog = c.SeccompPolicy, c.SeccompString, c.SeccompLog
+	j.seccompAudit = c.SeccompViolations
+
+	j.cgroupMemMax, j.cgroupMemMemswMax, j.cgroupMemSwapMax = c.CgroupMemMax, c.CgroupMemMemswMax, c.CgroupMemSwapMax
+	j.cgroupMemMount, j.cgroupMemParent = c.CgroupMemMount, c.CgroupMemParent
+	j.cgroupPidsMax, j.cgroupPidsMount, j.cgroupPidsParent = c.CgroupPidsMax, c.CgroupPidsMount, c.CgroupPidsParent
+	j.cgroupNetClsClassid, j.cgroupNetClsMount, j.cgroupNetClsParent = c.CgroupNetClsClassid, c.CgroupNetClsMount, c.CgroupNetClsParent
+	j.cgroupCpuMsPerSec, j.cgroupCpuMount, j.cgroupCpuParent = c.CgroupCpuMsPerSec, c.CgroupCpuMount, c.CgroupCpuParent
+	j.cgroupv2Mount, j.useCgroupv2, j.detectCgroupv2 = c.Cgroupv2Mount, c.UseCgroupv2, c.DetectCgroupv2
+	j.cgroupAuto, j.cgroupAutoParent = c.CgroupAuto, c.CgroupAutoParent
+	if v2 := c.CgroupV2; v2 != nil {
+		l := j.cgroupV2Limits()
+		l.memoryMax, l.memorySwapMax, l.pidsMax = v2.MemoryMax, v2.MemorySwapMax, v2.PidsMax
+		j.WithCgroupV2CpuMax(time.Duration(v2.CpuQuotaUs)*time.Microsecond,
+			time.Duration(v2.CpuPeriodUs)*time.Microsecond)
+		for _, io := range v2.IoMax {
+			j.AddCgroupV2IoMax(io)
+		}
+	}
+
+	j.logFile, j.daemon, j.verbose, j.quiet, j.reallyQuiet = c.LogFile, c.Daemon, c.Verbose, c.Quiet, c.ReallyQuiet
+	j.disableTsc, j.forwardSignals = c.DisableTsc, c.ForwardSignals
+	j.isolationWarnings = c.IsolationWarnings
+	if c.LogFd != nil {
+		j.WithLogFd(*c.LogFd)
+	}
+	if c.Nice != nil {
+		j.WithNiceLevel(*c.Nice)
+	}
+
+	j.initShim, j.pidFile = c.InitShim, c.PidFile
+	if w := c.Workspace; w != nil {
+		j.WithWorkspace(WorkspaceOptions{Path: w.Path, Dir: w.Dir, Size: w.Size, Quota: w.Quota,
+			Filesystem: w.Filesystem, ImageDir: w.ImageDir})
+	}
+	if f := c.FileAccess; f != nil {
+		j.fileAccess = &FileAccessPolicy{Exec: f.Exec, Write: f.Write}
+	}
+	if c.KillSignal != 0 || c.KillGraceUs != 0 {
+		j.WithKillSignal(syscall.Signal(c.KillSignal), time.Duration(c.KillGraceUs)*time.Microsecond)
+	}
+	j.connDeadline, j.exitAfterConns = time.Duration(c.ConnDeadlineUs)*time.Microsecond, c.ExitAfterConns
+	j.restartLimit = c.RestartLimit
+	j.stdinDeadline = time.Duration(c.StdinDeadlineUs) * time.Microsecond
+	j.drainTimeout = time.Duration(c.DrainTimeoutUs) * time.Microsecond
+	if b := c.StreamBuffering; b != nil {
+		j.WithStreamBuffering(StreamConfig{BufferSize: b.BufferSize, Policy: b.Policy,
+			PauseTimeout: time.Duration(b.PauseTimeoutUs) * time.Microsecond,
+			FlushTimeout: time.Duration(b.FlushTimeoutUs) * time.Microsecond})
+	}
+	j.artifactPatterns = c.CollectFiles
+	if a := c.Artifacts; a != nil {
+		j.WithArtifactOptions(ArtifactOptions{MaxFileSize: a.MaxFileSize, MaxTotalSize: a.MaxTotalSize, Dir: a.Dir})
+	}
+	for _, l := range c.FileLimits {
+		j.WithFileLimits(l.Dir, FileLimits{MaxFiles: l.MaxFiles, MaxFileSize: l.MaxFileSize,
+			Interval: time.Duration(l.IntervalUs) * time.Microsecond, FlagOnly: l.FlagOnly})
+	}
+	if w := c.Watchdog; w != nil {
+		j.WithWatchdog(Watchdog{MaxRSS: w.MaxRSS, MaxProcesses: w.MaxProcesses,
+			MaxCPU: time.Duration(w.MaxCPUUs) * time.Microsecond, MaxDiskWrite: w.MaxDiskWrite,
+			MaxWorkspaceSize: w.MaxWorkspaceSize, MaxOutput: w.MaxOutput,
+			Interval: time.Duration(w.IntervalUs) * time.Microsecond, FlagOnly: w.FlagOnly})
+	}
+	j.WithCompatibility(c.Compat)
+
+	j.keepOpaque(n)
+	*n = *j
+}
+
+// keepOpaque copies the settings of n the encoding leaves out to j, see opaqueSettings.
+func (j *NsJail) keepOpaque(n *NsJail) {
+	j.extraFiles, j.closeAfterStart, j.secrets = n.extraFiles, n.closeAfterStart, n.secrets
+	j.wireGuard, j.dns, j.httpCapture = n.wireGuard, n.dns, n.httpCapture
+	if j.workspace != nil && n.workspace != nil {
+		j.workspace.Collect = n.workspace.Collect
+	}
+	if j.watchdog != nil && n.watchdog != nil {
+		j.watchdog.Check = n.watchdog.Check
+	}
+	j.stdin, j.stdout, j.stderr, j.stdinFeed, j.connFile = n.stdin, n.stdout, n.stderr, n.stdinFeed, n.connFile
+	j.watches, j.logEvents, j.logger, j.executor = n.watches, n.logEvents, n.logger, n.executor
+	j.dryRun, j.binaryCaps, j.sessionAgent, j.runID = n.dryRun, n.binaryCaps, n.sessionAgent, n.runID
+}
+
+// applyNetwork sets the veth, slirp4netns, port forwards, network policy, resolv.conf and hosts entries of
+// c on j, parsing their addresses.
+func applyNetwork(j *NsJail, c *jailConfig) {
+	if v := c.Veth; v != nil {
+		host := parsePrefix(j, "veth.host_addr", v.HostAddr)
+		jail := parsePrefix(j, "veth.jail_addr", v.JailAddr)
+		j.WithVeth(VethConfig{HostAddr: host, JailAddr: jail, Iface: v.Iface, NAT: v.NAT, MTU: v.MTU})
+	}
+	if s := c.Slirp; s != nil {
+		j.WithSlirp4netns(SlirpConfig{Path: s.Path, MTU: s.MTU, CIDR: parsePrefix(j, "slirp4netns.cidr", s.CIDR),
+			AllowHostLoopback: s.AllowHostLoopback, IPv6: s.IPv6, Sandbox: s.Sandbox})
+	}
+	for _, f := range c.PortForwards {
+		host, jail, ok := strings.Cut(f, ":")
+		hostPort, err1 := strconv.ParseUint(host, 10, 16)
+		jailPort, err2 := strconv.ParseUint(jail, 10, 16)
+		if !ok || errors.Join(err1, err2) != nil {
+			j.fail("port_forward", "%q is not host_port:jail_port", f)
+			continue
+		}
+		j.ForwardPort(uint16(hostPort), uint16(jailPort))
+	}
+	if p := c.NetworkPolicy; p != nil {
+		policy := NetworkPolicy{AllowDomains: p.AllowDomains, Ports: p.Ports, AllowDNS: p.AllowDNS}
+		for _, s := range p.AllowCIDRs {
+			if cidr := parsePrefix(j, "network_policy.allow_cidr", s); cidr.IsValid() {
+				policy.AllowCIDRs = append(policy.AllowCIDRs, cidr)
+			}
+		}
+		j.WithNetworkPolicy(policy)
+	}
+	if r := c.ResolvConf; r != nil {
+		j.WithResolvConf(r.Nameservers, r.Search)
+	}
+	for _, e := range c.HostsEntries {
+		addr, err := netip.ParseAddr(e.Addr)
+		if err != nil {
+			j.fail("hosts_entry", "%v", err)
+			continue
+		}
+		j.WithHostsEntries(HostsEntry{Addr: addr, Names: e.Names})
+	}
+}
+
+// MarshalJSON encodes the configuration with keys named after nsjail's long flags, e.g.
+// {"command": "/bin/sh", "chroot": "/srv/root", "rlimit": {"as": "512"}}, and the settings Start adds, such
+// as "workspace", "veth" or "network_policy", with durations in microseconds. The stdio set with WithStdio
+// is left out. Settings that hold values of the program, i.e. WithExtraFile, WithSecretFd, WithWireGuard,
+// WithDNSInterceptor, WithHTTPCapture, WorkspaceOptions.Collect, Watchdog.Check, WithStdinBytes,
+// WithStdinReader, OnLogEvent, WatchDir, WithExecutor, WithLogger and ControlConn, make it fail rather than
+// be dropped.
+func (n *NsJail) MarshalJSON() ([]byte, error) {
+	if err := n.unencodable(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(n.config())
+}
+
+// UnmarshalJSON replaces the configuration with the one encoded by MarshalJSON, keeping the settings the
+// encoding leaves out. Keys missing from the encoding keep the values set with SetDefaults. Unknown keys
+// are rejected, so typos in hand-written configurations are not silently ignored, and values are checked
+// like the arguments of the builder methods setting them, returning what Validate reports.
+func (n *NsJail) UnmarshalJSON(data []byte) error {
+	c := New("").Clone().config()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("nsjail: decoding configuration: %w", err)
+	}
+	n.apply(c)
+	return n.Validate()
+}
+
+// MarshalYAML encodes the configuration like MarshalJSON for YAML libraries that support the
+// yaml.Marshaler interface.
+func (n *NsJail) MarshalYAML() (any, error) {
+	if err := n.unencodable(); err != nil {
+		return nil, err
+	}
+	return n.config(), nil
+}
+
+// UnmarshalYAML decodes the configuration like UnmarshalJSON for YAML libraries that support the
+// yaml.v2-style yaml.Unmarshaler interface.
+func (n *NsJail) UnmarshalYAML(unmarshal func(any) error) error {
+	c := New("").Clone().config()
+	if err := unmarshal(c); err != nil {
+		return err
+	}
+	n.apply(c)
+	return n.Validate()
+}
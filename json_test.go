@@ -0,0 +1,117 @@
+package nsjail
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		jail *NsJail
+	}{
+		{"defaults", New("/bin/true")},
+		{"args", New("/bin/echo", "-x", "--", "--flag=1")},
+		{"filesystem", New("/bin/sh").WithChroot("/srv/root").AddBindMountRO("/lib").AddBindMountRW("/tmp:/work").
+			AddTmpfsMount("/run").AddMount("none", "/dev/shm", "tmpfs", "size=1M").AddSymlink("/bin", "/usr/bin")},
+		{"limits", New("/bin/sh").WithTimeLimit(10).WithRlimitAs("512").WithRlimitVal(ResourceNofile, RlimitMax).
+			WithNiceLevel(5).WithCgroupV2CpuMax(time.Second/2, time.Second)},
+		{"identity", New("/bin/sh").WithHostname("box").AddEnv("A", "1").AddEnv("B", "").
+			AddUidMapping("0:1000:1").AddCap(CapNetBindService).WithMode(ModeExecve)},
+		{"run settings", New("/bin/sh").WithStdinDeadline(time.Second).WithDrainTimeout(-1).WithRestartLimit(3).
+			WithStreamBuffering(StreamConfig{BufferSize: 4096, Policy: Pause, PauseTimeout: time.Millisecond})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := tt.jail.Args()
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+			data, err := json.Marshal(tt.jail)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var got NsJail
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal(%s): %v", data, err)
+			}
+			args, err := got.Args()
+			if err != nil {
+				t.Fatalf("Args after round trip: %v", err)
+			}
+			if !slices.Equal(args, want) {
+				t.Errorf("Args after round trip = %q, want %q", args, want)
+			}
+			again, err := json.Marshal(&got)
+			if err != nil {
+				t.Fatalf("Marshal after round trip: %v", err)
+			}
+			if string(again) != string(data) {
+				t.Errorf("Marshal after round trip = %s, want %s", again, data)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"mode", `{"command": "/bin/true", "mode": "zz"}`, "WithMode"},
+		{"rlimit", `{"command": "/bin/true", "rlimit": {"as": "lots"}}`, "rlimit.as"},
+		{"rlimit resource", `{"command": "/bin/true", "rlimit": {"bogus": "1"}}`, "unknown resource"},
+		{"nice level", `{"command": "/bin/true", "nice_level": 99}`, "WithNiceLevel"},
+		{"env", `{"command": "/bin/true", "env": ["=x"]}`, "invalid variable name"},
+		{"bind mount", `{"command": "/bin/true", "bindmount_ro": [":/x"]}`, "AddBindMountRO"},
+		{"uid mapping", `{"command": "/bin/true", "uid_mapping": ["a b c"]}`, "AddUidMapping"},
+		{"port forward", `{"command": "/bin/true", "port_forward": ["80:0"]}`, "ForwardPort"},
+		{"unknown key", `{"command": "/bin/true", "chrooot": "/"}`, "unknown field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n NsJail
+			err := json.Unmarshal([]byte(tt.data), &n)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Unmarshal(%s) = %v, want an error containing %q", tt.data, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONKeepsPlumbing(t *testing.T) {
+	n := New("/bin/true").WithStdio(nil, io.Discard, io.Discard)
+	if err := json.Unmarshal([]byte(`{"command": "/bin/echo", "hostname": "box"}`), n); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if n.stdout != io.Discard || n.stderr != io.Discard {
+		t.Errorf("Unmarshal dropped the stdio set with WithStdio")
+	}
+	if n.execCmd != "/bin/echo" || n.hostname != "box" {
+		t.Errorf("Unmarshal set command %q, hostname %q", n.execCmd, n.hostname)
+	}
+}
+
+func TestMarshalJSONOpaque(t *testing.T) {
+	tests := []struct {
+		name string
+		jail *NsJail
+	}{
+		{"stdin bytes", New("/bin/cat").WithStdinBytes([]byte("x"))},
+		{"logger", New("/bin/true").WithLogger(slog.Default())},
+		{"watch", New("/bin/true").WatchDir("/tmp", func(FileEvent) error { return nil })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := json.Marshal(tt.jail); err == nil || !strings.Contains(err.Error(), "cannot be encoded") {
+				t.Errorf("Marshal = %v, want an error naming the setting", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package nsjail
+
+import "slices"
+
+// KernelFeatures lists the optional kernel features that options of nsjail depend on, see KernelSupport.
+type KernelFeatures struct {
+	// Release is the kernel release, e.g. "6.8.0-45-generic", or "" if it is unknown.
+	Release string
+	// TimeNamespace reports time namespaces (Linux 5.6), needed by EnableCloneNewTime.
+	TimeNamespace bool
+	// SeccompLog reports the SECCOMP_RET_LOG action (Linux 4.14), needed by EnableSeccompLog.
+	SeccompLog bool
+	// CgroupV2Controllers lists the controllers of the cgroup v2 hierarchy, e.g. "cpu", "memory" and "pids",
+	// or is empty without one. Whether they are delegated to the user is checked by Preflight.
+	CgroupV2Controllers []string
+	// DisableTsc reports whether reading the time stamp counter can be disabled (x86 only), needed by
+	// DisableTsc.
+	DisableTsc bool
+}
+
+// HasCgroupV2Controller reports whether the cgroup v2 controller name is available.
+func (k *KernelFeatures) HasCgroupV2Controller(name string) bool {
+	return slices.Contains(k.CgroupV2Controllers, name)
+}
+
+// KernelSupport probes the running kernel for optional features, so callers enable options such as
+// EnableCloneNewTime and DisableTsc only where they work, instead of having nsjail fail to start the jail.
+// It needs no privileges. On other systems than Linux no feature is reported.
+func KernelSupport() *KernelFeatures {
+	return kernelSupport()
+}
@@ -0,0 +1,38 @@
+package nsjail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func kernelSupport() *KernelFeatures {
+	k := &KernelFeatures{}
+	var uts unix.Utsname
+	if unix.Uname(&uts) == nil {
+		k.Release = unix.ByteSliceToString(uts.Release[:])
+	}
+	_, err := os.Stat("/proc/self/ns/time")
+	k.TimeNamespace = err == nil
+	k.SeccompLog = seccompActionAvail(unix.SECCOMP_RET_LOG, "log")
+	if data, err := os.ReadFile(filepath.Join(cgroup2Root(), "cgroup.controllers")); err == nil {
+		k.CgroupV2Controllers = strings.Fields(string(data))
+	}
+	// PR_GET_TSC fails with EINVAL on architectures without PR_SET_TSC.
+	var tsc int32
+	k.DisableTsc = unix.Prctl(unix.PR_GET_TSC, uintptr(unsafe.Pointer(&tsc)), 0, 0, 0) == nil
+	return k
+}
+
+// seccompActionAvail reports whether the kernel supports the seccomp filter return action, listed in
+// /proc/sys/kernel/seccomp/actions_avail as name.
+func seccompActionAvail(action uint32, name string) bool {
+	if actions, err := readSysctl("kernel/seccomp/actions_avail"); err == nil {
+		return strings.Contains(" "+actions+" ", " "+name+" ")
+	}
+	_, _, errno := unix.Syscall(unix.SYS_SECCOMP, unix.SECCOMP_GET_ACTION_AVAIL, 0, uintptr(unsafe.Pointer(&action)))
+	return errno == 0
+}
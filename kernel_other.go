@@ -0,0 +1,5 @@
+//go:build !linux
+
+package nsjail
+
+func kernelSupport() *KernelFeatures { return &KernelFeatures{} }
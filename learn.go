@@ -0,0 +1,164 @@
+package nsjail
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultLearnRounds bounds the runs of LearnSeccompPolicy unless LearnOptions.MaxRounds is set.
+const defaultLearnRounds = 20
+
+// LearnOptions configures LearnSeccompPolicy.
+type LearnOptions struct {
+	// Source is the audit log the syscalls are read from, see CollectSeccompViolations. Defaults to
+	// DefaultSeccompAuditSource.
+	Source string
+	// MaxRounds bounds the number of runs. Defaults to 20.
+	MaxRounds int
+}
+
+// LearnedPolicy is the seccomp policy learned by LearnSeccompPolicy.
+type LearnedPolicy struct {
+	// Syscalls lists the names of the syscalls the workload used, sorted.
+	Syscalls []string
+	// Unnamed lists the numbers of the syscalls used that have no known name, sorted.
+	Unnamed []int
+	// Rounds is the number of runs it took.
+	Rounds int
+	// Complete reports whether the last run used no new syscall. If it did, MaxRounds ran out and the
+	// policy may lack syscalls.
+	Complete bool
+	// Result is the result of the last run.
+	Result *Result
+}
+
+// Kafel returns the policy in Kafel syntax: an allowlist of the learned syscalls, with defaultAction for
+// every other syscall, e.g. "KILL", "ERRNO(1)" or "LOG". An empty defaultAction means "KILL". The policy
+// is ready for WithSeccompString.
+func (p *LearnedPolicy) Kafel(defaultAction string) string {
+	return kafelAllowlist(p.Syscalls, p.Unnamed, cmp.Or(defaultAction, "KILL"))
+}
+
+// kafelAllowlist returns a Kafel policy allowing the syscalls by name and number.
+func kafelAllowlist(names []string, numbers []int, defaultAction string) string {
+	entries := slices.Clone(names)
+	for _, nr := range numbers {
+		entries = append(entries, fmt.Sprintf("SYSCALL[%d]", nr))
+	}
+	var b strings.Builder
+	if len(entries) > 0 {
+		b.WriteString("ALLOW {\n")
+		for i, e := range entries {
+			if i%8 == 0 {
+				b.WriteString("\t")
+			}
+			b.WriteString(e)
+			switch {
+			case i == len(entries)-1:
+				b.WriteString("\n")
+			case i%8 == 7:
+				b.WriteString(",\n")
+			default:
+				b.WriteString(", ")
+			}
+		}
+		b.WriteString("}\n")
+	}
+	b.WriteString("DEFAULT " + defaultAction)
+	return b.String()
+}
+
+// LearnSeccompPolicy runs the workload of n under a permissive seccomp policy that logs every syscall,
+// reads the syscalls used from the audit log (see CollectSeccompViolations), and returns them as a minimal
+// allowlist, so a policy does not have to be written by hand:
+//
+//	learned, err := n.LearnSeccompPolicy(ctx, nsjail.LearnOptions{})
+//	n.WithSeccompString(learned.Kafel("KILL"))
+//
+// The kernel rate-limits audit records written to the kernel log, by default to 10 every 5 seconds
+// (kernel.printk_ratelimit_burst and kernel.printk_ratelimit), so the workload is run in rounds, each
+// allowing the syscalls learned so far and logging the others, until a round uses no new syscall. Rounds
+// reading the kernel log are that interval apart; auditd's log is not rate-limited. The workload must
+// therefore be repeatable, and should exercise every code path the policy has to allow. Only syscalls of
+// the native ABI are learned. Any seccomp policy of n is replaced for the runs. Requires Linux and a
+// readable audit log.
+func (n *NsJail) LearnSeccompPolicy(ctx context.Context, opts LearnOptions) (*LearnedPolicy, error) {
+	arch, ok := map[string]string{"amd64": "x86_64", "arm64": "aarch64"}[runtime.GOARCH]
+	if !ok {
+		return nil, fmt.Errorf("nsjail: learning seccomp policies is not supported on %s", runtime.GOARCH)
+	}
+	rounds := opts.MaxRounds
+	if rounds <= 0 {
+		rounds = defaultLearnRounds
+	}
+	var interval time.Duration
+	if cmp.Or(opts.Source, DefaultSeccompAuditSource) == DefaultSeccompAuditSource {
+		interval = printkRateLimitInterval()
+	}
+	names := make(map[string]bool)
+	numbers := make(map[int]bool)
+	p := &LearnedPolicy{}
+	for p.Rounds < rounds {
+		if p.Rounds > 0 && interval > 0 {
+			// Let the rate limit of the kernel log recover, so the records of the round are not dropped.
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+		p.Rounds++
+		c := n.Clone().CollectSeccompViolations(opts.Source)
+		c.seccompPolicy = ""
+		c.seccompString = kafelAllowlist(slices.Sorted(maps.Keys(names)), slices.Sorted(maps.Keys(numbers)), "LOG")
+		r, err := c.Run(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.Result = r
+		learned := 0
+		for _, v := range r.SeccompViolations {
+			if v.Action != SeccompLogged || v.Arch != arch {
+				continue
+			}
+			if name := v.SyscallName(); name != "" && !names[name] {
+				names[name] = true
+				learned++
+			} else if name == "" && !numbers[v.Syscall] {
+				numbers[v.Syscall] = true
+				learned++
+			}
+		}
+		if learned == 0 {
+			p.Complete = true
+			break
+		}
+	}
+	if len(names) == 0 && len(numbers) == 0 {
+		return nil, errors.New("nsjail: no syscalls of the jail were found in the audit log")
+	}
+	p.Syscalls = slices.Sorted(maps.Keys(names))
+	p.Unnamed = slices.Sorted(maps.Keys(numbers))
+	return p, nil
+}
+
+// printkRateLimitInterval returns the interval in which the kernel log takes a burst of records.
+func printkRateLimitInterval() time.Duration {
+	value, err := readSysctl("kernel/printk_ratelimit")
+	if err != nil {
+		return 5 * time.Second
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return 5 * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,121 @@
+package nsjail
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrConnectionLimit is recorded in Result.Aborted when a listening jail is shut down by ExitAfterConnections.
+var ErrConnectionLimit = errors.New("nsjail: served connection limit reached")
+
+// WithConnectionTimeLimit sets the time limit of each connection's process in ModeListenTCP (-t),
+// rounded up to whole seconds. nsjail checks it about once per second; see WithConnectionDeadline.
+func (n *NsJail) WithConnectionTimeLimit(d time.Duration) *NsJail {
+	n.timeLimit = uint64((max(d, 0) + time.Second - 1) / time.Second)
+	return n.changed()
+}
+
+// WithConnectionDeadline kills each connection's process in ModeListenTCP once it has run for d.
+// Unlike WithConnectionTimeLimit it is enforced by the wrapper, with sub-second precision.
+func (n *NsJail) WithConnectionDeadline(d time.Duration) *NsJail {
+	n.connDeadline = d
+	return n.changed()
+}
+
+// ExitAfterConnections shuts a jail in ModeListenTCP down after count connections, e.g. to rotate instances
+// under steady traffic. Once nsjail logged the count-th connection it is stopped with SIGSTOP, so it
+// accepts no more, and killed as soon as the connections in flight finished, or after the connection
+// deadline (WithConnectionDeadline) or else the time limit of a connection, whose processes are then killed
+// too. Connections made after the count-th wait in the listen backlog and are reset when nsjail is killed.
+// Result.Aborted is ErrConnectionLimit.
+func (n *NsJail) ExitAfterConnections(count uint) *NsJail {
+	n.exitAfterConns = count
+	return n.changed()
+}
+
+// countConnections tracks connections from the nsjail log and shuts the jail down after limit of them,
+// waiting at most drain for those in flight.
+func (j *Jail) countConnections(limit uint, drain time.Duration) {
+	var accepted uint
+	j.onLogLine(func(line string) {
+		if !strings.Contains(line, "New connection from") {
+			return
+		}
+		accepted++
+		j.connections.Add(1)
+		if accepted == limit {
+			// Stopped right away, nsjail accepts nothing more while the log is read on.
+			j.proc.Signal(sigStop)
+			go j.drainConnections(drain)
+		}
+	})
+}
+
+// drainConnections waits up to timeout for the processes of the stopped nsjail, each serving a connection,
+// to exit and kills the jail with ErrConnectionLimit.
+func (j *Jail) drainConnections(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && j.serving() {
+		select {
+		case <-j.done:
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	j.signalTree(syscall.SIGKILL)
+	j.Abort(ErrConnectionLimit)
+	// A stopped nsjail would not act on another kill signal until it is continued.
+	j.proc.Signal(os.Kill)
+}
+
+// serving reports whether a child of nsjail is still running. Those that exited stay zombies, as the stopped
+// nsjail does not reap them.
+func (j *Jail) serving() bool {
+	pids, err := childPids(j.Pid())
+	if err != nil {
+		return false
+	}
+	for _, pid := range pids {
+		if _, err := processStartTime(pid); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// enforceConnDeadline kills children of nsjail, each serving one connection, that run longer than d.
+func (j *Jail) enforceConnDeadline(d time.Duration) {
+	t := time.NewTicker(min(max(d/10, 10*time.Millisecond), 100*time.Millisecond))
+	defer t.Stop()
+	seen := make(map[int]time.Time)
+	for {
+		select {
+		case <-j.done:
+			return
+		case <-t.C:
+		}
+		pids, err := childPids(j.Pid())
+		if err != nil {
+			j.Abort(err)
+			return
+		}
+		now := time.Now()
+		alive := make(map[int]time.Time, len(pids))
+		for _, pid := range pids {
+			first, ok := seen[pid]
+			if !ok {
+				first = now
+			}
+			alive[pid] = first
+			if now.Sub(first) >= d {
+				if p, err := os.FindProcess(pid); err == nil {
+					p.Kill()
+				}
+			}
+		}
+		seen = alive
+	}
+}
@@ -0,0 +1,75 @@
+package nsjail
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// Lockdown is the layout of LockdownRootfs, which its hooks adjust.
+type Lockdown struct {
+	// Tmpfs lists the paths in the jail backed by an empty tmpfs, writable and discarded with the jail.
+	// Defaults to /tmp, /dev/shm and /run.
+	Tmpfs []string
+	// Writable lists host paths bind-mounted read-write at the same path, the only host paths the jail
+	// can change. Empty by default.
+	Writable []string
+}
+
+// LockdownRootfs mounts the host's root read-only as the root of the jail (-c /), with an empty writable
+// tmpfs at /tmp, /dev/shm and /run and nothing else writable. nsjail bind-mounts the root recursively but
+// only makes the root itself read-only, so the host mounts below it that are writable, e.g. a separate
+// /home or /var, are mounted read-only again (-R); /proc is left to nsjail. The host's mounts are listed
+// when LockdownRootfs is called, on Linux only.
+//
+// The hooks are called in order with the default layout and may change it, e.g. to add a writable
+// directory:
+//
+//	n.LockdownRootfs(func(l *nsjail.Lockdown) { l.Writable = append(l.Writable, "/srv/data") })
+func (n *NsJail) LockdownRootfs(hooks ...func(*Lockdown)) *NsJail {
+	const method = "LockdownRootfs"
+	l := Lockdown{Tmpfs: []string{"/tmp", "/dev/shm", "/run"}}
+	for _, hook := range hooks {
+		hook(&l)
+	}
+	for _, p := range slices.Concat(l.Tmpfs, l.Writable) {
+		if !path.IsAbs(p) {
+			n.fail(method, "path %q is not absolute", p)
+			return n.changed()
+		}
+	}
+	// Writable paths would be hidden by a tmpfs mounted after them.
+	for _, w := range l.Writable {
+		for _, t := range l.Tmpfs {
+			if within(path.Clean(w), path.Clean(t)) {
+				n.fail(method, "writable path %s lies inside the tmpfs %s", w, t)
+				return n.changed()
+			}
+		}
+	}
+	mounts, err := hostWritableMounts()
+	if err != nil {
+		n.fail(method, "listing the host's mounts: %v", err)
+		return n.changed()
+	}
+	n.WithChroot("/")
+	n.rwChroot = false
+	for _, m := range mounts {
+		// Mounts inside a tmpfs are hidden by it, and those inside a writable path are meant to be writable.
+		// Paths with a colon cannot be given to -R.
+		covered := within(m, "/proc") || strings.Contains(m, ":")
+		for _, p := range slices.Concat(l.Tmpfs, l.Writable) {
+			covered = covered || within(m, path.Clean(p))
+		}
+		if !covered {
+			n.AddBindMountRO(m)
+		}
+	}
+	for _, p := range l.Writable {
+		n.AddBindMountRW(p)
+	}
+	for _, p := range l.Tmpfs {
+		n.AddTmpfsMount(p)
+	}
+	return n.changed()
+}
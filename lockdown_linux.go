@@ -0,0 +1,42 @@
+package nsjail
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// hostWritableMounts returns the mount points below the host's root that are mounted read-write, except
+// for automount points, which bind-mounting would trigger, and those this process cannot reach.
+func hostWritableMounts() ([]string, error) {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	var mounts []string
+	for _, line := range strings.Split(string(data), "\n") {
+		// id parent major:minor root mount-point options [optional fields] - fstype source super-options
+		fields := strings.Fields(line)
+		i := slices.Index(fields, "-")
+		if i <= 5 || i+1 >= len(fields) || fields[i+1] == "autofs" {
+			continue
+		}
+		mountPoint := unescapeMountPath(fields[4])
+		if mountPoint == "/" || !slices.Contains(strings.Split(fields[5], ","), "rw") {
+			continue
+		}
+		if _, err := os.Stat(mountPoint); err != nil {
+			continue
+		}
+		mounts = append(mounts, mountPoint)
+	}
+	// Parents are mounted first, as nsjail mounts in order, and paths mounted over again only once.
+	slices.Sort(mounts)
+	return slices.Compact(mounts), nil
+}
+
+// unescapeMountPath decodes the octal escapes of spaces, tabs, newlines and backslashes in a path of
+// /proc/self/mountinfo.
+func unescapeMountPath(p string) string {
+	return strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`).Replace(p)
+}
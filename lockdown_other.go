@@ -0,0 +1,5 @@
+//go:build !linux
+
+package nsjail
+
+func hostWritableMounts() ([]string, error) { return nil, nil }
@@ -0,0 +1,21 @@
+package nsjail
+
+import "log/slog"
+
+// discardLogger is used by configurations without a logger.
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// WithLogger sets the logger the package reports its own diagnostics to: the argv built, the nsjail
+// process starting and exiting, aborts and the cleanup of resources created for the jail. Messages are
+// logged at debug level, except for the start and exit of the jail and failures to start it. Nothing is
+// logged by default; set a logger for every jail with SetDefaults(WithLoggerOpt(l)). A nil l discards
+// the messages.
+func (n *NsJail) WithLogger(l *slog.Logger) *NsJail { n.logger = l; return n.changed() }
+
+// log returns the logger of n.
+func (n *NsJail) log() *slog.Logger {
+	if n.logger == nil {
+		return discardLogger
+	}
+	return n.logger
+}
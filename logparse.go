@@ -0,0 +1,213 @@
+package nsjail
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogEventKind classifies a LogEvent.
+type LogEventKind uint8
+
+const (
+	// LogOther is any line not covered by the other kinds.
+	LogOther LogEventKind = iota
+	// LogJailStarted reports the jail parameters nsjail starts with, logged once per nsjail run.
+	LogJailStarted
+	// LogProcessStarted reports a process about to be executed in the jail. LogEvent.Pid is its pid.
+	LogProcessStarted
+	// LogProcessExited reports a process that exited. LogEvent.ExitStatus is its exit code.
+	LogProcessExited
+	// LogProcessSignaled reports a process killed by a signal. LogEvent.Signal is the signal number.
+	LogProcessSignaled
+	// LogTimeLimitKill reports a process killed for reaching its time limit (-t).
+	LogTimeLimitKill
+	// LogSeccompViolation reports a syscall denied by the seccomp policy. LogEvent.Syscall is its number,
+	// if logged.
+	LogSeccompViolation
+	// LogRlimit reports a resource limit nsjail set, or failed to set if LogEvent.Level is "W" or "E".
+	LogRlimit
+	// LogConnection reports a connection accepted in ModeListenTCP. LogEvent.Remote is the peer address.
+	LogConnection
+)
+
+func (k LogEventKind) String() string {
+	switch k {
+	case LogOther:
+		return "other"
+	case LogJailStarted:
+		return "jail started"
+	case LogProcessStarted:
+		return "process started"
+	case LogProcessExited:
+		return "process exited"
+	case LogProcessSignaled:
+		return "process signaled"
+	case LogTimeLimitKill:
+		return "time limit kill"
+	case LogSeccompViolation:
+		return "seccomp violation"
+	case LogRlimit:
+		return "rlimit"
+	case LogConnection:
+		return "connection"
+	}
+	return fmt.Sprintf("LogEventKind(%d)", uint8(k))
+}
+
+// LogEvent is a line of the nsjail log, parsed.
+type LogEvent struct {
+	Kind LogEventKind
+	// Level is the log level: "D", "I", "W", "E" or "F".
+	Level string
+	// Time is when nsjail logged the line, or zero if the line carries no timestamp.
+	Time time.Time
+	// Pid is the jailed process the line is about, or zero.
+	Pid int
+	// ExitStatus is set for LogProcessExited.
+	ExitStatus int
+	// Signal is set for LogProcessSignaled.
+	Signal int
+	// Syscall is the number of the syscall for LogSeccompViolation, or -1 if it was not logged.
+	Syscall int
+	// Rlimit and Value are the resource, e.g. "RLIMIT_AS", and its value for LogRlimit.
+	Rlimit string
+	Value  string
+	// Remote is the peer address for LogConnection.
+	Remote string
+	// Message is the line without its level, timestamp and source location.
+	Message string
+	// Line is the line as logged.
+	Line string
+}
+
+// LogEventFunc receives the events parsed from the nsjail log.
+type LogEventFunc func(LogEvent)
+
+// OnLogEvent passes every line nsjail logs while the jail started with Start runs to fn, parsed into a
+// LogEvent. The log is still copied to stderr. Cannot be combined with WithLogFile or WithLogFd; parse
+// those logs with a LogParser instead. Can be called multiple times.
+func (n *NsJail) OnLogEvent(fn LogEventFunc) *NsJail {
+	n.logEvents = append(n.logEvents, fn)
+	return n.changed()
+}
+
+// LogParser parses an nsjail log stream, as written to the file of -l or the descriptor of -L, into
+// LogEvents.
+type LogParser struct {
+	fn LogEventFunc
+}
+
+// NewLogParser returns a parser passing each event to fn.
+func NewLogParser(fn LogEventFunc) *LogParser { return &LogParser{fn: fn} }
+
+// Parse reads the log from r until EOF, passing each line's event to the callback of the parser.
+func (p *LogParser) Parse(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		if line := sc.Text(); line != "" {
+			p.fn(ParseLogLine(line))
+		}
+	}
+	return sc.Err()
+}
+
+// LogEvents parses the log from r in a goroutine and returns its events on a channel, which is closed
+// at EOF.
+func LogEvents(r io.Reader) <-chan LogEvent {
+	ch := make(chan LogEvent, 16)
+	go func() {
+		defer close(ch)
+		NewLogParser(func(ev LogEvent) { ch <- ev }).Parse(r)
+	}()
+	return ch
+}
+
+var (
+	logPidRe      = regexp.MustCompile(`(?i)\bpid(?:: |=)(\d+)`)
+	logStatusRe   = regexp.MustCompile(`exited with status: (-?\d+)`)
+	logSignalRe   = regexp.MustCompile(`terminated with signal: (?:\w+ \((\d+)\)|(\d+))`)
+	logSyscallRe  = regexp.MustCompile(`(?i)syscall(?: number)?[#:]\s*(0x[0-9a-f]+|\d+)`)
+	logRlimitRe   = regexp.MustCompile(`(RLIMIT_[A-Z]+)\W+([^,)\s]+)`)
+	logConnFromRe = regexp.MustCompile(`New connection from: ?(\S+)`)
+)
+
+// ParseLogLine parses a line of the nsjail log like
+// "[I][2024-05-01T10:00:00+0000] pid=42 ([STANDALONE MODE]) exited with status: 0, (PIDs left: 0)".
+// Lines that do not look like nsjail log lines are returned as LogOther with only Message and Line set.
+func ParseLogLine(line string) LogEvent {
+	ev := LogEvent{Kind: LogOther, Syscall: -1, Line: line}
+	msg := line
+	for strings.HasPrefix(msg, "[") {
+		i := strings.IndexByte(msg, ']')
+		if i < 0 {
+			break
+		}
+		field := msg[1:i]
+		switch {
+		case len(field) == 1 && strings.Contains("DIWEF", field):
+			ev.Level = field
+		case ev.Time.IsZero():
+			if t, err := time.Parse("2006-01-02T15:04:05-0700", field); err == nil {
+				ev.Time = t
+			}
+		}
+		msg = strings.TrimLeft(msg[i+1:], " ")
+	}
+	// Drop the source location of verbose logs, e.g. "void subproc::reapProc():231 ".
+	if i := strings.Index(msg, "():"); i >= 0 {
+		if j := strings.IndexByte(msg[i:], ' '); j >= 0 {
+			msg = msg[i+j+1:]
+		}
+	}
+	ev.Message = msg
+	if m := logPidRe.FindStringSubmatch(msg); m != nil {
+		ev.Pid, _ = strconv.Atoi(m[1])
+	}
+
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.HasPrefix(msg, "Jail parameters:"):
+		ev.Kind = LogJailStarted
+	case strings.Contains(msg, "about to execute"):
+		ev.Kind = LogProcessStarted
+	case strings.Contains(lower, "time limit") && strings.Contains(lower, "killing"):
+		ev.Kind = LogTimeLimitKill
+	case strings.Contains(lower, "seccomp violation") || strings.Contains(lower, "syscall number"):
+		ev.Kind = LogSeccompViolation
+		if m := logSyscallRe.FindStringSubmatch(msg); m != nil {
+			if nr, err := strconv.ParseInt(m[1], 0, 0); err == nil {
+				ev.Syscall = int(nr)
+			}
+		}
+	case logStatusRe.MatchString(msg):
+		ev.Kind = LogProcessExited
+		ev.ExitStatus, _ = strconv.Atoi(logStatusRe.FindStringSubmatch(msg)[1])
+	case logSignalRe.MatchString(msg):
+		ev.Kind = LogProcessSignaled
+		m := logSignalRe.FindStringSubmatch(msg)
+		ev.Signal, _ = strconv.Atoi(m[1] + m[2])
+	case strings.Contains(msg, "RLIMIT_"):
+		if m := logRlimitRe.FindStringSubmatch(msg); m != nil {
+			ev.Kind, ev.Rlimit, ev.Value = LogRlimit, m[1], m[2]
+		}
+	case logConnFromRe.MatchString(msg):
+		ev.Kind, ev.Remote = LogConnection, logConnFromRe.FindStringSubmatch(msg)[1]
+	}
+	return ev
+}
+
+// deliverLogEvents passes the parsed nsjail log to the OnLogEvent callbacks.
+func (j *Jail) deliverLogEvents(fns []LogEventFunc) {
+	j.onLogLine(func(line string) {
+		ev := ParseLogLine(line)
+		for _, fn := range fns {
+			fn(ev)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package nsjail
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogLine(t *testing.T) {
+	ts := "[2024-05-01T10:00:00+0000]"
+	tests := []struct {
+		line string
+		want LogEvent
+	}{
+		{"[I]" + ts + " Jail parameters: hostname:'NSJAIL', chroot:'', process:'/bin/sh', bind:[::]:0, " +
+			"max_conns:0, max_conns_per_ip:0, time_limit:600, personality:0, daemonize:false",
+			LogEvent{Kind: LogJailStarted, Level: "I"}},
+		{"[D]" + ts + "[1234] void subproc::runChild(nsj_t*, int, int, int, int)():436 pid=42 about to execute " +
+			"'/bin/sh' for '[STANDALONE MODE]'",
+			LogEvent{Kind: LogProcessStarted, Level: "D", Pid: 42,
+				Message: "pid=42 about to execute '/bin/sh' for '[STANDALONE MODE]'"}},
+		{"[I]" + ts + " pid=42 ([STANDALONE MODE]) exited with status: 3, (PIDs left: 0)",
+			LogEvent{Kind: LogProcessExited, Level: "I", Pid: 42, ExitStatus: 3}},
+		{"[I]" + ts + " pid=42 ([STANDALONE MODE]) terminated with signal: Killed (9), (PIDs left: 0)",
+			LogEvent{Kind: LogProcessSignaled, Level: "I", Pid: 42, Signal: 9}},
+		{"[I]" + ts + " pid=42 ([STANDALONE MODE]) terminated with signal: 11, (PIDs left: 0)",
+			LogEvent{Kind: LogProcessSignaled, Level: "I", Pid: 42, Signal: 11}},
+		{"[I]" + ts + " pid=42 run time >= time limit (10 >= 10) ([STANDALONE MODE]). Killing it",
+			LogEvent{Kind: LogTimeLimitKill, Level: "I", Pid: 42}},
+		{"[W]" + ts + " pid=42 commited a syscall/seccomp violation and exited with SIGSYS",
+			LogEvent{Kind: LogSeccompViolation, Level: "W", Pid: 42, Syscall: -1}},
+		{"[W]" + ts + " PID: 42, Syscall number: 101, Arguments: 0, 0, 0, 0, 0, 0, SP: 0x7ffc, PC: 0x4011",
+			LogEvent{Kind: LogSeccompViolation, Level: "W", Pid: 42, Syscall: 101}},
+		{"[E]" + ts + " setrlimit64(0, RLIMIT_AS, 536870912): Operation not permitted",
+			LogEvent{Kind: LogRlimit, Level: "E", Rlimit: "RLIMIT_AS", Value: "536870912"}},
+		{"[I]" + ts + " New connection from: [::ffff:127.0.0.1]:45678 on: [::ffff:127.0.0.1]:8080",
+			LogEvent{Kind: LogConnection, Level: "I", Remote: "[::ffff:127.0.0.1]:45678"}},
+		{"[I]" + ts + " Mode: STANDALONE_ONCE", LogEvent{Kind: LogOther, Level: "I"}},
+		{"hello from the jailed program", LogEvent{Kind: LogOther}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want.Kind.String(), func(t *testing.T) {
+			got := ParseLogLine(tt.line)
+			want := tt.want
+			want.Line = tt.line
+			if want.Syscall == 0 {
+				want.Syscall = -1
+			}
+			if want.Level != "" {
+				want.Time = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+			}
+			if want.Message == "" {
+				want.Message = got.Message
+			}
+			if !got.Time.Equal(want.Time) {
+				t.Errorf("Time = %v, want %v", got.Time, want.Time)
+			}
+			got.Time = want.Time
+			if got != want {
+				t.Errorf("ParseLogLine(%q) =\n%+v\nwant\n%+v", tt.line, got, want)
+			}
+			if strings.HasPrefix(got.Message, "[") {
+				t.Errorf("Message %q keeps the prefix", got.Message)
+			}
+		})
+	}
+}
+
+func TestLogParser(t *testing.T) {
+	log := "[I][2024-05-01T10:00:00+0000] Mode: STANDALONE_ONCE\n\n" +
+		"[I][2024-05-01T10:00:00+0000] pid=7 ([STANDALONE MODE]) exited with status: 0, (PIDs left: 0)\n"
+	var kinds []LogEventKind
+	p := NewLogParser(func(ev LogEvent) { kinds = append(kinds, ev.Kind) })
+	if err := p.Parse(strings.NewReader(log)); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if want := []LogEventKind{LogOther, LogProcessExited}; !slices.Equal(kinds, want) {
+		t.Errorf("Parse = %v, want %v", kinds, want)
+	}
+	kinds = nil
+	for ev := range LogEvents(strings.NewReader(log)) {
+		kinds = append(kinds, ev.Kind)
+	}
+	if want := []LogEventKind{LogOther, LogProcessExited}; !slices.Equal(kinds, want) {
+		t.Errorf("LogEvents = %v, want %v", kinds, want)
+	}
+}
@@ -0,0 +1,67 @@
+package nsjail
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// onLogLine registers fn to receive each line nsjail logs. It must be called before the process is started.
+func (j *Jail) onLogLine(fn func(line string)) { j.logHandlers = append(j.logHandlers, fn) }
+
+// tapLog redirects the nsjail log to a pipe (-L) read by the wrapper. Lines are handed to the handlers
+// registered with onLogLine and copied to mirror, which is where nsjail would have logged otherwise.
+func (j *Jail) tapLog(n *NsJail, l *launch, mirror io.Writer) error {
+	if n.logFile != "" || n.logFd != -1 {
+		return errors.New("nsjail: cannot read the nsjail log when WithLogFile or WithLogFd is set")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	fd := l.inherit(w)
+	l.closeAfterStart(w)
+	l.flags = append(l.flags, "-L", strconv.Itoa(fd))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				if mirror != nil {
+					io.WriteString(mirror, line)
+				}
+				for _, fn := range j.logHandlers {
+					fn(strings.TrimSuffix(line, "\n"))
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	j.logPipe, j.logDone = r, done
+	if n.daemon {
+		// The daemon forked by -d logs after nsjail exited; closing the pipe would fail its writes.
+		go func() {
+			<-done
+			r.Close()
+		}()
+		return nil
+	}
+	j.onClose(func() {
+		// nsjail has exited, so only lines still buffered in the pipe are left to read.
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+		r.Close()
+	})
+	return nil
+}
@@ -0,0 +1,75 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// MacvlanParent is a host interface suitable as the parent of a MACVLAN interface.
+type MacvlanParent struct {
+	// Iface is the name of the host interface carrying the default route.
+	Iface string
+	// Prefix is the IPv4 address and subnet of the host on Iface.
+	Prefix netip.Prefix
+	// Gateway is the default gateway reached through Iface.
+	Gateway netip.Addr
+}
+
+// DetectMacvlanParent finds the interface of the host's IPv4 default route, with its subnet and gateway.
+func DetectMacvlanParent() (*MacvlanParent, error) {
+	iface, gw, err := defaultRoute()
+	if err != nil {
+		return nil, err
+	}
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.To4() == nil {
+			continue
+		}
+		addr, _ := netip.AddrFromSlice(ipnet.IP.To4())
+		bits, _ := ipnet.Mask.Size()
+		return &MacvlanParent{Iface: iface, Prefix: netip.PrefixFrom(addr, bits), Gateway: gw}, nil
+	}
+	return nil, fmt.Errorf("nsjail: interface %s has no IPv4 address", iface)
+}
+
+// AutoSelectMacvlanParent fills in the MACVLAN parent interface (-I), netmask and gateway from the host's
+// default route when the jail is built, unless they were set explicitly. The address of the jail itself
+// must still be set with WithMacvlanIpAddr and is checked to lie in the parent's subnet.
+func (n *NsJail) AutoSelectMacvlanParent() *NsJail { n.macvlanAuto = true; return n.changed() }
+
+// resolveMacvlan returns a copy of n with the MACVLAN options completed by AutoSelectMacvlanParent.
+func (n *NsJail) resolveMacvlan() (*NsJail, error) {
+	parent, err := DetectMacvlanParent()
+	if err != nil {
+		return nil, fmt.Errorf("nsjail: selecting MACVLAN parent: %w", err)
+	}
+	if n.macvlanVsIp == "" {
+		return nil, errors.New("nsjail: AutoSelectMacvlanParent requires the jail address, set with WithMacvlanIpAddr")
+	}
+	if ip, err := netip.ParseAddr(n.macvlanVsIp); err == nil && !parent.Prefix.Contains(ip) {
+		return nil, fmt.Errorf("nsjail: MACVLAN address %s is outside %s on %s", ip, parent.Prefix.Masked(), parent.Iface)
+	}
+	c := n.Clone()
+	if c.macvlanIface == "" {
+		c.macvlanIface = parent.Iface
+	}
+	if c.macvlanVsNm == "" {
+		mask, _ := netip.AddrFromSlice(net.CIDRMask(parent.Prefix.Bits(), 32))
+		c.macvlanVsNm = mask.String()
+	}
+	if c.macvlanVsGw == "" && parent.Gateway.IsValid() {
+		c.macvlanVsGw = parent.Gateway.String()
+	}
+	return c, nil
+}
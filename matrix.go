@@ -0,0 +1,106 @@
+package nsjail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// MatrixEntry is the outcome of a job under one profile of RunMatrix.
+type MatrixEntry struct {
+	// Profile is the index of the profile in the slice passed to RunMatrix.
+	Profile int
+	Result  *Result
+	// Err is the error of starting the jail, e.g. an invalid profile.
+	Err error
+}
+
+// MatrixReport compares the outcomes of a job across profiles. The first profile is the baseline.
+type MatrixReport struct {
+	Entries []MatrixEntry
+}
+
+// RunMatrix runs job under every profile in parallel, e.g. under seccomp policies of different strictness,
+// and reports how the outcomes compare. Each run works on a copy of its profile; job.Stdin is read once
+// and fed to every run.
+func RunMatrix(ctx context.Context, job Payload, profiles []*NsJail) (*MatrixReport, error) {
+	var stdin []byte
+	if job.Stdin != nil {
+		var err error
+		if stdin, err = io.ReadAll(job.Stdin); err != nil {
+			return nil, err
+		}
+	}
+	report := &MatrixReport{Entries: make([]MatrixEntry, len(profiles))}
+	var wg sync.WaitGroup
+	for i, profile := range profiles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := job
+			if stdin != nil {
+				p.Stdin = bytes.NewReader(stdin)
+			}
+			res, err := p.run(ctx, profile)
+			report.Entries[i] = MatrixEntry{Profile: i, Result: res, Err: err}
+		}()
+	}
+	wg.Wait()
+	return report, nil
+}
+
+// Divergent returns the indexes of the profiles whose outcome differs from the baseline in status,
+// exit code, output or policy violations.
+func (r *MatrixReport) Divergent() []int {
+	var out []int
+	for i := 1; i < len(r.Entries); i++ {
+		if !r.Entries[i].sameOutcome(&r.Entries[0]) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func (e *MatrixEntry) sameOutcome(base *MatrixEntry) bool {
+	if (e.Err == nil) != (base.Err == nil) {
+		return false
+	}
+	if e.Err != nil {
+		return true
+	}
+	a, b := e.Result, base.Result
+	return a.Status == b.Status && a.NormalizedCode == b.NormalizedCode &&
+		bytes.Equal(a.Stdout, b.Stdout) && bytes.Equal(a.Stderr, b.Stderr) &&
+		len(a.Violations) == len(b.Violations)
+}
+
+// String formats the report as a table with one row per profile.
+func (r *MatrixReport) String() string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROFILE\tSTATUS\tCODE\tDURATION\tVIOLATIONS\tOUTPUT")
+	for i := range r.Entries {
+		e := &r.Entries[i]
+		if e.Err != nil {
+			fmt.Fprintf(tw, "%d\terror\t-\t-\t-\t%v\n", e.Profile, e.Err)
+			continue
+		}
+		output := "baseline"
+		if i > 0 {
+			output = "same"
+			base := r.Entries[0].Result
+			if base == nil || !bytes.Equal(e.Result.Stdout, base.Stdout) || !bytes.Equal(e.Result.Stderr, base.Stderr) {
+				output = "differs"
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%v\t%d\t%v\t%d\t%s\n", e.Profile, e.Result.Status, e.Result.NormalizedCode,
+			e.Result.Duration.Round(time.Millisecond), len(e.Result.Violations), output)
+	}
+	tw.Flush()
+	return sb.String()
+}
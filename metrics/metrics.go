@@ -0,0 +1,168 @@
+// Package metrics exposes Prometheus metrics for jails run with nsjail-go. Create a Metrics, register it
+// with a registry and run jails through it:
+//
+//	m := metrics.New(metrics.Opts{Namespace: "judge"})
+//	prometheus.MustRegister(m)
+//	res, err := m.Run(ctx, nsjail.New("/usr/bin/python3", "main.py"))
+package metrics
+
+import (
+	"context"
+	"strings"
+
+	nsjail "github.com/OptimusePrime/nsjail-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sigsys is SIGSYS, the signal seccomp kills with, on every Linux architecture but MIPS.
+const sigsys = 31
+
+// Opts configures the metrics.
+type Opts struct {
+	// Namespace and Subsystem prefix the metric names, e.g. "judge_nsjail_runs_started_total" for
+	// Namespace "judge". Subsystem defaults to "nsjail".
+	Namespace string
+	Subsystem string
+	// ConstLabels are added to every metric.
+	ConstLabels prometheus.Labels
+	// WallTimeBuckets are the buckets of the wall time histogram in seconds. Defaults to 10ms to ~10min.
+	WallTimeBuckets []float64
+	// MemoryBuckets are the buckets of the peak memory histogram in bytes. Defaults to 1MiB to 16GiB.
+	MemoryBuckets []float64
+}
+
+// Metrics counts jail executions. It implements prometheus.Collector and is safe for concurrent use.
+type Metrics struct {
+	started      prometheus.Counter
+	running      prometheus.Gauge
+	finished     *prometheus.CounterVec
+	failures     *prometheus.CounterVec
+	wallTime     prometheus.Histogram
+	peakMemory   prometheus.Histogram
+	seccompKills prometheus.Counter
+}
+
+// New returns metrics configured by opts.
+func New(opts Opts) *Metrics {
+	if opts.Subsystem == "" {
+		opts.Subsystem = "nsjail"
+	}
+	if opts.WallTimeBuckets == nil {
+		opts.WallTimeBuckets = prometheus.ExponentialBuckets(0.01, 2, 16)
+	}
+	if opts.MemoryBuckets == nil {
+		opts.MemoryBuckets = prometheus.ExponentialBuckets(1<<20, 2, 15)
+	}
+	name := func(name, help string) prometheus.Opts {
+		return prometheus.Opts{Namespace: opts.Namespace, Subsystem: opts.Subsystem, Name: name, Help: help,
+			ConstLabels: opts.ConstLabels}
+	}
+	histogram := func(o prometheus.Opts, buckets []float64) prometheus.Histogram {
+		return prometheus.NewHistogram(prometheus.HistogramOpts{Namespace: o.Namespace, Subsystem: o.Subsystem,
+			Name: o.Name, Help: o.Help, ConstLabels: o.ConstLabels, Buckets: buckets})
+	}
+	return &Metrics{
+		started: prometheus.NewCounter(prometheus.CounterOpts(name("runs_started_total",
+			"Jails started."))),
+		running: prometheus.NewGauge(prometheus.GaugeOpts(name("runs_running",
+			"Jails currently running."))),
+		finished: prometheus.NewCounterVec(prometheus.CounterOpts(name("runs_finished_total",
+			"Jails finished, by how they ended.")), []string{"status"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts(name("failures_total",
+			"Jails that failed to start or did not exit with code 0, by cause.")), []string{"cause"}),
+		wallTime: histogram(name("wall_time_seconds",
+			"Wall time of finished jails."), opts.WallTimeBuckets),
+		peakMemory: histogram(name("peak_memory_bytes",
+			"Peak memory of finished jails with cgroup limits."), opts.MemoryBuckets),
+		seccompKills: prometheus.NewCounter(prometheus.CounterOpts(name("seccomp_kills_total",
+			"Jails killed by SIGSYS for a seccomp violation."))),
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.started, m.running, m.finished, m.failures, m.wallTime, m.peakMemory,
+		m.seccompKills}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// Start starts n like NsJail.Start and records the jail once it finished.
+func (m *Metrics) Start(ctx context.Context, n *nsjail.NsJail) (*nsjail.Jail, error) {
+	j, err := n.Start(ctx)
+	if err != nil {
+		m.failures.WithLabelValues("start").Inc()
+		return nil, err
+	}
+	m.started.Inc()
+	m.running.Inc()
+	go func() {
+		res, err := j.Wait()
+		m.running.Dec()
+		m.observe(res, err)
+	}()
+	return j, nil
+}
+
+// Run runs n like NsJail.Run and records the result.
+func (m *Metrics) Run(ctx context.Context, n *nsjail.NsJail) (*nsjail.Result, error) {
+	j, err := n.Start(ctx)
+	if err != nil {
+		m.failures.WithLabelValues("start").Inc()
+		return nil, err
+	}
+	m.started.Inc()
+	m.running.Inc()
+	res, err := j.Wait()
+	m.running.Dec()
+	m.observe(res, err)
+	return res, err
+}
+
+// Observe records a jail run without Start or Run, e.g. one run with NsJail.Exec. A non-nil err counts
+// as a failure to start.
+func (m *Metrics) Observe(res *nsjail.Result, err error) {
+	if err != nil && res == nil {
+		m.failures.WithLabelValues("start").Inc()
+		return
+	}
+	m.started.Inc()
+	m.observe(res, err)
+}
+
+func (m *Metrics) observe(res *nsjail.Result, err error) {
+	if res == nil {
+		m.failures.WithLabelValues("wait").Inc()
+		return
+	}
+	m.finished.WithLabelValues(label(res.Status.String())).Inc()
+	m.wallTime.Observe(res.Duration.Seconds())
+	if res.Usage != nil {
+		m.peakMemory.Observe(float64(res.Usage.MemoryPeak))
+	}
+	switch {
+	case err != nil:
+		m.failures.WithLabelValues("wait").Inc()
+	case res.Status == nsjail.StatusSignaled && res.NormalizedCode == 128+sigsys:
+		m.seccompKills.Inc()
+		m.failures.WithLabelValues("seccomp").Inc()
+	case res.Status != nsjail.StatusExited:
+		m.failures.WithLabelValues(label(res.Status.String())).Inc()
+	case res.NormalizedCode != 0:
+		m.failures.WithLabelValues("nonzero_exit").Inc()
+	}
+}
+
+// label turns a status like "time limit" into a label value like "time_limit".
+func label(s string) string { return strings.ReplaceAll(s, " ", "_") }
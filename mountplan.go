@@ -0,0 +1,196 @@
+package nsjail
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PlannedMount is a mount nsjail performs when it sets up the jail, as listed by MountPlan.
+type PlannedMount struct {
+	// Flag is the option the mount comes from, e.g. "-R", or "" for the root and procfs, which nsjail
+	// mounts by itself.
+	Flag string
+	// Src is the host path of a bind mount, the source of another file system, or the target of a symlink.
+	Src string
+	// Dst is the path in the jail.
+	Dst string
+	// FsType is "bind", "tmpfs", "proc", "symlink", or the file system type of -m.
+	FsType string
+	// Opts are the mount options of -m.
+	Opts     string
+	ReadOnly bool
+}
+
+func (m PlannedMount) String() string {
+	s := m.FsType + " " + m.Dst
+	if m.Src != "" {
+		s = m.FsType + " " + m.Src + " at " + m.Dst
+	}
+	if m.Flag != "" {
+		s = m.Flag + " " + s
+	}
+	if m.ReadOnly {
+		s += " (read-only)"
+	}
+	return s
+}
+
+// MountConflict is a mount of a MountPlan that does not do what it appears to.
+type MountConflict struct {
+	Mount PlannedMount
+	// Other is the mount it conflicts with, if any.
+	Other  *PlannedMount
+	Reason string
+}
+
+func (c *MountConflict) Error() string {
+	if c.Other != nil {
+		return fmt.Sprintf("nsjail: mount %v: %s: %v", c.Mount, c.Reason, *c.Other)
+	}
+	return fmt.Sprintf("nsjail: mount %v: %s", c.Mount, c.Reason)
+}
+
+// MountPlan returns the mounts nsjail performs for the jail, in order: the root, which is the chroot of
+// WithChroot or else an empty tmpfs, the read-only bind mounts, the read-write ones, tmpfs mounts, the
+// mounts of AddMount, symlinks, and finally procfs. Mounts of a config file and those Start adds for the
+// files it creates, such as workspaces, ephemeral overlays and generated resolv.conf files, are not listed.
+//
+// The plan is checked for mounts that do not do what they appear to, which nsjail reports, if at all, as
+// an obscure error when it sets up the jail. Each is returned as a *MountConflict, joined with the others:
+// a mount hidden by a later one at the same destination or above it; a symlink, or a writable mount whose
+// mount point does not exist, inside a read-only bind mount, as nsjail then creates it in the host
+// directory; and a destination that is not a clean absolute path or that a symlink in the chroot or in a
+// bind-mounted directory leads out of it. The plan is returned with the conflicts.
+func (n *NsJail) MountPlan() ([]PlannedMount, error) {
+	if n.workspace != nil {
+		n = n.Clone()
+		n.workspace = nil
+	}
+	r, err := n.resolve()
+	if err != nil {
+		return nil, err
+	}
+	plan := []PlannedMount{{Dst: "/", FsType: "tmpfs", ReadOnly: !r.rwChroot}}
+	if r.chroot != "" {
+		plan[0] = PlannedMount{Flag: "-c", Src: r.chroot, Dst: "/", FsType: "bind", ReadOnly: !r.rwChroot}
+	}
+	for _, o := range r.options() {
+		switch o.flag {
+		case "-R", "-B":
+			src, dst, _ := strings.Cut(o.value, ":")
+			plan = append(plan, PlannedMount{Flag: o.flag, Src: src, Dst: cmp.Or(dst, src), FsType: "bind",
+				ReadOnly: o.flag == "-R"})
+		case "-T":
+			plan = append(plan, PlannedMount{Flag: o.flag, Dst: o.value, FsType: "tmpfs"})
+		case "-m":
+			// The source may contain colons, e.g. the options of an overlay, but the other fields do not.
+			fields := strings.Split(o.value, ":")
+			if len(fields) < 4 {
+				continue
+			}
+			k := len(fields) - 3
+			m := PlannedMount{Flag: o.flag, Src: strings.Join(fields[:k], ":"), Dst: fields[k], FsType: fields[k+1],
+				Opts: fields[k+2]}
+			if m.FsType == "" {
+				m.FsType = "bind"
+			}
+			for _, opt := range strings.Split(m.Opts, ",") {
+				m.ReadOnly = m.ReadOnly || opt == "ro"
+			}
+			plan = append(plan, m)
+		case "-s":
+			target, link, _ := strings.Cut(o.value, ":")
+			plan = append(plan, PlannedMount{Flag: o.flag, Src: target, Dst: link, FsType: "symlink"})
+		}
+	}
+	if !r.procMountDisabled {
+		plan = append(plan, PlannedMount{Dst: cmp.Or(r.procPath, "/proc"), FsType: "proc", ReadOnly: !r.procRw})
+	}
+	return plan, checkMountPlan(plan)
+}
+
+// checkMountPlan returns the conflicts of a plan, see MountPlan.
+func checkMountPlan(plan []PlannedMount) error {
+	var errs []error
+	conflict := func(m PlannedMount, other *PlannedMount, format string, args ...any) {
+		errs = append(errs, &MountConflict{Mount: m, Other: other, Reason: fmt.Sprintf(format, args...)})
+	}
+	for i, m := range plan {
+		if i == 0 {
+			continue
+		}
+		if !path.IsAbs(m.Dst) || path.Clean(m.Dst) != m.Dst {
+			conflict(m, nil, "destination is not a clean absolute path")
+			continue
+		}
+		for _, later := range plan[i+1:] {
+			if later.Dst == m.Dst {
+				conflict(m, &later, "a later mount has the same destination")
+				break
+			}
+			if within(m.Dst, later.Dst) && later.FsType != "symlink" {
+				conflict(m, &later, "hidden by a later mount above it")
+				break
+			}
+		}
+		// The innermost earlier mount m is created in.
+		parent := 0
+		for k := 1; k < i; k++ {
+			if e := plan[k]; e.Dst != m.Dst && within(m.Dst, e.Dst) && e.FsType != "symlink" &&
+				len(e.Dst) >= len(plan[parent].Dst) {
+				parent = k
+			}
+		}
+		rel := strings.TrimPrefix(m.Dst, plan[parent].Dst)
+		if p := plan[parent]; parent > 0 && p.FsType == "bind" && p.ReadOnly && !m.ReadOnly {
+			if m.FsType == "symlink" {
+				conflict(m, &p, "symlink is created on the host, inside the read-only bind mount")
+			} else if _, err := os.Lstat(filepath.Join(p.Src, rel)); errors.Is(err, fs.ErrNotExist) {
+				conflict(m, &p, "writable mount point is created on the host, inside the read-only bind mount")
+			}
+		}
+		if p := plan[parent]; p.FsType == "bind" && path.IsAbs(p.Src) {
+			if link, ok := escapingSymlink(p.Src, rel, m.FsType != "symlink"); ok {
+				what := "the bind-mounted directory"
+				if parent == 0 {
+					what = "the chroot"
+				}
+				conflict(m, &p, "destination leads out of %s through the symlink %s", what, link)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// escapingSymlink returns the first symlink on the path rel below the host directory root whose target
+// lies outside root, where the kernel resolves it when nsjail mounts on the path, and reports whether
+// there is one. The last element of rel is only followed if last is set; a symlink is not created through
+// one there.
+func escapingSymlink(root, rel string, last bool) (string, bool) {
+	elems := strings.Split(strings.Trim(rel, "/"), "/")
+	if !last || elems[len(elems)-1] == "" {
+		elems = elems[:len(elems)-1]
+	}
+	dir := root
+	for _, elem := range elems {
+		p := filepath.Join(dir, elem)
+		target, err := os.Readlink(p)
+		if err != nil {
+			// Not a symlink, or missing, so nsjail creates it as a directory.
+			dir = p
+			continue
+		}
+		resolved := filepath.Join(dir, target)
+		if filepath.IsAbs(target) || !within(resolved, filepath.Clean(root)) {
+			return p, true
+		}
+		dir = resolved
+	}
+	return "", false
+}
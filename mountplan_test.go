@@ -0,0 +1,99 @@
+package nsjail
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMountPlanOrder(t *testing.T) {
+	n := New("/bin/true").WithChroot("/srv/root").AddSymlink("usr/bin", "/bin").AddTmpfsMount("/tmp").
+		AddMount("none", "/dev/shm", "tmpfs", "size=1M").AddBindRW("/srv/data", "/data").AddBindRO("/lib", "")
+	plan, err := n.MountPlan()
+	if err != nil {
+		t.Fatalf("MountPlan: %v", err)
+	}
+	var got []string
+	for _, m := range plan {
+		got = append(got, m.String())
+	}
+	want := []string{
+		"-c bind /srv/root at / (read-only)",
+		"-R bind /lib at /lib (read-only)",
+		"-B bind /srv/data at /data",
+		"-T tmpfs /tmp",
+		"-m tmpfs none at /dev/shm",
+		"-s symlink usr/bin at /bin",
+		"proc /proc (read-only)",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("MountPlan =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMountPlanConflicts(t *testing.T) {
+	host := t.TempDir()
+	for _, dir := range []string{"root/usr", "ro/sub"} {
+		if err := os.MkdirAll(filepath.Join(host, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{"root/etc": "/etc", "root/up": "../..", "root/lib": "usr"} {
+		if err := os.Symlink(target, filepath.Join(host, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root, ro := filepath.Join(host, "root"), filepath.Join(host, "ro")
+
+	tests := []struct {
+		name string
+		jail *NsJail
+		want []string
+	}{
+		{"none", New("/bin/true").WithChroot(root).AddBindRO(ro, "/opt").AddTmpfsMount("/opt/sub").
+			AddTmpfsMount("/lib/x"), nil},
+		{"same destination", New("/bin/true").AddBindRO(ro, "/data").AddTmpfsMount("/data"),
+			[]string{"a later mount has the same destination"}},
+		{"hidden", New("/bin/true").AddBindRO(ro, "/a/b").AddTmpfsMount("/a"),
+			[]string{"hidden by a later mount above it"}},
+		{"symlink does not hide", New("/bin/true").AddTmpfsMount("/a/b").AddSymlink("x", "/a"), nil},
+		{"unclean", New("/bin/true").AddTmpfsMount("/a/../b").AddTmpfsMount("tmp"),
+			[]string{"not a clean absolute path", "not a clean absolute path"}},
+		{"symlink in read-only bind", New("/bin/true").AddBindRO(ro, "/opt").AddSymlink("x", "/opt/link"),
+			[]string{"symlink is created on the host"}},
+		{"missing mount point in read-only bind", New("/bin/true").AddBindRO(ro, "/opt").AddTmpfsMount("/opt/new"),
+			[]string{"writable mount point is created on the host"}},
+		{"absolute symlink in chroot", New("/bin/true").WithChroot(root).AddTmpfsMount("/etc/x"),
+			[]string{"leads out of the chroot through the symlink " + filepath.Join(root, "etc")}},
+		{"relative symlink in chroot", New("/bin/true").WithChroot(root).AddTmpfsMount("/up/x"),
+			[]string{"leads out of the chroot through the symlink " + filepath.Join(root, "up")}},
+		{"symlink in bind mount", New("/bin/true").AddBindRW(root, "/r").AddTmpfsMount("/r/etc"),
+			[]string{"leads out of the bind-mounted directory"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.jail.MountPlan()
+			var got []*MountConflict
+			if err != nil {
+				for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+					var c *MountConflict
+					if !errors.As(e, &c) {
+						t.Fatalf("MountPlan returned %v, want a *MountConflict", e)
+					}
+					got = append(got, c)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("MountPlan = %v, want %d conflicts", err, len(tt.want))
+			}
+			for i, c := range got {
+				if !strings.Contains(c.Reason, tt.want[i]) {
+					t.Errorf("conflict %d = %q, want %q", i, c.Reason, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// WithBindhostAddr sets the IP address to bind the listening port to (--bindhost). nsjail listens on an
+// IPv6 socket, so IPv4 addresses are passed in their IPv4-mapped form.
+func (n *NsJail) WithBindhostAddr(addr netip.Addr) *NsJail {
+	if addr.Is4() {
+		n.bindhost = netip.AddrFrom16(addr.As16()).String()
+	} else {
+		n.bindhost = addr.String()
+	}
+	return n.changed()
+}
+
+// WithListenAddrPort sets both the address and the port to listen on in ModeListenTCP, e.g. "[::1]:8080".
+func (n *NsJail) WithListenAddrPort(ap netip.AddrPort) *NsJail {
+	n.port = ap.Port()
+	return n.WithBindhostAddr(ap.Addr())
+}
+
+// ListenDualStack listens on all IPv6 and IPv4 addresses ("::"), which is nsjail's default bindhost.
+func (n *NsJail) ListenDualStack() *NsJail { n.bindhost = "::"; return n.changed() }
+
+// WithMacvlanIpAddr sets the IP for the MACVLAN 'vs' interface (--macvlan_vs_ip). It must be an IPv4 address.
+func (n *NsJail) WithMacvlanIpAddr(ip netip.Addr) *NsJail {
+	n.macvlanVsIp = ip.String()
+	return n.changed()
+}
+
+// WithMacvlanPrefix sets the IP and netmask of the MACVLAN 'vs' interface from a prefix such as
+// 192.168.0.2/24 (--macvlan_vs_ip, --macvlan_vs_nm).
+func (n *NsJail) WithMacvlanPrefix(p netip.Prefix) *NsJail {
+	n.macvlanVsIp = p.Addr().String()
+	if p.Addr().Is4() && p.Bits() >= 0 {
+		mask, _ := netip.AddrFromSlice(net.CIDRMask(p.Bits(), 32))
+		n.macvlanVsNm = mask.String()
+	} else {
+		// Leave an invalid netmask for validation to report.
+		n.macvlanVsNm = p.String()
+	}
+	return n.changed()
+}
+
+// WithMacvlanGatewayAddr sets the gateway for the MACVLAN 'vs' interface (--macvlan_vs_gw). It must be an IPv4 address.
+func (n *NsJail) WithMacvlanGatewayAddr(gw netip.Addr) *NsJail {
+	n.macvlanVsGw = gw.String()
+	return n.changed()
+}
+
+// WithMacvlanHardwareAddr sets the MAC address for the MACVLAN 'vs' interface (--macvlan_vs_ma).
+func (n *NsJail) WithMacvlanHardwareAddr(mac net.HardwareAddr) *NsJail {
+	n.macvlanVsMa = mac.String()
+	return n.changed()
+}
+
+// trimBrackets removes the brackets of an IPv6 literal such as "[::1]".
+func trimBrackets(s string) string {
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// validateNet checks that the addresses given as strings are valid for nsjail.
+func (n *NsJail) validateNet() error {
+	if n.bindhost != "" {
+		if _, err := netip.ParseAddr(n.bindhost); err != nil {
+			return fmt.Errorf("nsjail: invalid bindhost %q: %w", n.bindhost, err)
+		}
+	}
+	for _, opt := range []struct{ flag, value string }{
+		{"--macvlan_vs_ip", n.macvlanVsIp},
+		{"--macvlan_vs_nm", n.macvlanVsNm},
+		{"--macvlan_vs_gw", n.macvlanVsGw},
+	} {
+		if opt.value == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(opt.value)
+		if err != nil {
+			return fmt.Errorf("nsjail: invalid %s %q: %w", opt.flag, opt.value, err)
+		}
+		if !addr.Is4() {
+			return fmt.Errorf("nsjail: %s %q: nsjail only supports IPv4 on MACVLAN interfaces", opt.flag, opt.value)
+		}
+	}
+	if n.macvlanVsMa != "" {
+		if mac, err := net.ParseMAC(n.macvlanVsMa); err != nil || len(mac) != 6 {
+			return fmt.Errorf("nsjail: invalid --macvlan_vs_ma %q: want a 48-bit MAC address", n.macvlanVsMa)
+		}
+	}
+	if n.egressLimit > 0 && n.cloneNewNetDisabled {
+		return errors.New("nsjail: an egress byte limit requires a network namespace")
+	}
+	return nil
+}
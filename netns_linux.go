@@ -0,0 +1,88 @@
+package nsjail
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// listenInNetns opens UDP and TCP listeners on addr inside the network namespace of pid.
+func listenInNetns(pid int, addr string) (pc net.PacketConn, ln net.Listener, err error) {
+	err = withNetns(pid, func() error {
+		if pc, err = net.ListenPacket("udp", addr); err != nil {
+			return err
+		}
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			pc.Close()
+		}
+		return err
+	})
+	return pc, ln, err
+}
+
+// listenTCPInNetns opens a TCP listener on addr inside the network namespace of pid.
+func listenTCPInNetns(pid int, addr string) (ln net.Listener, err error) {
+	err = withNetns(pid, func() error {
+		ln, err = net.Listen("tcp", addr)
+		return err
+	})
+	return ln, err
+}
+
+// dialInNetns connects to the TCP address addr from inside the network namespace of pid.
+func dialInNetns(pid int, addr string) (c net.Conn, err error) {
+	err = withNetns(pid, func() error {
+		c, err = net.DialTimeout("tcp", addr, 5*time.Second)
+		return err
+	})
+	return c, err
+}
+
+// withNetns runs fn on a thread switched to the network namespace of pid. Sockets stay in the namespace
+// they were created in, so only their creation needs to happen there.
+func withNetns(pid int, fn func() error) error {
+	runtime.LockOSThread()
+	own, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer own.Close()
+	target, err := os.Open(fmt.Sprintf("/proc/%d/ns/net", pid))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer target.Close()
+
+	if err := setns(target, syscall.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("nsjail: entering network namespace of %d: %w", pid, err)
+	}
+	err = fn()
+	if setns(own, syscall.CLONE_NEWNET) == nil {
+		runtime.UnlockOSThread()
+	}
+	// Otherwise the thread stays locked and is discarded when this goroutine exits.
+	return err
+}
+
+// sysSetns holds the setns syscall number per architecture; package syscall does not define it.
+var sysSetns = map[string]uintptr{
+	"386": 346, "amd64": 308, "arm": 375, "arm64": 268, "loong64": 268, "riscv64": 268,
+	"ppc64": 350, "ppc64le": 350, "s390x": 339, "mips": 4344, "mipsle": 4344, "mips64": 5303, "mips64le": 5303,
+}
+
+func setns(f *os.File, nstype int) error {
+	nr, ok := sysSetns[runtime.GOARCH]
+	if !ok {
+		return syscall.ENOSYS
+	}
+	if _, _, errno := syscall.RawSyscall(nr, f.Fd(), uintptr(nstype), 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
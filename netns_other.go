@@ -0,0 +1,20 @@
+//go:build !linux
+
+package nsjail
+
+import (
+	"errors"
+	"net"
+)
+
+func listenInNetns(pid int, addr string) (net.PacketConn, net.Listener, error) {
+	return nil, nil, errors.New("nsjail: network namespaces require linux")
+}
+
+func dialInNetns(pid int, addr string) (net.Conn, error) {
+	return nil, errors.New("nsjail: network namespaces require linux")
+}
+
+func listenTCPInNetns(pid int, addr string) (net.Listener, error) {
+	return nil, errors.New("nsjail: network namespaces require linux")
+}
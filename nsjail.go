@@ -4,9 +4,17 @@
 package nsjail
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
 	"os/exec"
+	"slices"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 // Mode defines the execution mode for NSJail.
@@ -51,24 +59,30 @@ const (
 
 // Mount represents a custom mount point configuration for the --mount flag.
 type Mount struct {
-	Src    string
-	Dst    string
-	FsType string
-	Opts   string
+	Src    string `json:"src,omitempty" yaml:"src,omitempty"`
+	Dst    string `json:"dst" yaml:"dst"`
+	FsType string `json:"fstype,omitempty" yaml:"fstype,omitempty"`
+	Opts   string `json:"options,omitempty" yaml:"options,omitempty"`
 }
 
 // Symlink represents a symbolic link to be created in the jail for the --symlink flag.
 type Symlink struct {
-	Src string
-	Dst string
+	Src string `json:"src" yaml:"src"`
+	Dst string `json:"dst" yaml:"dst"`
 }
 
 // NsJail holds the complete configuration for a single NSJail execution.
-// It is configured using the builder methods.
+// It is configured using the builder methods. Invalid arguments are recorded rather than applied, and
+// reported together by Validate, Exec, Args, Start and Run.
+//
+// An NsJail must not be modified concurrently, nor while it is used by another goroutine. Exec, Args,
+// Start and Run do not modify it and may be called concurrently once it is configured. To customize a
+// shared template per goroutine, give each one a Clone, or share the immutable Config made by Freeze.
 type NsJail struct {
-	path    string
-	execCmd string
-	args    []string
+	path         string
+	pathChecksum string
+	execCmd      string
+	args         []string
 
 	// Core options
 	mode       Mode
@@ -86,12 +100,17 @@ type NsJail struct {
 	cwd               string
 	keepEnv           bool
 	envVars           []string
+	envPatterns       []string
+	envDeny           []string
+	envFiles          []string
 	keepCaps          bool
 	caps              []string
 	silent            bool
 	stderrToNull      bool
 	skipSetsid        bool
 	passFds           []int
+	extraFiles        []*os.File // see WithExtraFile
+	closeAfterStart   []*os.File // extra files owned by n, see ControlConn
 	disableNoNewPrivs bool
 
 	// Namespaces
@@ -108,7 +127,10 @@ type NsJail struct {
 
 	// Resource limits
 	timeLimit      uint64
+	deadline       bool // the limits of WithDeadline are checked
 	maxCpus        uint
+	cpuSet         []int // CPUs and NUMA nodes, see WithCpuSet
+	cpuSetMems     []int
 	rlimitAs       string // Supports numbers and RlimitVal
 	rlimitCore     string
 	rlimitCpu      string
@@ -131,6 +153,9 @@ type NsJail struct {
 	// Mounts
 	bindMountsRO      []string
 	bindMountsRW      []string
+	strictMounts      bool
+	binaryDeps        []string
+	overlay           *overlay
 	tmpfsMounts       []string
 	mounts            []Mount
 	symlinks          []Symlink
@@ -153,6 +178,17 @@ type NsJail struct {
 	macvlanVsGw  string
 	macvlanVsMa  string
 	macvlanVsMo  MacVlanMode
+	macvlanAuto  bool
+	wireGuard    *WireGuardConfig
+	veth         *VethConfig
+	slirp        *SlirpConfig
+	portForwards []portForward
+	netPolicy    *NetworkPolicy
+	resolvConf   *resolvConf
+	hostsEntries []HostsEntry
+	dns          *DNSConfig
+	httpCapture  *HTTPCaptureConfig
+	egressLimit  uint64
 
 	// Seccomp
 	seccompPolicy string
@@ -179,6 +215,11 @@ type NsJail struct {
 	cgroupv2Mount  string
 	useCgroupv2    bool
 	detectCgroupv2 bool
+	cgroupV2       *cgroupV2
+	cgroupAuto     bool // the hierarchy is detected, see WithMemoryLimit
+
+	// Cgroups created by the wrapper (Start/Run only)
+	cgroupAutoParent bool
 
 	// Other
 	logFile        string
@@ -190,55 +231,176 @@ type NsJail struct {
 	niceLevel      int
 	disableTsc     bool
 	forwardSignals bool
+
+	// Runtime (Start/Run only)
+	stdin            io.Reader
+	stdinFeed        *stdinFeed
+	stdinDeadline    time.Duration
+	stdout           io.Writer
+	stderr           io.Writer
+	watches          []dirWatch
+	fileLimits       []fileLimit
+	initShim         string
+	drainTimeout     time.Duration
+	streamBuffering  *StreamConfig
+	killSignal       syscall.Signal
+	killGrace        time.Duration
+	sessionAgent     bool
+	workspace        *WorkspaceOptions
+	artifactPatterns []string
+	artifactOptions  ArtifactOptions
+	secrets          []secret
+	randomIdentity   bool
+	pidFile          string
+	watchdog         *Watchdog
+	seccompAudit     string
+	fileAccess       *FileAccessPolicy
+	runID            string // the identifier of a started run, see WithRandomIdentity
+
+	// Log analysis (Start/Run only)
+	isolationWarnings bool
+	logEvents         []LogEventFunc
+	logger            *slog.Logger
+
+	// Listen mode (Start/Run only)
+	connDeadline   time.Duration
+	exitAfterConns uint
+	restartLimit   uint
+	connFile       *os.File
+	dryRun         io.Writer
+	executor       Executor
+
+	// Compatibility with the installed binary
+	compat     CompatPolicy
+	binaryCaps *Capabilities
+
+	// Flags built, until a builder method runs
+	argv *argvCache
+
+	// Invalid arguments given to builder methods, see Validate
+	errs []error
 }
 
 // New creates a new NsJail configuration for the given command and arguments.
 // The path to the nsjail binary defaults to "nsjail" and can be overridden with WithPath().
+// Options set with SetDefaults are applied first.
 func New(cmd string, args ...string) *NsJail {
+	return applyDefaults(newBare(cmd, args))
+}
+
+// NewFromConfigFile creates a new NsJail configuration running the jail described by the nsjail config
+// file in ProtoBuf format at path, including its exec_bin, see WithConfigFile. Options set with SetDefaults
+// and builder methods add flags, which nsjail applies over the file.
+func NewFromConfigFile(path string) *NsJail {
+	return New("").WithConfigFile(path)
+}
+
+// newBare returns a configuration with no options set, not even the defaults.
+func newBare(cmd string, args []string) *NsJail {
 	return &NsJail{
 		path:      "nsjail",
 		execCmd:   cmd,
 		args:      args,
 		logFd:     -1,   // Use -1 to indicate not set, nsjail default is 2
 		niceLevel: -256, // Use magic number to indicate not set
+		argv:      new(argvCache),
 	}
 }
 
 // Exec builds the final exec.Cmd object based on the NsJail configuration.
 // This allows the caller to manage stdin/stdout/stderr and how the process is run.
 func (n *NsJail) Exec() (*exec.Cmd, error) {
-	args := []string{}
+	c, err := n.Build()
+	if err != nil {
+		return nil, err
+	}
+	if err := n.verifyBinary(); err != nil {
+		return nil, err
+	}
+	cmd := c.cmd()
+	n.log().Debug("nsjail: command built", "path", cmd.Path, "args", cmd.Args[1:])
+	if n.stdinFeed != nil {
+		if cmd.Stdin, err = n.stdinFeed.reader(); err != nil {
+			return nil, err
+		}
+		cmd.WaitDelay = n.stdinDeadlineDuration()
+	}
+	return cmd, nil
+}
+
+// Args returns the exact argv that Exec would run, starting with the path of the nsjail binary, or of
+// taskset with WithCpuSet and numactl with WithCpuSetMems, without building an exec.Cmd. Useful for audit
+// logging and for asserting on generated flags.
+func (n *NsJail) Args() ([]string, error) {
+	l, err := n.newLaunch()
+	if err != nil {
+		return nil, err
+	}
+	c := l.build(n.path)
+	return append([]string{c.Path}, c.Args...), nil
+}
+
+// option is a single nsjail option with its value, if it takes one.
+type option struct {
+	flag     string
+	value    string
+	hasValue bool
+}
+
+// flatten converts options into arguments, in a slice of exactly their number.
+func flatten(opts []option) []string {
+	size := len(opts)
+	for _, o := range opts {
+		if o.hasValue {
+			size++
+		}
+	}
+	args := make([]string, 0, size)
+	for _, o := range opts {
+		args = append(args, o.flag)
+		if o.hasValue {
+			args = append(args, o.value)
+		}
+	}
+	return args
+}
+
+// options returns the nsjail options for the configuration in the order they are passed.
+func (n *NsJail) options() []option { return n.appendOptions(nil) }
+
+// appendOptions appends the options of the configuration to args.
+func (n *NsJail) appendOptions(args []option) []option {
 
 	// Helper functions
 	appendFlag := func(flag, value string) {
 		if value != "" {
-			args = append(args, flag, value)
+			args = append(args, option{flag, value, true})
 		}
 	}
 	appendFlagUint := func(flag string, value uint) {
 		if value > 0 {
-			args = append(args, flag, strconv.FormatUint(uint64(value), 10))
+			args = append(args, option{flag, strconv.FormatUint(uint64(value), 10), true})
 		}
 	}
 	appendFlagUint64 := func(flag string, value uint64) {
 		if value > 0 {
-			args = append(args, flag, strconv.FormatUint(value, 10))
+			args = append(args, option{flag, strconv.FormatUint(value, 10), true})
 		}
 	}
 	appendFlagBool := func(flag string, value bool) {
 		if value {
-			args = append(args, flag)
+			args = append(args, option{flag: flag})
 		}
 	}
 	appendFlagSlice := func(flag string, values []string) {
 		for _, v := range values {
-			args = append(args, flag, v)
+			args = append(args, option{flag, v, true})
 		}
 	}
 
 	// Build arguments from configuration
 	if n.mode != "" {
-		args = append(args, "-M", string(n.mode))
+		args = append(args, option{"-M", string(n.mode), true})
 	}
 	appendFlag("-C", n.configFile)
 	appendFlag("-x", n.execFile)
@@ -259,7 +421,7 @@ func (n *NsJail) Exec() (*exec.Cmd, error) {
 	appendFlagBool("--stderr_to_null", n.stderrToNull)
 	appendFlagBool("--skip_setsid", n.skipSetsid)
 	for _, fd := range n.passFds {
-		args = append(args, "--pass_fd", strconv.Itoa(fd))
+		args = append(args, option{"--pass_fd", strconv.Itoa(fd), true})
 	}
 	appendFlagBool("--disable_no_new_privs", n.disableNoNewPrivs)
 
@@ -294,23 +456,26 @@ func (n *NsJail) Exec() (*exec.Cmd, error) {
 	appendFlagBool("--persona_addr_limit_3gb", n.personaAddrLimit3gb)
 	appendFlagBool("--persona_addr_no_randomize", n.personaAddrNoRandomize)
 
+	if n.overlay != nil && n.overlay.upper != "" {
+		args = append(args, option{"-m", n.overlay.mount(), true})
+	}
 	appendFlagSlice("-R", n.bindMountsRO)
 	appendFlagSlice("-B", n.bindMountsRW)
 	appendFlagSlice("-T", n.tmpfsMounts)
 	for _, m := range n.mounts {
 		mountStr := fmt.Sprintf("%s:%s:%s:%s", m.Src, m.Dst, m.FsType, m.Opts)
-		args = append(args, "-m", mountStr)
+		args = append(args, option{"-m", mountStr, true})
 	}
 	for _, s := range n.symlinks {
 		symlinkStr := fmt.Sprintf("%s:%s", s.Src, s.Dst)
-		args = append(args, "-s", symlinkStr)
+		args = append(args, option{"-s", symlinkStr, true})
 	}
 	appendFlagBool("--disable_proc", n.procMountDisabled)
 	appendFlag("--proc_path", n.procPath)
 	appendFlagBool("--proc_rw", n.procRw)
 
 	if n.port > 0 {
-		args = append(args, "-p", strconv.Itoa(int(n.port)))
+		args = append(args, option{"-p", strconv.Itoa(int(n.port)), true})
 	}
 	appendFlag("--bindhost", n.bindhost)
 	appendFlagUint("--max_conns", n.maxConns)
@@ -324,7 +489,7 @@ func (n *NsJail) Exec() (*exec.Cmd, error) {
 	appendFlag("--macvlan_vs_gw", n.macvlanVsGw)
 	appendFlag("--macvlan_vs_ma", n.macvlanVsMa)
 	if n.macvlanVsMo != "" {
-		args = append(args, "--macvlan_vs_mo", string(n.macvlanVsMo))
+		args = append(args, option{"--macvlan_vs_mo", string(n.macvlanVsMo), true})
 	}
 
 	appendFlag("-P", n.seccompPolicy)
@@ -340,7 +505,7 @@ func (n *NsJail) Exec() (*exec.Cmd, error) {
 	appendFlag("--cgroup_pids_mount", n.cgroupPidsMount)
 	appendFlag("--cgroup_pids_parent", n.cgroupPidsParent)
 	if n.cgroupNetClsClassid > 0 {
-		args = append(args, "--cgroup_net_cls_classid", fmt.Sprintf("0x%x", n.cgroupNetClsClassid))
+		args = append(args, option{"--cgroup_net_cls_classid", fmt.Sprintf("0x%x", n.cgroupNetClsClassid), true})
 	}
 	appendFlag("--cgroup_net_cls_mount", n.cgroupNetClsMount)
 	appendFlag("--cgroup_net_cls_parent", n.cgroupNetClsParent)
@@ -353,26 +518,47 @@ func (n *NsJail) Exec() (*exec.Cmd, error) {
 
 	appendFlag("-l", n.logFile)
 	if n.logFd != -1 {
-		args = append(args, "-L", strconv.Itoa(n.logFd))
+		args = append(args, option{"-L", strconv.Itoa(n.logFd), true})
 	}
 	appendFlagBool("-d", n.daemon)
 	appendFlagBool("-v", n.verbose)
 	appendFlagBool("-q", n.quiet)
 	appendFlagBool("-Q", n.reallyQuiet)
 	if n.niceLevel != -256 {
-		args = append(args, "--nice_level", strconv.Itoa(n.niceLevel))
+		args = append(args, option{"--nice_level", strconv.Itoa(n.niceLevel), true})
 	}
 	appendFlagBool("--disable_tsc", n.disableTsc)
 	appendFlagBool("--forward_signals", n.forwardSignals)
 
-	// Command and its arguments
-	if n.execCmd != "" {
-		args = append(args, "--", n.execCmd)
-		args = append(args, n.args...)
+	return args
+}
+
+// validateCommand checks that there is a command to run and that it can be passed to execve.
+func (n *NsJail) validateCommand() error {
+	if n.execCmd == "" && n.configFile == "" {
+		if len(n.args) > 0 {
+			return fmt.Errorf("nsjail: arguments %q set without a command", n.args)
+		}
+		// Sessions run the agent of the init shim instead.
+		if !n.sessionAgent {
+			return errors.New("nsjail: no command to run: set one with New or WithCommand, or use a config file")
+		}
+	}
+	for _, a := range append([]string{n.execCmd}, n.args...) {
+		if strings.IndexByte(a, 0) >= 0 {
+			return fmt.Errorf("nsjail: command argument %q contains a NUL byte", a)
+		}
 	}
+	return nil
+}
 
-	cmd := exec.Command(n.path, args...)
-	return cmd, nil
+// command returns the jailed command and its arguments, or nil if none is set. It always follows "--" on
+// the command line of nsjail, so nsjail never parses it as options.
+func (n *NsJail) command() []string {
+	if n.execCmd == "" {
+		return nil
+	}
+	return append([]string{n.execCmd}, n.args...)
 }
 
 // String returns the string representation of the command to be executed. Useful for debugging.
@@ -388,329 +574,502 @@ func (n *NsJail) String() string {
 // --- Builder Methods ---
 
 // WithPath sets the path to the nsjail binary.
-func (n *NsJail) WithPath(path string) *NsJail { n.path = path; return n }
+func (n *NsJail) WithPath(path string) *NsJail { n.path = path; return n.changed() }
+
+// WithCommand sets the command run in the jail and its arguments, replacing those given to New. The
+// command line is passed after a "--" separator ending the options of nsjail, so arguments starting with
+// "-", including further "--", reach the command unchanged; no shell is involved, so they are never split
+// or expanded either.
+func (n *NsJail) WithCommand(cmd string, args ...string) *NsJail {
+	n.execCmd = cmd
+	n.args = slices.Clone(args)
+	return n.changed()
+}
+
+// WithArgs replaces the arguments of the command, see WithCommand.
+func (n *NsJail) WithArgs(args ...string) *NsJail { n.args = slices.Clone(args); return n.changed() }
+
+// AppendArgs appends arguments to those of the command, see WithCommand.
+func (n *NsJail) AppendArgs(args ...string) *NsJail {
+	n.args = append(n.args, args...)
+	return n.changed()
+}
 
 // WithMode sets the execution mode (-M).
-func (n *NsJail) WithMode(mode Mode) *NsJail { n.mode = mode; return n }
+func (n *NsJail) WithMode(mode Mode) *NsJail {
+	switch mode {
+	case "", ModeListenTCP, ModeOnce, ModeExecve, ModeRerun:
+		n.mode = mode
+	default:
+		n.fail("WithMode", "unknown mode %q", mode)
+	}
+	return n.changed()
+}
 
-// WithConfigFile uses a configuration file in ProtoBuf format (-C).
-func (n *NsJail) WithConfigFile(path string) *NsJail { n.configFile = path; return n }
+// Mode returns the execution mode set with WithMode, or ModeOnce, the default of nsjail.
+func (n *NsJail) Mode() Mode {
+	if n.mode == "" {
+		return ModeOnce
+	}
+	return n.mode
+}
+
+// WithConfigFile uses a configuration file in ProtoBuf format (-C). The command is then optional: the one of
+// New or WithCommand replaces exec_bin of the file, binary included (-x), while arguments set with WithArgs
+// without a command only replace the argv of exec_bin, starting with argv[0].
+func (n *NsJail) WithConfigFile(path string) *NsJail { n.configFile = path; return n.changed() }
 
 // WithExecFile sets the file to exec (-x).
-func (n *NsJail) WithExecFile(path string) *NsJail { n.execFile = path; return n }
+func (n *NsJail) WithExecFile(path string) *NsJail { n.execFile = path; return n.changed() }
 
 // EnableExecuteFd uses execveat() to execute a file-descriptor instead of a path (--execute_fd).
-func (n *NsJail) EnableExecuteFd() *NsJail { n.executeFd = true; return n }
+func (n *NsJail) EnableExecuteFd() *NsJail { n.executeFd = true; return n.changed() }
 
 // WithChroot sets the directory to be the root of the jail (-c).
-func (n *NsJail) WithChroot(path string) *NsJail { n.chroot = path; return n }
+func (n *NsJail) WithChroot(path string) *NsJail { n.chroot = path; return n.changed() }
 
 // EnableNoPivotRoot uses mount(MS_MOVE) and chroot() instead of pivot_root() (--no_pivotroot).
-func (n *NsJail) EnableNoPivotRoot() *NsJail { n.noPivotRoot = true; return n }
+func (n *NsJail) EnableNoPivotRoot() *NsJail { n.noPivotRoot = true; return n.changed() }
 
 // MountChrootRW mounts the chroot directory as read-write (--rw). Default is read-only.
-func (n *NsJail) MountChrootRW() *NsJail { n.rwChroot = true; return n }
+func (n *NsJail) MountChrootRW() *NsJail { n.rwChroot = true; return n.changed() }
 
 // WithUser sets the user (uid or name) for the jailed process (-u).
-func (n *NsJail) WithUser(user string) *NsJail { n.user = user; return n }
+func (n *NsJail) WithUser(user string) *NsJail { n.user = user; return n.changed() }
 
 // WithGroup sets the group (gid or name) for the jailed process (-g).
-func (n *NsJail) WithGroup(group string) *NsJail { n.group = group; return n }
+func (n *NsJail) WithGroup(group string) *NsJail { n.group = group; return n.changed() }
 
 // WithHostname sets the hostname inside the jail (-H).
-func (n *NsJail) WithHostname(hostname string) *NsJail { n.hostname = hostname; return n }
+func (n *NsJail) WithHostname(hostname string) *NsJail { n.hostname = hostname; return n.changed() }
 
 // WithCwd sets the working directory inside the jail (-D).
-func (n *NsJail) WithCwd(cwd string) *NsJail { n.cwd = cwd; return n }
+func (n *NsJail) WithCwd(cwd string) *NsJail { n.cwd = cwd; return n.changed() }
 
 // WithTimeLimit sets the maximum time in seconds the jail can exist (-t).
-func (n *NsJail) WithTimeLimit(seconds uint64) *NsJail { n.timeLimit = seconds; return n }
+func (n *NsJail) WithTimeLimit(seconds uint64) *NsJail { n.timeLimit = seconds; return n.changed() }
 
 // Verbose enables verbose logging (-v).
-func (n *NsJail) Verbose() *NsJail { n.verbose = true; return n }
+func (n *NsJail) Verbose() *NsJail { n.verbose = true; return n.changed() }
 
 // Quiet enables quiet logging, showing only warnings and more important messages (-q).
-func (n *NsJail) Quiet() *NsJail { n.quiet = true; return n }
+func (n *NsJail) Quiet() *NsJail { n.quiet = true; return n.changed() }
 
 // ReallyQuiet enables logging of fatal messages only (-Q).
-func (n *NsJail) ReallyQuiet() *NsJail { n.reallyQuiet = true; return n }
+func (n *NsJail) ReallyQuiet() *NsJail { n.reallyQuiet = true; return n.changed() }
 
-// KeepEnv passes all environment variables to the child process (-e).
-func (n *NsJail) KeepEnv() *NsJail { n.keepEnv = true; return n }
+// KeepEnv passes all environment variables to the child process (-e). InheritEnvMatching and PassEnv pass
+// only selected ones, KeepEnvExcept all but selected ones.
+func (n *NsJail) KeepEnv() *NsJail { n.keepEnv = true; return n.changed() }
 
 // AddEnv adds an environment variable (-E). If value is empty, the current value is inherited.
 func (n *NsJail) AddEnv(key, value string) *NsJail {
+	if key == "" || strings.ContainsAny(key, "=\x00") {
+		n.fail("AddEnv", "invalid variable name %q", key)
+		return n.changed()
+	}
 	if value == "" {
 		n.envVars = append(n.envVars, key)
 	} else {
 		n.envVars = append(n.envVars, fmt.Sprintf("%s=%s", key, value))
 	}
-	return n
+	return n.changed()
 }
 
 // KeepCaps retains all capabilities (--keep_caps).
-func (n *NsJail) KeepCaps() *NsJail { n.keepCaps = true; return n }
+func (n *NsJail) KeepCaps() *NsJail { n.keepCaps = true; return n.changed() }
 
-// AddCap retains a specific capability, e.g., "CAP_PTRACE" (--cap). Can be called multiple times.
-func (n *NsJail) AddCap(cap string) *NsJail { n.caps = append(n.caps, cap); return n }
+// AddCap retains a specific capability, e.g., CapSysPtrace (--cap). Can be called multiple times. Unknown
+// capabilities are reported by Validate and Exec, as nsjail refuses to start with them.
+func (n *NsJail) AddCap(cap Capability) *NsJail {
+	n.caps = append(n.caps, string(cap))
+	return n.changed()
+}
 
 // Silent redirects the child's stdin, stdout, and stderr to /dev/null (--silent).
-func (n *NsJail) Silent() *NsJail { n.silent = true; return n }
+func (n *NsJail) Silent() *NsJail { n.silent = true; return n.changed() }
 
 // StderrToNull redirects the child's stderr to /dev/null (--stderr_to_null).
-func (n *NsJail) StderrToNull() *NsJail { n.stderrToNull = true; return n }
+func (n *NsJail) StderrToNull() *NsJail { n.stderrToNull = true; return n.changed() }
 
 // SkipSetsid avoids calling setsid(), allowing for terminal signal handling (--skip_setsid).
-func (n *NsJail) SkipSetsid() *NsJail { n.skipSetsid = true; return n }
+func (n *NsJail) SkipSetsid() *NsJail { n.skipSetsid = true; return n.changed() }
 
 // AddPassFd keeps a file descriptor open for the child process (--pass_fd). Can be called multiple times.
-func (n *NsJail) AddPassFd(fd int) *NsJail { n.passFds = append(n.passFds, fd); return n }
+// To pass a file of this process, use WithExtraFile, which also makes nsjail inherit it.
+func (n *NsJail) AddPassFd(fd int) *NsJail {
+	if fd < 0 {
+		n.fail("AddPassFd", "negative descriptor %d", fd)
+		return n.changed()
+	}
+	n.passFds = append(n.passFds, fd)
+	return n.changed()
+}
+
+// WithExtraFile passes f to the jailed process and returns its descriptor number there, e.g. to name it on
+// the command line as /dev/fd/N. nsjail inherits f like exec.Cmd.ExtraFiles and keeps it open with
+// --pass_fd, so the number is the same in nsjail and in the jail: 3 for the first file, 4 for the second,
+// and so on. Unlike the other builder methods it does not return n. The caller keeps ownership of f and
+// may close it once the jail started.
+func (n *NsJail) WithExtraFile(f *os.File) int {
+	n.extraFiles = append(n.extraFiles, f)
+	return 2 + len(n.extraFiles)
+}
 
 // DisableNoNewPrivs allows the jailed process to gain new privileges (--disable_no_new_privs). DANGEROUS.
-func (n *NsJail) DisableNoNewPrivs() *NsJail { n.disableNoNewPrivs = true; return n }
+func (n *NsJail) DisableNoNewPrivs() *NsJail { n.disableNoNewPrivs = true; return n.changed() }
 
 // WithRlimitAs sets RLIMIT_AS in MB (--rlimit_as). Use a number string or a RlimitVal constant.
-func (n *NsJail) WithRlimitAs(val string) *NsJail { n.rlimitAs = val; return n }
+func (n *NsJail) WithRlimitAs(val string) *NsJail {
+	return n.setRlimit("WithRlimitAs", &n.rlimitAs, val)
+}
 
 // WithRlimitCore sets RLIMIT_CORE in MB (--rlimit_core). Use a number string or a RlimitVal constant.
-func (n *NsJail) WithRlimitCore(val string) *NsJail { n.rlimitCore = val; return n }
+func (n *NsJail) WithRlimitCore(val string) *NsJail {
+	return n.setRlimit("WithRlimitCore", &n.rlimitCore, val)
+}
 
 // WithRlimitCpu sets RLIMIT_CPU in seconds (--rlimit_cpu). Use a number string or a RlimitVal constant.
-func (n *NsJail) WithRlimitCpu(val string) *NsJail { n.rlimitCpu = val; return n }
+func (n *NsJail) WithRlimitCpu(val string) *NsJail {
+	return n.setRlimit("WithRlimitCpu", &n.rlimitCpu, val)
+}
 
 // WithRlimitFsize sets RLIMIT_FSIZE in MB (--rlimit_fsize). Use a number string or a RlimitVal constant.
-func (n *NsJail) WithRlimitFsize(val string) *NsJail { n.rlimitFsize = val; return n }
+func (n *NsJail) WithRlimitFsize(val string) *NsJail {
+	return n.setRlimit("WithRlimitFsize", &n.rlimitFsize, val)
+}
 
 // WithRlimitNofile sets RLIMIT_NOFILE (--rlimit_nofile). Use a number string or a RlimitVal constant.
-func (n *NsJail) WithRlimitNofile(val string) *NsJail { n.rlimitNofile = val; return n }
+func (n *NsJail) WithRlimitNofile(val string) *NsJail {
+	return n.setRlimit("WithRlimitNofile", &n.rlimitNofile, val)
+}
 
 // WithRlimitNproc sets RLIMIT_NPROC (--rlimit_nproc). Use a number string or a RlimitVal constant.
-func (n *NsJail) WithRlimitNproc(val string) *NsJail { n.rlimitNproc = val; return n }
+func (n *NsJail) WithRlimitNproc(val string) *NsJail {
+	return n.setRlimit("WithRlimitNproc", &n.rlimitNproc, val)
+}
 
 // WithRlimitStack sets RLIMIT_STACK in MB (--rlimit_stack). Use a number string or a RlimitVal constant.
-func (n *NsJail) WithRlimitStack(val string) *NsJail { n.rlimitStack = val; return n }
+func (n *NsJail) WithRlimitStack(val string) *NsJail {
+	return n.setRlimit("WithRlimitStack", &n.rlimitStack, val)
+}
 
 // WithRlimitMemlock sets RLIMIT_MEMLOCK in KB (--rlimit_memlock). Use a number string or a RlimitVal constant.
-func (n *NsJail) WithRlimitMemlock(val string) *NsJail { n.rlimitMemlock = val; return n }
+func (n *NsJail) WithRlimitMemlock(val string) *NsJail {
+	return n.setRlimit("WithRlimitMemlock", &n.rlimitMemlock, val)
+}
 
 // WithRlimitRtprio sets RLIMIT_RTPRIO (--rlimit_rtprio). Use a number string or a RlimitVal constant.
-func (n *NsJail) WithRlimitRtprio(val string) *NsJail { n.rlimitRtprio = val; return n }
+func (n *NsJail) WithRlimitRtprio(val string) *NsJail {
+	return n.setRlimit("WithRlimitRtprio", &n.rlimitRtprio, val)
+}
 
 // WithRlimitMsgqueue sets RLIMIT_MSGQUEUE in bytes (--rlimit_msgqueue). Use a number string or a RlimitVal constant.
-func (n *NsJail) WithRlimitMsgqueue(val string) *NsJail { n.rlimitMsgqueue = val; return n }
+func (n *NsJail) WithRlimitMsgqueue(val string) *NsJail {
+	return n.setRlimit("WithRlimitMsgqueue", &n.rlimitMsgqueue, val)
+}
 
 // DisableRlimits disables all rlimits, using the parent's limits instead (--disable_rlimits).
-func (n *NsJail) DisableRlimits() *NsJail { n.disableRlimits = true; return n }
+func (n *NsJail) DisableRlimits() *NsJail { n.disableRlimits = true; return n.changed() }
 
 // EnablePersonaAddrCompatLayout sets personality(ADDR_COMPAT_LAYOUT) (--persona_addr_compat_layout).
-func (n *NsJail) EnablePersonaAddrCompatLayout() *NsJail { n.personaAddrCompatLayout = true; return n }
+func (n *NsJail) EnablePersonaAddrCompatLayout() *NsJail {
+	n.personaAddrCompatLayout = true
+	return n.changed()
+}
 
 // EnablePersonaMmapPageZero sets personality(MMAP_PAGE_ZERO) (--persona_mmap_page_zero).
-func (n *NsJail) EnablePersonaMmapPageZero() *NsJail { n.personaMmapPageZero = true; return n }
+func (n *NsJail) EnablePersonaMmapPageZero() *NsJail {
+	n.personaMmapPageZero = true
+	return n.changed()
+}
 
 // EnablePersonaReadImpliesExec sets personality(READ_IMPLIES_EXEC) (--persona_read_implies_exec).
-func (n *NsJail) EnablePersonaReadImpliesExec() *NsJail { n.personaReadImpliesExec = true; return n }
+func (n *NsJail) EnablePersonaReadImpliesExec() *NsJail {
+	n.personaReadImpliesExec = true
+	return n.changed()
+}
 
 // EnablePersonaAddrLimit3gb sets personality(ADDR_LIMIT_3GB) (--persona_addr_limit_3gb).
-func (n *NsJail) EnablePersonaAddrLimit3gb() *NsJail { n.personaAddrLimit3gb = true; return n }
+func (n *NsJail) EnablePersonaAddrLimit3gb() *NsJail {
+	n.personaAddrLimit3gb = true
+	return n.changed()
+}
 
 // EnablePersonaAddrNoRandomize sets personality(ADDR_NO_RANDOMIZE) (--persona_addr_no_randomize).
-func (n *NsJail) EnablePersonaAddrNoRandomize() *NsJail { n.personaAddrNoRandomize = true; return n }
+func (n *NsJail) EnablePersonaAddrNoRandomize() *NsJail {
+	n.personaAddrNoRandomize = true
+	return n.changed()
+}
 
 // DisableCloneNewNet disables the CLONE_NEWNET flag, allowing global network access (-N).
-func (n *NsJail) DisableCloneNewNet() *NsJail { n.cloneNewNetDisabled = true; return n }
+func (n *NsJail) DisableCloneNewNet() *NsJail { n.cloneNewNetDisabled = true; return n.changed() }
 
 // DisableCloneNewUser disables CLONE_NEWUSER (--disable_clone_newuser). Requires euid==0.
-func (n *NsJail) DisableCloneNewUser() *NsJail { n.cloneNewUserDisabled = true; return n }
+func (n *NsJail) DisableCloneNewUser() *NsJail { n.cloneNewUserDisabled = true; return n.changed() }
 
 // DisableCloneNewNs disables CLONE_NEWNS (--disable_clone_newns).
-func (n *NsJail) DisableCloneNewNs() *NsJail { n.cloneNewNsDisabled = true; return n }
+func (n *NsJail) DisableCloneNewNs() *NsJail { n.cloneNewNsDisabled = true; return n.changed() }
 
 // DisableCloneNewPid disables CLONE_NEWPID (--disable_clone_newpid).
-func (n *NsJail) DisableCloneNewPid() *NsJail { n.cloneNewPidDisabled = true; return n }
+func (n *NsJail) DisableCloneNewPid() *NsJail { n.cloneNewPidDisabled = true; return n.changed() }
 
 // DisableCloneNewIpc disables CLONE_NEWIPC (--disable_clone_newipc).
-func (n *NsJail) DisableCloneNewIpc() *NsJail { n.cloneNewIpcDisabled = true; return n }
+func (n *NsJail) DisableCloneNewIpc() *NsJail { n.cloneNewIpcDisabled = true; return n.changed() }
 
 // DisableCloneNewUts disables CLONE_NEWUTS (--disable_clone_newuts).
-func (n *NsJail) DisableCloneNewUts() *NsJail { n.cloneNewUtsDisabled = true; return n }
+func (n *NsJail) DisableCloneNewUts() *NsJail { n.cloneNewUtsDisabled = true; return n.changed() }
 
 // DisableCloneNewCgroup disables CLONE_NEWCGROUP (--disable_clone_newcgroup).
-func (n *NsJail) DisableCloneNewCgroup() *NsJail { n.cloneNewCgroupDisabled = true; return n }
+func (n *NsJail) DisableCloneNewCgroup() *NsJail { n.cloneNewCgroupDisabled = true; return n.changed() }
 
 // EnableCloneNewTime enables CLONE_NEWTIME (--enable_clone_newtime). Kernel >= 5.3.
-func (n *NsJail) EnableCloneNewTime() *NsJail { n.cloneNewTimeEnabled = true; return n }
+func (n *NsJail) EnableCloneNewTime() *NsJail { n.cloneNewTimeEnabled = true; return n.changed() }
 
 // AddUidMapping adds a custom uid mapping of the form "inside_uid:outside_uid:count" (-U).
+//
+// Deprecated: Use AddUidMap.
 func (n *NsJail) AddUidMapping(mapping string) *NsJail {
+	if _, err := parseIDMap(mapping); err != nil {
+		n.fail("AddUidMapping", "invalid mapping %q: %v", mapping, err)
+		return n.changed()
+	}
 	n.uidMappings = append(n.uidMappings, mapping)
-	return n
+	return n.changed()
 }
 
 // AddGidMapping adds a custom gid mapping of the form "inside_gid:outside_gid:count" (-G).
+//
+// Deprecated: Use AddGidMap.
 func (n *NsJail) AddGidMapping(mapping string) *NsJail {
+	if _, err := parseIDMap(mapping); err != nil {
+		n.fail("AddGidMapping", "invalid mapping %q: %v", mapping, err)
+		return n.changed()
+	}
 	n.gidMappings = append(n.gidMappings, mapping)
-	return n
+	return n.changed()
 }
 
-// AddBindMountRO adds a read-only bind mount (-R). Supports 'source' or 'source:dest'.
+// AddBindMountRO adds a read-only bind mount (-R). Supports 'source' or 'source:dest'. See also AddBindRO.
 func (n *NsJail) AddBindMountRO(path string) *NsJail {
+	if path == "" || strings.HasPrefix(path, ":") {
+		n.fail("AddBindMountRO", "empty source")
+		return n.changed()
+	}
 	n.bindMountsRO = append(n.bindMountsRO, path)
-	return n
+	return n.changed()
 }
 
-// AddBindMountRW adds a read-write bind mount (-B). Supports 'source' or 'source:dest'.
+// AddBindMountRW adds a read-write bind mount (-B). Supports 'source' or 'source:dest'. See also AddBindRW.
 func (n *NsJail) AddBindMountRW(path string) *NsJail {
+	if path == "" || strings.HasPrefix(path, ":") {
+		n.fail("AddBindMountRW", "empty source")
+		return n.changed()
+	}
 	n.bindMountsRW = append(n.bindMountsRW, path)
-	return n
+	return n.changed()
 }
 
 // AddTmpfsMount adds a tmpfs mount at the specified destination (-T).
 func (n *NsJail) AddTmpfsMount(dest string) *NsJail {
+	if dest == "" {
+		n.fail("AddTmpfsMount", "empty destination")
+		return n.changed()
+	}
 	n.tmpfsMounts = append(n.tmpfsMounts, dest)
-	return n
+	return n.changed()
 }
 
 // AddMount adds an arbitrary mount point (-m), e.g., AddMount("src", "dst", "type", "options").
 func (n *NsJail) AddMount(src, dst, fsType, opts string) *NsJail {
+	if dst == "" {
+		n.fail("AddMount", "empty destination")
+		return n.changed()
+	}
 	n.mounts = append(n.mounts, Mount{Src: src, Dst: dst, FsType: fsType, Opts: opts})
-	return n
+	return n.changed()
 }
 
 // AddSymlink creates a symlink inside the jail (-s), e.g., AddSymlink("src", "dst").
 func (n *NsJail) AddSymlink(src, dst string) *NsJail {
+	if src == "" || dst == "" {
+		n.fail("AddSymlink", "empty source or destination")
+		return n.changed()
+	}
 	n.symlinks = append(n.symlinks, Symlink{Src: src, Dst: dst})
-	return n
+	return n.changed()
 }
 
 // DisableProcMount disables mounting procfs in the jail (--disable_proc).
-func (n *NsJail) DisableProcMount() *NsJail { n.procMountDisabled = true; return n }
+func (n *NsJail) DisableProcMount() *NsJail { n.procMountDisabled = true; return n.changed() }
 
 // WithProcPath sets the path to mount procfs (--proc_path). Default is '/proc'.
-func (n *NsJail) WithProcPath(path string) *NsJail { n.procPath = path; return n }
+func (n *NsJail) WithProcPath(path string) *NsJail { n.procPath = path; return n.changed() }
 
 // MountProcRW mounts procfs as read-write (--proc_rw). Default is read-only.
-func (n *NsJail) MountProcRW() *NsJail { n.procRw = true; return n }
+func (n *NsJail) MountProcRW() *NsJail { n.procRw = true; return n.changed() }
 
 // WithSeccompString uses a kafel seccomp-bpf policy from a string (--seccomp_string).
-func (n *NsJail) WithSeccompString(policy string) *NsJail { n.seccompString = policy; return n }
+func (n *NsJail) WithSeccompString(policy string) *NsJail {
+	n.seccompString = policy
+	return n.changed()
+}
 
 // WithSeccompPolicy uses a kafel seccomp-bpf policy from a file (-P).
-func (n *NsJail) WithSeccompPolicy(path string) *NsJail { n.seccompPolicy = path; return n }
+func (n *NsJail) WithSeccompPolicy(path string) *NsJail { n.seccompPolicy = path; return n.changed() }
 
 // EnableSeccompLog enables logging of seccomp filter actions (--seccomp_log). Kernel >= 4.14.
-func (n *NsJail) EnableSeccompLog() *NsJail { n.seccompLog = true; return n }
+func (n *NsJail) EnableSeccompLog() *NsJail { n.seccompLog = true; return n.changed() }
 
 // WithNiceLevel sets the niceness of the jailed process (--nice_level). Range: -20 (high prio) to 19 (low prio).
-func (n *NsJail) WithNiceLevel(level int) *NsJail { n.niceLevel = level; return n }
+func (n *NsJail) WithNiceLevel(level int) *NsJail {
+	if level < -20 || level > 19 {
+		n.fail("WithNiceLevel", "level %d out of range [-20, 19]", level)
+		return n.changed()
+	}
+	n.niceLevel = level
+	return n.changed()
+}
 
 // WithCgroupMemMax sets the memory cgroup's max bytes (--cgroup_mem_max).
-func (n *NsJail) WithCgroupMemMax(bytes uint64) *NsJail { n.cgroupMemMax = bytes; return n }
+func (n *NsJail) WithCgroupMemMax(bytes uint64) *NsJail { n.cgroupMemMax = bytes; return n.changed() }
 
 // WithCgroupMemMemswMax sets the memory cgroup's memory+swap max bytes (--cgroup_mem_memsw_max).
-func (n *NsJail) WithCgroupMemMemswMax(bytes uint64) *NsJail { n.cgroupMemMemswMax = bytes; return n }
+func (n *NsJail) WithCgroupMemMemswMax(bytes uint64) *NsJail {
+	n.cgroupMemMemswMax = bytes
+	return n.changed()
+}
 
 // WithCgroupMemSwapMax sets the memory cgroup's swap max bytes (--cgroup_mem_swap_max). Use "-1" for unlimited.
-func (n *NsJail) WithCgroupMemSwapMax(bytes string) *NsJail { n.cgroupMemSwapMax = bytes; return n }
+func (n *NsJail) WithCgroupMemSwapMax(bytes string) *NsJail {
+	n.cgroupMemSwapMax = bytes
+	return n.changed()
+}
 
 // WithCgroupMemMount sets the memory cgroup mount point (--cgroup_mem_mount).
-func (n *NsJail) WithCgroupMemMount(path string) *NsJail { n.cgroupMemMount = path; return n }
+func (n *NsJail) WithCgroupMemMount(path string) *NsJail { n.cgroupMemMount = path; return n.changed() }
 
 // WithCgroupMemParent sets the parent memory cgroup (--cgroup_mem_parent).
-func (n *NsJail) WithCgroupMemParent(parent string) *NsJail { n.cgroupMemParent = parent; return n }
+func (n *NsJail) WithCgroupMemParent(parent string) *NsJail {
+	n.cgroupMemParent = parent
+	return n.changed()
+}
 
 // WithCgroupPidsMax sets the pids cgroup's max number of PIDs (--cgroup_pids_max).
-func (n *NsJail) WithCgroupPidsMax(max uint) *NsJail { n.cgroupPidsMax = max; return n }
+func (n *NsJail) WithCgroupPidsMax(max uint) *NsJail { n.cgroupPidsMax = max; return n.changed() }
 
 // WithCgroupPidsMount sets the pids cgroup mount point (--cgroup_pids_mount).
-func (n *NsJail) WithCgroupPidsMount(path string) *NsJail { n.cgroupPidsMount = path; return n }
+func (n *NsJail) WithCgroupPidsMount(path string) *NsJail {
+	n.cgroupPidsMount = path
+	return n.changed()
+}
 
 // WithCgroupPidsParent sets the parent pids cgroup (--cgroup_pids_parent).
-func (n *NsJail) WithCgroupPidsParent(parent string) *NsJail { n.cgroupPidsParent = parent; return n }
+func (n *NsJail) WithCgroupPidsParent(parent string) *NsJail {
+	n.cgroupPidsParent = parent
+	return n.changed()
+}
 
 // WithCgroupNetClsClassid sets the net_cls cgroup's class ID (--cgroup_net_cls_classid).
-func (n *NsJail) WithCgroupNetClsClassid(id uint32) *NsJail { n.cgroupNetClsClassid = id; return n }
+func (n *NsJail) WithCgroupNetClsClassid(id uint32) *NsJail {
+	n.cgroupNetClsClassid = id
+	return n.changed()
+}
 
 // WithCgroupNetClsMount sets the net_cls cgroup mount point (--cgroup_net_cls_mount).
-func (n *NsJail) WithCgroupNetClsMount(path string) *NsJail { n.cgroupNetClsMount = path; return n }
+func (n *NsJail) WithCgroupNetClsMount(path string) *NsJail {
+	n.cgroupNetClsMount = path
+	return n.changed()
+}
 
 // WithCgroupNetClsParent sets the parent net_cls cgroup (--cgroup_net_cls_parent).
 func (n *NsJail) WithCgroupNetClsParent(parent string) *NsJail {
 	n.cgroupNetClsParent = parent
-	return n
+	return n.changed()
 }
 
 // WithCgroupCpuMsPerSec sets the CPU cgroup's milliseconds of CPU time per second (--cgroup_cpu_ms_per_sec).
-func (n *NsJail) WithCgroupCpuMsPerSec(ms uint) *NsJail { n.cgroupCpuMsPerSec = ms; return n }
+func (n *NsJail) WithCgroupCpuMsPerSec(ms uint) *NsJail { n.cgroupCpuMsPerSec = ms; return n.changed() }
 
 // WithCgroupCpuMount sets the CPU cgroup mount point (--cgroup_cpu_mount).
-func (n *NsJail) WithCgroupCpuMount(path string) *NsJail { n.cgroupCpuMount = path; return n }
+func (n *NsJail) WithCgroupCpuMount(path string) *NsJail { n.cgroupCpuMount = path; return n.changed() }
 
 // WithCgroupCpuParent sets the parent CPU cgroup (--cgroup_cpu_parent).
-func (n *NsJail) WithCgroupCpuParent(parent string) *NsJail { n.cgroupCpuParent = parent; return n }
+func (n *NsJail) WithCgroupCpuParent(parent string) *NsJail {
+	n.cgroupCpuParent = parent
+	return n.changed()
+}
 
 // WithCgroupV2Mount sets the cgroupv2 mount point (--cgroupv2_mount).
-func (n *NsJail) WithCgroupV2Mount(path string) *NsJail { n.cgroupv2Mount = path; return n }
+func (n *NsJail) WithCgroupV2Mount(path string) *NsJail { n.cgroupv2Mount = path; return n.changed() }
 
 // UseCgroupV2 forces the use of cgroup v2 (--use_cgroupv2).
-func (n *NsJail) UseCgroupV2() *NsJail { n.useCgroupv2 = true; return n }
+func (n *NsJail) UseCgroupV2() *NsJail { n.useCgroupv2 = true; return n.changed() }
 
 // DetectAndUseCgroupV2 automatically uses cgroup v2 if available (--detect_cgroupv2).
-func (n *NsJail) DetectAndUseCgroupV2() *NsJail { n.detectCgroupv2 = true; return n }
+func (n *NsJail) DetectAndUseCgroupV2() *NsJail { n.detectCgroupv2 = true; return n.changed() }
 
 // DisableLoopbackInterface prevents bringing up the 'lo' interface (--iface_no_lo).
-func (n *NsJail) DisableLoopbackInterface() *NsJail { n.ifaceNoLo = true; return n }
+func (n *NsJail) DisableLoopbackInterface() *NsJail { n.ifaceNoLo = true; return n.changed() }
 
 // AddOwnInterface moves an existing network interface into the new NET namespace (--iface_own).
 func (n *NsJail) AddOwnInterface(iface string) *NsJail {
 	n.ifaceOwn = append(n.ifaceOwn, iface)
-	return n
+	return n.changed()
 }
 
 // WithMacvlanIface clones an interface (MACVLAN) and places it inside the namespace (-I).
-func (n *NsJail) WithMacvlanIface(iface string) *NsJail { n.macvlanIface = iface; return n }
+func (n *NsJail) WithMacvlanIface(iface string) *NsJail { n.macvlanIface = iface; return n.changed() }
 
 // WithMacvlanIp sets the IP for the MACVLAN 'vs' interface (--macvlan_vs_ip).
-func (n *NsJail) WithMacvlanIp(ip string) *NsJail { n.macvlanVsIp = ip; return n }
+func (n *NsJail) WithMacvlanIp(ip string) *NsJail { n.macvlanVsIp = ip; return n.changed() }
 
 // WithMacvlanNetmask sets the netmask for the MACVLAN 'vs' interface (--macvlan_vs_nm).
-func (n *NsJail) WithMacvlanNetmask(nm string) *NsJail { n.macvlanVsNm = nm; return n }
+func (n *NsJail) WithMacvlanNetmask(nm string) *NsJail { n.macvlanVsNm = nm; return n.changed() }
 
 // WithMacvlanGateway sets the gateway for the MACVLAN 'vs' interface (--macvlan_vs_gw).
-func (n *NsJail) WithMacvlanGateway(gw string) *NsJail { n.macvlanVsGw = gw; return n }
+func (n *NsJail) WithMacvlanGateway(gw string) *NsJail { n.macvlanVsGw = gw; return n.changed() }
 
 // WithMacvlanMac sets the MAC address for the MACVLAN 'vs' interface (--macvlan_vs_ma).
-func (n *NsJail) WithMacvlanMac(mac string) *NsJail { n.macvlanVsMa = mac; return n }
+func (n *NsJail) WithMacvlanMac(mac string) *NsJail { n.macvlanVsMa = mac; return n.changed() }
 
 // WithMacvlanMode sets the mode of the MACVLAN 'vs' interface (--macvlan_vs_mo).
-func (n *NsJail) WithMacvlanMode(mode MacVlanMode) *NsJail { n.macvlanVsMo = mode; return n }
+func (n *NsJail) WithMacvlanMode(mode MacVlanMode) *NsJail { n.macvlanVsMo = mode; return n.changed() }
 
 // DisableTsc disables RDTSC and RDTSCP instructions (--disable_tsc).
-func (n *NsJail) DisableTsc() *NsJail { n.disableTsc = true; return n }
+func (n *NsJail) DisableTsc() *NsJail { n.disableTsc = true; return n.changed() }
 
 // ForwardSignals forwards fatal signals to the child instead of using SIGKILL (--forward_signals).
-func (n *NsJail) ForwardSignals() *NsJail { n.forwardSignals = true; return n }
+func (n *NsJail) ForwardSignals() *NsJail { n.forwardSignals = true; return n.changed() }
 
 // WithPort sets the TCP port to bind to (-p), enabling ModeListenTCP.
-func (n *NsJail) WithPort(port uint16) *NsJail { n.port = port; return n }
+func (n *NsJail) WithPort(port uint16) *NsJail { n.port = port; return n.changed() }
 
 // WithBindhost sets the IP address to bind the listening port to (--bindhost).
-func (n *NsJail) WithBindhost(ip string) *NsJail { n.bindhost = ip; return n }
+// Bracketed IPv6 literals such as "[::1]" are accepted.
+func (n *NsJail) WithBindhost(ip string) *NsJail { n.bindhost = trimBrackets(ip); return n.changed() }
 
 // WithMaxConns sets the maximum number of connections for listen mode (--max_conns).
-func (n *NsJail) WithMaxConns(max uint) *NsJail { n.maxConns = max; return n }
+func (n *NsJail) WithMaxConns(max uint) *NsJail { n.maxConns = max; return n.changed() }
 
 // WithMaxConnsPerIp sets the maximum number of connections per IP for listen mode (-i).
-func (n *NsJail) WithMaxConnsPerIp(max uint) *NsJail { n.maxConnsPerIp = max; return n }
+func (n *NsJail) WithMaxConnsPerIp(max uint) *NsJail { n.maxConnsPerIp = max; return n.changed() }
 
 // WithLogFile sets the log file path (-l).
-func (n *NsJail) WithLogFile(path string) *NsJail { n.logFile = path; return n }
+func (n *NsJail) WithLogFile(path string) *NsJail { n.logFile = path; return n.changed() }
 
 // WithLogFd sets the log file descriptor (-L).
-func (n *NsJail) WithLogFd(fd int) *NsJail { n.logFd = fd; return n }
+func (n *NsJail) WithLogFd(fd int) *NsJail {
+	if fd < 0 {
+		n.fail("WithLogFd", "negative descriptor %d", fd)
+		return n.changed()
+	}
+	n.logFd = fd
+	return n.changed()
+}
 
-// Daemonize runs nsjail as a daemon (-d).
-func (n *NsJail) Daemonize() *NsJail { n.daemon = true; return n }
+// Daemonize runs nsjail as a daemon (-d). Jail.WaitReady waits for the daemon and returns its pid.
+func (n *NsJail) Daemonize() *NsJail { n.daemon = true; return n.changed() }
 
 // WithMaxCpus sets the maximum number of CPUs the jailed process can use (--max_cpus).
-func (n *NsJail) WithMaxCpus(max uint) *NsJail { n.maxCpus = max; return n }
+func (n *NsJail) WithMaxCpus(max uint) *NsJail { n.maxCpus = max; return n.changed() }
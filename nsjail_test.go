@@ -0,0 +1,78 @@
+package nsjail
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestArgsCommandVerbatim(t *testing.T) {
+	tests := []struct {
+		name string
+		jail *NsJail
+		want []string
+	}{
+		{"plain", New("/bin/echo", "hi"), []string{"/bin/echo", "hi"}},
+		{"option-like", New("/bin/echo", "-x", "--flag=1", "-Mo"), []string{"/bin/echo", "-x", "--flag=1", "-Mo"}},
+		{"separator", New("/bin/echo", "--", "a", "--"), []string{"/bin/echo", "--", "a", "--"}},
+		{"empty and spaces", New("/bin/echo", "", " a b "), []string{"/bin/echo", "", " a b "}},
+		{"command like an option", New("-x", "--"), []string{"-x", "--"}},
+		{"WithCommand", New("/bin/true").WithCommand("/bin/echo", "-x"), []string{"/bin/echo", "-x"}},
+		{"WithArgs", New("/bin/echo", "a").WithArgs("--", "-b"), []string{"/bin/echo", "--", "-b"}},
+		{"AppendArgs", New("/bin/echo", "a").AppendArgs("--flag=1").AppendArgs("--"),
+			[]string{"/bin/echo", "a", "--flag=1", "--"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.jail.Args()
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+			i := slices.Index(args, "--")
+			if i < 0 {
+				t.Fatalf("Args = %q, no -- separator", args)
+			}
+			if got := args[i+1:]; !slices.Equal(got, tt.want) {
+				t.Errorf("command after -- = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgsCommandErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		jail *NsJail
+		want string
+	}{
+		{"args without command", New("").WithArgs("-x"), "set without a command"},
+		{"no command", New(""), "no command to run"},
+		{"NUL in command", New("/bin/e\x00cho"), "contains a NUL byte"},
+		{"NUL in argument", New("/bin/echo", "a\x00b"), "contains a NUL byte"},
+		{"NUL in appended argument", New("/bin/echo").AppendArgs("--", "\x00"), "contains a NUL byte"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.jail.Args()
+			if err == nil {
+				t.Fatalf("Args = %q, want an error containing %q", args, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Args error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendArgsDoesNotAlias(t *testing.T) {
+	a := New("/bin/echo", "a")
+	b := a.Clone().AppendArgs("b")
+	a.AppendArgs("c")
+	got, err := b.Args()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/bin/echo", "a", "b"}; !slices.Equal(got[slices.Index(got, "--")+1:], want) {
+		t.Errorf("clone command = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+// Package nsjailtest provides a fake nsjail.Executor for testing code that runs jails without an nsjail
+// binary or privileges.
+//
+//	exec := nsjailtest.NewExecutor(func(p *nsjailtest.Process) int {
+//		fmt.Fprintln(p.Command.Stdout, "hello")
+//		return 0
+//	})
+//	res, err := nsjail.New("/bin/echo", "hello").WithExecutor(exec).RunCaptured(ctx, 1024, 1024)
+//	// assert on res and on exec.Commands()
+package nsjailtest
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+
+	nsjail "github.com/OptimusePrime/nsjail-go"
+)
+
+// Executor is a fake nsjail.Executor. It records every command and simulates the process with a function.
+type Executor struct {
+	run func(p *Process) int
+
+	mu       sync.Mutex
+	commands []*nsjail.Command
+	nextPid  int
+	startErr error
+}
+
+// NewExecutor returns an Executor simulating each started process with run, which is called in its own
+// goroutine. Its return value becomes the exit code. run may write to p.Command.Stdout and p.Command.Stderr,
+// and should return when p.Killed() is closed if it simulates a long-running process.
+// A nil run exits with code 0 immediately.
+func NewExecutor(run func(p *Process) int) *Executor {
+	if run == nil {
+		run = func(*Process) int { return 0 }
+	}
+	return &Executor{run: run, nextPid: 1000}
+}
+
+// FailStart makes subsequent Start calls fail with err, e.g. to simulate a missing nsjail binary.
+func (e *Executor) FailStart(err error) *Executor {
+	e.mu.Lock()
+	e.startErr = err
+	e.mu.Unlock()
+	return e
+}
+
+// Commands returns the commands started so far.
+func (e *Executor) Commands() []*nsjail.Command {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*nsjail.Command(nil), e.commands...)
+}
+
+// Start implements nsjail.Executor.
+func (e *Executor) Start(c *nsjail.Command) (nsjail.Process, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.commands = append(e.commands, c)
+	if e.startErr != nil {
+		return nil, e.startErr
+	}
+	e.nextPid++
+	p := &Process{
+		Command: c,
+		pid:     e.nextPid,
+		signals: make(chan os.Signal, 16),
+		killed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		code := e.run(p)
+		p.mu.Lock()
+		if p.isKilled {
+			p.exit = nsjail.Exit{Code: -1, Signal: syscall.SIGKILL}
+		} else {
+			p.exit = nsjail.Exit{Code: code}
+		}
+		p.mu.Unlock()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// Process is a simulated nsjail process.
+type Process struct {
+	// Command is the command the process was started with.
+	Command *nsjail.Command
+
+	pid     int
+	signals chan os.Signal
+	killed  chan struct{}
+	done    chan struct{}
+
+	mu       sync.Mutex
+	isKilled bool
+	exit     nsjail.Exit
+}
+
+// Pid implements nsjail.Process. Fake pids start at 1001.
+func (p *Process) Pid() int { return p.pid }
+
+// Signal implements nsjail.Process. os.Kill closes Killed; other signals are delivered on Signals.
+func (p *Process) Signal(sig os.Signal) error {
+	select {
+	case <-p.done:
+		return os.ErrProcessDone
+	default:
+	}
+	if sig == os.Kill {
+		p.mu.Lock()
+		if !p.isKilled {
+			p.isKilled = true
+			close(p.killed)
+		}
+		p.mu.Unlock()
+		return nil
+	}
+	select {
+	case p.signals <- sig:
+		return nil
+	default:
+		return errors.New("nsjailtest: signal buffer full")
+	}
+}
+
+// Wait implements nsjail.Process.
+func (p *Process) Wait() (nsjail.Exit, error) {
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.exit, nil
+}
+
+// Killed returns a channel closed when the process is killed. A killed process exits with code -1 and SIGKILL.
+func (p *Process) Killed() <-chan struct{} { return p.killed }
+
+// Signals returns the channel non-kill signals sent to the process are delivered on.
+func (p *Process) Signals() <-chan os.Signal { return p.signals }
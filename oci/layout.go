@@ -0,0 +1,346 @@
+package oci
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Media types of manifests and indexes, in OCI and Docker flavours.
+const (
+	mediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	maxManifestSize         = 4 << 20
+	maxConfigSize           = 16 << 20
+)
+
+// source is an image whose layers can be read in order.
+type source interface {
+	config() []byte
+	layers() []opener
+	Close() error
+}
+
+// opener opens the stream of a layer, which may be compressed.
+type opener func(ctx context.Context) (io.ReadCloser, error)
+
+type descriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	Platform  *platform `json:"platform,omitempty"`
+}
+
+type platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+// manifest is an image manifest or, with Manifests set, an index of manifests.
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+	Manifests []descriptor `json:"manifests"`
+}
+
+func (m *manifest) isIndex() bool {
+	return m.MediaType == mediaTypeOCIIndex || m.MediaType == mediaTypeDockerList || len(m.Manifests) > 0
+}
+
+// selectManifest picks the manifest for the host's platform from an index.
+func selectManifest(m *manifest) (descriptor, error) {
+	for _, d := range m.Manifests {
+		if d.Platform == nil || d.Platform.OS == runtime.GOOS && d.Platform.Architecture == runtime.GOARCH {
+			return d, nil
+		}
+	}
+	return descriptor{}, fmt.Errorf("no image for %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// layoutSource is an OCI image layout or an unpacked `docker save` tarball in a directory.
+type layoutSource struct {
+	root    string
+	cfg     []byte
+	openers []opener
+	cleanup func()
+}
+
+func (s *layoutSource) config() []byte   { return s.cfg }
+func (s *layoutSource) layers() []opener { return s.openers }
+
+func (s *layoutSource) Close() error {
+	if s.cleanup != nil {
+		s.cleanup()
+	}
+	return nil
+}
+
+// openLayout reads the image in dir, which is an OCI image layout or the contents of a `docker save` tarball.
+func openLayout(dir string) (*layoutSource, error) {
+	s := &layoutSource{root: dir}
+	if _, err := os.Stat(filepath.Join(dir, "oci-layout")); err == nil {
+		return s, s.readOCI()
+	}
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); err == nil {
+		return s, s.readDocker()
+	}
+	return nil, errors.New("neither an OCI image layout nor a docker save archive")
+}
+
+func (s *layoutSource) readOCI() error {
+	data, err := s.readFile("index.json", maxManifestSize)
+	if err != nil {
+		return err
+	}
+	m := new(manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("parsing index.json: %w", err)
+	}
+	for m.isIndex() {
+		d, err := selectManifest(m)
+		if err != nil {
+			return err
+		}
+		if data, err = s.readBlob(d, maxManifestSize); err != nil {
+			return err
+		}
+		m = new(manifest)
+		if err := json.Unmarshal(data, m); err != nil {
+			return fmt.Errorf("parsing manifest %s: %w", d.Digest, err)
+		}
+	}
+	if s.cfg, err = s.readBlob(m.Config, maxConfigSize); err != nil {
+		return err
+	}
+	for _, d := range m.Layers {
+		s.openers = append(s.openers, func(context.Context) (io.ReadCloser, error) {
+			p, err := s.blobPath(d.Digest)
+			if err != nil {
+				return nil, err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return nil, err
+			}
+			return verify(f, d.Digest)
+		})
+	}
+	return nil
+}
+
+func (s *layoutSource) readDocker() error {
+	data, err := s.readFile("manifest.json", maxManifestSize)
+	if err != nil {
+		return err
+	}
+	var entries []struct {
+		Config string
+		Layers []string
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("parsing manifest.json: %w", err)
+	}
+	if len(entries) == 0 {
+		return errors.New("manifest.json lists no images")
+	}
+	if len(entries) > 1 {
+		return errors.New("archive holds several images")
+	}
+	if s.cfg, err = s.readFile(entries[0].Config, maxConfigSize); err != nil {
+		return err
+	}
+	for _, name := range entries[0].Layers {
+		s.openers = append(s.openers, func(context.Context) (io.ReadCloser, error) {
+			p, err := secureJoin(s.root, name)
+			if err != nil {
+				return nil, err
+			}
+			return os.Open(p)
+		})
+	}
+	return nil
+}
+
+// readFile reads the file name of the layout, resolving symlinks inside it.
+func (s *layoutSource) readFile(name string, limit int64) ([]byte, error) {
+	p, err := secureJoin(s.root, name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLimited(f, limit, name)
+}
+
+func (s *layoutSource) blobPath(digest string) (string, error) {
+	alg, hexDigest, ok := strings.Cut(digest, ":")
+	if !ok || strings.ContainsAny(alg+hexDigest, "/\\.") {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return secureJoin(s.root, path.Join("blobs", alg, hexDigest))
+}
+
+func (s *layoutSource) readBlob(d descriptor, limit int64) ([]byte, error) {
+	p, err := s.blobPath(d.Digest)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	r, err := verify(f, d.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimited(r, limit, d.Digest)
+}
+
+// openArchive extracts the image tarball at file, which may be gzip-compressed, into a temporary
+// directory and reads it as a layout.
+func openArchive(file string) (*layoutSource, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := decompress(f)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "nsjail-oci-archive-*")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	if err := extractArchive(tar.NewReader(r), dir); err != nil {
+		cleanup()
+		return nil, err
+	}
+	s, err := openLayout(dir)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	s.cleanup = cleanup
+	return s, nil
+}
+
+// extractArchive extracts the files, directories and symlinks of an image tarball into dir. Unlike
+// layers, the archive is only read by this package, so its modes and owners are not kept.
+func extractArchive(tr *tar.Reader, dir string) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := secureJoin(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0o755)
+		case tar.TypeReg:
+			var out *os.File
+			if out, err = os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644); err == nil {
+				_, err = io.Copy(out, tr)
+				err = errors.Join(err, out.Close())
+			}
+		case tar.TypeSymlink:
+			// Symlinks are resolved inside dir when read, see secureJoin.
+			err = os.Symlink(hdr.Linkname, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// decompress returns the uncompressed stream of r, which is a tar archive, possibly gzip-compressed.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		return gzip.NewReader(br)
+	case len(magic) == 4 && string(magic) == "\x28\xb5\x2f\xfd":
+		return nil, errors.New("zstd-compressed layers are not supported")
+	}
+	return br, nil
+}
+
+func readLimited(r io.Reader, limit int64, name string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", name, limit)
+	}
+	return data, nil
+}
+
+// verifier fails the read that reaches EOF if the content does not match its digest.
+type verifier struct {
+	rc     io.ReadCloser
+	h      hash.Hash
+	digest string
+}
+
+// verify wraps rc to check its content against digest, e.g. "sha256:...".
+func verify(rc io.ReadCloser, digest string) (io.ReadCloser, error) {
+	alg, _, _ := strings.Cut(digest, ":")
+	var h hash.Hash
+	switch alg {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		rc.Close()
+		return nil, fmt.Errorf("unsupported digest %q", digest)
+	}
+	return &verifier{rc: rc, h: h, digest: digest}, nil
+}
+
+func (v *verifier) Read(p []byte) (int, error) {
+	n, err := v.rc.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF {
+		alg, _, _ := strings.Cut(v.digest, ":")
+		if got := alg + ":" + hex.EncodeToString(v.h.Sum(nil)); got != v.digest {
+			return n, fmt.Errorf("content has digest %s, want %s", got, v.digest)
+		}
+	}
+	return n, err
+}
+
+func (v *verifier) Close() error { return v.rc.Close() }
@@ -0,0 +1,238 @@
+// Package oci runs container images with nsjail. It unpacks an image from a registry, an OCI image layout
+// or a `docker save` tarball into a directory and configures a jail from the image's config:
+//
+//	img, err := oci.Load(ctx, "docker.io/library/alpine:3.20", "")
+//	if err != nil { ... }
+//	defer img.Close()
+//	n, err := img.Jail("echo", "hello")
+//	res, err := n.Run(ctx)
+package oci
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	nsjail "github.com/OptimusePrime/nsjail-go"
+)
+
+// Config is the part of an image's configuration that applies to running it.
+type Config struct {
+	User       string   `json:"User,omitempty"`
+	Env        []string `json:"Env,omitempty"`
+	Entrypoint []string `json:"Entrypoint,omitempty"`
+	Cmd        []string `json:"Cmd,omitempty"`
+	WorkingDir string   `json:"WorkingDir,omitempty"`
+}
+
+// Image is an image unpacked into a directory.
+type Image struct {
+	// Dir is the flattened root filesystem.
+	Dir string
+	// Config is the image's run configuration.
+	Config Config
+
+	temp bool
+}
+
+// imageConfig is the image configuration blob, of which only the run configuration is used.
+type imageConfig struct {
+	Config Config `json:"config"`
+}
+
+// Load unpacks the image src into dir and reads its configuration. src is either the path of a
+// `docker save` tarball, of an OCI image layout directory or tarball, or a registry reference such as
+// "alpine:3.20" or "ghcr.io/org/image@sha256:...", pulled anonymously. An empty dir unpacks into a new
+// temporary directory, which Close removes.
+//
+// Device nodes in the image are skipped; bind-mount the ones the program needs.
+func Load(ctx context.Context, src, dir string) (_ *Image, err error) {
+	img := &Image{Dir: dir}
+	if dir == "" {
+		if img.Dir, err = os.MkdirTemp("", "nsjail-oci-*"); err != nil {
+			return nil, err
+		}
+		img.temp = true
+		os.Chmod(img.Dir, 0o755)
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			img.Close()
+		}
+	}()
+
+	var s source
+	if info, serr := os.Stat(src); serr == nil {
+		if info.IsDir() {
+			s, err = openLayout(src)
+		} else {
+			s, err = openArchive(src)
+		}
+	} else {
+		s, err = pull(ctx, src)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("oci: %s: %w", src, err)
+	}
+	defer s.Close()
+
+	var cfg imageConfig
+	if err := json.Unmarshal(s.config(), &cfg); err != nil {
+		return nil, fmt.Errorf("oci: %s: parsing image config: %w", src, err)
+	}
+	img.Config = cfg.Config
+	u := newUnpacker(img.Dir)
+	for i, layer := range s.layers() {
+		if err := u.apply(ctx, layer); err != nil {
+			return nil, fmt.Errorf("oci: %s: layer %d: %w", src, i, err)
+		}
+	}
+	u.finish()
+	return img, nil
+}
+
+// Close removes the image directory if Load created it. Jails using the image must have exited.
+func (img *Image) Close() error {
+	if !img.temp {
+		return nil
+	}
+	// Directories from the image may not be writable.
+	filepath.WalkDir(img.Dir, func(p string, d os.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			os.Chmod(p, 0o755)
+		}
+		return nil
+	})
+	return os.RemoveAll(img.Dir)
+}
+
+// Jail returns a jail running the image: chrooted into its directory, with its environment, working
+// directory and user. args replace the image's Cmd, as with `docker run image args...`; the Entrypoint is
+// kept. The program is looked up in the PATH of the image. The root filesystem is mounted read-only unless
+// MountChrootRW is added.
+func (img *Image) Jail(args ...string) (*nsjail.NsJail, error) {
+	argv := append([]string(nil), img.Config.Entrypoint...)
+	if len(args) > 0 {
+		argv = append(argv, args...)
+	} else {
+		argv = append(argv, img.Config.Cmd...)
+	}
+	if len(argv) == 0 {
+		return nil, errors.New("oci: image has no entrypoint or command")
+	}
+	prog, err := img.lookPath(argv[0])
+	if err != nil {
+		return nil, err
+	}
+	n := nsjail.New(prog, argv[1:]...).WithChroot(img.Dir)
+	for _, kv := range img.Config.Env {
+		k, v, _ := strings.Cut(kv, "=")
+		n.AddEnv(k, v)
+	}
+	if img.Config.WorkingDir != "" {
+		n.WithCwd(img.Config.WorkingDir)
+	}
+	if img.Config.User != "" {
+		uid, gid, err := img.resolveUser(img.Config.User)
+		if err != nil {
+			return nil, err
+		}
+		n.WithUser(uid).WithGroup(gid)
+	}
+	return n, nil
+}
+
+// env returns the value of key in the image's environment.
+func (img *Image) env(key string) string {
+	for _, kv := range img.Config.Env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// lookPath finds prog in the image like a shell would, as nsjail does not search PATH.
+func (img *Image) lookPath(prog string) (string, error) {
+	if strings.Contains(prog, "/") {
+		return prog, nil
+	}
+	dirs := img.env("PATH")
+	if dirs == "" {
+		dirs = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	}
+	for _, dir := range strings.Split(dirs, ":") {
+		p := path.Join("/", dir, prog)
+		host, err := secureJoin(img.Dir, p)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(host); err == nil && info.Mode().IsRegular() && info.Mode()&0o111 != 0 {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("oci: %s not found in the image's PATH %s", prog, dirs)
+}
+
+// resolveUser resolves the image's "user[:group]" to numeric ids using its /etc/passwd and /etc/group.
+func (img *Image) resolveUser(spec string) (uid, gid string, err error) {
+	user, group, hasGroup := strings.Cut(spec, ":")
+	uid, gid = user, "0"
+	if !isNumeric(user) {
+		fields, err := img.lookupDB("/etc/passwd", user)
+		if err != nil {
+			return "", "", err
+		}
+		uid, gid = fields[2], fields[3]
+	} else if fields, err := img.lookupDB("/etc/passwd", user); err == nil {
+		gid = fields[3]
+	}
+	if hasGroup {
+		gid = group
+		if !isNumeric(group) {
+			fields, err := img.lookupDB("/etc/group", group)
+			if err != nil {
+				return "", "", err
+			}
+			gid = fields[2]
+		}
+	}
+	return uid, gid, nil
+}
+
+// lookupDB returns the fields of the entry for name, by name or, for numeric names, by id, in a
+// passwd-style file of the image.
+func (img *Image) lookupDB(file, name string) ([]string, error) {
+	p, err := secureJoin(img.Dir, file)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("oci: resolving user %s: %w", name, err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Split(sc.Text(), ":")
+		if len(fields) < 4 {
+			continue
+		}
+		if fields[0] == name || isNumeric(name) && fields[2] == name {
+			return fields, nil
+		}
+	}
+	return nil, fmt.Errorf("oci: %s not found in the image's %s", name, file)
+}
+
+func isNumeric(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
@@ -0,0 +1,237 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// reference is a parsed image reference.
+type reference struct {
+	registry string // host[:port] of the registry API
+	repo     string
+	ref      string // tag or digest
+}
+
+// parseReference parses refs like "alpine", "alpine:3.20", "ghcr.io/org/image@sha256:..." with the
+// defaults of docker: Docker Hub, the "library/" namespace and the "latest" tag.
+func parseReference(s string) (*reference, error) {
+	r := &reference{registry: "docker.io", ref: "latest"}
+	name := s
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, r.ref = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, r.ref = name[:i], name[i+1:]
+	}
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.registry, name = first, rest
+	}
+	if r.registry == "docker.io" {
+		r.registry = "registry-1.docker.io"
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+	}
+	if name == "" || r.ref == "" || name != strings.ToLower(name) {
+		return nil, fmt.Errorf("invalid image reference %q", s)
+	}
+	r.repo = name
+	return r, nil
+}
+
+// registryClient talks to the distribution API of a registry, authenticating anonymously with bearer
+// tokens when the registry asks for them.
+type registryClient struct {
+	ref    *reference
+	scheme string
+
+	mu    sync.Mutex
+	token string
+}
+
+// registrySource is an image pulled layer by layer from a registry.
+type registrySource struct {
+	cfg     []byte
+	openers []opener
+}
+
+func (s *registrySource) config() []byte   { return s.cfg }
+func (s *registrySource) layers() []opener { return s.openers }
+func (s *registrySource) Close() error     { return nil }
+
+func pull(ctx context.Context, ref string) (*registrySource, error) {
+	r, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	c := &registryClient{ref: r, scheme: "https"}
+	if host := strings.Split(r.registry, ":")[0]; host == "localhost" || host == "127.0.0.1" {
+		c.scheme = "http"
+	}
+	m, err := c.manifest(ctx, r.ref)
+	if err != nil {
+		return nil, err
+	}
+	for m.isIndex() {
+		d, err := selectManifest(m)
+		if err != nil {
+			return nil, err
+		}
+		if m, err = c.manifest(ctx, d.Digest); err != nil {
+			return nil, err
+		}
+	}
+	rc, err := c.blob(ctx, m.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	s := &registrySource{}
+	if s.cfg, err = readLimited(rc, maxConfigSize, "config"); err != nil {
+		return nil, err
+	}
+	for _, d := range m.Layers {
+		s.openers = append(s.openers, func(ctx context.Context) (io.ReadCloser, error) { return c.blob(ctx, d.Digest) })
+	}
+	return s, nil
+}
+
+func (c *registryClient) manifest(ctx context.Context, ref string) (*manifest, error) {
+	accept := strings.Join([]string{mediaTypeOCIIndex, mediaTypeOCIManifest, mediaTypeDockerList, mediaTypeDockerManifest}, ", ")
+	resp, err := c.get(ctx, "manifests/"+ref, accept)
+	if err != nil {
+		return nil, err
+	}
+	var body io.ReadCloser = resp.Body
+	if strings.Contains(ref, ":") {
+		if body, err = verify(resp.Body, ref); err != nil {
+			return nil, err
+		}
+	}
+	defer body.Close()
+	data, err := readLimited(body, maxManifestSize, "manifest")
+	if err != nil {
+		return nil, err
+	}
+	m := new(manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+	return m, nil
+}
+
+func (c *registryClient) blob(ctx context.Context, digest string) (io.ReadCloser, error) {
+	resp, err := c.get(ctx, "blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+	return verify(resp.Body, digest)
+}
+
+// get requests path under the repository, fetching a token first if the registry requires one.
+func (c *registryClient) get(ctx context.Context, p, accept string) (*http.Response, error) {
+	u := fmt.Sprintf("%s://%s/v2/%s/%s", c.scheme, c.ref.registry, c.ref.repo, p)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		c.mu.Lock()
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		c.mu.Unlock()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			if err := c.authenticate(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+}
+
+// authenticate fetches an anonymous pull token as described by a WWW-Authenticate challenge.
+func (c *registryClient) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("registry requires %q authentication, which is not supported", scheme)
+	}
+	attrs := parseChallenge(params)
+	if attrs["realm"] == "" {
+		return errors.New("registry sent no token realm")
+	}
+	q := url.Values{}
+	if s := attrs["service"]; s != "" {
+		q.Set("service", s)
+	}
+	q.Set("scope", "repository:"+c.ref.repo+":pull")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, attrs["realm"]+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching registry token: %s", resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&tok); err != nil {
+		return fmt.Errorf("fetching registry token: %w", err)
+	}
+	c.mu.Lock()
+	c.token = tok.Token
+	if c.token == "" {
+		c.token = tok.AccessToken
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// parseChallenge parses the comma-separated key="value" pairs of a WWW-Authenticate challenge.
+func parseChallenge(s string) map[string]string {
+	attrs := make(map[string]string)
+	for {
+		key, rest, ok := strings.Cut(strings.TrimLeft(s, ", "), "=")
+		if !ok {
+			return attrs
+		}
+		var val string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return attrs
+			}
+			val, s = rest[1:end+1], rest[end+2:]
+		} else {
+			val, s, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.ToLower(strings.TrimSpace(key))] = val
+	}
+}
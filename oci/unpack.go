@@ -0,0 +1,206 @@
+package oci
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+	maxSymlinks    = 255
+)
+
+// secureJoin returns the host path of name inside root, resolving symlinks in its directories as if root
+// were the root directory, so that no path leaves root. The last element is resolved too.
+func secureJoin(root, name string) (string, error) {
+	cur := "/"
+	pending := strings.Split(name, "/")
+	links := 0
+	for len(pending) > 0 {
+		part := pending[0]
+		pending = pending[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			cur = path.Dir(cur)
+			continue
+		}
+		next := path.Join(cur, part)
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(next)))
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			// Missing elements are created by the caller, below which nothing can be a symlink.
+			cur = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("%s: too many levels of symbolic links", name)
+		}
+		target, err := os.Readlink(filepath.Join(root, filepath.FromSlash(next)))
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			cur = "/"
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return filepath.Join(root, filepath.FromSlash(cur)), nil
+}
+
+// unpacker flattens layers into a directory.
+type unpacker struct {
+	root string
+	// dirModes are applied once all layers are unpacked, as read-only directories could not be written to.
+	dirModes map[string]fs.FileMode
+	root0    bool
+}
+
+func newUnpacker(root string) *unpacker {
+	return &unpacker{root: root, dirModes: make(map[string]fs.FileMode), root0: os.Geteuid() == 0}
+}
+
+// apply unpacks one layer, handling whiteouts of files from lower layers.
+func (u *unpacker) apply(ctx context.Context, open opener) error {
+	rc, err := open(ctx)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	r, err := decompress(rc)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	added := make(map[string]bool) // entries of this layer, which its opaque whiteouts keep
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		dir, base := path.Split(name)
+		parent, err := secureJoin(u.root, dir)
+		if err != nil {
+			return err
+		}
+		switch {
+		case base == whiteoutOpaque:
+			err = u.clearDir(parent, dir, added)
+		case strings.HasPrefix(base, whiteoutPrefix):
+			err = os.RemoveAll(filepath.Join(parent, base[len(whiteoutPrefix):]))
+		default:
+			added[name] = true
+			err = u.extract(tr, hdr, parent, filepath.Join(parent, base))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+}
+
+// clearDir removes the contents of dir from lower layers.
+func (u *unpacker) clearDir(hostDir, dir string, added map[string]bool) error {
+	entries, err := os.ReadDir(hostDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !added[path.Join(dir, e.Name())] {
+			if err := os.RemoveAll(filepath.Join(hostDir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (u *unpacker) extract(tr *tar.Reader, hdr *tar.Header, parent, target string) error {
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return err
+	}
+	mode := fs.FileMode(hdr.Mode).Perm() | fs.FileMode(hdr.Mode)&(fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)
+	if hdr.Typeflag != tar.TypeDir {
+		// Entries replace what lower layers had at the same path; directories are merged.
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	} else if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := os.Mkdir(target, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		u.dirModes[target] = mode
+	case tar.TypeReg:
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if err = errors.Join(err, f.Close()); err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		return errors.Join(os.Symlink(hdr.Linkname, target), u.chown(target, hdr))
+	case tar.TypeLink:
+		src, err := secureJoin(u.root, hdr.Linkname)
+		if err != nil {
+			return err
+		}
+		return os.Link(src, target)
+	default:
+		// Device nodes and fifos cannot be created without privileges and are bind-mounted instead.
+		return nil
+	}
+	if err := u.chown(target, hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag == tar.TypeReg {
+		if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+	}
+	return os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+}
+
+// chown gives target the owner from hdr when running as root. Otherwise files belong to the caller,
+// which nsjail maps to the jail's user.
+func (u *unpacker) chown(target string, hdr *tar.Header) error {
+	if !u.root0 {
+		return nil
+	}
+	return os.Lchown(target, hdr.Uid, hdr.Gid)
+}
+
+// finish applies the modes of directories.
+func (u *unpacker) finish() {
+	for dir, mode := range u.dirModes {
+		os.Chmod(dir, mode)
+	}
+}
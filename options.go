@@ -0,0 +1,737 @@
+package nsjail
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// Option configures an NsJail. Options are an alternative to the builder methods for code that composes
+// configuration from several packages; every builder method has an Option form with an "Opt" suffix.
+type Option func(*NsJail)
+
+// NewWithOptions creates a new NsJail configuration for the given command and applies opts in order.
+// Arguments of the command are set with WithArgsOpt.
+func NewWithOptions(cmd string, opts ...Option) *NsJail {
+	return New(cmd).Apply(opts...)
+}
+
+// Apply applies opts to the configuration in order.
+func (n *NsJail) Apply(opts ...Option) *NsJail {
+	for _, opt := range opts {
+		if opt != nil {
+			opt(n)
+		}
+	}
+	return n.changed()
+}
+
+// Options combines several options into one, e.g. to export a named option set from a package.
+func Options(opts ...Option) Option {
+	return func(n *NsJail) { n.Apply(opts...) }
+}
+
+// WithPathOpt is the Option form of NsJail.WithPath.
+func WithPathOpt(path string) Option { return func(n *NsJail) { n.WithPath(path) } }
+
+// WithCommandOpt is the Option form of NsJail.WithCommand.
+func WithCommandOpt(cmd string, args ...string) Option {
+	return func(n *NsJail) { n.WithCommand(cmd, args...) }
+}
+
+// WithArgsOpt is the Option form of NsJail.WithArgs.
+func WithArgsOpt(args ...string) Option { return func(n *NsJail) { n.WithArgs(args...) } }
+
+// AppendArgsOpt is the Option form of NsJail.AppendArgs.
+func AppendArgsOpt(args ...string) Option { return func(n *NsJail) { n.AppendArgs(args...) } }
+
+// WithModeOpt is the Option form of NsJail.WithMode.
+func WithModeOpt(mode Mode) Option { return func(n *NsJail) { n.WithMode(mode) } }
+
+// WithConfigFileOpt is the Option form of NsJail.WithConfigFile.
+func WithConfigFileOpt(path string) Option { return func(n *NsJail) { n.WithConfigFile(path) } }
+
+// WithExecFileOpt is the Option form of NsJail.WithExecFile.
+func WithExecFileOpt(path string) Option { return func(n *NsJail) { n.WithExecFile(path) } }
+
+// EnableExecuteFdOpt is the Option form of NsJail.EnableExecuteFd.
+func EnableExecuteFdOpt() Option { return func(n *NsJail) { n.EnableExecuteFd() } }
+
+// WithChrootOpt is the Option form of NsJail.WithChroot.
+func WithChrootOpt(path string) Option { return func(n *NsJail) { n.WithChroot(path) } }
+
+// EnableNoPivotRootOpt is the Option form of NsJail.EnableNoPivotRoot.
+func EnableNoPivotRootOpt() Option { return func(n *NsJail) { n.EnableNoPivotRoot() } }
+
+// MountChrootRWOpt is the Option form of NsJail.MountChrootRW.
+func MountChrootRWOpt() Option { return func(n *NsJail) { n.MountChrootRW() } }
+
+// WithUserOpt is the Option form of NsJail.WithUser.
+func WithUserOpt(user string) Option { return func(n *NsJail) { n.WithUser(user) } }
+
+// WithGroupOpt is the Option form of NsJail.WithGroup.
+func WithGroupOpt(group string) Option { return func(n *NsJail) { n.WithGroup(group) } }
+
+// WithHostnameOpt is the Option form of NsJail.WithHostname.
+func WithHostnameOpt(hostname string) Option { return func(n *NsJail) { n.WithHostname(hostname) } }
+
+// WithCwdOpt is the Option form of NsJail.WithCwd.
+func WithCwdOpt(cwd string) Option { return func(n *NsJail) { n.WithCwd(cwd) } }
+
+// WithTimeLimitOpt is the Option form of NsJail.WithTimeLimit.
+func WithTimeLimitOpt(seconds uint64) Option { return func(n *NsJail) { n.WithTimeLimit(seconds) } }
+
+// VerboseOpt is the Option form of NsJail.Verbose.
+func VerboseOpt() Option { return func(n *NsJail) { n.Verbose() } }
+
+// QuietOpt is the Option form of NsJail.Quiet.
+func QuietOpt() Option { return func(n *NsJail) { n.Quiet() } }
+
+// ReallyQuietOpt is the Option form of NsJail.ReallyQuiet.
+func ReallyQuietOpt() Option { return func(n *NsJail) { n.ReallyQuiet() } }
+
+// KeepEnvOpt is the Option form of NsJail.KeepEnv.
+func KeepEnvOpt() Option { return func(n *NsJail) { n.KeepEnv() } }
+
+// AddEnvOpt is the Option form of NsJail.AddEnv.
+func AddEnvOpt(key, value string) Option { return func(n *NsJail) { n.AddEnv(key, value) } }
+
+// KeepCapsOpt is the Option form of NsJail.KeepCaps.
+func KeepCapsOpt() Option { return func(n *NsJail) { n.KeepCaps() } }
+
+// AddCapOpt is the Option form of NsJail.AddCap.
+func AddCapOpt(cap Capability) Option { return func(n *NsJail) { n.AddCap(cap) } }
+
+// SilentOpt is the Option form of NsJail.Silent.
+func SilentOpt() Option { return func(n *NsJail) { n.Silent() } }
+
+// StderrToNullOpt is the Option form of NsJail.StderrToNull.
+func StderrToNullOpt() Option { return func(n *NsJail) { n.StderrToNull() } }
+
+// SkipSetsidOpt is the Option form of NsJail.SkipSetsid.
+func SkipSetsidOpt() Option { return func(n *NsJail) { n.SkipSetsid() } }
+
+// AddPassFdOpt is the Option form of NsJail.AddPassFd.
+func AddPassFdOpt(fd int) Option { return func(n *NsJail) { n.AddPassFd(fd) } }
+
+// DisableNoNewPrivsOpt is the Option form of NsJail.DisableNoNewPrivs.
+func DisableNoNewPrivsOpt() Option { return func(n *NsJail) { n.DisableNoNewPrivs() } }
+
+// WithRlimitAsOpt is the Option form of NsJail.WithRlimitAs.
+func WithRlimitAsOpt(val string) Option { return func(n *NsJail) { n.WithRlimitAs(val) } }
+
+// WithRlimitCoreOpt is the Option form of NsJail.WithRlimitCore.
+func WithRlimitCoreOpt(val string) Option { return func(n *NsJail) { n.WithRlimitCore(val) } }
+
+// WithRlimitCpuOpt is the Option form of NsJail.WithRlimitCpu.
+func WithRlimitCpuOpt(val string) Option { return func(n *NsJail) { n.WithRlimitCpu(val) } }
+
+// WithRlimitFsizeOpt is the Option form of NsJail.WithRlimitFsize.
+func WithRlimitFsizeOpt(val string) Option { return func(n *NsJail) { n.WithRlimitFsize(val) } }
+
+// WithRlimitNofileOpt is the Option form of NsJail.WithRlimitNofile.
+func WithRlimitNofileOpt(val string) Option { return func(n *NsJail) { n.WithRlimitNofile(val) } }
+
+// WithRlimitNprocOpt is the Option form of NsJail.WithRlimitNproc.
+func WithRlimitNprocOpt(val string) Option { return func(n *NsJail) { n.WithRlimitNproc(val) } }
+
+// WithRlimitStackOpt is the Option form of NsJail.WithRlimitStack.
+func WithRlimitStackOpt(val string) Option { return func(n *NsJail) { n.WithRlimitStack(val) } }
+
+// WithRlimitMemlockOpt is the Option form of NsJail.WithRlimitMemlock.
+func WithRlimitMemlockOpt(val string) Option { return func(n *NsJail) { n.WithRlimitMemlock(val) } }
+
+// WithRlimitRtprioOpt is the Option form of NsJail.WithRlimitRtprio.
+func WithRlimitRtprioOpt(val string) Option { return func(n *NsJail) { n.WithRlimitRtprio(val) } }
+
+// WithRlimitMsgqueueOpt is the Option form of NsJail.WithRlimitMsgqueue.
+func WithRlimitMsgqueueOpt(val string) Option { return func(n *NsJail) { n.WithRlimitMsgqueue(val) } }
+
+// DisableRlimitsOpt is the Option form of NsJail.DisableRlimits.
+func DisableRlimitsOpt() Option { return func(n *NsJail) { n.DisableRlimits() } }
+
+// EnablePersonaAddrCompatLayoutOpt is the Option form of NsJail.EnablePersonaAddrCompatLayout.
+func EnablePersonaAddrCompatLayoutOpt() Option {
+	return func(n *NsJail) { n.EnablePersonaAddrCompatLayout() }
+}
+
+// EnablePersonaMmapPageZeroOpt is the Option form of NsJail.EnablePersonaMmapPageZero.
+func EnablePersonaMmapPageZeroOpt() Option { return func(n *NsJail) { n.EnablePersonaMmapPageZero() } }
+
+// EnablePersonaReadImpliesExecOpt is the Option form of NsJail.EnablePersonaReadImpliesExec.
+func EnablePersonaReadImpliesExecOpt() Option {
+	return func(n *NsJail) { n.EnablePersonaReadImpliesExec() }
+}
+
+// EnablePersonaAddrLimit3gbOpt is the Option form of NsJail.EnablePersonaAddrLimit3gb.
+func EnablePersonaAddrLimit3gbOpt() Option { return func(n *NsJail) { n.EnablePersonaAddrLimit3gb() } }
+
+// EnablePersonaAddrNoRandomizeOpt is the Option form of NsJail.EnablePersonaAddrNoRandomize.
+func EnablePersonaAddrNoRandomizeOpt() Option {
+	return func(n *NsJail) { n.EnablePersonaAddrNoRandomize() }
+}
+
+// DisableCloneNewNetOpt is the Option form of NsJail.DisableCloneNewNet.
+func DisableCloneNewNetOpt() Option { return func(n *NsJail) { n.DisableCloneNewNet() } }
+
+// DisableCloneNewUserOpt is the Option form of NsJail.DisableCloneNewUser.
+func DisableCloneNewUserOpt() Option { return func(n *NsJail) { n.DisableCloneNewUser() } }
+
+// DisableCloneNewNsOpt is the Option form of NsJail.DisableCloneNewNs.
+func DisableCloneNewNsOpt() Option { return func(n *NsJail) { n.DisableCloneNewNs() } }
+
+// DisableCloneNewPidOpt is the Option form of NsJail.DisableCloneNewPid.
+func DisableCloneNewPidOpt() Option { return func(n *NsJail) { n.DisableCloneNewPid() } }
+
+// DisableCloneNewIpcOpt is the Option form of NsJail.DisableCloneNewIpc.
+func DisableCloneNewIpcOpt() Option { return func(n *NsJail) { n.DisableCloneNewIpc() } }
+
+// DisableCloneNewUtsOpt is the Option form of NsJail.DisableCloneNewUts.
+func DisableCloneNewUtsOpt() Option { return func(n *NsJail) { n.DisableCloneNewUts() } }
+
+// DisableCloneNewCgroupOpt is the Option form of NsJail.DisableCloneNewCgroup.
+func DisableCloneNewCgroupOpt() Option { return func(n *NsJail) { n.DisableCloneNewCgroup() } }
+
+// EnableCloneNewTimeOpt is the Option form of NsJail.EnableCloneNewTime.
+func EnableCloneNewTimeOpt() Option { return func(n *NsJail) { n.EnableCloneNewTime() } }
+
+// AddUidMappingOpt is the Option form of NsJail.AddUidMapping.
+//
+// Deprecated: Use AddUidMapOpt.
+func AddUidMappingOpt(mapping string) Option { return func(n *NsJail) { n.AddUidMapping(mapping) } }
+
+// AddGidMappingOpt is the Option form of NsJail.AddGidMapping.
+//
+// Deprecated: Use AddGidMapOpt.
+func AddGidMappingOpt(mapping string) Option { return func(n *NsJail) { n.AddGidMapping(mapping) } }
+
+// AddBindMountROOpt is the Option form of NsJail.AddBindMountRO.
+func AddBindMountROOpt(path string) Option { return func(n *NsJail) { n.AddBindMountRO(path) } }
+
+// AddBindMountRWOpt is the Option form of NsJail.AddBindMountRW.
+func AddBindMountRWOpt(path string) Option { return func(n *NsJail) { n.AddBindMountRW(path) } }
+
+// AddTmpfsMountOpt is the Option form of NsJail.AddTmpfsMount.
+func AddTmpfsMountOpt(dest string) Option { return func(n *NsJail) { n.AddTmpfsMount(dest) } }
+
+// AddMountOpt is the Option form of NsJail.AddMount.
+func AddMountOpt(src, dst, fsType, opts string) Option {
+	return func(n *NsJail) { n.AddMount(src, dst, fsType, opts) }
+}
+
+// AddSymlinkOpt is the Option form of NsJail.AddSymlink.
+func AddSymlinkOpt(src, dst string) Option { return func(n *NsJail) { n.AddSymlink(src, dst) } }
+
+// DisableProcMountOpt is the Option form of NsJail.DisableProcMount.
+func DisableProcMountOpt() Option { return func(n *NsJail) { n.DisableProcMount() } }
+
+// WithProcPathOpt is the Option form of NsJail.WithProcPath.
+func WithProcPathOpt(path string) Option { return func(n *NsJail) { n.WithProcPath(path) } }
+
+// MountProcRWOpt is the Option form of NsJail.MountProcRW.
+func MountProcRWOpt() Option { return func(n *NsJail) { n.MountProcRW() } }
+
+// WithSeccompStringOpt is the Option form of NsJail.WithSeccompString.
+func WithSeccompStringOpt(policy string) Option {
+	return func(n *NsJail) { n.WithSeccompString(policy) }
+}
+
+// WithSeccompPolicyOpt is the Option form of NsJail.WithSeccompPolicy.
+func WithSeccompPolicyOpt(path string) Option { return func(n *NsJail) { n.WithSeccompPolicy(path) } }
+
+// EnableSeccompLogOpt is the Option form of NsJail.EnableSeccompLog.
+func EnableSeccompLogOpt() Option { return func(n *NsJail) { n.EnableSeccompLog() } }
+
+// WithNiceLevelOpt is the Option form of NsJail.WithNiceLevel.
+func WithNiceLevelOpt(level int) Option { return func(n *NsJail) { n.WithNiceLevel(level) } }
+
+// WithCgroupMemMaxOpt is the Option form of NsJail.WithCgroupMemMax.
+func WithCgroupMemMaxOpt(bytes uint64) Option { return func(n *NsJail) { n.WithCgroupMemMax(bytes) } }
+
+// WithCgroupMemMemswMaxOpt is the Option form of NsJail.WithCgroupMemMemswMax.
+func WithCgroupMemMemswMaxOpt(bytes uint64) Option {
+	return func(n *NsJail) { n.WithCgroupMemMemswMax(bytes) }
+}
+
+// WithCgroupMemSwapMaxOpt is the Option form of NsJail.WithCgroupMemSwapMax.
+func WithCgroupMemSwapMaxOpt(bytes string) Option {
+	return func(n *NsJail) { n.WithCgroupMemSwapMax(bytes) }
+}
+
+// WithCgroupMemMountOpt is the Option form of NsJail.WithCgroupMemMount.
+func WithCgroupMemMountOpt(path string) Option { return func(n *NsJail) { n.WithCgroupMemMount(path) } }
+
+// WithCgroupMemParentOpt is the Option form of NsJail.WithCgroupMemParent.
+func WithCgroupMemParentOpt(parent string) Option {
+	return func(n *NsJail) { n.WithCgroupMemParent(parent) }
+}
+
+// WithCgroupPidsMaxOpt is the Option form of NsJail.WithCgroupPidsMax.
+func WithCgroupPidsMaxOpt(max uint) Option { return func(n *NsJail) { n.WithCgroupPidsMax(max) } }
+
+// WithCgroupPidsMountOpt is the Option form of NsJail.WithCgroupPidsMount.
+func WithCgroupPidsMountOpt(path string) Option {
+	return func(n *NsJail) { n.WithCgroupPidsMount(path) }
+}
+
+// WithCgroupPidsParentOpt is the Option form of NsJail.WithCgroupPidsParent.
+func WithCgroupPidsParentOpt(parent string) Option {
+	return func(n *NsJail) { n.WithCgroupPidsParent(parent) }
+}
+
+// WithCgroupNetClsClassidOpt is the Option form of NsJail.WithCgroupNetClsClassid.
+func WithCgroupNetClsClassidOpt(id uint32) Option {
+	return func(n *NsJail) { n.WithCgroupNetClsClassid(id) }
+}
+
+// WithCgroupNetClsMountOpt is the Option form of NsJail.WithCgroupNetClsMount.
+func WithCgroupNetClsMountOpt(path string) Option {
+	return func(n *NsJail) { n.WithCgroupNetClsMount(path) }
+}
+
+// WithCgroupNetClsParentOpt is the Option form of NsJail.WithCgroupNetClsParent.
+func WithCgroupNetClsParentOpt(parent string) Option {
+	return func(n *NsJail) { n.WithCgroupNetClsParent(parent) }
+}
+
+// WithCgroupCpuMsPerSecOpt is the Option form of NsJail.WithCgroupCpuMsPerSec.
+func WithCgroupCpuMsPerSecOpt(ms uint) Option { return func(n *NsJail) { n.WithCgroupCpuMsPerSec(ms) } }
+
+// WithCgroupCpuMountOpt is the Option form of NsJail.WithCgroupCpuMount.
+func WithCgroupCpuMountOpt(path string) Option { return func(n *NsJail) { n.WithCgroupCpuMount(path) } }
+
+// WithCgroupCpuParentOpt is the Option form of NsJail.WithCgroupCpuParent.
+func WithCgroupCpuParentOpt(parent string) Option {
+	return func(n *NsJail) { n.WithCgroupCpuParent(parent) }
+}
+
+// WithCgroupV2MountOpt is the Option form of NsJail.WithCgroupV2Mount.
+func WithCgroupV2MountOpt(path string) Option { return func(n *NsJail) { n.WithCgroupV2Mount(path) } }
+
+// UseCgroupV2Opt is the Option form of NsJail.UseCgroupV2.
+func UseCgroupV2Opt() Option { return func(n *NsJail) { n.UseCgroupV2() } }
+
+// DetectAndUseCgroupV2Opt is the Option form of NsJail.DetectAndUseCgroupV2.
+func DetectAndUseCgroupV2Opt() Option { return func(n *NsJail) { n.DetectAndUseCgroupV2() } }
+
+// DisableLoopbackInterfaceOpt is the Option form of NsJail.DisableLoopbackInterface.
+func DisableLoopbackInterfaceOpt() Option { return func(n *NsJail) { n.DisableLoopbackInterface() } }
+
+// AddOwnInterfaceOpt is the Option form of NsJail.AddOwnInterface.
+func AddOwnInterfaceOpt(iface string) Option { return func(n *NsJail) { n.AddOwnInterface(iface) } }
+
+// WithMacvlanIfaceOpt is the Option form of NsJail.WithMacvlanIface.
+func WithMacvlanIfaceOpt(iface string) Option { return func(n *NsJail) { n.WithMacvlanIface(iface) } }
+
+// WithMacvlanIpOpt is the Option form of NsJail.WithMacvlanIp.
+func WithMacvlanIpOpt(ip string) Option { return func(n *NsJail) { n.WithMacvlanIp(ip) } }
+
+// WithMacvlanNetmaskOpt is the Option form of NsJail.WithMacvlanNetmask.
+func WithMacvlanNetmaskOpt(nm string) Option { return func(n *NsJail) { n.WithMacvlanNetmask(nm) } }
+
+// WithMacvlanGatewayOpt is the Option form of NsJail.WithMacvlanGateway.
+func WithMacvlanGatewayOpt(gw string) Option { return func(n *NsJail) { n.WithMacvlanGateway(gw) } }
+
+// WithMacvlanMacOpt is the Option form of NsJail.WithMacvlanMac.
+func WithMacvlanMacOpt(mac string) Option { return func(n *NsJail) { n.WithMacvlanMac(mac) } }
+
+// WithMacvlanModeOpt is the Option form of NsJail.WithMacvlanMode.
+func WithMacvlanModeOpt(mode MacVlanMode) Option { return func(n *NsJail) { n.WithMacvlanMode(mode) } }
+
+// DisableTscOpt is the Option form of NsJail.DisableTsc.
+func DisableTscOpt() Option { return func(n *NsJail) { n.DisableTsc() } }
+
+// ForwardSignalsOpt is the Option form of NsJail.ForwardSignals.
+func ForwardSignalsOpt() Option { return func(n *NsJail) { n.ForwardSignals() } }
+
+// WithPortOpt is the Option form of NsJail.WithPort.
+func WithPortOpt(port uint16) Option { return func(n *NsJail) { n.WithPort(port) } }
+
+// WithBindhostOpt is the Option form of NsJail.WithBindhost.
+func WithBindhostOpt(ip string) Option { return func(n *NsJail) { n.WithBindhost(ip) } }
+
+// WithMaxConnsOpt is the Option form of NsJail.WithMaxConns.
+func WithMaxConnsOpt(max uint) Option { return func(n *NsJail) { n.WithMaxConns(max) } }
+
+// WithMaxConnsPerIpOpt is the Option form of NsJail.WithMaxConnsPerIp.
+func WithMaxConnsPerIpOpt(max uint) Option { return func(n *NsJail) { n.WithMaxConnsPerIp(max) } }
+
+// WithLogFileOpt is the Option form of NsJail.WithLogFile.
+func WithLogFileOpt(path string) Option { return func(n *NsJail) { n.WithLogFile(path) } }
+
+// WithLogFdOpt is the Option form of NsJail.WithLogFd.
+func WithLogFdOpt(fd int) Option { return func(n *NsJail) { n.WithLogFd(fd) } }
+
+// DaemonizeOpt is the Option form of NsJail.Daemonize.
+func DaemonizeOpt() Option { return func(n *NsJail) { n.Daemonize() } }
+
+// WithMaxCpusOpt is the Option form of NsJail.WithMaxCpus.
+func WithMaxCpusOpt(max uint) Option { return func(n *NsJail) { n.WithMaxCpus(max) } }
+
+// CollectFilesOpt is the Option form of NsJail.CollectFiles.
+func CollectFilesOpt(patterns ...string) Option {
+	return func(n *NsJail) { n.CollectFiles(patterns...) }
+}
+
+// WithArtifactOptionsOpt is the Option form of NsJail.WithArtifactOptions.
+func WithArtifactOptionsOpt(opts ArtifactOptions) Option {
+	return func(n *NsJail) { n.WithArtifactOptions(opts) }
+}
+
+// AddBindROOpt is the Option form of NsJail.AddBindRO.
+func AddBindROOpt(src, dst string) Option { return func(n *NsJail) { n.AddBindRO(src, dst) } }
+
+// AddBindRWOpt is the Option form of NsJail.AddBindRW.
+func AddBindRWOpt(src, dst string) Option { return func(n *NsJail) { n.AddBindRW(src, dst) } }
+
+// StrictMountsOpt is the Option form of NsJail.StrictMounts.
+func StrictMountsOpt() Option { return func(n *NsJail) { n.StrictMounts() } }
+
+// WithMemoryLimitOpt is the Option form of NsJail.WithMemoryLimit.
+func WithMemoryLimitOpt(bytes uint64) Option { return func(n *NsJail) { n.WithMemoryLimit(bytes) } }
+
+// WithPidsLimitOpt is the Option form of NsJail.WithPidsLimit.
+func WithPidsLimitOpt(max uint) Option { return func(n *NsJail) { n.WithPidsLimit(max) } }
+
+// WithCpuPercentOpt is the Option form of NsJail.WithCpuPercent.
+func WithCpuPercentOpt(percent uint) Option { return func(n *NsJail) { n.WithCpuPercent(percent) } }
+
+// WithCgroupAutoParentOpt is the Option form of NsJail.WithCgroupAutoParent.
+func WithCgroupAutoParentOpt() Option { return func(n *NsJail) { n.WithCgroupAutoParent() } }
+
+// WithCgroupV2MemoryMaxOpt is the Option form of NsJail.WithCgroupV2MemoryMax.
+func WithCgroupV2MemoryMaxOpt(bytes uint64) Option {
+	return func(n *NsJail) { n.WithCgroupV2MemoryMax(bytes) }
+}
+
+// WithCgroupV2MemorySwapMaxOpt is the Option form of NsJail.WithCgroupV2MemorySwapMax.
+func WithCgroupV2MemorySwapMaxOpt(bytes uint64) Option {
+	return func(n *NsJail) { n.WithCgroupV2MemorySwapMax(bytes) }
+}
+
+// WithCgroupV2CpuMaxOpt is the Option form of NsJail.WithCgroupV2CpuMax.
+func WithCgroupV2CpuMaxOpt(quota, period time.Duration) Option {
+	return func(n *NsJail) { n.WithCgroupV2CpuMax(quota, period) }
+}
+
+// WithCgroupV2PidsMaxOpt is the Option form of NsJail.WithCgroupV2PidsMax.
+func WithCgroupV2PidsMaxOpt(max uint) Option { return func(n *NsJail) { n.WithCgroupV2PidsMax(max) } }
+
+// AddCgroupV2IoMaxOpt is the Option form of NsJail.AddCgroupV2IoMax.
+func AddCgroupV2IoMaxOpt(limit IoMax) Option { return func(n *NsJail) { n.AddCgroupV2IoMax(limit) } }
+
+// WithCompatibilityOpt is the Option form of NsJail.WithCompatibility.
+func WithCompatibilityOpt(policy CompatPolicy) Option {
+	return func(n *NsJail) { n.WithCompatibility(policy) }
+}
+
+// WithCapabilitiesOpt is the Option form of NsJail.WithCapabilities.
+func WithCapabilitiesOpt(c *Capabilities) Option { return func(n *NsJail) { n.WithCapabilities(c) } }
+
+// WithCpuSetOpt is the Option form of NsJail.WithCpuSet.
+func WithCpuSetOpt(cpus []int) Option { return func(n *NsJail) { n.WithCpuSet(cpus) } }
+
+// WithCpuSetMemsOpt is the Option form of NsJail.WithCpuSetMems.
+func WithCpuSetMemsOpt(nodes []int) Option { return func(n *NsJail) { n.WithCpuSetMems(nodes) } }
+
+// WithDeadlineOpt is the Option form of NsJail.WithDeadline.
+func WithDeadlineOpt(wall, cpu time.Duration) Option {
+	return func(n *NsJail) { n.WithDeadline(wall, cpu) }
+}
+
+// WithDeadlineQuotaOpt is the Option form of NsJail.WithDeadlineQuota.
+func WithDeadlineQuotaOpt(wall, cpu time.Duration, percent uint) Option {
+	return func(n *NsJail) { n.WithDeadlineQuota(wall, cpu, percent) }
+}
+
+// AddBinaryWithDepsOpt is the Option form of NsJail.AddBinaryWithDeps.
+func AddBinaryWithDepsOpt(path string) Option { return func(n *NsJail) { n.AddBinaryWithDeps(path) } }
+
+// WithDNSInterceptorOpt is the Option form of NsJail.WithDNSInterceptor.
+func WithDNSInterceptorOpt(cfg DNSConfig) Option {
+	return func(n *NsJail) { n.WithDNSInterceptor(cfg) }
+}
+
+// DryRunOpt is the Option form of NsJail.DryRun.
+func DryRunOpt(w io.Writer) Option { return func(n *NsJail) { n.DryRun(w) } }
+
+// WithEgressByteLimitOpt is the Option form of NsJail.WithEgressByteLimit.
+func WithEgressByteLimitOpt(limit uint64) Option {
+	return func(n *NsJail) { n.WithEgressByteLimit(limit) }
+}
+
+// InheritEnvMatchingOpt is the Option form of NsJail.InheritEnvMatching.
+func InheritEnvMatchingOpt(patterns ...string) Option {
+	return func(n *NsJail) { n.InheritEnvMatching(patterns...) }
+}
+
+// PassEnvOpt is the Option form of NsJail.PassEnv.
+func PassEnvOpt(keys ...string) Option { return func(n *NsJail) { n.PassEnv(keys...) } }
+
+// KeepEnvExceptOpt is the Option form of NsJail.KeepEnvExcept.
+func KeepEnvExceptOpt(keys ...string) Option { return func(n *NsJail) { n.KeepEnvExcept(keys...) } }
+
+// AddEnvFileOpt is the Option form of NsJail.AddEnvFile.
+func AddEnvFileOpt(path string) Option { return func(n *NsJail) { n.AddEnvFile(path) } }
+
+// WithExecutorOpt is the Option form of NsJail.WithExecutor.
+func WithExecutorOpt(e Executor) Option { return func(n *NsJail) { n.WithExecutor(e) } }
+
+// WithKillSignalOpt is the Option form of NsJail.WithKillSignal.
+func WithKillSignalOpt(sig syscall.Signal, grace time.Duration) Option {
+	return func(n *NsJail) { n.WithKillSignal(sig, grace) }
+}
+
+// WithFileAccessPolicyOpt is the Option form of NsJail.WithFileAccessPolicy.
+func WithFileAccessPolicyOpt(p FileAccessPolicy) Option {
+	return func(n *NsJail) { n.WithFileAccessPolicy(p) }
+}
+
+// ForwardPortOpt is the Option form of NsJail.ForwardPort.
+func ForwardPortOpt(hostPort, jailPort uint16) Option {
+	return func(n *NsJail) { n.ForwardPort(hostPort, jailPort) }
+}
+
+// WithFileLimitsOpt is the Option form of NsJail.WithFileLimits.
+func WithFileLimitsOpt(dir string, limits FileLimits) Option {
+	return func(n *NsJail) { n.WithFileLimits(dir, limits) }
+}
+
+// WithHTTPCaptureOpt is the Option form of NsJail.WithHTTPCapture.
+func WithHTTPCaptureOpt(cfg HTTPCaptureConfig) Option {
+	return func(n *NsJail) { n.WithHTTPCapture(cfg) }
+}
+
+// WithRandomIdentityOpt is the Option form of NsJail.WithRandomIdentity.
+func WithRandomIdentityOpt() Option { return func(n *NsJail) { n.WithRandomIdentity() } }
+
+// AddUidMapOpt is the Option form of NsJail.AddUidMap.
+func AddUidMapOpt(inside, outside, count uint32) Option {
+	return func(n *NsJail) { n.AddUidMap(inside, outside, count) }
+}
+
+// AddGidMapOpt is the Option form of NsJail.AddGidMap.
+func AddGidMapOpt(inside, outside, count uint32) Option {
+	return func(n *NsJail) { n.AddGidMap(inside, outside, count) }
+}
+
+// MapCurrentUserOpt is the Option form of NsJail.MapCurrentUser.
+func MapCurrentUserOpt() Option { return func(n *NsJail) { n.MapCurrentUser() } }
+
+// MapRootOpt is the Option form of NsJail.MapRoot.
+func MapRootOpt() Option { return func(n *NsJail) { n.MapRoot() } }
+
+// CollectIsolationWarningsOpt is the Option form of NsJail.CollectIsolationWarnings.
+func CollectIsolationWarningsOpt() Option { return func(n *NsJail) { n.CollectIsolationWarnings() } }
+
+// WithConnectionTimeLimitOpt is the Option form of NsJail.WithConnectionTimeLimit.
+func WithConnectionTimeLimitOpt(d time.Duration) Option {
+	return func(n *NsJail) { n.WithConnectionTimeLimit(d) }
+}
+
+// WithConnectionDeadlineOpt is the Option form of NsJail.WithConnectionDeadline.
+func WithConnectionDeadlineOpt(d time.Duration) Option {
+	return func(n *NsJail) { n.WithConnectionDeadline(d) }
+}
+
+// ExitAfterConnectionsOpt is the Option form of NsJail.ExitAfterConnections.
+func ExitAfterConnectionsOpt(count uint) Option {
+	return func(n *NsJail) { n.ExitAfterConnections(count) }
+}
+
+// LockdownRootfsOpt is the Option form of NsJail.LockdownRootfs.
+func LockdownRootfsOpt(hooks ...func(*Lockdown)) Option {
+	return func(n *NsJail) { n.LockdownRootfs(hooks...) }
+}
+
+// WithLoggerOpt is the Option form of NsJail.WithLogger.
+func WithLoggerOpt(l *slog.Logger) Option { return func(n *NsJail) { n.WithLogger(l) } }
+
+// OnLogEventOpt is the Option form of NsJail.OnLogEvent.
+func OnLogEventOpt(fn LogEventFunc) Option { return func(n *NsJail) { n.OnLogEvent(fn) } }
+
+// AutoSelectMacvlanParentOpt is the Option form of NsJail.AutoSelectMacvlanParent.
+func AutoSelectMacvlanParentOpt() Option { return func(n *NsJail) { n.AutoSelectMacvlanParent() } }
+
+// WithBindhostAddrOpt is the Option form of NsJail.WithBindhostAddr.
+func WithBindhostAddrOpt(addr netip.Addr) Option { return func(n *NsJail) { n.WithBindhostAddr(addr) } }
+
+// WithListenAddrPortOpt is the Option form of NsJail.WithListenAddrPort.
+func WithListenAddrPortOpt(ap netip.AddrPort) Option {
+	return func(n *NsJail) { n.WithListenAddrPort(ap) }
+}
+
+// ListenDualStackOpt is the Option form of NsJail.ListenDualStack.
+func ListenDualStackOpt() Option { return func(n *NsJail) { n.ListenDualStack() } }
+
+// WithMacvlanIpAddrOpt is the Option form of NsJail.WithMacvlanIpAddr.
+func WithMacvlanIpAddrOpt(ip netip.Addr) Option { return func(n *NsJail) { n.WithMacvlanIpAddr(ip) } }
+
+// WithMacvlanPrefixOpt is the Option form of NsJail.WithMacvlanPrefix.
+func WithMacvlanPrefixOpt(p netip.Prefix) Option { return func(n *NsJail) { n.WithMacvlanPrefix(p) } }
+
+// WithMacvlanGatewayAddrOpt is the Option form of NsJail.WithMacvlanGatewayAddr.
+func WithMacvlanGatewayAddrOpt(gw netip.Addr) Option {
+	return func(n *NsJail) { n.WithMacvlanGatewayAddr(gw) }
+}
+
+// WithMacvlanHardwareAddrOpt is the Option form of NsJail.WithMacvlanHardwareAddr.
+func WithMacvlanHardwareAddrOpt(mac net.HardwareAddr) Option {
+	return func(n *NsJail) { n.WithMacvlanHardwareAddr(mac) }
+}
+
+// WithOverlayOpt is the Option form of NsJail.WithOverlay.
+func WithOverlayOpt(lower, upper, work string) Option {
+	return func(n *NsJail) { n.WithOverlay(lower, upper, work) }
+}
+
+// WithEphemeralOverlayOpt is the Option form of NsJail.WithEphemeralOverlay.
+func WithEphemeralOverlayOpt(lower string) Option {
+	return func(n *NsJail) { n.WithEphemeralOverlay(lower) }
+}
+
+// WithPidFileOpt is the Option form of NsJail.WithPidFile.
+func WithPidFileOpt(path string) Option { return func(n *NsJail) { n.WithPidFile(path) } }
+
+// WithPathChecksumOpt is the Option form of NsJail.WithPathChecksum.
+func WithPathChecksumOpt(path, sha256Hex string) Option {
+	return func(n *NsJail) { n.WithPathChecksum(path, sha256Hex) }
+}
+
+// WithNetworkPolicyOpt is the Option form of NsJail.WithNetworkPolicy.
+func WithNetworkPolicyOpt(p NetworkPolicy) Option { return func(n *NsJail) { n.WithNetworkPolicy(p) } }
+
+// WithResolvConfOpt is the Option form of NsJail.WithResolvConf.
+func WithResolvConfOpt(servers []string, searchDomains []string) Option {
+	return func(n *NsJail) { n.WithResolvConf(servers, searchDomains) }
+}
+
+// WithHostsEntriesOpt is the Option form of NsJail.WithHostsEntries.
+func WithHostsEntriesOpt(entries ...HostsEntry) Option {
+	return func(n *NsJail) { n.WithHostsEntries(entries...) }
+}
+
+// WithRlimitValOpt is the Option form of NsJail.WithRlimitVal.
+func WithRlimitValOpt(res RlimitResource, val RlimitVal) Option {
+	return func(n *NsJail) { n.WithRlimitVal(res, val) }
+}
+
+// WithRlimitAsBytesOpt is the Option form of NsJail.WithRlimitAsBytes.
+func WithRlimitAsBytesOpt(bytes uint64) Option { return func(n *NsJail) { n.WithRlimitAsBytes(bytes) } }
+
+// WithRlimitCoreBytesOpt is the Option form of NsJail.WithRlimitCoreBytes.
+func WithRlimitCoreBytesOpt(bytes uint64) Option {
+	return func(n *NsJail) { n.WithRlimitCoreBytes(bytes) }
+}
+
+// WithRlimitCpuDurationOpt is the Option form of NsJail.WithRlimitCpuDuration.
+func WithRlimitCpuDurationOpt(d time.Duration) Option {
+	return func(n *NsJail) { n.WithRlimitCpuDuration(d) }
+}
+
+// WithRlimitFsizeBytesOpt is the Option form of NsJail.WithRlimitFsizeBytes.
+func WithRlimitFsizeBytesOpt(bytes uint64) Option {
+	return func(n *NsJail) { n.WithRlimitFsizeBytes(bytes) }
+}
+
+// WithRlimitNofileCountOpt is the Option form of NsJail.WithRlimitNofileCount.
+func WithRlimitNofileCountOpt(count uint64) Option {
+	return func(n *NsJail) { n.WithRlimitNofileCount(count) }
+}
+
+// WithRlimitNprocCountOpt is the Option form of NsJail.WithRlimitNprocCount.
+func WithRlimitNprocCountOpt(count uint64) Option {
+	return func(n *NsJail) { n.WithRlimitNprocCount(count) }
+}
+
+// WithRlimitStackBytesOpt is the Option form of NsJail.WithRlimitStackBytes.
+func WithRlimitStackBytesOpt(bytes uint64) Option {
+	return func(n *NsJail) { n.WithRlimitStackBytes(bytes) }
+}
+
+// WithRlimitMemlockBytesOpt is the Option form of NsJail.WithRlimitMemlockBytes.
+func WithRlimitMemlockBytesOpt(bytes uint64) Option {
+	return func(n *NsJail) { n.WithRlimitMemlockBytes(bytes) }
+}
+
+// WithRlimitRtprioLevelOpt is the Option form of NsJail.WithRlimitRtprioLevel.
+func WithRlimitRtprioLevelOpt(prio uint64) Option {
+	return func(n *NsJail) { n.WithRlimitRtprioLevel(prio) }
+}
+
+// WithRlimitMsgqueueBytesOpt is the Option form of NsJail.WithRlimitMsgqueueBytes.
+func WithRlimitMsgqueueBytesOpt(bytes uint64) Option {
+	return func(n *NsJail) { n.WithRlimitMsgqueueBytes(bytes) }
+}
+
+// WithRootfsOpt is the Option form of NsJail.WithRootfs.
+func WithRootfsOpt(r *Rootfs) Option { return func(n *NsJail) { n.WithRootfs(r) } }
+
+// WithDrainTimeoutOpt is the Option form of NsJail.WithDrainTimeout.
+func WithDrainTimeoutOpt(d time.Duration) Option { return func(n *NsJail) { n.WithDrainTimeout(d) } }
+
+// WithStdioOpt is the Option form of NsJail.WithStdio.
+func WithStdioOpt(stdin io.Reader, stdout, stderr io.Writer) Option {
+	return func(n *NsJail) { n.WithStdio(stdin, stdout, stderr) }
+}
+
+// CollectSeccompViolationsOpt is the Option form of NsJail.CollectSeccompViolations.
+func CollectSeccompViolationsOpt(source string) Option {
+	return func(n *NsJail) { n.CollectSeccompViolations(source) }
+}
+
+// WithSecretFdOpt is the Option form of NsJail.WithSecretFd.
+func WithSecretFdOpt(name string, data []byte) Option {
+	return func(n *NsJail) { n.WithSecretFd(name, data) }
+}
+
+// WithRestartLimitOpt is the Option form of NsJail.WithRestartLimit.
+func WithRestartLimitOpt(limit uint) Option { return func(n *NsJail) { n.WithRestartLimit(limit) } }
+
+// WithInitShimOpt is the Option form of NsJail.WithInitShim.
+func WithInitShimOpt(hostPath string) Option { return func(n *NsJail) { n.WithInitShim(hostPath) } }
+
+// WithSlirp4netnsOpt is the Option form of NsJail.WithSlirp4netns.
+func WithSlirp4netnsOpt(cfg SlirpConfig) Option { return func(n *NsJail) { n.WithSlirp4netns(cfg) } }
+
+// WithStdinBytesOpt is the Option form of NsJail.WithStdinBytes.
+func WithStdinBytesOpt(b []byte) Option { return func(n *NsJail) { n.WithStdinBytes(b) } }
+
+// WithStdinReaderOpt is the Option form of NsJail.WithStdinReader.
+func WithStdinReaderOpt(r io.Reader, limit int64) Option {
+	return func(n *NsJail) { n.WithStdinReader(r, limit) }
+}
+
+// WithStdinDeadlineOpt is the Option form of NsJail.WithStdinDeadline.
+func WithStdinDeadlineOpt(d time.Duration) Option { return func(n *NsJail) { n.WithStdinDeadline(d) } }
+
+// WithStreamBufferingOpt is the Option form of NsJail.WithStreamBuffering.
+func WithStreamBufferingOpt(cfg StreamConfig) Option {
+	return func(n *NsJail) { n.WithStreamBuffering(cfg) }
+}
+
+// WithTemplateOpt is the Option form of NsJail.WithTemplate.
+func WithTemplateOpt(t *Template, dst string) Option {
+	return func(n *NsJail) { n.WithTemplate(t, dst) }
+}
+
+// WithVethOpt is the Option form of NsJail.WithVeth.
+func WithVethOpt(cfg VethConfig) Option { return func(n *NsJail) { n.WithVeth(cfg) } }
+
+// WatchDirOpt is the Option form of NsJail.WatchDir.
+func WatchDirOpt(dir string, fn FileEventFunc) Option { return func(n *NsJail) { n.WatchDir(dir, fn) } }
+
+// WithWatchdogOpt is the Option form of NsJail.WithWatchdog.
+func WithWatchdogOpt(w Watchdog) Option { return func(n *NsJail) { n.WithWatchdog(w) } }
+
+// WithWireGuardOpt is the Option form of NsJail.WithWireGuard.
+func WithWireGuardOpt(cfg WireGuardConfig) Option { return func(n *NsJail) { n.WithWireGuard(cfg) } }
+
+// WithWorkspaceOpt is the Option form of NsJail.WithWorkspace.
+func WithWorkspaceOpt(opts WorkspaceOptions) Option { return func(n *NsJail) { n.WithWorkspace(opts) } }
+
+// WithDiskQuotaOpt is the Option form of NsJail.WithDiskQuota.
+func WithDiskQuotaOpt(bytes uint64) Option { return func(n *NsJail) { n.WithDiskQuota(bytes) } }
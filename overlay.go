@@ -0,0 +1,85 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// overlay is the root filesystem set up by WithOverlay or WithEphemeralOverlay.
+type overlay struct {
+	lower, upper, work string
+	ephemeral          bool
+}
+
+// WithOverlay makes the root of the jail an overlayfs of the read-only lower directory and the writable
+// upper directory, so the jail can change its root without modifying lower (-m none:/:overlay:...). work
+// must be an empty directory on the same filesystem as upper. It replaces WithChroot; other mounts are
+// made on top of the overlay. Unprivileged overlay mounts need Linux 5.11 or later.
+func (n *NsJail) WithOverlay(lower, upper, work string) *NsJail {
+	n.overlay = &overlay{lower: lower, upper: upper, work: work}
+	return n.changed()
+}
+
+// WithEphemeralOverlay is like WithOverlay with upper and work directories that Start and Run create in a
+// temporary directory and remove once the jail exited, discarding all changes.
+func (n *NsJail) WithEphemeralOverlay(lower string) *NsJail {
+	n.overlay = &overlay{lower: lower, ephemeral: true}
+	return n.changed()
+}
+
+// mount returns the -m option of the overlay.
+func (o *overlay) mount() string {
+	return fmt.Sprintf("none:/:overlay:lowerdir=%s,upperdir=%s,workdir=%s", o.lower, o.upper, o.work)
+}
+
+// validateOverlay checks the overlay directories can be passed to nsjail.
+func (n *NsJail) validateOverlay() error {
+	o := n.overlay
+	if o == nil {
+		return nil
+	}
+	if n.chroot != "" {
+		return errors.New("nsjail: an overlay root cannot be combined with WithChroot")
+	}
+	if o.upper == "" {
+		return errors.New("nsjail: the ephemeral overlay is only created by Start and Run")
+	}
+	for _, dir := range []string{o.lower, o.upper, o.work} {
+		if dir == "" || strings.ContainsAny(dir, ":,") {
+			return fmt.Errorf("nsjail: overlay directory %q must be non-empty and contain no ':' or ','", dir)
+		}
+	}
+	return nil
+}
+
+// createEphemeralOverlay returns a copy of n using new upper and work directories, and a function
+// removing them.
+func (n *NsJail) createEphemeralOverlay() (*NsJail, func(), error) {
+	dir, err := os.MkdirTemp("", "nsjail-overlay-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	remove := func() {
+		// The kernel leaves an inaccessible directory in work.
+		filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if d != nil && d.IsDir() {
+				os.Chmod(p, 0o755)
+			}
+			return nil
+		})
+		os.RemoveAll(dir)
+	}
+	c := n.Clone()
+	c.overlay = &overlay{lower: n.overlay.lower, upper: filepath.Join(dir, "upper"), work: filepath.Join(dir, "work")}
+	for _, d := range []string{c.overlay.upper, c.overlay.work} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			remove()
+			return nil, nil, err
+		}
+	}
+	return c, remove, nil
+}
@@ -0,0 +1,171 @@
+package nsjail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// daemonStopGrace is how long StopDaemon waits after SIGTERM before it sends SIGKILL.
+const daemonStopGrace = 5 * time.Second
+
+// WithPidFile records the pid of the jail in a file when Start or Run start it, so the jail can be managed
+// by other processes or after the controlling process restarted, see StatusDaemon and StopDaemon. With
+// Daemonize the file holds the pid of the daemon, is written once the daemon is ready (see Jail.WaitReady)
+// and stays in place until StopDaemon removes it. Otherwise it holds the pid of nsjail and is removed once
+// nsjail exited. The start time of the process is recorded along with the pid, so a pid reused by another
+// process is not taken for the jail.
+func (n *NsJail) WithPidFile(path string) *NsJail { n.pidFile = path; return n.changed() }
+
+// DaemonStatus is the state of a jail recorded in a pid file, see StatusDaemon.
+type DaemonStatus struct {
+	Pid int
+	// Running reports whether the process is still running.
+	Running bool
+}
+
+// StatusDaemon reads the pid file written for WithPidFile and reports whether its jail is running.
+func StatusDaemon(path string) (*DaemonStatus, error) {
+	pid, start, err := readPidFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DaemonStatus{Pid: pid, Running: processRunning(pid, start)}, nil
+}
+
+// StopDaemon stops the jail recorded in the pid file written for WithPidFile and removes the file. The
+// jail is sent SIGTERM, on which nsjail kills the jailed processes and exits, and SIGKILL if it is still
+// running after 5 seconds.
+func StopDaemon(path string) error {
+	pid, start, err := readPidFile(path)
+	if err != nil {
+		return err
+	}
+	if processRunning(pid, start) {
+		p, err := os.FindProcess(pid)
+		if err != nil {
+			return err
+		}
+		p.Signal(syscall.SIGTERM)
+		if !awaitExit(pid, start, daemonStopGrace) {
+			p.Kill()
+			if !awaitExit(pid, start, daemonStopGrace) {
+				return fmt.Errorf("nsjail: pid %d did not exit", pid)
+			}
+		}
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// usePidFile makes the jail record its pid in the file of WithPidFile.
+func (j *Jail) usePidFile(n *NsJail) error {
+	w := j.daemon
+	if w == nil {
+		j.pidFile = n.pidFile
+		return nil
+	}
+	if w.err != nil {
+		return fmt.Errorf("nsjail: pid file: %w", w.err)
+	}
+	w.pidFile = n.pidFile
+	j.onStarted(func() error {
+		// WaitReady writes the file.
+		if _, err := j.WaitReady(context.Background()); err != nil {
+			j.log.Warn("nsjail: no pid file written for the daemon", "path", n.pidFile, "err", err)
+		}
+		return nil
+	})
+	return nil
+}
+
+// writeNsjailPidFile records the pid of nsjail in the pid file and removes it once nsjail exited.
+func (j *Jail) writeNsjailPidFile() {
+	if err := writePidFile(j.pidFile, j.proc.Pid()); err != nil {
+		j.Abort(err)
+		return
+	}
+	j.onClose(func() { os.Remove(j.pidFile) })
+}
+
+// writePidFile atomically replaces the file at path with the pid and start time of a process.
+func writePidFile(path string, pid int) error {
+	content := strconv.Itoa(pid)
+	if start, err := processStartTime(pid); err == nil {
+		content += " " + strconv.FormatUint(start, 10)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".nsjail-pid-*")
+	if err != nil {
+		return fmt.Errorf("nsjail: pid file: %w", err)
+	}
+	_, err = tmp.WriteString(content + "\n")
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("nsjail: pid file: %w", err)
+	}
+	return nil
+}
+
+// readPidFile returns the pid and, if recorded, the start time of the process in the pid file at path.
+func readPidFile(path string) (pid int, start uint64, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("nsjail: pid file: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) > 0 {
+		pid, err = strconv.Atoi(fields[0])
+	}
+	if len(fields) == 0 || err != nil || pid <= 0 {
+		return 0, 0, fmt.Errorf("nsjail: pid file %s holds no pid", path)
+	}
+	if len(fields) > 1 {
+		if start, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("nsjail: pid file %s holds an invalid start time", path)
+		}
+	}
+	return pid, start, nil
+}
+
+// awaitExit waits up to timeout for a process to exit and reports whether it did.
+func awaitExit(pid int, start uint64, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for processRunning(pid, start) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return true
+}
+
+// processRunning reports whether pid is running and, if start is not 0, started at start.
+func processRunning(pid int, start uint64) bool {
+	t, err := processStartTime(pid)
+	switch {
+	case err == nil:
+		return start == 0 || t == start
+	case errors.Is(err, os.ErrNotExist):
+		return false
+	}
+	// Without procfs the start time cannot be compared.
+	p, err := os.FindProcess(pid)
+	return err == nil && p.Signal(syscall.Signal(0)) == nil
+}
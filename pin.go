@@ -0,0 +1,54 @@
+package nsjail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned by Exec, Start and Run when the nsjail binary does not match the
+// checksum pinned with WithPathChecksum.
+var ErrChecksumMismatch = errors.New("nsjail: binary checksum mismatch")
+
+// WithPathChecksum sets the path to the nsjail binary like WithPath and pins its SHA-256, given in hex.
+// Exec, Start and Run hash the binary right before executing it and fail with ErrChecksumMismatch if it
+// changed. The pin stays in place when only WithPath is called afterwards, so a clone of a pinned template
+// can only run another binary by pinning that one too, e.g. n.Clone().WithPathChecksum(path, sum).
+func (n *NsJail) WithPathChecksum(path, sha256Hex string) *NsJail {
+	if b, err := hex.DecodeString(sha256Hex); err != nil || len(b) != sha256.Size {
+		n.fail("WithPathChecksum", "invalid SHA-256 %q", sha256Hex)
+		return n.changed()
+	}
+	n.path = path
+	n.pathChecksum = strings.ToLower(sha256Hex)
+	return n.changed()
+}
+
+// verifyBinary checks the nsjail binary against the pinned checksum, if any.
+func (n *NsJail) verifyBinary() error {
+	if n.pathChecksum == "" {
+		return nil
+	}
+	path, err := exec.LookPath(n.path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != n.pathChecksum {
+		return fmt.Errorf("%w: %s has SHA-256 %s, want %s", ErrChecksumMismatch, path, got, n.pathChecksum)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package nsjail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NetworkPolicy restricts the destinations the jail can connect to, see WithNetworkPolicy. Everything not
+// allowed is dropped; loopback traffic and replies to allowed connections always pass.
+type NetworkPolicy struct {
+	// AllowCIDRs are the networks the jail may reach.
+	AllowCIDRs []netip.Prefix
+	// AllowDomains are names whose addresses, resolved on the host when the jail starts, the jail may
+	// reach. Addresses the names resolve to later, e.g. behind a CDN, are not allowed. To also keep the
+	// jail from resolving other names, combine the policy with WithDNSInterceptor and AllowDomains.
+	AllowDomains []string
+	// Ports restricts the allowed destinations to these TCP and UDP ports. Empty allows all ports.
+	Ports []uint16
+	// AllowDNS lets the jail send DNS queries (port 53) to any destination, e.g. the nameservers of its
+	// resolv.conf. It is not needed with WithDNSInterceptor, whose forwarder listens on the loopback.
+	AllowDNS bool
+}
+
+// WithNetworkPolicy filters the outgoing traffic of the jail with firewall rules installed in its network
+// namespace by Start and Run, with nft or, if it is missing, iptables and ip6tables. The rules vanish with
+// the namespace. The init shim of WithInitShim or StartSession, which is required, holds the command until
+// the rules are in place, so the jail sends nothing unfiltered, also over interfaces nsjail creates itself
+// like MACVLAN. Exec, Args and Build fail, as the command they return would run unfiltered. Requires root,
+// nsenter and a network namespace (no DisableCloneNewNet).
+func (n *NsJail) WithNetworkPolicy(p NetworkPolicy) *NsJail { n.netPolicy = &p; return n.changed() }
+
+// validateNetworkPolicy rejects a network policy that Start or Run did not take over.
+func (n *NsJail) validateNetworkPolicy() error {
+	if n.netPolicy != nil {
+		return errors.New("nsjail: the network policy is only installed by Start and Run")
+	}
+	return nil
+}
+
+// useNetworkPolicy resolves the allowed domains and has the init shim hold the command until the rules are
+// installed.
+func (j *Jail) useNetworkPolicy(n *NsJail, l *launch, p *NetworkPolicy) error {
+	if n.cloneNewNetDisabled {
+		return errors.New("nsjail: a network policy requires a network namespace")
+	}
+	if n.initShim == "" {
+		return errors.New("nsjail: a network policy requires the init shim (WithInitShim), which holds the command until the rules are installed")
+	}
+	allowed := slices.Clone(p.AllowCIDRs)
+	for _, name := range p.AllowDomains {
+		ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", name)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("nsjail: network policy: %w", err)
+		}
+		for _, a := range addrs {
+			a = a.Unmap()
+			allowed = append(allowed, netip.PrefixFrom(a, a.BitLen()))
+		}
+	}
+	rules := policyRules{allowed: allowed, ports: p.Ports, dns: p.AllowDNS}
+	return j.holdCommand(l, func() error {
+		pid, err := j.jailPid(5 * time.Second)
+		if err != nil {
+			return fmt.Errorf("nsjail: network policy: %w", err)
+		}
+		if _, err := exec.LookPath("nft"); err == nil {
+			return runTool(strings.NewReader(rules.nftables()), "nsenter",
+				fmt.Sprintf("--net=/proc/%d/ns/net", pid), "--", "nft", "-f", "-")
+		}
+		for _, cmd := range rules.iptables() {
+			if err := inNetns(pid, cmd[0], cmd[1:]...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// policyRules renders a NetworkPolicy as firewall rules.
+type policyRules struct {
+	allowed []netip.Prefix
+	ports   []uint16
+	dns     bool
+}
+
+// split returns the allowed IPv4 and IPv6 networks.
+func (r *policyRules) split() (v4, v6 []string) {
+	for _, p := range r.allowed {
+		if p.Addr().Is4() {
+			v4 = append(v4, p.Masked().String())
+		} else {
+			v6 = append(v6, p.Masked().String())
+		}
+	}
+	return v4, v6
+}
+
+func (r *policyRules) portList() string {
+	s := make([]string, len(r.ports))
+	for i, p := range r.ports {
+		s[i] = strconv.Itoa(int(p))
+	}
+	return strings.Join(s, ",")
+}
+
+// nftables returns the ruleset for nft -f.
+func (r *policyRules) nftables() string {
+	var b strings.Builder
+	b.WriteString("table inet nsjail_policy {\n\tchain output {\n\t\ttype filter hook output priority 0; policy drop;\n")
+	b.WriteString("\t\toifname \"lo\" accept\n\t\tct state established,related accept\n")
+	if r.dns {
+		b.WriteString("\t\tudp dport 53 accept\n\t\ttcp dport 53 accept\n")
+	}
+	v4, v6 := r.split()
+	for _, set := range []struct {
+		family string
+		nets   []string
+	}{{"ip", v4}, {"ip6", v6}} {
+		if len(set.nets) == 0 {
+			continue
+		}
+		match := fmt.Sprintf("%s daddr { %s }", set.family, strings.Join(set.nets, ", "))
+		if len(r.ports) == 0 {
+			fmt.Fprintf(&b, "\t\t%s accept\n", match)
+			continue
+		}
+		for _, proto := range []string{"tcp", "udp"} {
+			fmt.Fprintf(&b, "\t\t%s %s dport { %s } accept\n", match, proto, r.portList())
+		}
+	}
+	b.WriteString("\t}\n}\n")
+	return b.String()
+}
+
+// iptables returns the iptables and ip6tables commands installing the rules.
+func (r *policyRules) iptables() [][]string {
+	v4, v6 := r.split()
+	var cmds [][]string
+	for _, set := range []struct {
+		tool string
+		nets []string
+	}{{"iptables", v4}, {"ip6tables", v6}} {
+		add := func(spec ...string) { cmds = append(cmds, append([]string{set.tool, "-A", "OUTPUT"}, spec...)) }
+		add("-o", "lo", "-j", "ACCEPT")
+		add("-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT")
+		if r.dns {
+			add("-p", "udp", "--dport", "53", "-j", "ACCEPT")
+			add("-p", "tcp", "--dport", "53", "-j", "ACCEPT")
+		}
+		for _, cidr := range set.nets {
+			if len(r.ports) == 0 {
+				add("-d", cidr, "-j", "ACCEPT")
+				continue
+			}
+			for _, proto := range []string{"tcp", "udp"} {
+				add("-d", cidr, "-p", proto, "-m", "multiport", "--dports", r.portList(), "-j", "ACCEPT")
+			}
+		}
+		// The policy is set last so the rules are complete once traffic is dropped.
+		cmds = append(cmds, []string{set.tool, "-P", "OUTPUT", "DROP"})
+	}
+	return cmds
+}
@@ -0,0 +1,118 @@
+package nsjail
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Payload is a job submitted to a Pool.
+type Payload struct {
+	// Command and Args replace the command of the template, if Command is set.
+	Command string
+	Args    []string
+	// Stdin is connected to the jailed process.
+	Stdin io.Reader
+	// MaxStdout and MaxStderr cap the output captured into the Result. Output is discarded if both are 0.
+	MaxStdout int64
+	MaxStderr int64
+	// Override adjusts the per-run copy of the template, e.g. to set a different working directory.
+	Override func(*NsJail)
+}
+
+type poolSlot struct {
+	template *NsJail
+	warm     bool
+}
+
+// Pool runs jobs on a fixed number of jail templates, at most one job per template at a time.
+// Every run works on a copy of its template, so overrides never leak between jobs.
+type Pool struct {
+	slots  chan *poolSlot
+	warmUp []func(ctx context.Context, template *NsJail) error
+}
+
+// NewPool creates a pool of size copies of template. The template itself is not retained.
+func NewPool(template *NsJail, size int) *Pool {
+	p := &Pool{slots: make(chan *poolSlot, max(size, 1))}
+	for range cap(p.slots) {
+		p.slots <- &poolSlot{template: template.Clone()}
+	}
+	return p
+}
+
+// WithWarmUp adds a hook that prepares each template once before its first job, e.g. to check the
+// nsjail binary or create directories. A failing hook is retried on the next job using that template.
+func (p *Pool) WithWarmUp(fn func(ctx context.Context, template *NsJail) error) *Pool {
+	p.warmUp = append(p.warmUp, fn)
+	return p
+}
+
+// Size returns the number of templates in the pool.
+func (p *Pool) Size() int { return cap(p.slots) }
+
+// WarmUp runs the warm-up hooks on every template that has not been warmed up yet.
+// It waits for templates busy with jobs.
+func (p *Pool) WarmUp(ctx context.Context) error {
+	slots := make([]*poolSlot, 0, cap(p.slots))
+	defer func() {
+		for _, s := range slots {
+			p.slots <- s
+		}
+	}()
+	for range cap(p.slots) {
+		select {
+		case s := <-p.slots:
+			slots = append(slots, s)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	var errs []error
+	for _, s := range slots {
+		errs = append(errs, p.warm(ctx, s))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Pool) warm(ctx context.Context, s *poolSlot) error {
+	if s.warm {
+		return nil
+	}
+	for _, fn := range p.warmUp {
+		if err := fn(ctx, s.template); err != nil {
+			return err
+		}
+	}
+	s.warm = true
+	return nil
+}
+
+// Submit waits for a free template, runs payload on a copy of it and returns the result.
+func (p *Pool) Submit(ctx context.Context, payload Payload) (*Result, error) {
+	var s *poolSlot
+	select {
+	case s = <-p.slots:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { p.slots <- s }()
+
+	if err := p.warm(ctx, s); err != nil {
+		return nil, err
+	}
+	return payload.run(ctx, s.template)
+}
+
+// run runs payload on a copy of template.
+func (payload *Payload) run(ctx context.Context, template *NsJail) (*Result, error) {
+	n := template.Clone()
+	if payload.Command != "" {
+		n.execCmd, n.args = payload.Command, payload.Args
+	}
+	n.stdin = payload.Stdin
+	if payload.Override != nil {
+		payload.Override(n)
+	}
+	return n.RunCaptured(ctx, payload.MaxStdout, payload.MaxStderr)
+}
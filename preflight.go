@@ -0,0 +1,277 @@
+package nsjail
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+)
+
+// CheckStatus is the outcome of a preflight check.
+type CheckStatus int
+
+const (
+	// CheckOK means the host provides what nsjail needs.
+	CheckOK CheckStatus = iota
+	// CheckWarn means some features will not work, e.g. multiple id mappings without newuidmap.
+	CheckWarn
+	// CheckFail means nsjail will not be able to run jails.
+	CheckFail
+)
+
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckOK:
+		return "ok"
+	case CheckWarn:
+		return "warn"
+	case CheckFail:
+		return "fail"
+	}
+	return fmt.Sprintf("CheckStatus(%d)", int(s))
+}
+
+// PreflightCheck is the result of one preflight check.
+type PreflightCheck struct {
+	// Name identifies the check: "platform", "user_namespaces", "newuidmap", "newgidmap", "subids",
+	// "cgroups" or "seccomp".
+	Name   string
+	Status CheckStatus
+	// Detail describes what was found.
+	Detail string
+	// Fix suggests how to resolve a warning or failure.
+	Fix string
+}
+
+// PreflightReport lists the results of Preflight.
+type PreflightReport struct {
+	Checks []PreflightCheck
+}
+
+// OK reports whether no check failed. Warnings are allowed.
+func (r *PreflightReport) OK() bool { return r.Err() == nil }
+
+// Err returns an error describing the failed checks and their fixes, or nil.
+func (r *PreflightReport) Err() error {
+	var errs []error
+	for _, c := range r.Checks {
+		if c.Status == CheckFail {
+			errs = append(errs, fmt.Errorf("nsjail: preflight %s: %s; %s", c.Name, c.Detail, c.Fix))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// String formats the report as a table.
+func (r *PreflightReport) String() string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL\tFIX")
+	for _, c := range r.Checks {
+		fmt.Fprintf(tw, "%s\t%v\t%s\t%s\n", c.Name, c.Status, c.Detail, c.Fix)
+	}
+	tw.Flush()
+	return sb.String()
+}
+
+// Preflight checks the host for what nsjail needs to run jails as the current user: unprivileged user
+// namespaces, setuid newuidmap and newgidmap with subordinate ids for multiple id mappings, a cgroup
+// delegated to the user for cgroup limits, and seccomp. Services can call it at startup to fail fast with
+// actionable messages instead of failing the first jail.
+func Preflight() *PreflightReport {
+	r := &PreflightReport{}
+	if runtime.GOOS != "linux" {
+		r.Checks = append(r.Checks, PreflightCheck{Name: "platform", Status: CheckFail,
+			Detail: runtime.GOOS + " has no Linux namespaces", Fix: "run on Linux"})
+		return r
+	}
+	root := os.Geteuid() == 0
+	r.Checks = append(r.Checks, checkUserNamespaces(root))
+	for _, tool := range []string{"newuidmap", "newgidmap"} {
+		r.Checks = append(r.Checks, checkIDMapTool(tool, root))
+	}
+	r.Checks = append(r.Checks, checkSubIDs(root), checkCgroups(root), checkSeccomp())
+	return r
+}
+
+func checkUserNamespaces(root bool) PreflightCheck {
+	c := PreflightCheck{Name: "user_namespaces", Detail: "enabled"}
+	if root {
+		c.Detail = "not needed when running as root"
+		return c
+	}
+	if v, err := readSysctl("user/max_user_namespaces"); err == nil && v == "0" {
+		c.Status, c.Detail = CheckFail, "user.max_user_namespaces is 0"
+		c.Fix = "sysctl -w user.max_user_namespaces=15000"
+		return c
+	}
+	// Debian and Ubuntu kernels.
+	if v, err := readSysctl("kernel/unprivileged_userns_clone"); err == nil && v == "0" {
+		c.Status, c.Detail = CheckFail, "kernel.unprivileged_userns_clone is 0"
+		c.Fix = "sysctl -w kernel.unprivileged_userns_clone=1"
+		return c
+	}
+	// Ubuntu 23.10 and later restrict unprivileged user namespaces with AppArmor.
+	if v, err := readSysctl("kernel/apparmor_restrict_unprivileged_userns"); err == nil && v == "1" {
+		c.Status, c.Detail = CheckFail, "kernel.apparmor_restrict_unprivileged_userns is 1"
+		c.Fix = "add an AppArmor profile allowing userns for nsjail, or sysctl -w kernel.apparmor_restrict_unprivileged_userns=0"
+		return c
+	}
+	if _, err := os.Stat("/proc/self/ns/user"); err != nil {
+		c.Status, c.Detail = CheckFail, "the kernel has no user namespaces"
+		c.Fix = "use a kernel built with CONFIG_USER_NS"
+	}
+	return c
+}
+
+func checkIDMapTool(name string, root bool) PreflightCheck {
+	c := PreflightCheck{Name: name}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		c.Status, c.Detail = CheckWarn, name+" not found"
+		c.Fix = "install the uidmap package to map more than one id"
+		return c
+	}
+	c.Detail = path
+	if root {
+		return c
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		c.Status, c.Detail = CheckWarn, err.Error()
+		return c
+	}
+	if st.Mode()&os.ModeSetuid == 0 {
+		// Some distributions grant file capabilities instead, which cannot be told apart without xattrs.
+		c.Status, c.Detail = CheckWarn, path+" is not setuid"
+		c.Fix = "chmod u+s " + path + " unless it has the cap_setuid/cap_setgid file capabilities"
+	}
+	return c
+}
+
+func checkSubIDs(root bool) PreflightCheck {
+	c := PreflightCheck{Name: "subids", Detail: "not needed when running as root"}
+	if root {
+		return c
+	}
+	u, err := user.Current()
+	if err != nil {
+		c.Status, c.Detail = CheckWarn, err.Error()
+		return c
+	}
+	var missing []string
+	for _, file := range []string{"/etc/subuid", "/etc/subgid"} {
+		if !hasSubIDs(file, u) {
+			missing = append(missing, file)
+		}
+	}
+	if len(missing) > 0 {
+		c.Status, c.Detail = CheckWarn, "no range for "+u.Username+" in "+strings.Join(missing, " and ")
+		c.Fix = "usermod --add-subuids 100000-165535 --add-subgids 100000-165535 " + u.Username
+		return c
+	}
+	c.Detail = "ranges for " + u.Username
+	return c
+}
+
+// hasSubIDs reports whether file lists a subordinate id range for u, by name or by id.
+func hasSubIDs(file string, u *user.User) bool {
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		owner, _, _ := strings.Cut(sc.Text(), ":")
+		if owner == u.Username || owner == u.Uid {
+			return true
+		}
+	}
+	return false
+}
+
+func checkCgroups(root bool) PreflightCheck {
+	c := PreflightCheck{Name: "cgroups"}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		c.Status, c.Detail = CheckWarn, err.Error()
+		return c
+	}
+	var own string
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			own = path
+		}
+	}
+	mount := cgroup2Root()
+	if _, err := os.Stat(filepath.Join(mount, "cgroup.controllers")); own == "" || err != nil {
+		c.Status, c.Detail = CheckWarn, "no cgroup v2 hierarchy; cgroup v1 limits need write access to /sys/fs/cgroup/<controller>/NSJAIL"
+		if !root {
+			c.Fix = "boot with systemd.unified_cgroup_hierarchy=1, or create the NSJAIL cgroups and chown them to the user"
+		}
+		return c
+	}
+	if root {
+		c.Detail = "cgroup v2 at " + mount
+		return c
+	}
+	// An unprivileged user needs a writable cgroup with the controllers enabled, e.g. a systemd scope.
+	dir := filepath.Join(mount, own)
+	f, err := os.OpenFile(filepath.Join(dir, "cgroup.subtree_control"), os.O_WRONLY, 0)
+	if err != nil {
+		c.Status, c.Detail = CheckWarn, "cgroup "+dir+" is not delegated to the user"
+		c.Fix = "run under systemd-run --user --scope -p Delegate=yes, and pass that cgroup to WithCgroupV2Mount"
+		return c
+	}
+	f.Close()
+	controllers, _ := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	var missing []string
+	for _, ctrl := range []string{"memory", "pids", "cpu"} {
+		if !strings.Contains(" "+strings.TrimSpace(string(controllers))+" ", " "+ctrl+" ") {
+			missing = append(missing, ctrl)
+		}
+	}
+	if len(missing) > 0 {
+		c.Status, c.Detail = CheckWarn, "controllers not delegated to "+dir+": "+strings.Join(missing, ", ")
+		c.Fix = "set Delegate=" + strings.Join(missing, " ") + " on the user's systemd unit"
+		return c
+	}
+	c.Detail = "delegated: " + dir
+	return c
+}
+
+func checkSeccomp() PreflightCheck {
+	c := PreflightCheck{Name: "seccomp"}
+	status, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		c.Status, c.Detail = CheckWarn, err.Error()
+		return c
+	}
+	if !strings.Contains(string(status), "\nSeccomp:") {
+		c.Status, c.Detail = CheckFail, "the kernel has no seccomp support"
+		c.Fix = "use a kernel built with CONFIG_SECCOMP_FILTER"
+		return c
+	}
+	c.Detail = "supported"
+	if actions, err := readSysctl("kernel/seccomp/actions_avail"); err == nil {
+		c.Detail += ", actions: " + actions
+		if !strings.Contains(actions, "log") {
+			c.Status = CheckWarn
+			c.Fix = "WithSeccompLog needs Linux 4.14 or later"
+		}
+	}
+	return c
+}
+
+// readSysctl reads a value from /proc/sys, e.g. "user/max_user_namespaces".
+func readSysctl(name string) (string, error) {
+	data, err := os.ReadFile("/proc/sys/" + name)
+	return strings.TrimSpace(string(data)), err
+}
@@ -0,0 +1,99 @@
+package nsjail
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+)
+
+// PresetSeccompPolicy is the seccomp policy of the presets, in Kafel syntax. It denies the syscalls that
+// toolchains do not need and that widen the kernel attack surface: debugging other processes, namespaces,
+// mounts, BPF, perf, keyrings and userfaultfd.
+const PresetSeccompPolicy = `ERRNO(1) {
+	ptrace, process_vm_readv, process_vm_writev,
+	unshare, setns, mount, umount2, pivot_root, chroot,
+	bpf, perf_event_open, userfaultfd,
+	keyctl, add_key, request_key,
+	kexec_load, init_module, finit_module, delete_module,
+	swapon, swapoff, reboot, acct
+}
+DEFAULT ALLOW`
+
+// presetMounts are the host paths mounted read-only by the presets, where they exist.
+var presetMounts = []string{"/usr", "/lib", "/lib64", "/lib32", "/bin", "/sbin", "/etc/ld.so.cache", "/etc/alternatives"}
+
+// presetDevices are the device nodes mounted by the presets.
+var presetDevices = []string{"/dev/null", "/dev/zero", "/dev/urandom"}
+
+// presetBase returns a jail running prog from the host's system directories, mounted read-only, with a
+// writable tmpfs /tmp as working and home directory, PresetSeccompPolicy and conservative limits.
+func presetBase(prog string, args []string, extraMounts ...string) *NsJail {
+	path := "/usr/bin/" + prog
+	for _, dir := range []string{"/usr/local/bin", "/usr/bin", "/bin"} {
+		if p, err := exec.LookPath(filepath.Join(dir, prog)); err == nil {
+			path = p
+			break
+		}
+	}
+	n := New(path, args...).
+		WithHostname("jail").
+		WithCwd("/tmp").
+		AddTmpfsMount("/tmp").
+		AddEnv("PATH", "/usr/local/bin:/usr/bin:/bin").
+		AddEnv("HOME", "/tmp").
+		AddEnv("LANG", "C.UTF-8").
+		WithSeccompString(PresetSeccompPolicy).
+		WithRlimitCpu("10").
+		WithRlimitFsize("64").
+		WithRlimitNofile("256").
+		WithRlimitNproc("64").
+		WithRlimitCore("0")
+	for _, p := range slices.Concat(presetMounts, extraMounts) {
+		if _, err := os.Lstat(p); err == nil {
+			n.AddBindMountRO(p)
+		}
+	}
+	for _, d := range presetDevices {
+		n.AddBindMountRW(d)
+	}
+	return n
+}
+
+// PresetPython3 returns a jail running python3 with args, e.g. PresetPython3("-c", code). It has the
+// host's /usr and libraries mounted read-only, a writable /tmp, 10 seconds of CPU time and 1 GiB of
+// address space. Like every preset, it is a regular configuration that can be adjusted further.
+func PresetPython3(args ...string) *NsJail {
+	return presetBase("python3", args).
+		AddEnv("PYTHONDONTWRITEBYTECODE", "1").
+		AddEnv("PYTHONUNBUFFERED", "1").
+		WithRlimitAs("1024")
+}
+
+// PresetNodeJS returns a jail running node with args. V8 reserves more address space than it uses, so
+// the address space is not limited; limit memory with a cgroup instead.
+func PresetNodeJS(args ...string) *NsJail {
+	return presetBase("node", args).
+		AddEnv("NODE_OPTIONS", "--max-old-space-size=512").
+		WithRlimitAs(string(RlimitInf))
+}
+
+// PresetJavaJDK returns a jail running java with args, with the JDK's configuration under /etc mounted.
+// The JVM reserves more address space than it uses and runs many threads, so the address space is not
+// limited and more processes are allowed; limit memory with a cgroup instead.
+func PresetJavaJDK(args ...string) *NsJail {
+	jdkConf, _ := filepath.Glob("/etc/java-*")
+	return presetBase("java", args, jdkConf...).
+		AddEnv("JAVA_TOOL_OPTIONS", "-XX:+UseSerialGC -Xss8m").
+		WithRlimitAs(string(RlimitInf)).
+		WithRlimitNproc("512").
+		WithRlimitNofile("1024")
+}
+
+// PresetGCC returns a jail running gcc with args. The compiler's intermediate files and output go to the
+// working directory /tmp.
+func PresetGCC(args ...string) *NsJail {
+	return presetBase("gcc", args).
+		WithRlimitAs("2048").
+		WithRlimitFsize("256")
+}
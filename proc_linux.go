@@ -0,0 +1,206 @@
+package nsjail
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// childPids returns the pids of the direct children of pid, read from /proc.
+func childPids(pid int) ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, e := range entries {
+		child, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		if ppid, err := parentPid(child); err == nil && ppid == pid {
+			pids = append(pids, child)
+		}
+	}
+	return pids, nil
+}
+
+// parentPid returns the parent of pid from /proc/<pid>/stat.
+func parentPid(pid int) (int, error) {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0, err
+	}
+	// The command name is in parentheses and may itself contain spaces and parentheses.
+	fields := bytes.Fields(stat[bytes.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 2 {
+		return 0, os.ErrInvalid
+	}
+	return strconv.Atoi(string(fields[1]))
+}
+
+// listeningOn reports whether a TCP socket listens on port, in any address family, per /proc/net.
+func listeningOn(port uint16) (bool, error) {
+	want := []byte(fmt.Sprintf(":%04X", port))
+	found := false
+	for _, file := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return false, err
+		}
+		for _, line := range bytes.Split(data, []byte("\n"))[1:] {
+			// sl local_address rem_address st ...; state 0A is TCP_LISTEN.
+			fields := bytes.Fields(line)
+			if len(fields) > 3 && bytes.HasSuffix(fields[1], want) && string(fields[3]) == "0A" {
+				found = true
+			}
+		}
+	}
+	return found, nil
+}
+
+// egressBytes sums the bytes sent on the non-loopback interfaces of pid's network namespace.
+func egressBytes(pid int) (uint64, error) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/net/dev")
+	if err != nil {
+		return 0, err
+	}
+	var total uint64
+	// Two header lines, then "iface: rx_bytes rx_packets ... (8 receive fields) tx_bytes ...".
+	for _, line := range bytes.Split(data, []byte("\n"))[2:] {
+		iface, counters, ok := bytes.Cut(line, []byte(":"))
+		if !ok || string(bytes.TrimSpace(iface)) == "lo" {
+			continue
+		}
+		fields := bytes.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		tx, err := strconv.ParseUint(string(fields[8]), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		total += tx
+	}
+	return total, nil
+}
+
+// oNoFollow makes opening a symlink fail. Files inside jails are only accessed through procfs on linux.
+const oNoFollow = syscall.O_NOFOLLOW
+
+// descendantPids returns the pids of all descendants of pid, parents before their children, read from /proc.
+func descendantPids(pid int) ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int][]int)
+	for _, e := range entries {
+		p, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		if ppid, err := parentPid(p); err == nil {
+			children[ppid] = append(children[ppid], p)
+		}
+	}
+	var pids []int
+	for queue := children[pid]; len(queue) > 0; queue = queue[1:] {
+		pids = append(pids, queue[0])
+		queue = append(queue, children[queue[0]]...)
+	}
+	return pids, nil
+}
+
+// sigStop and sigCont pause and continue the processes of a jail.
+const sigStop, sigCont = syscall.SIGSTOP, syscall.SIGCONT
+
+// pipeHolders returns the pids of the processes other than this one that have an end of the pipe f open.
+func pipeHolders(f *os.File) ([]int, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	want := fmt.Sprintf("pipe:[%d]", fi.Sys().(*syscall.Stat_t).Ino)
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		dir := "/proc/" + e.Name() + "/fd/"
+		fds, _ := os.ReadDir(dir)
+		for _, fd := range fds {
+			if link, err := os.Readlink(dir + fd.Name()); err == nil && link == want {
+				pids = append(pids, pid)
+				break
+			}
+		}
+	}
+	return pids, nil
+}
+
+// processStartTime returns when pid started, in clock ticks since boot, from /proc/<pid>/stat. A zombie,
+// which is no longer running, is reported as os.ErrNotExist.
+func processStartTime(pid int) (uint64, error) {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0, err
+	}
+	// starttime is the 22nd field, the 20th after the command name.
+	fields := bytes.Fields(stat[bytes.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 20 {
+		return 0, os.ErrInvalid
+	}
+	if string(fields[0]) == "Z" {
+		return 0, os.ErrNotExist
+	}
+	return strconv.ParseUint(string(fields[19]), 10, 64)
+}
+
+// userHZ is the unit of the times in /proc/<pid>/stat, 100 on every Linux architecture.
+const userHZ = 100
+
+// readProcessUsage returns the resource usage of pid from /proc/<pid>/stat and /proc/<pid>/io. The bytes
+// written stay 0 if /proc/<pid>/io cannot be read, e.g. for a process that changed its credentials.
+func readProcessUsage(pid int) (processUsage, error) {
+	dir := "/proc/" + strconv.Itoa(pid)
+	stat, err := os.ReadFile(dir + "/stat")
+	if err != nil {
+		return processUsage{}, err
+	}
+	// utime, stime, starttime and rss are the 14th, 15th, 22nd and 24th fields.
+	fields := bytes.Fields(stat[bytes.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 22 {
+		return processUsage{}, os.ErrInvalid
+	}
+	var v [4]uint64
+	for i, field := range []int{11, 12, 19, 21} {
+		if v[i], err = strconv.ParseUint(string(fields[field]), 10, 64); err != nil {
+			return processUsage{}, err
+		}
+	}
+	u := processUsage{
+		start: v[2],
+		cpu:   time.Duration(v[0]+v[1]) * time.Second / userHZ,
+		rss:   v[3] * uint64(os.Getpagesize()),
+	}
+	if io, err := os.ReadFile(dir + "/io"); err == nil {
+		for _, line := range bytes.Split(io, []byte("\n")) {
+			if value, ok := bytes.CutPrefix(line, []byte("write_bytes: ")); ok {
+				u.written, _ = strconv.ParseUint(string(value), 10, 64)
+			}
+		}
+	}
+	return u, nil
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+
+package nsjail
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errNoProcfs = errors.New("nsjail: process inspection requires linux")
+
+func childPids(pid int) ([]int, error) { return nil, errNoProcfs }
+
+func parentPid(pid int) (int, error) { return 0, errNoProcfs }
+
+func descendantPids(pid int) ([]int, error) { return nil, errNoProcfs }
+
+// sigStop and sigCont are the Linux values; not every platform defines them and jails are never walked here.
+const sigStop, sigCont = syscall.Signal(0x13), syscall.Signal(0x12)
+
+func listeningOn(port uint16) (bool, error) { return false, errNoProcfs }
+
+func pipeHolders(f *os.File) ([]int, error) { return nil, errNoProcfs }
+
+func processStartTime(pid int) (uint64, error) { return 0, errNoProcfs }
+
+func egressBytes(pid int) (uint64, error) { return 0, errNoProcfs }
+
+func readProcessUsage(pid int) (processUsage, error) { return processUsage{}, errNoProcfs }
+
+// oNoFollow makes opening a symlink fail. Files inside jails are only accessed through procfs on linux.
+const oNoFollow = 0
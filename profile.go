@@ -0,0 +1,127 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownProfile is returned by ProfileRegistry.Chain for names that are not registered.
+var ErrUnknownProfile = errors.New("nsjail: unknown profile")
+
+// Profile is a named, reusable bundle of options, e.g. "baseline-hardening", "allow-network" or
+// "compiler". Profiles are stacked per job with ProfileChain.
+type Profile struct {
+	Name        string
+	Description string
+	Options     []Option
+}
+
+// NewProfile returns a profile applying opts in order.
+func NewProfile(name string, opts ...Option) *Profile {
+	return &Profile{Name: name, Options: opts}
+}
+
+// Apply applies the options of the profile to n.
+func (p *Profile) Apply(n *NsJail) *NsJail {
+	return n.Apply(p.Options...)
+}
+
+// Option returns the profile as a single Option.
+func (p *Profile) Option() Option {
+	return Options(p.Options...)
+}
+
+// ProfileChain is a stack of profiles applied in order, so later profiles override the settings of
+// earlier ones and add to their lists of mounts, environment variables and the like.
+type ProfileChain []*Profile
+
+// Apply applies every profile of the chain to n in order.
+func (c ProfileChain) Apply(n *NsJail) *NsJail {
+	for _, p := range c {
+		p.Apply(n)
+	}
+	return n
+}
+
+// Option returns the chain as a single Option.
+func (c ProfileChain) Option() Option {
+	return func(n *NsJail) { c.Apply(n) }
+}
+
+// Names returns the names of the profiles in order.
+func (c ProfileChain) Names() []string {
+	names := make([]string, len(c))
+	for i, p := range c {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// ProfileRegistry holds profiles by name. It is safe for concurrent use.
+type ProfileRegistry struct {
+	mu       sync.RWMutex
+	profiles map[string]*Profile
+}
+
+// NewProfileRegistry returns an empty registry.
+func NewProfileRegistry() *ProfileRegistry {
+	return &ProfileRegistry{profiles: make(map[string]*Profile)}
+}
+
+// DefaultProfiles is the registry used by RegisterProfile and ProfilesByName.
+var DefaultProfiles = NewProfileRegistry()
+
+// Register adds p to the registry. It fails if a profile of the same name is already registered.
+func (r *ProfileRegistry) Register(p *Profile) error {
+	if p.Name == "" {
+		return errors.New("nsjail: profile without a name")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.profiles[p.Name]; ok {
+		return fmt.Errorf("nsjail: profile %q is already registered", p.Name)
+	}
+	r.profiles[p.Name] = p
+	return nil
+}
+
+// Lookup returns the profile registered under name.
+func (r *ProfileRegistry) Lookup(name string) (*Profile, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.profiles[name]
+	return p, ok
+}
+
+// Chain returns the chain of the profiles registered under names, in the given order.
+func (r *ProfileRegistry) Chain(names ...string) (ProfileChain, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	chain := make(ProfileChain, len(names))
+	for i, name := range names {
+		p, ok := r.profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownProfile, name)
+		}
+		chain[i] = p
+	}
+	return chain, nil
+}
+
+// Names returns the names of the registered profiles, sorted.
+func (r *ProfileRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sortedKeys(r.profiles)
+}
+
+// RegisterProfile adds p to DefaultProfiles.
+func RegisterProfile(p *Profile) error {
+	return DefaultProfiles.Register(p)
+}
+
+// ProfilesByName returns the chain of the profiles registered in DefaultProfiles under names.
+func ProfilesByName(names ...string) (ProfileChain, error) {
+	return DefaultProfiles.Chain(names...)
+}
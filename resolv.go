@@ -0,0 +1,101 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+)
+
+// resolvConf is the resolver configuration set with WithResolvConf.
+type resolvConf struct {
+	servers, search []string
+}
+
+// HostsEntry is a line of the jail's /etc/hosts, see WithHostsEntries.
+type HostsEntry struct {
+	Addr  netip.Addr
+	Names []string
+}
+
+// WithResolvConf gives the jail an /etc/resolv.conf listing the nameservers (IP addresses) and search
+// domains, mounted read-only over the one of the rootfs. Not compatible with WithDNSInterceptor, which
+// points the jail at its own forwarder.
+func (n *NsJail) WithResolvConf(servers []string, searchDomains []string) *NsJail {
+	n.resolvConf = &resolvConf{servers: slices.Clone(servers), search: slices.Clone(searchDomains)}
+	return n.changed()
+}
+
+// WithHostsEntries gives the jail an /etc/hosts with the entries, after ones for localhost and the hostname
+// of the jail, mounted read-only over the one of the rootfs. Can be called multiple times.
+func (n *NsJail) WithHostsEntries(entries ...HostsEntry) *NsJail {
+	n.hostsEntries = append(n.hostsEntries, entries...)
+	return n.changed()
+}
+
+// useResolvConf mounts the resolv.conf set with WithResolvConf.
+func (j *Jail) useResolvConf(n *NsJail, l *launch) error {
+	if n.dns != nil {
+		return errors.New("nsjail: WithResolvConf cannot be combined with WithDNSInterceptor")
+	}
+	var b strings.Builder
+	for _, s := range n.resolvConf.servers {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return fmt.Errorf("nsjail: invalid nameserver %q: %w", s, err)
+		}
+		fmt.Fprintf(&b, "nameserver %s\n", addr)
+	}
+	if search := n.resolvConf.search; len(search) > 0 {
+		for _, d := range search {
+			if d == "" || strings.ContainsAny(d, " \t\n") {
+				return fmt.Errorf("nsjail: invalid search domain %q", d)
+			}
+		}
+		fmt.Fprintf(&b, "search %s\n", strings.Join(search, " "))
+	}
+	return j.mountTempFile(l, "nsjail-resolv-*.conf", b.String(), "/etc/resolv.conf")
+}
+
+// useHostsEntries mounts the hosts file set with WithHostsEntries.
+func (j *Jail) useHostsEntries(n *NsJail, l *launch) error {
+	var b strings.Builder
+	b.WriteString("127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost ip6-loopback\n")
+	if n.hostname != "" {
+		fmt.Fprintf(&b, "127.0.1.1\t%s\n", n.hostname)
+	}
+	for _, e := range n.hostsEntries {
+		if !e.Addr.IsValid() || len(e.Names) == 0 {
+			return errors.New("nsjail: a hosts entry needs an address and at least one name")
+		}
+		for _, name := range e.Names {
+			if name == "" || strings.ContainsAny(name, " \t\n#") {
+				return fmt.Errorf("nsjail: invalid host name %q", name)
+			}
+		}
+		fmt.Fprintf(&b, "%s\t%s\n", e.Addr, strings.Join(e.Names, " "))
+	}
+	return j.mountTempFile(l, "nsjail-hosts-*", b.String(), "/etc/hosts")
+}
+
+// mountTempFile writes content to a temporary file removed with the jail and mounts it read-only at dst.
+func (j *Jail) mountTempFile(l *launch, pattern, content, dst string) error {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return err
+	}
+	j.onClose(func() { os.Remove(f.Name()) })
+	_, err = f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	// The jailed user may be mapped to another host user.
+	os.Chmod(f.Name(), 0o644)
+	l.flags = append(l.flags, "-R", f.Name()+":"+dst)
+	return nil
+}
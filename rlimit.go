@@ -0,0 +1,147 @@
+package nsjail
+
+import (
+	"strconv"
+	"time"
+)
+
+// RlimitResource identifies one of the resource limits nsjail sets with --rlimit_*.
+type RlimitResource string
+
+const (
+	ResourceAs       RlimitResource = "as"       // RLIMIT_AS, in MB
+	ResourceCore     RlimitResource = "core"     // RLIMIT_CORE, in MB
+	ResourceCpu      RlimitResource = "cpu"      // RLIMIT_CPU, in seconds
+	ResourceFsize    RlimitResource = "fsize"    // RLIMIT_FSIZE, in MB
+	ResourceNofile   RlimitResource = "nofile"   // RLIMIT_NOFILE, a count
+	ResourceNproc    RlimitResource = "nproc"    // RLIMIT_NPROC, a count
+	ResourceStack    RlimitResource = "stack"    // RLIMIT_STACK, in MB
+	ResourceMemlock  RlimitResource = "memlock"  // RLIMIT_MEMLOCK, in KB
+	ResourceRtprio   RlimitResource = "rtprio"   // RLIMIT_RTPRIO, a priority
+	ResourceMsgqueue RlimitResource = "msgqueue" // RLIMIT_MSGQUEUE, in bytes
+)
+
+const (
+	kib = 1 << 10
+	mib = 1 << 20
+)
+
+// rlimit returns the field holding the value of res, or nil for an unknown resource.
+func (n *NsJail) rlimit(res RlimitResource) *string {
+	switch res {
+	case ResourceAs:
+		return &n.rlimitAs
+	case ResourceCore:
+		return &n.rlimitCore
+	case ResourceCpu:
+		return &n.rlimitCpu
+	case ResourceFsize:
+		return &n.rlimitFsize
+	case ResourceNofile:
+		return &n.rlimitNofile
+	case ResourceNproc:
+		return &n.rlimitNproc
+	case ResourceStack:
+		return &n.rlimitStack
+	case ResourceMemlock:
+		return &n.rlimitMemlock
+	case ResourceRtprio:
+		return &n.rlimitRtprio
+	case ResourceMsgqueue:
+		return &n.rlimitMsgqueue
+	}
+	return nil
+}
+
+// WithRlimitVal sets a resource limit to one of the special RlimitVal values, e.g. WithRlimitVal(ResourceNofile, RlimitMax).
+func (n *NsJail) WithRlimitVal(res RlimitResource, val RlimitVal) *NsJail {
+	p := n.rlimit(res)
+	if p == nil {
+		n.fail("WithRlimitVal", "unknown resource %q", res)
+		return n.changed()
+	}
+	return n.setRlimit("WithRlimitVal", p, string(val))
+}
+
+// setRlimit sets the limit at p to val, a number or a RlimitVal, recording an error in method otherwise.
+func (n *NsJail) setRlimit(method string, p *string, val string) *NsJail {
+	switch RlimitVal(val) {
+	case RlimitMax, RlimitHard, RlimitDef, RlimitSoft, RlimitInf:
+	default:
+		if _, err := strconv.ParseUint(val, 10, 64); err != nil {
+			n.fail(method, "invalid value %q: want a number or a RlimitVal", val)
+			return n.changed()
+		}
+	}
+	*p = val
+	return n.changed()
+}
+
+// ceilDiv divides v by unit, rounding up so that a small non-zero limit never becomes 0.
+func ceilDiv(v, unit uint64) string {
+	return strconv.FormatUint(v/unit+min(v%unit, 1), 10)
+}
+
+// WithRlimitAsBytes sets RLIMIT_AS in bytes (--rlimit_as), rounded up to whole MB.
+func (n *NsJail) WithRlimitAsBytes(bytes uint64) *NsJail {
+	n.rlimitAs = ceilDiv(bytes, mib)
+	return n.changed()
+}
+
+// WithRlimitCoreBytes sets RLIMIT_CORE in bytes (--rlimit_core), rounded up to whole MB.
+func (n *NsJail) WithRlimitCoreBytes(bytes uint64) *NsJail {
+	n.rlimitCore = ceilDiv(bytes, mib)
+	return n.changed()
+}
+
+// WithRlimitCpuDuration sets RLIMIT_CPU (--rlimit_cpu), rounded up to whole seconds. d must not be negative.
+func (n *NsJail) WithRlimitCpuDuration(d time.Duration) *NsJail {
+	if d < 0 {
+		n.fail("WithRlimitCpuDuration", "negative duration %v", d)
+		return n.changed()
+	}
+	n.rlimitCpu = ceilDiv(uint64(d), uint64(time.Second))
+	return n.changed()
+}
+
+// WithRlimitFsizeBytes sets RLIMIT_FSIZE in bytes (--rlimit_fsize), rounded up to whole MB.
+func (n *NsJail) WithRlimitFsizeBytes(bytes uint64) *NsJail {
+	n.rlimitFsize = ceilDiv(bytes, mib)
+	return n.changed()
+}
+
+// WithRlimitNofileCount sets the maximum number of open files (--rlimit_nofile).
+func (n *NsJail) WithRlimitNofileCount(count uint64) *NsJail {
+	n.rlimitNofile = strconv.FormatUint(count, 10)
+	return n.changed()
+}
+
+// WithRlimitNprocCount sets the maximum number of processes (--rlimit_nproc).
+func (n *NsJail) WithRlimitNprocCount(count uint64) *NsJail {
+	n.rlimitNproc = strconv.FormatUint(count, 10)
+	return n.changed()
+}
+
+// WithRlimitStackBytes sets RLIMIT_STACK in bytes (--rlimit_stack), rounded up to whole MB.
+func (n *NsJail) WithRlimitStackBytes(bytes uint64) *NsJail {
+	n.rlimitStack = ceilDiv(bytes, mib)
+	return n.changed()
+}
+
+// WithRlimitMemlockBytes sets RLIMIT_MEMLOCK in bytes (--rlimit_memlock), rounded up to whole KB.
+func (n *NsJail) WithRlimitMemlockBytes(bytes uint64) *NsJail {
+	n.rlimitMemlock = ceilDiv(bytes, kib)
+	return n.changed()
+}
+
+// WithRlimitRtprioLevel sets the maximum real-time priority (--rlimit_rtprio).
+func (n *NsJail) WithRlimitRtprioLevel(prio uint64) *NsJail {
+	n.rlimitRtprio = strconv.FormatUint(prio, 10)
+	return n.changed()
+}
+
+// WithRlimitMsgqueueBytes sets RLIMIT_MSGQUEUE in bytes (--rlimit_msgqueue).
+func (n *NsJail) WithRlimitMsgqueueBytes(bytes uint64) *NsJail {
+	n.rlimitMsgqueue = strconv.FormatUint(bytes, 10)
+	return n.changed()
+}
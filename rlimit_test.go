@@ -0,0 +1,84 @@
+package nsjail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCeilDiv(t *testing.T) {
+	tests := []struct {
+		v, unit uint64
+		want    string
+	}{
+		{0, mib, "0"},
+		{1, mib, "1"},
+		{mib - 1, mib, "1"},
+		{mib, mib, "1"},
+		{mib + 1, mib, "2"},
+		{512 * mib, mib, "512"},
+		{1<<64 - 1, mib, "17592186044416"},
+		{1, 1, "1"},
+	}
+	for _, tt := range tests {
+		if got := ceilDiv(tt.v, tt.unit); got != tt.want {
+			t.Errorf("ceilDiv(%d, %d) = %s, want %s", tt.v, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestTypedRlimits(t *testing.T) {
+	tests := []struct {
+		name string
+		jail *NsJail
+		res  RlimitResource
+		want string
+	}{
+		{"as", New("/bin/true").WithRlimitAsBytes(mib + 1), ResourceAs, "2"},
+		{"core zero", New("/bin/true").WithRlimitCoreBytes(0), ResourceCore, "0"},
+		{"cpu", New("/bin/true").WithRlimitCpuDuration(1500 * time.Millisecond), ResourceCpu, "2"},
+		{"cpu below a second", New("/bin/true").WithRlimitCpuDuration(time.Nanosecond), ResourceCpu, "1"},
+		{"cpu whole", New("/bin/true").WithRlimitCpuDuration(3 * time.Second), ResourceCpu, "3"},
+		{"fsize", New("/bin/true").WithRlimitFsizeBytes(10 * mib), ResourceFsize, "10"},
+		{"stack", New("/bin/true").WithRlimitStackBytes(1), ResourceStack, "1"},
+		{"memlock", New("/bin/true").WithRlimitMemlockBytes(kib + 1), ResourceMemlock, "2"},
+		{"nofile", New("/bin/true").WithRlimitNofileCount(64), ResourceNofile, "64"},
+		{"nproc", New("/bin/true").WithRlimitNprocCount(16), ResourceNproc, "16"},
+		{"rtprio", New("/bin/true").WithRlimitRtprioLevel(5), ResourceRtprio, "5"},
+		{"msgqueue", New("/bin/true").WithRlimitMsgqueueBytes(1000), ResourceMsgqueue, "1000"},
+		{"special value", New("/bin/true").WithRlimitVal(ResourceNofile, RlimitMax), ResourceNofile, "max"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.jail.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if got := *tt.jail.rlimit(tt.res); got != tt.want {
+				t.Errorf("rlimit %s = %q, want %q", tt.res, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRlimitErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		jail *NsJail
+		want string
+	}{
+		{"negative cpu", New("/bin/true").WithRlimitCpuDuration(-time.Second), "WithRlimitCpuDuration"},
+		{"unknown resource", New("/bin/true").WithRlimitVal("bogus", RlimitMax), "unknown resource"},
+		{"invalid value", New("/bin/true").WithRlimitVal(ResourceAs, "lots"), "invalid value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.jail.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+	if got := New("/bin/true").WithRlimitCpuDuration(-time.Second).rlimitCpu; got != New("/bin/true").rlimitCpu {
+		t.Errorf("a negative duration set RLIMIT_CPU to %q", got)
+	}
+}
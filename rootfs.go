@@ -0,0 +1,206 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// RootfsSpec describes a minimal root filesystem for a jail. Paths are absolute paths inside the jail.
+type RootfsSpec struct {
+	// Dir is where the tree is created. Defaults to a new temporary directory, which Close removes.
+	Dir string
+	// Dirs are created empty, e.g. "/tmp" or "/home/user".
+	Dirs []string
+	// Files are host files or directories made available at the same path, e.g. binaries and libraries.
+	Files []string
+	// Devices are host device nodes bind-mounted read-write, e.g. "/dev/null" or "/dev/urandom".
+	Devices []string
+	// Contents maps paths to file contents written into the tree, e.g. "/etc/passwd".
+	Contents map[string][]byte
+	// Symlinks maps link paths to their targets, e.g. "/bin/sh" to "busybox".
+	Symlinks map[string]string
+	// Copy copies Files into the tree instead of bind-mounting them read-only.
+	Copy bool
+}
+
+// Rootfs is a root filesystem materialized from a RootfsSpec. Use it with WithRootfs.
+type Rootfs struct {
+	dir     string
+	temp    bool
+	bindsRO []string
+	bindsRW []string
+}
+
+// NewRootfs creates the directory tree described by spec. Mount points are created for bind-mounted files
+// and devices, which are mounted by nsjail when the jail starts.
+func NewRootfs(spec RootfsSpec) (_ *Rootfs, err error) {
+	r := &Rootfs{dir: spec.Dir}
+	if r.dir == "" {
+		if r.dir, err = os.MkdirTemp("", "nsjail-rootfs-*"); err != nil {
+			return nil, err
+		}
+		r.temp = true
+		// The tree must be traversable by the jail's user.
+		os.Chmod(r.dir, 0o755)
+	} else if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			r.Close()
+		}
+	}()
+
+	for _, d := range spec.Dirs {
+		if err := os.MkdirAll(r.path(d), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range spec.Files {
+		if spec.Copy {
+			if err := copyTree(f, r.path(f)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := r.mountPoint(f); err != nil {
+			return nil, err
+		}
+		r.bindsRO = append(r.bindsRO, path.Clean(f))
+	}
+	for _, d := range spec.Devices {
+		if err := r.mountPoint(d); err != nil {
+			return nil, err
+		}
+		r.bindsRW = append(r.bindsRW, path.Clean(d))
+	}
+	for _, p := range sortedKeys(spec.Contents) {
+		if err := os.MkdirAll(filepath.Dir(r.path(p)), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(r.path(p), spec.Contents[p], 0o644); err != nil {
+			return nil, err
+		}
+	}
+	for _, link := range sortedKeys(spec.Symlinks) {
+		if err := os.MkdirAll(filepath.Dir(r.path(link)), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.Symlink(spec.Symlinks[link], r.path(link)); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Dir returns the root directory on the host.
+func (r *Rootfs) Dir() string { return r.dir }
+
+// Close removes the tree if NewRootfs created it in a temporary directory. Jails using it must have exited.
+func (r *Rootfs) Close() error {
+	if !r.temp {
+		return nil
+	}
+	return os.RemoveAll(r.dir)
+}
+
+// WithRootfs uses r as the root of the jail (-c) and bind-mounts its files and devices (-R, -B).
+func (n *NsJail) WithRootfs(r *Rootfs) *NsJail {
+	n.WithChroot(r.dir)
+	for _, p := range r.bindsRO {
+		n.AddBindMountRO(p)
+	}
+	for _, p := range r.bindsRW {
+		n.AddBindMountRW(p)
+	}
+	return n.changed()
+}
+
+// path returns the host path of p inside the tree.
+func (r *Rootfs) path(p string) string {
+	return filepath.Join(r.dir, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+// mountPoint creates an empty file or directory in the tree to bind-mount the host path p on.
+func (r *Rootfs) mountPoint(p string) error {
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	dst := r.path(p)
+	if info.IsDir() {
+		return os.MkdirAll(dst, 0o755)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// copyTree copies the file or directory src to dst, keeping permissions and symlinks.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(p, target, info.Mode().Perm())
+		default:
+			return fmt.Errorf("nsjail: cannot copy special file %s, add it as a device", p)
+		}
+	})
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return errors.Join(err, out.Close())
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
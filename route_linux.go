@@ -0,0 +1,51 @@
+package nsjail
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultRoute returns the interface and gateway of the IPv4 default route with the lowest metric.
+func defaultRoute() (iface string, gw netip.Addr, err error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", netip.Addr{}, err
+	}
+	defer f.Close()
+
+	best := -1
+	sc := bufio.NewScanner(f)
+	sc.Scan() // header
+	for sc.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		metric, _ := strconv.Atoi(fields[6])
+		if best >= 0 && metric >= best {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		// The kernel prints the address in host byte order, which is little-endian on supported platforms.
+		var a [4]byte
+		binary.BigEndian.PutUint32(a[:], binary.LittleEndian.Uint32(raw))
+		iface, gw, best = fields[0], netip.AddrFrom4(a), metric
+	}
+	if err := sc.Err(); err != nil {
+		return "", netip.Addr{}, err
+	}
+	if best < 0 {
+		return "", netip.Addr{}, errors.New("nsjail: no IPv4 default route")
+	}
+	return iface, gw, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package nsjail
+
+import (
+	"errors"
+	"net/netip"
+)
+
+func defaultRoute() (string, netip.Addr, error) {
+	return "", netip.Addr{}, errors.New("nsjail: route detection requires linux")
+}
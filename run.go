@@ -0,0 +1,784 @@
+package nsjail
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/OptimusePrime/nsjail-go/initshim"
+)
+
+// Result describes a finished jail.
+type Result struct {
+	// ID identifies the run, see NsJail.WithRandomIdentity. It is empty without it.
+	ID string
+	// ExitCode is the exit code reported by nsjail, or -1 if it was killed by a signal.
+	ExitCode int
+	// Signal is the signal that killed nsjail, or 0.
+	Signal syscall.Signal
+	// State is the raw process state of the nsjail process. It is nil with executors that run no real process.
+	State *os.ProcessState
+	// Duration is the wall time between starting and reaping the nsjail process.
+	Duration time.Duration
+	// Timing splits the run into setup overhead and program runtime.
+	Timing Timing
+	// Clock records the host clocks at start and end and the state of the host clock.
+	Clock ClockProvenance
+	// Status classifies how the jail ended and NormalizedCode is its exit code in the stable scheme
+	// documented at ExitTimeLimit. Prefer them over ExitCode and Signal when storing results.
+	Status         Status
+	NormalizedCode int
+	// Aborted holds the reason the wrapper killed the jail, or nil if it ran to completion.
+	Aborted error
+	// Violations lists policy violations that were recorded without killing the jail.
+	Violations []error
+	// IsolationWarnings lists protections nsjail logged as skipped or failed. It is only collected with
+	// CollectIsolationWarnings.
+	IsolationWarnings []IsolationWarning
+
+	// Stdout and Stderr hold the output captured by RunCaptured.
+	Stdout []byte
+	Stderr []byte
+	// StdoutTruncated and StderrTruncated report whether RunCaptured discarded output beyond its caps.
+	StdoutTruncated bool
+	StderrTruncated bool
+	// StdoutDropped and StderrDropped count the bytes discarded for slow consumers, see WithStreamBuffering.
+	StdoutDropped int64
+	StderrDropped int64
+
+	// DrainTimedOut reports that the output streams were still open when the drain timeout after nsjail's
+	// exit expired, see WithDrainTimeout. Output written after that point is lost.
+	DrainTimedOut bool
+
+	// DryRun reports that the command was only logged, see NsJail.DryRun.
+	DryRun bool
+
+	// Connections counts connections accepted in ModeListenTCP. It is only tracked with ExitAfterConnections.
+	Connections int
+
+	// SeccompViolations lists the syscalls the seccomp policy logged or denied, see
+	// CollectSeccompViolations.
+	SeccompViolations []SeccompViolation
+	// DNSQueries lists the name resolutions seen by the forwarder enabled with WithDNSInterceptor.
+	DNSQueries []DNSQuery
+	// HTTPExchanges lists the requests recorded by the proxy enabled with WithHTTPCapture.
+	HTTPExchanges []HTTPExchange
+
+	// EgressBytes is the number of bytes the jail sent, as last polled. It is only tracked with WithEgressByteLimit.
+	EgressBytes uint64
+
+	// Usage is the last resource usage sampled from the jail's cgroup, if it had one. See Jail.Stats.
+	Usage *ResourceUsage
+
+	// Shim is the report of the init shim enabled with WithInitShim, if it delivered one.
+	Shim *initshim.Report
+
+	// Artifacts holds the files gathered by CollectFiles.
+	Artifacts *ArtifactSet
+}
+
+// launch is the argv and inherited files of an nsjail process about to be started.
+// Runtime features adjust it before the process is created.
+type launch struct {
+	flags      []string // nsjail options
+	command    []string // the jailed command line, passed after "--"
+	argv       []string // without a command, the argv replacing that of exec_bin in the config file
+	execBin    bool     // the command replaces exec_bin in the config file (-x)
+	wrapper    []string // a host command line nsjail is run under, e.g. taskset for WithCpuSet
+	extraFiles []*os.File
+	parentEnds []*os.File // closed in the parent once nsjail started
+}
+
+// resolve returns the configuration with the settings resolved that Exec, Args and Start expand into
+// plain options, once it has been validated.
+func (n *NsJail) resolve() (*NsJail, error) {
+	if len(n.errs) > 0 {
+		return nil, errors.Join(n.errs...)
+	}
+	if n.macvlanAuto {
+		resolved, err := n.resolveMacvlan()
+		if err != nil {
+			return nil, err
+		}
+		n = resolved
+	}
+	if n.workspace != nil {
+		n = n.placeholderWorkspace()
+	}
+	if len(n.envFiles) > 0 {
+		resolved, err := n.resolveEnvFiles()
+		if err != nil {
+			return nil, err
+		}
+		n = resolved
+	}
+	if len(n.envPatterns) > 0 {
+		resolved, err := n.resolveEnvPatterns()
+		if err != nil {
+			return nil, err
+		}
+		n = resolved
+	}
+	if n.cgroupAuto {
+		n = n.resolveCgroupAuto()
+	}
+	if n.cgroupV2 != nil {
+		resolved, err := n.resolveCgroupV2()
+		if err != nil {
+			return nil, err
+		}
+		n = resolved
+	}
+	if len(n.binaryDeps) > 0 {
+		resolved, err := n.resolveBinaryDeps()
+		if err != nil {
+			return nil, err
+		}
+		n = resolved
+	}
+	if err := n.validateCommand(); err != nil {
+		return nil, err
+	}
+	if err := n.validateCaps(); err != nil {
+		return nil, err
+	}
+	if err := n.validateDeadline(); err != nil {
+		return nil, err
+	}
+	if err := n.validateNet(); err != nil {
+		return nil, err
+	}
+	if err := n.validateIDMaps(); err != nil {
+		return nil, err
+	}
+	if err := n.validateInitShim(); err != nil {
+		return nil, err
+	}
+	if err := n.validateFileAccess(); err != nil {
+		return nil, err
+	}
+	if err := n.validateMounts(); err != nil {
+		return nil, err
+	}
+	if err := n.validateOverlay(); err != nil {
+		return nil, err
+	}
+	if err := n.validateNetworkPolicy(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *NsJail) newLaunch() (*launch, error) {
+	cache := n.argv
+	if n.hostDependent() {
+		cache = nil
+	}
+	n, err := n.resolve()
+	if err != nil {
+		return nil, err
+	}
+	// The resolved copy only differs from n in what its configuration determines, so its flags are those
+	// of n.
+	flags, err := cache.get(func() ([]string, error) {
+		buf := optionBufs.Get().(*[]option)
+		defer optionBufs.Put(buf)
+		opts := n.appendOptions((*buf)[:0])
+		*buf = opts[:0]
+		adapted, err := n.applyCompat(opts)
+		if err != nil {
+			return nil, err
+		}
+		return flatten(adapted), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	l := &launch{flags: flags, command: n.command()}
+	for _, f := range n.extraFiles {
+		l.passFile(f)
+	}
+	for _, f := range n.closeAfterStart {
+		l.closeAfterStart(f)
+	}
+	switch {
+	case len(n.cpuSetMems) > 0:
+		l.wrapper = []string{"numactl", "--membind=" + cpuList(n.cpuSetMems)}
+		if len(n.cpuSet) > 0 {
+			l.wrapper = append(l.wrapper, "--physcpubind="+cpuList(n.cpuSet))
+		}
+	case len(n.cpuSet) > 0:
+		l.wrapper = []string{"taskset", "--cpu-list", cpuList(n.cpuSet)}
+	}
+	if n.configFile != "" {
+		// nsjail keeps the binary of exec_bin when given a command line, only replacing its argv.
+		l.execBin = n.execFile == "" && !n.executeFd
+		if n.execCmd == "" {
+			l.argv = n.args
+		}
+	}
+	return l, nil
+}
+
+// inherit makes f available to the nsjail process and returns its descriptor number there.
+func (l *launch) inherit(f *os.File) int {
+	l.extraFiles = append(l.extraFiles, f)
+	return 2 + len(l.extraFiles)
+}
+
+// passFile makes f available to nsjail and keeps it open in the jailed process (--pass_fd).
+// It returns the descriptor number of f on both sides.
+func (l *launch) passFile(f *os.File) int {
+	fd := l.inherit(f)
+	l.flags = append(l.flags, "--pass_fd", strconv.Itoa(fd))
+	return fd
+}
+
+// closeAfterStart closes f in the parent once nsjail started or failed to start.
+func (l *launch) closeAfterStart(f *os.File) { l.parentEnds = append(l.parentEnds, f) }
+
+func (l *launch) closeParentEnds() {
+	for _, f := range l.parentEnds {
+		f.Close()
+	}
+}
+
+// args returns the arguments of nsjail: the options, then the command after "--".
+func (l *launch) args() []string {
+	args := make([]string, 0, len(l.flags)+3+len(l.command)+len(l.argv))
+	args = append(args, l.flags...)
+	switch {
+	case len(l.command) > 0:
+		if l.execBin {
+			args = append(args, "-x", l.command[0])
+		}
+		args = append(append(args, "--"), l.command...)
+	case len(l.argv) > 0:
+		args = append(append(args, "--"), l.argv...)
+	}
+	return args
+}
+
+func (l *launch) build(path string) *Command {
+	if len(l.wrapper) > 0 {
+		args := slices.Concat(l.wrapper[1:], []string{path}, l.args())
+		return &Command{Path: l.wrapper[0], Args: args, ExtraFiles: l.extraFiles}
+	}
+	return &Command{Path: path, Args: l.args(), ExtraFiles: l.extraFiles}
+}
+
+// Jail is a handle to a running NSJail process, as returned by Start.
+type Jail struct {
+	id          string
+	command     *Command
+	proc        Process
+	startCalled time.Time
+	spawned     time.Time
+	started     time.Time
+	cleanup     time.Duration
+	workspace   string
+	artifacts   *ArtifactSet
+	clock       ClockProvenance
+	log         *slog.Logger
+
+	mu         sync.Mutex
+	aborted    error
+	violations []error
+	warnings   []IsolationWarning
+	closers    []func()
+	done       chan struct{}
+	result     *Result
+	shimReport *initshim.Report
+	waitErr    error
+	stopOnce   sync.Once
+	killSignal syscall.Signal
+	killGrace  time.Duration
+	timeLimit  time.Duration
+
+	logHandlers       []func(line string)
+	logPipe           *os.File      // the read end of the nsjail log, see tapLog
+	logDone           chan struct{} // closed once the nsjail log was read to its end
+	daemon            *daemonWatch
+	pidFile           string // the pid file of nsjail, see WithPidFile
+	seccomp           *seccompCollector
+	startHooks        []func() error
+	held              []func() error // run before the shim may run the command, see holdCommand
+	goAhead           *os.File       // written to once they succeeded
+	dnsQueries        []DNSQuery
+	seccompViolations []SeccompViolation
+	httpExchanges     []HTTPExchange
+	hostPorts         map[uint16]uint16
+	usage             *ResourceUsage
+	connections       atomic.Int64
+	egress            atomic.Uint64
+	agentReq          *os.File
+	agentResp         *os.File
+	stdoutStream      *StreamWriter
+	stderrStream      *StreamWriter
+}
+
+// defaultDrainTimeout is the drain timeout used unless WithDrainTimeout sets another.
+const defaultDrainTimeout = 2 * time.Second
+
+// WithDrainTimeout bounds how long output is still collected after nsjail exited, e.g. when it was killed on
+// a deadline while a process it started still holds stdout open. Output already written is drained within
+// that time instead of racing the pipes being closed. Defaults to 2 seconds; a negative d waits until the
+// streams are closed.
+func (n *NsJail) WithDrainTimeout(d time.Duration) *NsJail { n.drainTimeout = d; return n.changed() }
+
+// WithStdio sets the standard streams of the nsjail process for Start and Run.
+// Nil values are connected to the null device. A non-nil stdin replaces the input set with WithStdinBytes
+// or WithStdinReader.
+func (n *NsJail) WithStdio(stdin io.Reader, stdout, stderr io.Writer) *NsJail {
+	n.stdin, n.stdout, n.stderr = stdin, stdout, stderr
+	if stdin != nil {
+		n.stdinFeed = nil
+	}
+	return n.changed()
+}
+
+// Start builds the command and starts it, along with any watchers configured on the jail.
+// The jail is killed when ctx is done.
+func (n *NsJail) Start(ctx context.Context) (*Jail, error) {
+	return n.start(ctx, n.stdout, n.stderr)
+}
+
+func (n *NsJail) start(ctx context.Context, stdout, stderr io.Writer) (*Jail, error) {
+	// Fail before anything is created for the jail where possible.
+	if len(n.errs) > 0 {
+		return nil, errors.Join(n.errs...)
+	}
+	if err := n.validateInitShim(); err != nil {
+		return nil, err
+	}
+	log := n.log()
+	j := &Jail{startCalled: time.Now(), clock: startProvenance(), done: make(chan struct{}), log: log}
+	if n.randomIdentity {
+		n = n.withRunID()
+		j.id = n.runID
+		log = log.With("jail_id", j.id)
+		j.log = log
+	}
+	if n.overlay != nil && n.overlay.ephemeral {
+		resolved, remove, err := n.createEphemeralOverlay()
+		if err != nil {
+			return nil, err
+		}
+		j.onClose(func() {
+			log.Debug("nsjail: removing the ephemeral overlay")
+			remove()
+		})
+		n = resolved
+	}
+	if n.workspace != nil {
+		resolved, err := j.useWorkspace(n)
+		if err != nil {
+			j.close()
+			return nil, err
+		}
+		n = resolved
+	}
+	if len(n.artifactPatterns) > 0 {
+		if err := j.useArtifacts(n); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if n.dryRun == nil && (len(n.cpuSet) > 0 || len(n.cpuSetMems) > 0) {
+		n = n.resolveCpuSet()
+	}
+	if n.dryRun == nil && (n.cgroupAutoParent && n.hasCgroupLimits() || n.cgroupV2 != nil && n.cgroupV2.needsCgroup()) {
+		resolved, remove, err := n.createCgroups()
+		if err != nil {
+			j.close()
+			return nil, err
+		}
+		j.onClose(func() {
+			log.Debug("nsjail: removing the cgroups created for the jail")
+			remove()
+		})
+		n = resolved
+	}
+	// The policy is installed below rather than by nsjail, see validateNetworkPolicy.
+	policy := n.netPolicy
+	if policy != nil {
+		n = n.Clone()
+		n.netPolicy = nil
+	}
+	l, err := n.newLaunch()
+	if err != nil {
+		j.close()
+		return nil, err
+	}
+	defer l.closeParentEnds()
+
+	if n.streamBuffering != nil {
+		stdout, stderr = j.bufferStreams(*n.streamBuffering, stdout, stderr)
+	}
+	var dog *watchdog
+	if n.watchdog != nil {
+		dog, stdout, stderr, err = j.useWatchdog(n, stdout, stderr)
+		if err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if n.sessionAgent {
+		if err := j.useAgent(n, l); err != nil {
+			j.close()
+			return nil, err
+		}
+	} else if n.initShim != "" {
+		if err := j.useInitShim(l, n.initShim); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+
+	if n.connFile != nil {
+		l.passConn(n.connFile)
+	}
+	if len(n.secrets) > 0 {
+		if err := j.passSecrets(l, n.secrets); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if n.exitAfterConns > 0 {
+		j.countConnections(n.exitAfterConns, cmp.Or(n.connDeadline, n.timeLimitDuration()))
+	}
+	if n.isolationWarnings {
+		j.collectIsolationWarnings()
+	}
+	if len(n.logEvents) > 0 {
+		j.deliverLogEvents(n.logEvents)
+	}
+	if n.daemon {
+		j.watchDaemon(n)
+	}
+	if n.pidFile != "" && n.dryRun == nil {
+		if err := j.usePidFile(n); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if n.seccompAudit != "" && n.dryRun == nil {
+		if err := j.collectSeccompViolations(n.seccompAudit); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if len(j.logHandlers) > 0 {
+		if err := j.tapLog(n, l, stderr); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+
+	if n.wireGuard != nil && n.dryRun == nil {
+		if err := j.useWireGuard(n, l); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if n.veth != nil && n.dryRun == nil {
+		if err := j.useVeth(n, l); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if n.slirp != nil && n.dryRun == nil {
+		if err := j.useSlirp(n); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if len(n.portForwards) > 0 && n.dryRun == nil {
+		if err := j.useForwards(n.portForwards); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if policy != nil && n.dryRun == nil {
+		if err := j.useNetworkPolicy(n, l, policy); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if n.resolvConf != nil && n.dryRun == nil {
+		if err := j.useResolvConf(n, l); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if len(n.hostsEntries) > 0 && n.dryRun == nil {
+		if err := j.useHostsEntries(n, l); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if n.dns != nil && n.dryRun == nil {
+		if err := j.useDNS(n, l); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	if n.httpCapture != nil && n.dryRun == nil {
+		if err := j.useHTTPCapture(n, l); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+
+	stdin := n.stdin
+	if n.stdinFeed != nil && n.dryRun == nil {
+		r, err := j.feedStdin(l, n.stdinFeed, n.stdinDeadlineDuration())
+		if err != nil {
+			j.close()
+			return nil, err
+		}
+		stdin = r
+	}
+	c := l.build(n.path)
+	c.Stdin, c.Stdout, c.Stderr = stdin, stdout, stderr
+	switch {
+	case n.drainTimeout > 0:
+		c.DrainTimeout = n.drainTimeout
+	case n.drainTimeout == 0:
+		c.DrainTimeout = defaultDrainTimeout
+	}
+	j.command = c
+	log.Debug("nsjail: command built", "path", c.Path, "args", c.Args)
+	j.killSignal, j.killGrace, j.timeLimit = n.killSignal, n.killGrace, n.timeLimitDuration()
+	if n.dryRun != nil {
+		l.closeParentEnds()
+		j.finishDryRun(n.dryRun)
+		return j, nil
+	}
+
+	watchers := make([]*Watcher, 0, len(n.watches))
+	for _, w := range n.watches {
+		watcher, err := NewWatcher(w.dir)
+		if err != nil {
+			j.close()
+			return nil, err
+		}
+		j.onClose(func() { watcher.Close() })
+		watchers = append(watchers, watcher)
+	}
+
+	if err := n.verifyBinary(); err != nil {
+		j.close()
+		return nil, err
+	}
+	executor := n.executor
+	if executor == nil {
+		executor = OSExecutor
+	}
+	j.spawned = time.Now()
+	j.proc, err = executor.Start(c)
+	l.closeParentEnds()
+	if err != nil {
+		log.Error("nsjail: start failed", "path", c.Path, "err", err)
+		j.close()
+		return nil, err
+	}
+	j.started = time.Now()
+	log.Info("nsjail: started", "pid", j.proc.Pid(), "command", n.command())
+	if j.pidFile != "" {
+		j.writeNsjailPidFile()
+	}
+	if j.seccomp != nil {
+		j.seccomp.start(j.proc.Pid())
+	}
+
+	for i, w := range n.watches {
+		go j.forwardEvents(watchers[i], w.fn)
+	}
+	j.startFileLimits(n.fileLimits)
+	if n.hasCgroupLimits() {
+		go j.sampleUsageLoop()
+	}
+	if n.egressLimit > 0 {
+		go j.enforceEgressLimit(n.egressLimit)
+	}
+	if n.connDeadline > 0 {
+		go j.enforceConnDeadline(n.connDeadline)
+	}
+	if dog != nil {
+		go dog.run()
+	}
+	for _, fn := range j.startHooks {
+		go func() {
+			if err := fn(); err != nil {
+				j.Abort(err)
+			}
+		}()
+	}
+	if j.goAhead != nil {
+		go j.releaseCommand()
+	}
+	go j.wait()
+	go func() {
+		select {
+		case <-ctx.Done():
+			j.Abort(ctx.Err())
+		case <-j.done:
+		}
+	}()
+	return j, nil
+}
+
+// Run starts the jail and waits for it to finish.
+func (n *NsJail) Run(ctx context.Context) (*Result, error) {
+	j, err := n.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return j.Wait()
+}
+
+// Pid returns the pid of the nsjail process, or 0 for a dry run.
+func (j *Jail) Pid() int {
+	if j.proc == nil {
+		return 0
+	}
+	return j.proc.Pid()
+}
+
+// InnerPid returns the host pid of the jailed process nsjail cloned, as opposed to Pid, e.g. to attach a
+// profiler or inspect its cgroup. It waits up to 5 seconds for nsjail to clone it. In ModeListenTCP and
+// ModeRerun the pid changes with every connection or run; InnerPid returns one of the current ones.
+func (j *Jail) InnerPid() (int, error) {
+	if !j.running() {
+		return 0, errNotRunning
+	}
+	return j.jailPid(5 * time.Second)
+}
+
+// jailPid waits up to timeout for nsjail to clone the jailed process and returns its pid.
+func (j *Jail) jailPid(timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		pids, err := childPids(j.Pid())
+		if err != nil {
+			return 0, err
+		}
+		if len(pids) > 0 {
+			return pids[0], nil
+		}
+		if time.Now().After(deadline) {
+			return 0, errors.New("nsjail: jailed process did not start")
+		}
+		select {
+		case <-j.done:
+			return 0, errors.New("nsjail: jail exited")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Done returns a channel that is closed once the jail has exited and its resources were released.
+func (j *Jail) Done() <-chan struct{} { return j.done }
+
+// Wait blocks until the jail exits and returns its result. A non-zero exit code is not an error.
+func (j *Jail) Wait() (*Result, error) {
+	<-j.done
+	return j.result, j.waitErr
+}
+
+// Abort kills the jail, recording reason as Result.Aborted. Only the first reason is kept.
+func (j *Jail) Abort(reason error) {
+	j.mu.Lock()
+	first := j.aborted == nil
+	if first {
+		j.aborted = reason
+	}
+	j.mu.Unlock()
+	if first {
+		j.log.Debug("nsjail: aborting", "pid", j.Pid(), "reason", reason)
+	}
+	if j.proc != nil {
+		j.stopOnce.Do(j.kill)
+	}
+}
+
+// flag records a policy violation without stopping the jail.
+func (j *Jail) flag(err error) {
+	j.mu.Lock()
+	j.violations = append(j.violations, err)
+	j.mu.Unlock()
+}
+
+func (j *Jail) wait() {
+	exit, err := j.proc.Wait()
+	exited := time.Now()
+	j.clock.End = readClocks()
+	j.waitErr = err
+	j.close()
+	j.cleanup = time.Since(exited)
+
+	j.mu.Lock()
+	j.result = &Result{
+		ID:                j.id,
+		ExitCode:          exit.Code,
+		Signal:            exit.Signal,
+		DrainTimedOut:     exit.DrainTimedOut,
+		State:             exit.State,
+		Duration:          exited.Sub(j.started),
+		Timing:            j.timing(exited),
+		Clock:             j.clock,
+		Aborted:           j.aborted,
+		Violations:        j.violations,
+		Connections:       int(j.connections.Load()),
+		DNSQueries:        j.dnsQueries,
+		SeccompViolations: j.seccompViolations,
+		HTTPExchanges:     j.httpExchanges,
+		Usage:             j.usage,
+		EgressBytes:       j.egress.Load(),
+		Shim:              j.shimReport,
+		Artifacts:         j.artifacts,
+	}
+	j.result.IsolationWarnings = j.warnings
+	if j.stdoutStream != nil {
+		j.result.StdoutDropped = j.stdoutStream.Dropped()
+	}
+	if j.stderrStream != nil {
+		j.result.StderrDropped = j.stderrStream.Dropped()
+	}
+	j.normalize(j.result)
+	j.mu.Unlock()
+	j.log.Info("nsjail: exited", "pid", j.Pid(), "status", j.result.Status, "code", j.result.NormalizedCode,
+		"duration", j.result.Duration, "aborted", j.result.Aborted)
+	close(j.done)
+}
+
+// onStarted runs fn in its own goroutine once nsjail started. An error aborts the jail.
+// Hooks must be registered before the process starts.
+func (j *Jail) onStarted(fn func() error) { j.startHooks = append(j.startHooks, fn) }
+
+func (j *Jail) onClose(fn func()) {
+	j.mu.Lock()
+	j.closers = append(j.closers, fn)
+	j.mu.Unlock()
+}
+
+// close releases resources registered with onClose, in reverse order.
+func (j *Jail) close() {
+	j.mu.Lock()
+	closers := j.closers
+	j.closers = nil
+	j.mu.Unlock()
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i]()
+	}
+}
@@ -0,0 +1,211 @@
+package nsjail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrRunnerClosed is returned by Runner.Submit after Close.
+var ErrRunnerClosed = errors.New("nsjail: runner closed")
+
+// Job is a jail submitted to a Runner.
+type Job struct {
+	// ID identifies the job in its JobResult.
+	ID string
+	// Jail is the configuration to run.
+	Jail *NsJail
+	// Timeout kills the jail once it ran that long. Zero means no timeout besides the time limit of the jail.
+	Timeout time.Duration
+	// Memory is the share of the memory budget of the runner the job reserves while it runs. It defaults to
+	// the cgroup memory limit of the jail.
+	Memory uint64
+	// MaxStdout and MaxStderr capture the output into the Result like RunCaptured. If both are 0 the
+	// streams set on the jail are used.
+	MaxStdout int64
+	MaxStderr int64
+}
+
+// JobResult is the outcome of a job run by a Runner.
+type JobResult struct {
+	Job    *Job
+	Result *Result
+	// Err is set if the jail could not be started or waited for, or the context of the job was done
+	// before it started.
+	Err error
+}
+
+// RunnerConfig configures a Runner.
+type RunnerConfig struct {
+	// MaxParallel is the number of jails running at once. Defaults to the number of CPUs.
+	MaxParallel int
+	// MemoryBudget is the memory the running jobs may reserve in total, see Job.Memory. Zero means no budget.
+	MemoryBudget uint64
+	// MaxQueued is the number of jobs waiting to run beyond which Submit blocks. Zero means no limit.
+	MaxQueued int
+}
+
+// Runner runs jobs with bounded parallelism and memory, in the order they were submitted, and delivers
+// their results on a channel. It is safe for concurrent use.
+type Runner struct {
+	cfg     RunnerConfig
+	results chan JobResult
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []queuedJob
+	running int
+	memory  uint64
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+type queuedJob struct {
+	ctx    context.Context
+	job    *Job
+	memory uint64
+}
+
+// RunnerStats is a snapshot of the load of a Runner.
+type RunnerStats struct {
+	Queued  int
+	Running int
+	// Memory is the memory reserved by the running jobs.
+	Memory uint64
+}
+
+// NewRunner returns a runner configured by cfg. Results must be read from Results until it is closed.
+func NewRunner(cfg RunnerConfig) *Runner {
+	if cfg.MaxParallel <= 0 {
+		cfg.MaxParallel = runtime.NumCPU()
+	}
+	r := &Runner{cfg: cfg, results: make(chan JobResult)}
+	r.cond = sync.NewCond(&r.mu)
+	go r.dispatch()
+	return r
+}
+
+// Submit queues job. It blocks while MaxQueued jobs are waiting, until ctx is done. The jail runs with ctx,
+// so canceling it kills the job or, while it is queued, makes it fail without running. A job reserving more
+// memory than the whole budget is rejected.
+func (r *Runner) Submit(ctx context.Context, job *Job) error {
+	mem := job.Memory
+	if mem == 0 {
+		mem = job.Jail.memoryLimit()
+	}
+	if r.cfg.MemoryBudget > 0 && mem > r.cfg.MemoryBudget {
+		return fmt.Errorf("nsjail: job %q needs %d bytes, more than the memory budget of %d", job.ID, mem,
+			r.cfg.MemoryBudget)
+	}
+	// Wake up the wait below when ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		r.mu.Lock()
+		r.cond.Broadcast()
+		r.mu.Unlock()
+	})
+	defer stop()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for !r.closed && r.cfg.MaxQueued > 0 && len(r.queue) >= r.cfg.MaxQueued && ctx.Err() == nil {
+		r.cond.Wait()
+	}
+	switch {
+	case r.closed:
+		return ErrRunnerClosed
+	case ctx.Err() != nil:
+		return ctx.Err()
+	}
+	r.queue = append(r.queue, queuedJob{ctx: ctx, job: job, memory: mem})
+	r.cond.Broadcast()
+	return nil
+}
+
+// Results returns the channel delivering the result of every submitted job. It is closed after Close once
+// all jobs finished.
+func (r *Runner) Results() <-chan JobResult { return r.results }
+
+// Close stops accepting jobs. Queued and running jobs still run; Results is closed once they finished.
+func (r *Runner) Close() {
+	r.mu.Lock()
+	r.closed = true
+	r.cond.Broadcast()
+	r.mu.Unlock()
+}
+
+// Stats returns the current load of the runner.
+func (r *Runner) Stats() RunnerStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return RunnerStats{Queued: len(r.queue), Running: r.running, Memory: r.memory}
+}
+
+// fits reports whether q can start now. r.mu must be held.
+func (r *Runner) fits(q queuedJob) bool {
+	if r.running >= r.cfg.MaxParallel {
+		return false
+	}
+	return r.cfg.MemoryBudget == 0 || r.memory+q.memory <= r.cfg.MemoryBudget
+}
+
+// dispatch starts queued jobs in order as capacity frees up. The head of the queue waits for enough memory
+// rather than being overtaken by smaller jobs, so large jobs are not starved.
+func (r *Runner) dispatch() {
+	r.mu.Lock()
+	for {
+		for len(r.queue) == 0 && !r.closed || len(r.queue) > 0 && !r.fits(r.queue[0]) {
+			r.cond.Wait()
+		}
+		if len(r.queue) == 0 {
+			r.mu.Unlock()
+			r.wg.Wait()
+			close(r.results)
+			return
+		}
+		q := r.queue[0]
+		r.queue = r.queue[1:]
+		r.running++
+		r.memory += q.memory
+		// Wake up Submit calls waiting for room in the queue.
+		r.cond.Broadcast()
+		r.wg.Add(1)
+		go r.run(q)
+	}
+}
+
+func (r *Runner) run(q queuedJob) {
+	defer r.wg.Done()
+	res := JobResult{Job: q.job}
+	if err := q.ctx.Err(); err != nil {
+		res.Err = err
+	} else {
+		ctx := q.ctx
+		if q.job.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, q.job.Timeout)
+			defer cancel()
+		}
+		if q.job.MaxStdout > 0 || q.job.MaxStderr > 0 {
+			res.Result, res.Err = q.job.Jail.RunCaptured(ctx, q.job.MaxStdout, q.job.MaxStderr)
+		} else {
+			res.Result, res.Err = q.job.Jail.Run(ctx)
+		}
+	}
+	r.mu.Lock()
+	r.running--
+	r.memory -= q.memory
+	r.cond.Broadcast()
+	r.mu.Unlock()
+	r.results <- res
+}
+
+// memoryLimit returns the cgroup memory limit of the jail, or 0 if it has none.
+func (n *NsJail) memoryLimit() uint64 {
+	if n.cgroupV2 != nil && n.cgroupV2.memoryMax > 0 {
+		return n.cgroupV2.memoryMax
+	}
+	return n.cgroupMemMax
+}
@@ -0,0 +1,41 @@
+package nsjail
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// ScriptDir is the directory in the jail where RunScriptFS mounts scripts.
+const ScriptDir = "/run/nsjail-script"
+
+// RunScriptFS runs the script name from fsys, e.g. an embed.FS, with interpreter in place of the configured
+// command, passing args after the script path. The script is written to a temporary directory on the host,
+// bind-mounted read-only at ScriptDir and removed after the run. The interpreter must be available in the
+// jail, e.g. with AddBinaryWithDeps.
+func (n *NsJail) RunScriptFS(ctx context.Context, fsys fs.FS, name, interpreter string, args ...string) (*Result, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "nsjail-script-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	// The jail's user must be able to read the script.
+	if err := os.Chmod(dir, 0o755); err != nil {
+		return nil, err
+	}
+	base := path.Base(name)
+	if err := os.WriteFile(filepath.Join(dir, base), data, 0o444); err != nil {
+		return nil, err
+	}
+	c := n.Clone()
+	c.execCmd = interpreter
+	c.args = append([]string{path.Join(ScriptDir, base)}, args...)
+	c.AddBindRO(dir, ScriptDir)
+	return c.Run(ctx)
+}
@@ -0,0 +1,336 @@
+package nsjail
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultSeccompAuditSource is the kernel log, where the kernel reports seccomp actions unless auditd
+// takes them. Reading it needs CAP_SYSLOG when kernel.dmesg_restrict is set.
+const DefaultSeccompAuditSource = "/dev/kmsg"
+
+// SeccompAction is what the seccomp policy did with a syscall, from the code of its audit record.
+type SeccompAction uint8
+
+const (
+	// SeccompLogged means the syscall was allowed and logged, as with EnableSeccompLog.
+	SeccompLogged SeccompAction = iota
+	// SeccompKilled means the thread or process was killed.
+	SeccompKilled
+	// SeccompErrno means the syscall failed with an errno.
+	SeccompErrno
+	// SeccompTrapped means the process got SIGSYS.
+	SeccompTrapped
+	// SeccompOther is any other action, e.g. passing the syscall to a tracer.
+	SeccompOther
+)
+
+func (a SeccompAction) String() string {
+	switch a {
+	case SeccompLogged:
+		return "log"
+	case SeccompKilled:
+		return "kill"
+	case SeccompErrno:
+		return "errno"
+	case SeccompTrapped:
+		return "trap"
+	case SeccompOther:
+		return "other"
+	}
+	return fmt.Sprintf("SeccompAction(%d)", uint8(a))
+}
+
+// SeccompViolation is a syscall of a jailed process that the seccomp policy logged or denied, read from the
+// kernel audit log.
+type SeccompViolation struct {
+	// Time is when the kernel logged the syscall.
+	Time time.Time
+	// Pid is the process on the host, Comm its command name and Exe its executable.
+	Pid  int
+	Comm string
+	Exe  string
+	// Arch is the syscall ABI, e.g. "x86_64", and Syscall the number of the syscall in it.
+	Arch    string
+	Syscall int
+	Action  SeccompAction
+	// Code is the raw return value of the filter.
+	Code uint32
+}
+
+// Denied reports whether the syscall did not run.
+func (v SeccompViolation) Denied() bool { return v.Action != SeccompLogged && v.Action != SeccompOther }
+
+// SyscallName returns the name of the syscall, or "" if it is not known for its ABI.
+func (v SeccompViolation) SyscallName() string { return syscallNames[v.Arch][v.Syscall] }
+
+func (v SeccompViolation) String() string {
+	name := v.SyscallName()
+	if name == "" {
+		name = "syscall " + strconv.Itoa(v.Syscall)
+	}
+	return fmt.Sprintf("pid %d (%s): %s %s: %v", v.Pid, v.Comm, v.Arch, name, v.Action)
+}
+
+// CollectSeccompViolations reads the kernel audit log while the jail started with Start runs, and lists the
+// seccomp records of the jailed processes in Result.SeccompViolations, so a policy can be refined from what
+// it denied. With EnableSeccompLog every syscall the policy would deny is allowed and logged instead,
+// otherwise only the actions the kernel logs are seen, by default kills and, with auditd, also errnos.
+//
+// source is the file the records are read from: the kernel log (DefaultSeccompAuditSource, used if source
+// is empty) or, when auditd runs, its log, e.g. /var/log/audit/audit.log. Records are attributed to the jail
+// by the host pids of its processes, so those of a process that exits before it is seen may be missed.
+// Requires Linux.
+func (n *NsJail) CollectSeccompViolations(source string) *NsJail {
+	n.seccompAudit = cmp.Or(source, DefaultSeccompAuditSource)
+	return n.changed()
+}
+
+var (
+	seccompRecordRe = regexp.MustCompile(`type=(?:1326|SECCOMP)\b.*?audit\((\d+)\.(\d+):\d+\): (.*)`)
+	auditFieldRe    = regexp.MustCompile(`(\w+)=("[^"]*"|\S+)`)
+)
+
+// auditArches names the syscall ABIs of AUDIT_ARCH_* values.
+var auditArches = map[string]string{
+	"c000003e": "x86_64", "40000003": "i386", "c00000b7": "aarch64", "40000028": "arm",
+	"c00000f3": "riscv64", "80000016": "s390x", "c0000015": "ppc64le", "80000015": "ppc64",
+}
+
+// parseSeccompRecord parses a SECCOMP record of the audit log, as written to the kernel log or by auditd,
+// and reports whether line is one.
+func parseSeccompRecord(line string) (SeccompViolation, bool) {
+	m := seccompRecordRe.FindStringSubmatch(line)
+	if m == nil {
+		return SeccompViolation{}, false
+	}
+	sec, _ := strconv.ParseInt(m[1], 10, 64)
+	ms, _ := strconv.ParseInt(m[2], 10, 64)
+	v := SeccompViolation{Time: time.Unix(sec, ms*int64(time.Millisecond)), Syscall: -1}
+	for _, f := range auditFieldRe.FindAllStringSubmatch(m[3], -1) {
+		key, value := f[1], f[2]
+		switch key {
+		case "pid":
+			v.Pid, _ = strconv.Atoi(value)
+		case "comm":
+			v.Comm = auditString(value)
+		case "exe":
+			v.Exe = auditString(value)
+		case "arch":
+			v.Arch = value
+			if name, ok := auditArches[strings.ToLower(value)]; ok {
+				v.Arch = name
+			}
+		case "syscall":
+			v.Syscall, _ = strconv.Atoi(value)
+		case "code":
+			code, _ := strconv.ParseUint(value, 0, 32)
+			v.Code = uint32(code)
+		}
+	}
+	if v.Pid == 0 {
+		return SeccompViolation{}, false
+	}
+	v.Action = seccompAction(v.Code)
+	return v, true
+}
+
+// auditString decodes a string field of an audit record, quoted or, if it holds special characters,
+// hex-encoded.
+func auditString(value string) string {
+	if unquoted, ok := strings.CutPrefix(value, `"`); ok {
+		return strings.TrimSuffix(unquoted, `"`)
+	}
+	if b, err := hex.DecodeString(value); err == nil {
+		return string(b)
+	}
+	return value
+}
+
+// seccompAction classifies the SECCOMP_RET_* action in the high bits of a filter return value.
+func seccompAction(code uint32) SeccompAction {
+	switch code & 0xffff0000 {
+	case 0x7ffc0000: // SECCOMP_RET_LOG
+		return SeccompLogged
+	case 0x00000000, 0x80000000: // SECCOMP_RET_KILL_THREAD, SECCOMP_RET_KILL_PROCESS
+		return SeccompKilled
+	case 0x00050000: // SECCOMP_RET_ERRNO
+		return SeccompErrno
+	case 0x00030000: // SECCOMP_RET_TRAP
+		return SeccompTrapped
+	}
+	return SeccompOther
+}
+
+// seccompPidInterval is how often the pids of a jail collecting seccomp violations are listed.
+const seccompPidInterval = 20 * time.Millisecond
+
+// seccompFlushDelay is how long the kernel log is still read after the jail exited, for the records of its
+// last syscalls.
+const seccompFlushDelay = 50 * time.Millisecond
+
+// seccompCollector reads the audit log for CollectSeccompViolations.
+type seccompCollector struct {
+	jail *Jail
+	f    *os.File
+	// kmsg is set for the kernel log, which returns one record per read and never ends.
+	kmsg bool
+	// root is the pid of nsjail, set once it started.
+	root    int
+	started bool
+	stop    chan struct{}
+	done    chan struct{}
+
+	mu sync.Mutex
+	// seen holds the pids of the jailed processes listed so far, so records of processes that exited by
+	// the time their record is read are still attributed.
+	seen map[int]bool
+}
+
+// collectSeccompViolations opens the audit log at its end before nsjail starts, so every record of the
+// jail is read once it started, see seccompCollector.start.
+func (j *Jail) collectSeccompViolations(source string) error {
+	f, kmsg, err := openAuditLog(source)
+	if err != nil {
+		return fmt.Errorf("nsjail: seccomp audit log: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return fmt.Errorf("nsjail: seccomp audit log: %w", err)
+	}
+	c := &seccompCollector{jail: j, f: f, kmsg: kmsg, stop: make(chan struct{}), done: make(chan struct{}),
+		seen: make(map[int]bool)}
+	j.seccomp = c
+	j.onClose(func() {
+		if c.started {
+			close(c.stop)
+			if c.kmsg {
+				c.f.SetReadDeadline(time.Now().Add(seccompFlushDelay))
+			}
+			<-c.done
+		}
+		c.f.Close()
+	})
+	return nil
+}
+
+// start reads the audit log of the jail whose nsjail process is pid.
+func (c *seccompCollector) start(pid int) {
+	c.root, c.started = pid, true
+	go c.poll()
+	go func() {
+		defer close(c.done)
+		if c.kmsg {
+			c.readKmsg()
+		} else {
+			c.tail()
+		}
+	}()
+}
+
+// readKmsg reads records of the kernel log, one per read, until its read deadline passes.
+func (c *seccompCollector) readKmsg() {
+	buf := make([]byte, 8192)
+	for {
+		n, err := c.f.Read(buf)
+		if errors.Is(err, syscall.EPIPE) {
+			// Records were overwritten before they were read.
+			continue
+		}
+		if err != nil {
+			return
+		}
+		// A record is "priority,sequence,timestamp,flags;message" followed by continuation lines.
+		record, _, _ := strings.Cut(string(buf[:n]), "\n")
+		if _, msg, ok := strings.Cut(record, ";"); ok {
+			c.record(msg)
+		}
+	}
+}
+
+// tail reads lines of a log file as they are appended, until stop is closed and the end is reached.
+func (c *seccompCollector) tail() {
+	r := bufio.NewReader(c.f)
+	var partial string
+	for {
+		line, err := r.ReadString('\n')
+		if err == nil {
+			c.record(partial + line)
+			partial = ""
+			continue
+		}
+		partial += line
+		if err != io.EOF {
+			return
+		}
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(seccompPidInterval):
+		}
+	}
+}
+
+// poll lists the jailed processes until the jail exits.
+func (c *seccompCollector) poll() {
+	t := time.NewTicker(seccompPidInterval)
+	defer t.Stop()
+	for {
+		if pids, err := descendantPids(c.root); err == nil {
+			c.mu.Lock()
+			for _, pid := range pids {
+				c.seen[pid] = true
+			}
+			c.mu.Unlock()
+		}
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// owns reports whether pid is, or was seen as, a jailed process.
+func (c *seccompCollector) owns(pid int) bool {
+	c.mu.Lock()
+	seen := c.seen[pid]
+	c.mu.Unlock()
+	if seen {
+		return true
+	}
+	for i := 0; i < 64 && pid > 1; i++ {
+		ppid, err := parentPid(pid)
+		if err != nil {
+			return false
+		}
+		if ppid == c.root {
+			return true
+		}
+		pid = ppid
+	}
+	return false
+}
+
+// record adds the violation in a line of the audit log if it is one of the jail.
+func (c *seccompCollector) record(line string) {
+	v, ok := parseSeccompRecord(line)
+	if !ok || !c.owns(v.Pid) {
+		return
+	}
+	c.jail.log.Debug("nsjail: seccomp violation", "pid", v.Pid, "syscall", v.Syscall, "action", v.Action)
+	c.jail.mu.Lock()
+	c.jail.seccompViolations = append(c.jail.seccompViolations, v)
+	c.jail.mu.Unlock()
+}
@@ -0,0 +1,21 @@
+package nsjail
+
+import (
+	"os"
+	"syscall"
+)
+
+// openAuditLog opens the audit log at path and reports whether it is the kernel log. It is opened
+// non-blocking, so reads of the kernel log wait in the runtime poller and honor read deadlines.
+func openAuditLog(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	return f, fi.Mode()&os.ModeCharDevice != 0, nil
+}
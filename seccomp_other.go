@@ -0,0 +1,12 @@
+//go:build !linux
+
+package nsjail
+
+import (
+	"errors"
+	"os"
+)
+
+func openAuditLog(path string) (*os.File, bool, error) {
+	return nil, false, errors.New("seccomp violations can only be collected on linux")
+}
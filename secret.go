@@ -0,0 +1,61 @@
+package nsjail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// secret is a value passed to the jail through a descriptor, see WithSecretFd.
+type secret struct {
+	name string
+	data []byte
+}
+
+// WithSecretFd passes data to the jail through an inherited descriptor (--pass_fd) whose number is set in
+// the environment variable name, so the secret appears neither in argv, the environment nor on a
+// filesystem. The jailed process reads it from /proc/self/fd/$name or the descriptor itself. On Linux the
+// descriptor is a sealed memfd positioned at the start of data; elsewhere, or if memfds are unavailable, it
+// is the read end of a pipe that is closed after data. data is copied. Only Start and Run pass secrets.
+// Can be called multiple times.
+func (n *NsJail) WithSecretFd(name string, data []byte) *NsJail {
+	n.secrets = append(n.secrets, secret{name: name, data: bytes.Clone(data)})
+	return n.changed()
+}
+
+// passSecrets passes the secrets of n to the jail.
+func (j *Jail) passSecrets(l *launch, secrets []secret) error {
+	for _, s := range secrets {
+		if s.name == "" || strings.ContainsAny(s.name, "=\x00") {
+			return fmt.Errorf("nsjail: invalid secret variable name %q", s.name)
+		}
+		f, err := secretMemfd(s.data)
+		if errors.Is(err, errors.ErrUnsupported) {
+			f, err = secretPipe(s.data)
+		}
+		if err != nil {
+			return fmt.Errorf("nsjail: secret %s: %w", s.name, err)
+		}
+		fd := l.passFile(f)
+		l.closeAfterStart(f)
+		l.flags = append(l.flags, "-E", s.name+"="+strconv.Itoa(fd))
+	}
+	return nil
+}
+
+// secretPipe returns the read end of a pipe delivering data.
+func secretPipe(data []byte) (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	// Writing blocks once the pipe buffer is full, until the jail reads.
+	go func() {
+		w.Write(data)
+		w.Close()
+	}()
+	return r, nil
+}
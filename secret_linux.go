@@ -0,0 +1,42 @@
+package nsjail
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const secretMemfdName = "nsjail-secret"
+
+// secretMemfd returns a sealed memfd holding data, or an error wrapping errors.ErrUnsupported if the kernel
+// has no memfds.
+func secretMemfd(data []byte) (*os.File, error) { return sealedMemfd(secretMemfdName, data, 0) }
+
+// sealedMemfd returns a memfd created with flags holding data, sealed against changes, or an error wrapping
+// errors.ErrUnsupported if the kernel has no memfds.
+func sealedMemfd(name string, data []byte, flags int) (*os.File, error) {
+	fd, err := unix.MemfdCreate(name, unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING|flags)
+	if err == unix.ENOSYS {
+		return nil, errors.ErrUnsupported
+	}
+	if err != nil {
+		return nil, os.NewSyscallError("memfd_create", err)
+	}
+	f := os.NewFile(uintptr(fd), name)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// The jail can read the secret but not change it for other readers of the descriptor.
+	seals := unix.F_SEAL_SEAL | unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE
+	if _, err := unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, seals); err != nil {
+		f.Close()
+		return nil, os.NewSyscallError("fcntl", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package nsjail
+
+import (
+	"errors"
+	"os"
+)
+
+func secretMemfd(data []byte) (*os.File, error) { return nil, errors.ErrUnsupported }
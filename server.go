@@ -0,0 +1,214 @@
+package nsjail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrServerClosed is returned by Server.Err after Shutdown.
+var ErrServerClosed = errors.New("nsjail: server closed")
+
+// Server runs a jail in ModeListenTCP and restarts nsjail when it crashes, as returned by ListenAndServe.
+type Server struct {
+	n     *NsJail
+	ctx   context.Context
+	addr  string
+	ready chan struct{}
+	done  chan struct{}
+
+	readyOnce sync.Once
+	mu        sync.Mutex
+	jail      *Jail
+	last      *Result
+	restarts  int
+	closing   bool
+	err       error
+}
+
+// WithRestartLimit makes ListenAndServe give up after restarting a crashed nsjail n times.
+// By default it restarts without limit.
+func (n *NsJail) WithRestartLimit(limit uint) *NsJail { n.restartLimit = limit; return n.changed() }
+
+// ListenAndServe starts the jail in ModeListenTCP and keeps it serving until ctx is done or Shutdown is called.
+// When nsjail exits on its own it is restarted with exponential backoff, see WithRestartLimit.
+// A port must be set with WithPort or WithListenAddrPort. Errors starting the first nsjail process are returned.
+func (n *NsJail) ListenAndServe(ctx context.Context) (*Server, error) {
+	if n.port == 0 {
+		return nil, errors.New("nsjail: ListenAndServe requires a port")
+	}
+	n = n.Clone().WithMode(ModeListenTCP)
+	host := n.bindhost
+	if host == "" {
+		host = "::"
+	}
+	s := &Server{
+		n:     n,
+		ctx:   ctx,
+		addr:  net.JoinHostPort(host, strconv.Itoa(int(n.port))),
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	j, err := n.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.jail = j
+	go s.serve(j)
+	return s, nil
+}
+
+// Addr returns the address the server listens on, e.g. "[::]:8080".
+func (s *Server) Addr() string { return s.addr }
+
+// Ready returns a channel that is closed once nsjail first bound its port.
+func (s *Server) Ready() <-chan struct{} { return s.ready }
+
+// Done returns a channel that is closed once the server stopped for good.
+func (s *Server) Done() <-chan struct{} { return s.done }
+
+// Err returns why the server stopped: ErrServerClosed after Shutdown, the context's error, or the error that
+// made it give up restarting. It returns nil while the server runs.
+func (s *Server) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Jail returns the currently running nsjail process.
+func (s *Server) Jail() *Jail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jail
+}
+
+// Restarts returns how often nsjail was restarted after crashing.
+func (s *Server) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// LastResult returns the result of the last nsjail process that exited, or nil.
+func (s *Server) LastResult() *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Shutdown stops restarting nsjail and waits for in-flight connections to finish, then terminates nsjail.
+// Connections accepted meanwhile are served too. If ctx is done first, nsjail and its connections are killed
+// and ctx's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	j := s.jail
+	s.mu.Unlock()
+
+	t := time.NewTicker(50 * time.Millisecond)
+	defer t.Stop()
+	for {
+		if pids, err := childPids(j.Pid()); err != nil || len(pids) == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			j.Abort(ctx.Err())
+			<-s.done
+			return ctx.Err()
+		case <-j.Done():
+		case <-t.C:
+			continue
+		}
+		break
+	}
+	if j.proc != nil {
+		j.proc.Signal(syscall.SIGTERM)
+	}
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		j.Abort(ctx.Err())
+		<-s.done
+		return ctx.Err()
+	}
+}
+
+// serve supervises j and its successors until the server stops.
+func (s *Server) serve(j *Jail) {
+	defer close(s.done)
+	backoff := 100 * time.Millisecond
+	for {
+		go s.awaitReady(j)
+		res, err := j.Wait()
+
+		s.mu.Lock()
+		s.last = res
+		switch {
+		case s.closing:
+			s.err = ErrServerClosed
+		case s.ctx.Err() != nil:
+			s.err = s.ctx.Err()
+		case err != nil:
+			s.err = err
+		case s.n.restartLimit > 0 && uint(s.restarts) >= s.n.restartLimit:
+			s.err = fmt.Errorf("nsjail: server exited with code %d after %d restarts", res.ExitCode, s.restarts)
+		}
+		stop := s.err != nil
+		s.mu.Unlock()
+		if stop {
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			s.mu.Lock()
+			s.err = s.ctx.Err()
+			s.mu.Unlock()
+			return
+		}
+		backoff = min(backoff*2, 5*time.Second)
+
+		next, err := s.n.Start(s.ctx)
+		s.mu.Lock()
+		if err == nil && s.closing {
+			next.Abort(ErrServerClosed)
+		}
+		if err != nil {
+			s.err = err
+		} else {
+			s.jail = next
+			s.restarts++
+		}
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+		j = next
+	}
+}
+
+// awaitReady closes s.ready once the port is bound while j runs.
+func (s *Server) awaitReady(j *Jail) {
+	t := time.NewTicker(20 * time.Millisecond)
+	defer t.Stop()
+	for {
+		if ok, err := listeningOn(s.n.port); ok || err != nil {
+			// Without procfs readiness cannot be observed; report it once nsjail runs.
+			s.readyOnce.Do(func() { close(s.ready) })
+			return
+		}
+		select {
+		case <-j.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
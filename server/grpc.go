@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// ServiceName is the full name of the gRPC service.
+const ServiceName = "nsjail.v1.ExecutionService"
+
+// ContentSubtype is the content subtype the messages of the service are sent with, so the content type is
+// "application/grpc+nsjail-json" on the wire. Requests with another content type, e.g. protobuf, are
+// rejected by the service. The codec is registered with gRPC under this name rather than "json", so it does
+// not replace a JSON codec of the program that is used for other services.
+//
+// Each message is a JSON object whose keys are the json tags of the message types, e.g.
+// {"command": "/bin/echo", "args": ["hi"]} for SubmitJobRequest, with []byte fields such as
+// SubmitJobRequest.Stdin and OutputChunk.Data in standard base64 with padding. The methods are
+// /nsjail.v1.ExecutionService/SubmitJob, CancelJob and, streaming from the server, StreamOutput.
+const ContentSubtype = "nsjail-json"
+
+func init() { encoding.RegisterCodec(jsonCodec{}) }
+
+// jsonCodec encodes the messages of the service as JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return ContentSubtype }
+
+// ExecutionServiceServer is the server API of the service, implemented by Service.
+type ExecutionServiceServer interface {
+	SubmitJob(context.Context, *SubmitJobRequest) (*SubmitJobResponse, error)
+	StreamOutput(*StreamOutputRequest, grpc.ServerStreamingServer[OutputChunk]) error
+	CancelJob(context.Context, *CancelJobRequest) (*CancelJobResponse, error)
+}
+
+// RegisterExecutionServiceServer registers srv with a gRPC server.
+func RegisterExecutionServiceServer(s grpc.ServiceRegistrar, srv ExecutionServiceServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*ExecutionServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "SubmitJob", Handler: unaryHandler("SubmitJob", ExecutionServiceServer.SubmitJob)},
+		{MethodName: "CancelJob", Handler: unaryHandler("CancelJob", ExecutionServiceServer.CancelJob)},
+	},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "StreamOutput",
+		ServerStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			req := new(StreamOutputRequest)
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return srv.(ExecutionServiceServer).StreamOutput(req,
+				&grpc.GenericServerStream[StreamOutputRequest, OutputChunk]{ServerStream: stream})
+		},
+	}},
+}
+
+// unaryHandler adapts a unary method of ExecutionServiceServer to grpc.MethodDesc.
+func unaryHandler[Req, Resp any](name string, method func(ExecutionServiceServer, context.Context, *Req) (*Resp, error)) grpc.MethodHandler {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		req := new(Req)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return method(srv.(ExecutionServiceServer), ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/" + name}
+		return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+			return method(srv.(ExecutionServiceServer), ctx, req.(*Req))
+		})
+	}
+}
+
+// Client calls an ExecutionService.
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewClient returns a client using the connection cc.
+func NewClient(cc grpc.ClientConnInterface) *Client { return &Client{cc: cc} }
+
+func callOptions(opts []grpc.CallOption) []grpc.CallOption {
+	return append([]grpc.CallOption{grpc.CallContentSubtype(ContentSubtype)}, opts...)
+}
+
+// SubmitJob starts a job.
+func (c *Client) SubmitJob(ctx context.Context, req *SubmitJobRequest, opts ...grpc.CallOption) (*SubmitJobResponse, error) {
+	resp := new(SubmitJobResponse)
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/SubmitJob", req, resp, callOptions(opts)...); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// StreamOutput streams the output of a job. Recv returns io.EOF after the message carrying the result.
+func (c *Client) StreamOutput(ctx context.Context, req *StreamOutputRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OutputChunk], error) {
+	stream, err := c.cc.NewStream(ctx, &serviceDesc.Streams[0], "/"+ServiceName+"/StreamOutput", callOptions(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	s := &grpc.GenericClientStream[StreamOutputRequest, OutputChunk]{ClientStream: stream}
+	if err := s.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := s.CloseSend(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CancelJob kills a running job.
+func (c *Client) CancelJob(ctx context.Context, req *CancelJobRequest, opts ...grpc.CallOption) (*CancelJobResponse, error) {
+	resp := new(CancelJobResponse)
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/CancelJob", req, resp, callOptions(opts)...); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxSpecBytes bounds the size of a job spec, including its stdin.
+const maxSpecBytes = 8 << 20
+
+// JobSpec is the body of a request to the HTTP handler of a Service.
+type JobSpec struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Stdin   string   `json:"stdin,omitempty"`
+	// Profile names a registered profile configuring the jail, applied after the base options.
+	Profile string    `json:"profile,omitempty"`
+	Limits  JobLimits `json:"limits"`
+}
+
+// JobLimits tighten the limits of a job. They cannot exceed those of the service and its profiles.
+type JobLimits struct {
+	// TimeLimitSeconds lowers the time limit of the jail.
+	TimeLimitSeconds uint64 `json:"time_limit_seconds,omitempty"`
+	// MaxOutputBytes lowers the output streamed per stream, see Config.MaxOutput.
+	MaxOutputBytes int64 `json:"max_output_bytes,omitempty"`
+}
+
+// ServeHTTP runs the job described by a JobSpec posted as JSON and streams its output while it runs,
+// as one JSON OutputChunk per line (application/x-ndjson) or, if the request accepts text/event-stream,
+// as server-sent events named "stdout", "stderr" and "result". The last chunk or event carries the
+// JobResult. The job is killed when the client goes away.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var spec JobSpec
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSpecBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		http.Error(w, "invalid job spec: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var profiles []string
+	if spec.Profile != "" {
+		profiles = []string{spec.Profile}
+	}
+	n, err := s.configure(spec.Command, spec.Args, profiles)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if l := spec.Limits.TimeLimitSeconds; l > 0 {
+		if limit := n.TimeLimit(); limit > 0 && float64(l) > limit.Seconds() {
+			http.Error(w, fmt.Sprintf("time limit above %v", limit), http.StatusBadRequest)
+			return
+		}
+		n.WithTimeLimit(l)
+	}
+	maxOutput := s.cfg.MaxOutput
+	if l := spec.Limits.MaxOutputBytes; l > 0 {
+		maxOutput = min(l, maxOutput)
+	}
+	if !s.acquire() {
+		http.Error(w, "too many running jobs", http.StatusTooManyRequests)
+		return
+	}
+	defer s.release()
+
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	out := &httpOutput{w: w, sse: sse, max: maxOutput, written: make(map[string]int64),
+		truncated: make(map[string]bool)}
+	out.flusher, _ = w.(http.Flusher)
+	n.WithStdio(strings.NewReader(spec.Stdin), out.stream("stdout"), out.stream("stderr"))
+	// Output is held back until the headers are written.
+	out.mu.Lock()
+	j, err := n.Start(r.Context())
+	if err != nil {
+		out.closed = true
+		out.mu.Unlock()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(http.StatusOK)
+	out.flush()
+	out.mu.Unlock()
+
+	res, err := j.Wait()
+	result := jobResult(res, err)
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	result.StdoutTruncated, result.StderrTruncated = out.truncated["stdout"], out.truncated["stderr"]
+	out.send("result", &OutputChunk{Result: result})
+	// Output still written after the drain timeout must not reach the finished response.
+	out.closed = true
+}
+
+// httpOutput writes the output of a job to an HTTP response.
+type httpOutput struct {
+	w       io.Writer
+	flusher http.Flusher
+	sse     bool
+	max     int64
+
+	mu        sync.Mutex
+	written   map[string]int64
+	truncated map[string]bool
+	closed    bool
+}
+
+// httpStream is one output stream of a job.
+type httpStream struct {
+	o    *httpOutput
+	name string
+}
+
+func (o *httpOutput) stream(name string) io.Writer { return &httpStream{o, name} }
+
+func (s *httpStream) Write(p []byte) (int, error) {
+	o, name := s.o, s.name
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return len(p), nil
+	}
+	data := p
+	if room := o.max - o.written[name]; int64(len(data)) > room {
+		data = data[:max(room, 0)]
+		o.truncated[name] = true
+	}
+	if len(data) > 0 {
+		o.written[name] += int64(len(data))
+		o.send(name, &OutputChunk{Stream: name, Data: data})
+	}
+	// A client that went away kills the job through the request context, so write errors are ignored.
+	return len(p), nil
+}
+
+// send writes c as an event or line and flushes it. o.mu must be held.
+func (o *httpOutput) send(event string, c *OutputChunk) {
+	data, _ := json.Marshal(c)
+	if o.sse {
+		fmt.Fprintf(o.w, "event: %s\ndata: %s\n\n", event, data)
+	} else {
+		o.w.Write(append(data, '\n'))
+	}
+	o.flush()
+}
+
+func (o *httpOutput) flush() {
+	if o.flusher != nil {
+		o.flusher.Flush()
+	}
+}
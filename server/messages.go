@@ -0,0 +1,60 @@
+package server
+
+// SubmitJobRequest starts a job.
+type SubmitJobRequest struct {
+	// Profiles names the registered profiles configuring the jail, applied in order after the base options
+	// of the service.
+	Profiles []string `json:"profiles,omitempty"`
+	// Command and Args are the program run in the jail.
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	// Stdin is the input of the program.
+	Stdin []byte `json:"stdin,omitempty"`
+}
+
+// SubmitJobResponse identifies the started job.
+type SubmitJobResponse struct {
+	JobID string `json:"job_id"`
+}
+
+// StreamOutputRequest selects the job whose output is streamed.
+type StreamOutputRequest struct {
+	JobID string `json:"job_id"`
+}
+
+// OutputChunk is a message of StreamOutput. The stream replays the output of the job from its start,
+// follows it while the job runs and ends with a message carrying only the Result.
+type OutputChunk struct {
+	// Stream is "stdout" or "stderr".
+	Stream string `json:"stream,omitempty"`
+	Data   []byte `json:"data,omitempty"`
+	// Result is set on the last message.
+	Result *JobResult `json:"result,omitempty"`
+}
+
+// JobResult describes a finished job.
+type JobResult struct {
+	// Status is the nsjail.Status of the jail, e.g. "exited" or "time limit", and ExitCode its normalized
+	// exit code.
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+	// DurationSeconds is the wall time of the jail.
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Aborted is why the jail was killed by the wrapper, e.g. after CancelJob.
+	Aborted string `json:"aborted,omitempty"`
+	// Error is set if waiting for the jail failed.
+	Error string `json:"error,omitempty"`
+	// StdoutTruncated and StderrTruncated report output beyond the limit of the service that was dropped.
+	StdoutTruncated bool `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool `json:"stderr_truncated,omitempty"`
+}
+
+// CancelJobRequest selects the job to cancel.
+type CancelJobRequest struct {
+	JobID string `json:"job_id"`
+}
+
+// CancelJobResponse reports whether the job was still running.
+type CancelJobResponse struct {
+	Canceled bool `json:"canceled"`
+}
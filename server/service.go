@@ -0,0 +1,277 @@
+// Package server implements ExecutionService, a gRPC service running jobs in jails configured with
+// nsjail-go, so a remote sandbox runner can be deployed without writing the service layer:
+//
+//	svc := server.NewService(server.Config{Base: []nsjail.Option{nsjail.WithTimeLimitOpt(10)}})
+//	gs := grpc.NewServer()
+//	server.RegisterExecutionServiceServer(gs, svc)
+//	gs.Serve(lis)
+//
+// Service is also an http.Handler running one-shot jobs posted as JSON, see Service.ServeHTTP:
+//
+//	http.Handle("/run", svc)
+//
+// Jobs select the profiles registered with nsjail.RegisterProfile (or in Config.Profiles) that configure
+// their jail. The messages are encoded as JSON (content subtype "nsjail-json", see ContentSubtype), so no
+// generated code is needed; Client selects the codec for Go callers.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	nsjail "github.com/OptimusePrime/nsjail-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrJobCanceled is the reason recorded in JobResult.Aborted for jobs stopped with CancelJob.
+var ErrJobCanceled = errors.New("server: job canceled")
+
+const (
+	defaultMaxOutput = 4 << 20
+	defaultRetention = 10 * time.Minute
+)
+
+// Config configures a Service.
+type Config struct {
+	// Base options are applied to every job before its profiles, e.g. the nsjail path and hardening.
+	Base []nsjail.Option
+	// Profiles holds the profiles jobs select by name. Defaults to nsjail.DefaultProfiles.
+	Profiles *nsjail.ProfileRegistry
+	// MaxOutput caps the output kept per job and stream. Defaults to 4MiB.
+	MaxOutput int64
+	// Retention is how long the output of a finished job can still be streamed. Defaults to 10 minutes.
+	Retention time.Duration
+	// MaxJobs limits the number of running jobs; SubmitJob fails with ResourceExhausted beyond it. Zero
+	// means no limit.
+	MaxJobs int
+}
+
+// Service implements ExecutionServiceServer. It is safe for concurrent use.
+type Service struct {
+	cfg Config
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	running int
+}
+
+// NewService returns a service configured by cfg.
+func NewService(cfg Config) *Service {
+	if cfg.Profiles == nil {
+		cfg.Profiles = nsjail.DefaultProfiles
+	}
+	if cfg.MaxOutput <= 0 {
+		cfg.MaxOutput = defaultMaxOutput
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultRetention
+	}
+	return &Service{cfg: cfg, jobs: make(map[string]*job)}
+}
+
+// SubmitJob starts a job and returns its id once the jail started.
+func (s *Service) SubmitJob(ctx context.Context, req *SubmitJobRequest) (*SubmitJobResponse, error) {
+	n, err := s.configure(req.Command, req.Args, req.Profiles)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !s.acquire() {
+		return nil, status.Error(codes.ResourceExhausted, "too many running jobs")
+	}
+
+	j := &job{
+		id:        newJobID(),
+		maxOutput: s.cfg.MaxOutput,
+		written:   make(map[string]int64),
+		truncated: make(map[string]bool),
+		changed:   make(chan struct{}),
+	}
+	n.WithStdio(bytes.NewReader(req.Stdin), &outputWriter{j, "stdout"}, &outputWriter{j, "stderr"})
+	// The job outlives the request that submitted it.
+	jail, err := n.Start(context.WithoutCancel(ctx))
+	if err != nil {
+		s.release()
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	j.jail = jail
+	s.mu.Lock()
+	s.jobs[j.id] = j
+	s.mu.Unlock()
+
+	go func() {
+		res, err := jail.Wait()
+		j.finish(res, err)
+		s.release()
+		time.AfterFunc(s.cfg.Retention, func() {
+			s.mu.Lock()
+			delete(s.jobs, j.id)
+			s.mu.Unlock()
+		})
+	}()
+	return &SubmitJobResponse{JobID: j.id}, nil
+}
+
+// configure returns the jail of a job running command with args, configured by the base options and
+// the named profiles.
+func (s *Service) configure(command string, args, profiles []string) (*nsjail.NsJail, error) {
+	if command == "" {
+		return nil, errors.New("command is required")
+	}
+	chain, err := s.cfg.Profiles.Chain(profiles...)
+	if err != nil {
+		return nil, err
+	}
+	n := nsjail.New(command, args...).Apply(s.cfg.Base...)
+	return chain.Apply(n), nil
+}
+
+// acquire reserves a slot for a running job, unless MaxJobs are running.
+func (s *Service) acquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg.MaxJobs > 0 && s.running >= s.cfg.MaxJobs {
+		return false
+	}
+	s.running++
+	return true
+}
+
+func (s *Service) release() {
+	s.mu.Lock()
+	s.running--
+	s.mu.Unlock()
+}
+
+// StreamOutput streams the output of a job, see OutputChunk.
+func (s *Service) StreamOutput(req *StreamOutputRequest, stream grpc.ServerStreamingServer[OutputChunk]) error {
+	j, err := s.job(req.JobID)
+	if err != nil {
+		return err
+	}
+	for next := 0; ; {
+		j.mu.Lock()
+		chunks, result, changed := j.chunks[next:], j.result, j.changed
+		j.mu.Unlock()
+		for _, c := range chunks {
+			if err := stream.Send(c); err != nil {
+				return err
+			}
+		}
+		next += len(chunks)
+		if result != nil {
+			return stream.Send(&OutputChunk{Result: result})
+		}
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
+
+// CancelJob kills a running job. Canceling a finished job is not an error.
+func (s *Service) CancelJob(ctx context.Context, req *CancelJobRequest) (*CancelJobResponse, error) {
+	j, err := s.job(req.JobID)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-j.jail.Done():
+		return &CancelJobResponse{}, nil
+	default:
+	}
+	j.jail.Abort(ErrJobCanceled)
+	return &CancelJobResponse{Canceled: true}, nil
+}
+
+func (s *Service) job(id string) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "job %q not found", id)
+	}
+	return j, nil
+}
+
+func newJobID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// job is a submitted job and the output it produced so far.
+type job struct {
+	id        string
+	jail      *nsjail.Jail
+	maxOutput int64
+
+	mu        sync.Mutex
+	chunks    []*OutputChunk
+	written   map[string]int64
+	truncated map[string]bool
+	result    *JobResult
+	// changed is closed and replaced whenever output or the result is added.
+	changed chan struct{}
+}
+
+func (j *job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *job) finish(res *nsjail.Result, err error) {
+	r := jobResult(res, err)
+	j.mu.Lock()
+	r.StdoutTruncated, r.StderrTruncated = j.truncated["stdout"], j.truncated["stderr"]
+	j.result = r
+	j.notify()
+	j.mu.Unlock()
+}
+
+// jobResult converts the result of a jail.
+func jobResult(res *nsjail.Result, err error) *JobResult {
+	r := &JobResult{}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if res != nil {
+		r.Status, r.ExitCode = res.Status.String(), res.NormalizedCode
+		r.DurationSeconds = res.Duration.Seconds()
+		if res.Aborted != nil {
+			r.Aborted = res.Aborted.Error()
+		}
+	}
+	return r
+}
+
+// outputWriter appends the output of one stream of a job, up to its limit.
+type outputWriter struct {
+	j      *job
+	stream string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	j := w.j
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	data := p
+	if room := j.maxOutput - j.written[w.stream]; int64(len(data)) > room {
+		data = data[:max(room, 0)]
+		j.truncated[w.stream] = true
+	}
+	if len(data) > 0 {
+		j.chunks = append(j.chunks, &OutputChunk{Stream: w.stream, Data: bytes.Clone(data)})
+		j.written[w.stream] += int64(len(data))
+		j.notify()
+	}
+	// Dropped output is reported in the result rather than failing the jail.
+	return len(p), nil
+}
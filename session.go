@@ -0,0 +1,208 @@
+package nsjail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/OptimusePrime/nsjail-go/initshim"
+)
+
+// ErrSessionClosed is returned by Session.Exec once the session's jail exited.
+var ErrSessionClosed = errors.New("nsjail: session closed")
+
+// ExecRequest is a command to run in a Session.
+type ExecRequest struct {
+	Args []string
+	// Env replaces the environment of the jail if non-nil.
+	Env   []string
+	Dir   string
+	Stdin []byte
+	// MaxOutput caps each of stdout and stderr. Defaults to 1 MiB.
+	MaxOutput int64
+	// Timeout kills the command and all processes in its process group after this long.
+	Timeout time.Duration
+
+	// Sub-limits of the command within the session's limits, applied with setrlimit by the agent so one
+	// runaway command does not exhaust the session. Zero leaves the session's limit.
+	// A command exceeding CPUTime is killed with SIGXCPU; allocations beyond AddressSpace and writes beyond
+	// FileSize fail.
+	CPUTime      time.Duration // RLIMIT_CPU, rounded up to whole seconds
+	AddressSpace uint64        // RLIMIT_AS in bytes
+	FileSize     uint64        // RLIMIT_FSIZE in bytes
+}
+
+// ExecResult describes a command run in a Session.
+type ExecResult struct {
+	// ExitCode is the exit code of the command, or -1 if it was killed by a signal.
+	ExitCode int
+	Signal   syscall.Signal
+	Stdout   []byte
+	Stderr   []byte
+	// Truncated reports that output beyond ExecRequest.MaxOutput was discarded.
+	Truncated bool
+	Duration  time.Duration
+	// TimedOut and Canceled report why the command was killed: ExecRequest.Timeout or the context of Exec.
+	TimedOut bool
+	Canceled bool
+}
+
+// Session is a long-lived jail running many commands, as returned by StartSession. Namespaces, mounts and
+// limits are set up once, and files and background processes persist between commands.
+type Session struct {
+	n *NsJail
+	j *Jail
+
+	mu     sync.Mutex // serializes Exec
+	wmu    sync.Mutex // serializes writes of requests and cancellations
+	enc    *json.Encoder
+	dec    *json.Decoder
+	nextID uint64
+}
+
+// StartSession starts the jail with the agent of the init shim set with WithInitShim as its only process,
+// ready to run commands with Exec. The configured command is not run. Limits such as WithTimeLimit and
+// WithRlimitCpu apply to the whole session; nsjail's default time limit is disabled unless set explicitly.
+// The session ends with Close or when ctx is done.
+func (n *NsJail) StartSession(ctx context.Context) (*Session, error) {
+	if n.initShim == "" {
+		return nil, errors.New("nsjail: sessions require the init shim, see WithInitShim")
+	}
+	if n.mode != "" && n.mode != ModeOnce && n.mode != ModeExecve {
+		return nil, fmt.Errorf("nsjail: sessions cannot run in mode %q", n.mode)
+	}
+	c := n.Clone()
+	c.sessionAgent = true
+	j, err := c.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{n: c, j: j, enc: json.NewEncoder(j.agentReq), dec: json.NewDecoder(j.agentResp)}, nil
+}
+
+// useAgent runs the init shim as a session agent, talking to it over a pair of pipes.
+func (j *Jail) useAgent(n *NsJail, l *launch) error {
+	if n.execFile != "" || n.executeFd {
+		return errors.New("nsjail: sessions cannot be combined with an exec file")
+	}
+	reqR, reqW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	respR, respW, err := os.Pipe()
+	if err != nil {
+		reqR.Close()
+		reqW.Close()
+		return err
+	}
+	inFd := l.passFile(reqR)
+	outFd := l.passFile(respW)
+	l.closeAfterStart(reqR)
+	l.closeAfterStart(respW)
+	j.agentReq, j.agentResp = reqW, respR
+	j.onClose(func() { reqW.Close(); respR.Close() })
+
+	if n.timeLimit == 0 {
+		l.flags = append(l.flags, "--time_limit", "0")
+	}
+	l.flags = append(l.flags, "-R", n.initShim+":"+shimJailPath)
+	l.command = []string{shimJailPath, "-agent", "-in-fd", strconv.Itoa(inFd), "-out-fd", strconv.Itoa(outFd)}
+	return nil
+}
+
+// Jail returns the jail backing the session.
+func (s *Session) Jail() *Jail { return s.j }
+
+// Exec runs a command in the session and waits for it. Commands run one at a time; concurrent calls wait
+// for their turn. When ctx is done the command is killed and its result returned with Canceled set.
+func (s *Session) Exec(ctx context.Context, req ExecRequest) (*ExecResult, error) {
+	if len(req.Args) == 0 {
+		return nil, errors.New("nsjail: no command given")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.j.Done():
+		return nil, ErrSessionClosed
+	default:
+	}
+
+	s.nextID++
+	id := s.nextID
+	if err := s.send(initshim.Request{
+		ID:        id,
+		Args:      req.Args,
+		Env:       req.Env,
+		Dir:       req.Dir,
+		Stdin:     req.Stdin,
+		MaxOutput: req.MaxOutput,
+		Timeout:   req.Timeout,
+
+		CPUTime:      req.CPUTime,
+		AddressSpace: req.AddressSpace,
+		FileSize:     req.FileSize,
+	}); err != nil {
+		return nil, ErrSessionClosed
+	}
+
+	type decoded struct {
+		resp initshim.Response
+		err  error
+	}
+	ch := make(chan decoded, 1)
+	go func() {
+		var d decoded
+		d.err = s.dec.Decode(&d.resp)
+		ch <- d
+	}()
+
+	done := ctx.Done()
+	for {
+		select {
+		case d := <-ch:
+			if d.err != nil {
+				return nil, ErrSessionClosed
+			}
+			if d.resp.ID != id {
+				return nil, fmt.Errorf("nsjail: session agent answered request %d instead of %d", d.resp.ID, id)
+			}
+			if d.resp.Error != "" {
+				return nil, errors.New("nsjail: session: " + d.resp.Error)
+			}
+			return &ExecResult{
+				ExitCode:  d.resp.ExitCode,
+				Signal:    syscall.Signal(d.resp.Signal),
+				Stdout:    d.resp.Stdout,
+				Stderr:    d.resp.Stderr,
+				Truncated: d.resp.Truncated,
+				Duration:  d.resp.Runtime,
+				TimedOut:  d.resp.TimedOut,
+				Canceled:  d.resp.Canceled,
+			}, nil
+		case <-done:
+			// The agent answers once the killed command is reaped.
+			s.send(initshim.Request{ID: id, Cancel: true})
+			done = nil
+		}
+	}
+}
+
+func (s *Session) send(req initshim.Request) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	return s.enc.Encode(req)
+}
+
+// Close ends the session: the agent exits once the running command finished, and the jail with it.
+func (s *Session) Close() (*Result, error) {
+	s.wmu.Lock()
+	s.j.agentReq.Close()
+	s.wmu.Unlock()
+	return s.j.Wait()
+}
@@ -0,0 +1,94 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/OptimusePrime/nsjail-go/initshim"
+)
+
+// shimJailPath is where the init shim is mounted inside the jail.
+const shimJailPath = "/.nsjail-init"
+
+// WithInitShim runs the command under the init shim found at hostPath on the host (see cmd/nsjail-init).
+// The shim becomes PID 1 of the jail: it reaps zombies, forwards signals to the command, and reports exact
+// timing and rusage of the command in Result.Shim. The report is missing if nsjail kills the whole jail,
+// e.g. on its own time limit. Not compatible with WithExecFile and EnableExecuteFd.
+func (n *NsJail) WithInitShim(hostPath string) *NsJail { n.initShim = hostPath; return n.changed() }
+
+// validateInitShim rejects the init shim together with an exec file, which nsjail would run instead of it.
+func (n *NsJail) validateInitShim() error {
+	if n.initShim != "" && (n.execFile != "" || n.executeFd) {
+		return errors.New("nsjail: the init shim cannot be combined with an exec file")
+	}
+	return nil
+}
+
+func (j *Jail) useInitShim(l *launch, hostPath string) error {
+	if len(l.command) == 0 {
+		return errors.New("nsjail: the init shim requires a command")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	fd := l.passFile(w)
+	l.closeAfterStart(w)
+	l.flags = append(l.flags, "-R", hostPath+":"+shimJailPath)
+	l.command = append([]string{shimJailPath, "-report-fd", strconv.Itoa(fd), "--"}, l.command...)
+
+	reports := make(chan *initshim.Report, 1)
+	go func() {
+		rep, _ := initshim.ReadReport(r)
+		reports <- rep
+	}()
+	j.onClose(func() {
+		// All writers are gone once nsjail exited, so this only waits for the decoder.
+		select {
+		case j.shimReport = <-reports:
+		case <-time.After(time.Second):
+		}
+		r.Close()
+	})
+	return nil
+}
+
+// holdCommand makes the init shim, set up by useInitShim or useAgent, wait with the command until fn
+// succeeded, e.g. to install firewall rules before the jailed code can send anything. fn runs once nsjail
+// started; if it fails, the jail is aborted without the command having run.
+func (j *Jail) holdCommand(l *launch, fn func() error) error {
+	if len(l.command) == 0 || l.command[0] != shimJailPath {
+		return errors.New("nsjail: holding the command requires the init shim")
+	}
+	if j.goAhead == nil {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		fd := l.passFile(r)
+		l.closeAfterStart(r)
+		l.command = slices.Insert(l.command, 1, "-wait-fd", strconv.Itoa(fd))
+		j.goAhead = w
+		j.onClose(func() { w.Close() })
+	}
+	j.held = append(j.held, fn)
+	return nil
+}
+
+// releaseCommand runs the functions of holdCommand in order and lets the shim run the command once all of
+// them succeeded.
+func (j *Jail) releaseCommand() {
+	for _, fn := range j.held {
+		if err := fn(); err != nil {
+			j.Abort(err)
+			return
+		}
+	}
+	if _, err := j.goAhead.Write([]byte{1}); err != nil {
+		j.Abort(fmt.Errorf("nsjail: releasing the command: %w", err))
+	}
+}
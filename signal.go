@@ -0,0 +1,101 @@
+package nsjail
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// errNotRunning is returned by the signal methods of a jail that exited or never ran.
+var errNotRunning = errors.New("nsjail: the jail is not running")
+
+// Signal sends sig to the jailed process, the one nsjail started. The signal is delivered directly rather
+// than through nsjail, which would kill the jail on most signals unless ForwardSignals is set. In a pid
+// namespace the jailed process is its init, which only receives signals it installed a handler for,
+// besides SIGKILL and SIGSTOP.
+func (j *Jail) Signal(sig syscall.Signal) error {
+	if !j.running() {
+		return errNotRunning
+	}
+	pid, err := j.jailPid(time.Second)
+	if err != nil {
+		return err
+	}
+	return signalPid(pid, sig)
+}
+
+// Pause stops all processes of the jail with SIGSTOP, leaving nsjail running. Its wall time limit keeps
+// counting while the jail is paused.
+func (j *Jail) Pause() error { return j.signalTree(sigStop) }
+
+// Resume continues the processes stopped by Pause with SIGCONT.
+func (j *Jail) Resume() error { return j.signalTree(sigCont) }
+
+// KillTree kills all processes of the jail, then nsjail, with SIGKILL. Unlike Abort it also reaches jailed
+// processes that nsjail would not kill itself, e.g. without a pid namespace, as long as they were not
+// reparented out of the jail's process tree. The result is not marked as aborted.
+func (j *Jail) KillTree() error {
+	if !j.running() {
+		return errNotRunning
+	}
+	err := j.signalTree(syscall.SIGKILL)
+	if kerr := j.proc.Signal(os.Kill); kerr != nil && !errors.Is(kerr, os.ErrProcessDone) {
+		err = errors.Join(err, kerr)
+	}
+	return err
+}
+
+func (j *Jail) running() bool {
+	if j.proc == nil {
+		return false
+	}
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// signalTree sends sig to every descendant of nsjail. The tree is walked again until no new process shows
+// up, so processes forked while it is signaled are not missed.
+func (j *Jail) signalTree(sig syscall.Signal) error {
+	if !j.running() {
+		return errNotRunning
+	}
+	var signaled []int
+	for {
+		pids, err := descendantPids(j.Pid())
+		if err != nil {
+			return err
+		}
+		fresh := false
+		for _, pid := range pids {
+			if slices.Contains(signaled, pid) {
+				continue
+			}
+			fresh = true
+			signaled = append(signaled, pid)
+			if err := signalPid(pid, sig); err != nil {
+				return err
+			}
+		}
+		if !fresh {
+			return nil
+		}
+	}
+}
+
+// signalPid sends sig to pid, ignoring processes that already exited.
+func signalPid(pid int, sig syscall.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+	if err := p.Signal(sig); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// slirpReadyTimeout bounds the wait for slirp4netns to configure the jail's interface.
+const slirpReadyTimeout = 10 * time.Second
+
+// SlirpConfig configures user-mode networking with slirp4netns.
+type SlirpConfig struct {
+	// Path of the slirp4netns binary. Defaults to slirp4netns in $PATH.
+	Path string
+	// MTU of the tap interface. Defaults to 65520.
+	MTU int
+	// CIDR is the network of the jail, with the gateway at .2 and the jail at .100. Defaults to 10.0.2.0/24.
+	CIDR netip.Prefix
+	// AllowHostLoopback lets the jail reach the host's loopback addresses through the gateway, which is
+	// disabled by default.
+	AllowHostLoopback bool
+	// IPv6 enables IPv6 (fd00::/64) besides IPv4.
+	IPv6 bool
+	// Sandbox confines slirp4netns itself with --enable-sandbox and --enable-seccomp.
+	Sandbox bool
+}
+
+// WithSlirp4netns gives the jail outbound connectivity without privileges: Start and Run attach slirp4netns
+// to the jail's network namespace once it started, which forwards the traffic of a tap interface inside
+// the jail through ordinary sockets of the host, and stop it once the jail exited. Until slirp4netns
+// configured the interface the jail has no egress. Requires the slirp4netns binary and a network and user
+// namespace (no DisableCloneNewNet or DisableCloneNewUser).
+func (n *NsJail) WithSlirp4netns(cfg SlirpConfig) *NsJail { n.slirp = &cfg; return n.changed() }
+
+// useSlirp arranges for slirp4netns to run alongside the jail.
+func (j *Jail) useSlirp(n *NsJail) error {
+	cfg := *n.slirp
+	if n.cloneNewNetDisabled || n.cloneNewUserDisabled {
+		return errors.New("nsjail: slirp4netns requires a network and user namespace")
+	}
+	if cfg.Path == "" {
+		cfg.Path = "slirp4netns"
+	}
+	path, err := exec.LookPath(cfg.Path)
+	if err != nil {
+		return fmt.Errorf("nsjail: slirp4netns: %w", err)
+	}
+	// slirp4netns exits once exitW is closed, also if it is only started after the jail exited.
+	exitR, exitW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	exited := make(chan struct{})
+	started := false
+	j.onClose(func() {
+		exitW.Close()
+		j.mu.Lock()
+		wait := started
+		j.mu.Unlock()
+		if wait {
+			select {
+			case <-exited:
+			case <-time.After(5 * time.Second):
+			}
+		}
+	})
+	j.onStarted(func() error {
+		defer exitR.Close()
+		pid, err := j.jailPid(5 * time.Second)
+		if err != nil {
+			return fmt.Errorf("nsjail: starting slirp4netns: %w", err)
+		}
+		readyR, readyW, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		defer readyR.Close()
+		cmd := exec.Command(path, append(cfg.args(), "--ready-fd=3", "--exit-fd=4", strconv.Itoa(pid), "tap0")...)
+		cmd.ExtraFiles = []*os.File{readyW, exitR}
+		err = cmd.Start()
+		readyW.Close()
+		if err != nil {
+			return fmt.Errorf("nsjail: starting slirp4netns: %w", err)
+		}
+		j.mu.Lock()
+		started = true
+		j.mu.Unlock()
+		go func() {
+			cmd.Wait()
+			close(exited)
+		}()
+
+		// slirp4netns writes "1" once the interface is configured and closes the pipe if it fails.
+		ready := make(chan error, 1)
+		go func() {
+			_, err := io.ReadFull(readyR, make([]byte, 1))
+			ready <- err
+		}()
+		select {
+		case err := <-ready:
+			if err != nil {
+				return errors.New("nsjail: slirp4netns failed to configure the jail's network")
+			}
+			return nil
+		case <-time.After(slirpReadyTimeout):
+			return errors.New("nsjail: slirp4netns did not configure the jail's network in time")
+		}
+	})
+	return nil
+}
+
+// args returns the options of slirp4netns.
+func (cfg *SlirpConfig) args() []string {
+	mtu := cfg.MTU
+	if mtu <= 0 {
+		mtu = 65520
+	}
+	args := []string{"--configure", "--mtu=" + strconv.Itoa(mtu)}
+	if cfg.CIDR.IsValid() {
+		args = append(args, "--cidr="+cfg.CIDR.Masked().String())
+	}
+	if !cfg.AllowHostLoopback {
+		args = append(args, "--disable-host-loopback")
+	}
+	if cfg.IPv6 {
+		args = append(args, "--enable-ipv6")
+	}
+	if cfg.Sandbox {
+		args = append(args, "--enable-sandbox", "--enable-seccomp")
+	}
+	return args
+}
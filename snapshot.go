@@ -0,0 +1,211 @@
+package nsjail
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Snapshot is a copy of files written in a Session, from which new sessions can be started. Only files
+// are captured: processes left running in the session are not part of it.
+type Snapshot struct {
+	n       *NsJail
+	archive []byte
+}
+
+// Snapshot copies the given directories of the session's jail, e.g. a tmpfs at /tmp or a writable home, into
+// a Snapshot. It waits for a running Exec to finish and blocks Exec meanwhile; processes the session left
+// running in the background may still change files while they are copied. Requires procfs access to the jail
+// (root or the same user).
+func (s *Session) Snapshot(paths ...string) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	root, err := s.jailRoot()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, p := range paths {
+		if err := archiveTree(tw, root, path.Clean("/"+p)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return &Snapshot{n: s.n, archive: buf.Bytes()}, nil
+}
+
+// Fork snapshots paths and starts a new session from the snapshot.
+func (s *Session) Fork(ctx context.Context, paths ...string) (*Session, error) {
+	snap, err := s.Snapshot(paths...)
+	if err != nil {
+		return nil, err
+	}
+	return snap.StartSession(ctx)
+}
+
+// ReadSnapshot reads a snapshot written by Snapshot.WriteTo. Sessions started from it use the configuration n.
+func ReadSnapshot(n *NsJail, r io.Reader) (*Snapshot, error) {
+	archive, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{n: n.Clone(), archive: archive}, nil
+}
+
+// WriteTo writes the snapshot's files as a tar archive.
+func (snap *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(snap.archive)
+	return int64(n), err
+}
+
+// StartSession starts a new session with the configuration of the snapshotted one and restores the
+// snapshot's files into it before returning.
+func (snap *Snapshot) StartSession(ctx context.Context) (*Session, error) {
+	s, err := snap.n.StartSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	root, err := s.jailRoot()
+	if err == nil {
+		err = restoreTree(tar.NewReader(bytes.NewReader(snap.archive)), root)
+	}
+	if err != nil {
+		s.j.Abort(err)
+		s.Close()
+		return nil, fmt.Errorf("nsjail: restoring snapshot: %w", err)
+	}
+	return s, nil
+}
+
+// jailRoot returns the root directory of the session's jail as seen from the host.
+func (s *Session) jailRoot() (string, error) {
+	pid, err := s.j.jailPid(5 * time.Second)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("/proc/%d/root", pid), nil
+}
+
+// checkNoSymlinks fails if any component of rel below root is a symlink. Symlinks under /proc/<pid>/root
+// resolve against the host's root, so following one planted in the jail would escape it.
+func checkNoSymlinks(root, rel string) error {
+	p := root
+	for _, part := range strings.Split(strings.Trim(rel, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		p = filepath.Join(p, part)
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("nsjail: %s is a symlink", strings.TrimPrefix(p, root))
+		}
+	}
+	return nil
+}
+
+// archiveTree adds dir inside the jail rooted at root to tw. Symlinks are stored, never followed.
+func archiveTree(tw *tar.Writer, root, dir string) error {
+	if err := checkNoSymlinks(root, dir); err != nil {
+		return err
+	}
+	return filepath.WalkDir(filepath.Join(root, dir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			// Sockets and other special files cannot be archived.
+			return nil
+		}
+		hdr.Name = strings.TrimPrefix(p, root)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.OpenFile(p, os.O_RDONLY|oNoFollow, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.CopyN(tw, f, hdr.Size)
+		return err
+	})
+}
+
+// restoreTree extracts tr into the jail rooted at root.
+func restoreTree(tr *tar.Reader, root string) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + hdr.Name)
+		if err := checkNoSymlinks(root, path.Dir(name)); err != nil {
+			return err
+		}
+		target := filepath.Join(root, name)
+		mode := fs.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.Mkdir(target, mode); err != nil && !errors.Is(err, fs.ErrExist) {
+				return err
+			}
+		case tar.TypeReg:
+			f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|oNoFollow, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			continue
+		}
+		if os.Geteuid() == 0 {
+			os.Lchown(target, hdr.Uid, hdr.Gid)
+		}
+		if hdr.Typeflag != tar.TypeSymlink {
+			os.Chmod(target, mode)
+			os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+		}
+	}
+}
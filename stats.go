@@ -0,0 +1,99 @@
+package nsjail
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoCgroup is returned by Jail.Stats when the jailed process has no cgroup of its own. nsjail only creates
+// one when a cgroup limit is set, e.g. with WithCgroupMemMax, WithCgroupPidsMax or WithCgroupCpuMsPerSec.
+var ErrNoCgroup = errors.New("nsjail: jail has no cgroup")
+
+// ResourceUsage is the resource usage of a jail, read from its cgroup. Fields the kernel or the cgroup
+// controllers in use do not provide are zero.
+type ResourceUsage struct {
+	// MemoryCurrent and MemoryPeak are in bytes. Without kernel support for memory.peak, MemoryPeak is
+	// the highest sampled value.
+	MemoryCurrent uint64
+	MemoryPeak    uint64
+	// CPU is the total CPU time, User and System its split.
+	CPU       time.Duration
+	CPUUser   time.Duration
+	CPUSystem time.Duration
+	// PidsCurrent and PidsPeak count tasks. Without kernel support for pids.peak, PidsPeak is the highest
+	// sampled value.
+	PidsCurrent uint64
+	PidsPeak    uint64
+	// OOMKills counts processes killed by the OOM killer in the cgroup (cgroup v2 only).
+	OOMKills uint64
+	// Sampled is when the values were read.
+	Sampled time.Time
+}
+
+// usageInterval is how often the cgroup of a jail with cgroup limits is sampled.
+const usageInterval = 100 * time.Millisecond
+
+// Stats returns the resource usage of the jailed process. While the jail runs it reads its cgroup; once
+// nsjail removed the cgroup, it returns the last sample, which may miss the final moments of CPU time.
+// In ModeListenTCP it reports the oldest running connection.
+func (j *Jail) Stats() (*ResourceUsage, error) {
+	select {
+	case <-j.done:
+	default:
+		j.sampleUsage()
+	}
+	return j.lastUsage()
+}
+
+func (j *Jail) lastUsage() (*ResourceUsage, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.usage == nil {
+		return nil, ErrNoCgroup
+	}
+	u := *j.usage
+	return &u, nil
+}
+
+// sampleUsage reads the cgroup of the jailed process and merges it into the last sample.
+func (j *Jail) sampleUsage() error {
+	pids, err := childPids(j.Pid())
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		return ErrNoCgroup
+	}
+	u, err := readCgroupUsage(pids[0])
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	if prev := j.usage; prev != nil {
+		u.MemoryPeak = max(u.MemoryPeak, prev.MemoryPeak)
+		u.PidsPeak = max(u.PidsPeak, prev.PidsPeak)
+	}
+	j.usage = u
+	j.mu.Unlock()
+	return nil
+}
+
+// sampleUsageLoop samples the jail's cgroup until it exits, so Stats and Result.Usage have values after it.
+func (j *Jail) sampleUsageLoop() {
+	t := time.NewTicker(usageInterval)
+	defer t.Stop()
+	for {
+		j.sampleUsage()
+		select {
+		case <-j.done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// hasCgroupLimits reports whether nsjail creates a cgroup for the jailed process.
+func (n *NsJail) hasCgroupLimits() bool {
+	return n.cgroupMemMax > 0 || n.cgroupMemMemswMax > 0 || n.cgroupPidsMax > 0 || n.cgroupCpuMsPerSec > 0 ||
+		n.cgroupV2 != nil
+}
@@ -0,0 +1,112 @@
+package nsjail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrStdinDeadline is recorded in Result.Violations when the jail did not consume the input set with
+	// WithStdinBytes or WithStdinReader within the stdin deadline. Its stdin is closed at that point.
+	ErrStdinDeadline = errors.New("nsjail: stdin was not consumed before the deadline")
+	// ErrStdinLimit is recorded in Result.Violations when the reader passed to WithStdinReader held more
+	// than its limit. Only the first limit bytes are fed.
+	ErrStdinLimit = errors.New("nsjail: stdin exceeds its size limit")
+)
+
+// defaultStdinDeadline is the stdin deadline used unless WithStdinDeadline sets another.
+const defaultStdinDeadline = 10 * time.Second
+
+// stdinFeed is the input set by WithStdinBytes or WithStdinReader.
+type stdinFeed struct {
+	data  []byte
+	r     io.Reader
+	limit int64
+	used  atomic.Bool // whether r was handed out, see reader
+}
+
+// reader returns the input to feed, a fresh one for every jail fed from bytes. A reader of
+// WithStdinReader is only handed out once, as a second jail, e.g. one started from a clone, would read
+// what the first one left.
+func (f *stdinFeed) reader() (io.Reader, error) {
+	if f.r == nil {
+		return bytes.NewReader(f.data), nil
+	}
+	if f.used.Swap(true) {
+		return nil, errors.New("nsjail: the reader of WithStdinReader already fed a jail")
+	}
+	return io.LimitReader(f.r, f.limit), nil
+}
+
+// WithStdinBytes feeds b to the standard input of the jail and closes it afterwards. It replaces the stdin
+// set with WithStdio. Start and Run give the jail the stdin deadline to consume b, see WithStdinDeadline,
+// so a program that never reads its input cannot block the caller.
+func (n *NsJail) WithStdinBytes(b []byte) *NsJail {
+	n.stdinFeed, n.stdin = &stdinFeed{data: b}, nil
+	return n.changed()
+}
+
+// WithStdinReader feeds up to limit bytes read from r to the standard input of the jail like
+// WithStdinBytes. r can only feed one jail, so Exec, Start and Run fail for a second jail sharing it
+// through Clone. If r holds more, ErrStdinLimit is recorded in Result.Violations.
+func (n *NsJail) WithStdinReader(r io.Reader, limit int64) *NsJail {
+	n.stdinFeed, n.stdin = &stdinFeed{r: r, limit: max(limit, 0)}, nil
+	return n.changed()
+}
+
+// WithStdinDeadline sets how long the input of WithStdinBytes or WithStdinReader may take to be consumed
+// after the jail started. When it expires, stdin is closed and ErrStdinDeadline is recorded in
+// Result.Violations. Defaults to 10 seconds; a negative d waits as long as the jail runs. Exec cannot
+// enforce it and bounds with exec.Cmd.WaitDelay how long Wait waits for the input instead.
+func (n *NsJail) WithStdinDeadline(d time.Duration) *NsJail { n.stdinDeadline = d; return n.changed() }
+
+// stdinDeadlineDuration returns the effective stdin deadline, or 0 for none.
+func (n *NsJail) stdinDeadlineDuration() time.Duration {
+	switch {
+	case n.stdinDeadline > 0:
+		return n.stdinDeadline
+	case n.stdinDeadline == 0:
+		return defaultStdinDeadline
+	}
+	return 0
+}
+
+// feedStdin returns a pipe that becomes the stdin of nsjail and feeds it once the jail started.
+func (j *Jail) feedStdin(l *launch, feed *stdinFeed, deadline time.Duration) (*os.File, error) {
+	src, err := feed.reader()
+	if err != nil {
+		return nil, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	l.closeAfterStart(r)
+	j.onClose(func() { w.Close() })
+	j.onStarted(func() error {
+		defer w.Close()
+		if deadline > 0 {
+			w.SetWriteDeadline(time.Now().Add(deadline))
+		}
+		written, err := io.Copy(w, src)
+		switch {
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			j.flag(fmt.Errorf("%w: %d bytes were written in %v", ErrStdinDeadline, written, deadline))
+		case err != nil && !errors.Is(err, syscall.EPIPE) && !errors.Is(err, os.ErrClosed):
+			// A failing reader is the caller's problem, not the jail's.
+			j.Abort(fmt.Errorf("nsjail: reading stdin: %w", err))
+		case feed.r != nil && written == feed.limit:
+			if k, _ := io.ReadFull(feed.r, make([]byte, 1)); k > 0 {
+				j.flag(fmt.Errorf("%w of %d bytes", ErrStdinLimit, feed.limit))
+			}
+		}
+		return nil
+	})
+	return r, nil
+}
@@ -0,0 +1,211 @@
+package nsjail
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// StreamPolicy decides what a StreamWriter does with output when its buffer is full.
+type StreamPolicy int
+
+const (
+	// DropNewest discards output that does not fit into the buffer.
+	DropNewest StreamPolicy = iota
+	// DropOldest discards the oldest buffered output to make room, so the consumer sees the latest output.
+	DropOldest
+	// Pause stops reading the jail's output until there is room, which blocks the jailed process on its
+	// next write. With StreamConfig.PauseTimeout, output is discarded once a wait exceeds it, until the
+	// consumer takes output again.
+	Pause
+)
+
+// StreamConfig configures the buffering between a jail and a slow output consumer.
+type StreamConfig struct {
+	// BufferSize is the number of bytes buffered for the consumer. Defaults to 64 KiB.
+	BufferSize int
+	// Policy decides what happens to output when the buffer is full.
+	Policy StreamPolicy
+	// PauseTimeout bounds how long the Pause policy blocks a write. Zero blocks until there is room.
+	PauseTimeout time.Duration
+	// FlushTimeout bounds how long buffered output is still delivered after the jail exited. Output not
+	// delivered by then is dropped. Defaults to 5 seconds.
+	FlushTimeout time.Duration
+}
+
+const (
+	defaultStreamBuffer = 64 << 10
+	defaultFlushTimeout = 5 * time.Second
+)
+
+// StreamWriter forwards output to a consumer from a bounded buffer, so a slow or stalled consumer can
+// neither block the writer, except under the Pause policy, nor make the buffer grow without bound.
+type StreamWriter struct {
+	w   io.Writer
+	cfg StreamConfig
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     []byte
+	dropped int64
+	err     error // from w, after which all output is dropped
+	stalled bool  // a paused write timed out, and the consumer has not taken output since
+	closed  bool
+	done    chan struct{}
+}
+
+// NewStreamWriter returns a StreamWriter forwarding to w. It must be closed to stop its goroutine.
+func NewStreamWriter(w io.Writer, cfg StreamConfig) *StreamWriter {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultStreamBuffer
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = defaultFlushTimeout
+	}
+	s := &StreamWriter{w: w, cfg: cfg, done: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	go s.forward()
+	return s
+}
+
+// Write buffers p for the consumer and always reports success. Output that the policy discards is
+// counted by Dropped.
+func (s *StreamWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(p)
+	if s.err != nil || s.closed {
+		s.dropped += int64(n)
+		return n, nil
+	}
+	switch s.cfg.Policy {
+	case DropOldest:
+		if len(p) > s.cfg.BufferSize {
+			s.dropped += int64(len(p) - s.cfg.BufferSize)
+			p = p[len(p)-s.cfg.BufferSize:]
+		}
+		if over := len(s.buf) + len(p) - s.cfg.BufferSize; over > 0 {
+			s.dropped += int64(over)
+			s.buf = append(s.buf[:0], s.buf[over:]...)
+		}
+		s.buf = append(s.buf, p...)
+	case Pause:
+		var deadline time.Time
+		if s.cfg.PauseTimeout > 0 {
+			deadline = time.Now().Add(s.cfg.PauseTimeout)
+			t := time.AfterFunc(s.cfg.PauseTimeout, func() {
+				s.mu.Lock()
+				s.cond.Broadcast()
+				s.mu.Unlock()
+			})
+			defer t.Stop()
+		}
+		for len(p) > 0 {
+			room := s.cfg.BufferSize - len(s.buf)
+			if room > 0 {
+				k := min(room, len(p))
+				s.buf = append(s.buf, p[:k]...)
+				p = p[k:]
+				s.cond.Broadcast()
+				continue
+			}
+			if s.err != nil || s.closed || s.stalled {
+				s.dropped += int64(len(p))
+				break
+			}
+			if !deadline.IsZero() && !time.Now().Before(deadline) {
+				s.stalled = true
+				continue
+			}
+			s.cond.Wait()
+		}
+	default:
+		k := min(s.cfg.BufferSize-len(s.buf), len(p))
+		s.buf = append(s.buf, p[:k]...)
+		s.dropped += int64(len(p) - k)
+	}
+	s.cond.Broadcast()
+	return n, nil
+}
+
+// forward delivers buffered output to the consumer until the writer is closed and drained.
+func (s *StreamWriter) forward() {
+	defer close(s.done)
+	chunk := make([]byte, 0, s.cfg.BufferSize)
+	for {
+		s.mu.Lock()
+		for len(s.buf) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.buf) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		chunk = append(chunk[:0], s.buf...)
+		s.buf = s.buf[:0]
+		s.stalled = false
+		s.cond.Broadcast()
+		s.mu.Unlock()
+
+		if _, err := s.w.Write(chunk); err != nil {
+			s.mu.Lock()
+			s.err = err
+			s.dropped += int64(len(s.buf))
+			s.buf = nil
+			s.cond.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// Close stops accepting output and waits up to the flush timeout for buffered output to be delivered.
+// Output still buffered after that is dropped. It returns the error of the consumer, if any.
+func (s *StreamWriter) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+	case <-time.After(s.cfg.FlushTimeout):
+		s.mu.Lock()
+		s.dropped += int64(len(s.buf))
+		s.buf = nil
+		s.mu.Unlock()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Dropped returns the number of bytes discarded so far.
+func (s *StreamWriter) Dropped() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// WithStreamBuffering puts a StreamWriter configured by cfg in front of the stdout and stderr writers set
+// with WithStdio, for consumers that may be slow, such as network clients. Discarded bytes are reported
+// in Result.StdoutDropped and Result.StderrDropped.
+func (n *NsJail) WithStreamBuffering(cfg StreamConfig) *NsJail {
+	n.streamBuffering = &cfg
+	return n.changed()
+}
+
+// bufferStreams wraps stdout and stderr as configured by WithStreamBuffering. The buffers are flushed
+// when the jail is closed.
+func (j *Jail) bufferStreams(cfg StreamConfig, stdout, stderr io.Writer) (io.Writer, io.Writer) {
+	wrap := func(w io.Writer) (io.Writer, *StreamWriter) {
+		if w == nil {
+			return nil, nil
+		}
+		s := NewStreamWriter(w, cfg)
+		j.onClose(func() { s.Close() })
+		return s, s
+	}
+	stdout, j.stdoutStream = wrap(stdout)
+	stderr, j.stderrStream = wrap(stderr)
+	return stdout, stderr
+}
@@ -0,0 +1,117 @@
+package nsjail
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRestartLimit is returned by Supervisor.Run when the jail should be restarted once more than
+// SupervisorConfig.MaxRestarts allows.
+var ErrRestartLimit = errors.New("nsjail: restart limit reached")
+
+// RestartOnFailure restarts a jail that did not exit with code 0, whether it exited with another code,
+// was killed or hit a limit. A jail that failed to start is not restarted, as that is usually a
+// configuration error that restarting does not fix.
+func RestartOnFailure(r *Result, err error) bool {
+	return err == nil && (r.Status != StatusExited || r.NormalizedCode != 0)
+}
+
+// RestartAlways restarts a jail however it ended, also when it failed to start.
+func RestartAlways(*Result, error) bool { return true }
+
+// SupervisorConfig configures a Supervisor.
+type SupervisorConfig struct {
+	// RestartIf decides from the result of a run, or the error it failed with, whether the jail is
+	// restarted. Defaults to RestartOnFailure.
+	RestartIf func(*Result, error) bool
+	// MaxRestarts is the number of times the jail is restarted. Zero means no limit.
+	MaxRestarts int
+	// InitialBackoff is the delay before the first restart. Defaults to 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay, which is multiplied by Multiplier with every restart. Defaults to 1m.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay between restarts. Defaults to 2.
+	Multiplier float64
+	// ResetAfter resets the delay to InitialBackoff after a run that lasted at least that long, so a jail
+	// that failed after serving for a while is restarted promptly. Zero means the delay is never reset.
+	ResetAfter time.Duration
+	// OnRestart is called before waiting delay to restart the jail, with the outcome of the run that ended.
+	OnRestart func(restart int, r *Result, err error, delay time.Duration)
+}
+
+// Supervisor runs a jail again whenever it ends, with exponential backoff between runs, as a Go-side
+// alternative to ModeRerun. Every run starts the jail afresh, so ephemeral resources such as workspaces,
+// overlays and cgroups are rebuilt between runs, and each run is logged, limited and reported as usual.
+type Supervisor struct {
+	jail *NsJail
+	cfg  SupervisorConfig
+
+	mu       sync.Mutex
+	restarts int
+}
+
+// NewSupervisor returns a supervisor of n configured by cfg. n must not be changed while Run runs.
+func NewSupervisor(n *NsJail, cfg SupervisorConfig) *Supervisor {
+	if cfg.RestartIf == nil {
+		cfg.RestartIf = RestartOnFailure
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+	if cfg.Multiplier < 1 {
+		cfg.Multiplier = 2
+	}
+	return &Supervisor{jail: n, cfg: cfg}
+}
+
+// Run runs the jail until RestartIf declines to restart it and returns the result of the last run. It
+// returns ErrRestartLimit with the last result if the jail should be restarted more than MaxRestarts
+// times, and ctx.Err() once ctx is done, which also kills the running jail.
+func (s *Supervisor) Run(ctx context.Context) (*Result, error) {
+	delay := min(s.cfg.InitialBackoff, s.cfg.MaxBackoff)
+	for restart := 1; ; restart++ {
+		r, err := s.jail.Run(ctx)
+		if ctx.Err() != nil {
+			return r, ctx.Err()
+		}
+		if !s.cfg.RestartIf(r, err) {
+			return r, err
+		}
+		if s.cfg.MaxRestarts > 0 && restart > s.cfg.MaxRestarts {
+			if err != nil {
+				return r, errors.Join(ErrRestartLimit, err)
+			}
+			return r, ErrRestartLimit
+		}
+		if s.cfg.ResetAfter > 0 && r != nil && r.Duration >= s.cfg.ResetAfter {
+			delay = min(s.cfg.InitialBackoff, s.cfg.MaxBackoff)
+		}
+		s.jail.log().Warn("nsjail: restarting jail", "restart", restart, "delay", delay, "err", err)
+		if s.cfg.OnRestart != nil {
+			s.cfg.OnRestart(restart, r, err, delay)
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return r, ctx.Err()
+		case <-t.C:
+		}
+		s.mu.Lock()
+		s.restarts = restart
+		s.mu.Unlock()
+		delay = min(time.Duration(float64(delay)*s.cfg.Multiplier), s.cfg.MaxBackoff)
+	}
+}
+
+// Restarts returns how many times Run restarted the jail so far.
+func (s *Supervisor) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
@@ -0,0 +1,72 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	nsjail "github.com/OptimusePrime/nsjail-go"
+)
+
+// Notify sends state, newline separated assignments such as "READY=1" or "STATUS=...", to the service
+// manager with the sd_notify protocol. It reports whether it was sent, which it is not when the process
+// does not run as a service of Type=notify, so it is safe to call unconditionally.
+func Notify(state string) (bool, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return false, nil
+	}
+	// A leading @ stands for the abstract socket namespace.
+	if path[0] == '@' {
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("systemd: notify: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("systemd: notify: %w", err)
+	}
+	return true, nil
+}
+
+// Start starts n like NsJail.Start and tells the service manager that the service is ready once the jail
+// runs, with Daemonize once the daemon is ready (see Jail.WaitReady), and otherwise that it is stopping once
+// the jail finished. Without a service manager to tell it only starts n. If the jail fails to start the service
+// manager is told nothing, so its start timeout handles the failure.
+func Start(ctx context.Context, n *nsjail.NsJail) (*nsjail.Jail, error) {
+	j, err := n.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return j, nil
+	}
+	status := "STATUS=jail running"
+	pid, err := j.WaitReady(ctx)
+	daemon := err == nil
+	if daemon {
+		status = fmt.Sprintf("STATUS=jail daemon running as pid %d", pid)
+	} else if !errors.Is(err, nsjail.ErrNotDaemon) {
+		j.Abort(err)
+		j.Wait()
+		return nil, err
+	}
+	if _, err := Notify("READY=1\n" + status); err != nil {
+		j.Abort(err)
+		j.Wait()
+		return nil, err
+	}
+	if daemon {
+		// nsjail exits once it forked the daemon, which outlives this process.
+		return j, nil
+	}
+	go func() {
+		<-j.Done()
+		Notify("STOPPING=1\nSTATUS=jail finished")
+	}()
+	return j, nil
+}
@@ -0,0 +1,179 @@
+// Package systemd runs jails configured with nsjail-go under systemd supervision. Render turns a jail into
+// a service unit running nsjail directly:
+//
+//	unit, err := systemd.Render(nsjail.NewHardened("/usr/bin/myservice").WithMode(nsjail.ModeListenTCP),
+//		systemd.Unit{Description: "myservice in nsjail", Hardening: true})
+//	os.WriteFile("/etc/systemd/system/myservice.service", unit, 0o644)
+//
+// A Go service that itself runs as a systemd service of Type=notify instead starts its jail with Start,
+// which tells systemd once the jail runs.
+package systemd
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	nsjail "github.com/OptimusePrime/nsjail-go"
+)
+
+// Unit holds the directives of a rendered unit that are not derived from the jail.
+type Unit struct {
+	// Description describes the unit (Description=). Defaults to the jailed command.
+	Description string
+	// Documentation lists URIs documenting the unit (Documentation=).
+	Documentation []string
+	// After, Wants and Requires order the unit and pull in others (After=, Wants=, Requires=). Units
+	// reaching the network usually want "network-online.target".
+	After    []string
+	Wants    []string
+	Requires []string
+	// Restart is when systemd restarts nsjail (Restart=). Defaults to "on-failure".
+	Restart string
+	// RestartSec is how long systemd waits before restarting nsjail (RestartSec=), unless 0.
+	RestartSec time.Duration
+	// TimeoutStopSec is how long systemd waits for nsjail to exit after SIGTERM before it sends SIGKILL
+	// (TimeoutStopSec=), unless 0.
+	TimeoutStopSec time.Duration
+	// Hardening confines nsjail itself with directives that leave it working: it may not load kernel
+	// modules, read the kernel log, set the clock, use realtime scheduling or switch to non-native
+	// syscall ABIs (ProtectKernelModules=, ProtectKernelLogs=, ProtectClock=, RestrictRealtime=,
+	// SystemCallArchitectures=). The seccomp filters these install are inherited by the jailed process.
+	Hardening bool
+	// Service holds further lines of the [Service] section, e.g. "MemoryMax=2G".
+	Service []string
+	// WantedBy lists the targets the unit is enabled for (WantedBy=). Defaults to "multi-user.target".
+	WantedBy []string
+}
+
+// Render returns a service unit running the nsjail command line of n. nsjail is the main process of the
+// service, so systemd restarts and stops it; with Daemonize the unit is Type=forking, and systemd takes the
+// daemon as the main process. Delegate= hands the cgroups below the service to nsjail, which creates the
+// cgroups of the jails there, and KillMode=mixed sends SIGTERM to nsjail alone, which kills its jails.
+//
+// Only the command line is rendered, so features carried out by this library around nsjail, such as log
+// handlers, workspaces, overlays, stdin feeds and extra files, are not part of the unit. A relative path
+// to the nsjail binary, see NsJail.WithPath, is looked up in $PATH.
+func Render(n *nsjail.NsJail, u Unit) ([]byte, error) {
+	args, err := n.Args()
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(args[0]) {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("systemd: %w", err)
+		}
+		if args[0], err = filepath.Abs(path); err != nil {
+			return nil, fmt.Errorf("systemd: %w", err)
+		}
+	}
+	// The options of nsjail end at "--", which is missing with WithConfigFile and no command.
+	opts, cmd := args[1:], []string(nil)
+	if i := slices.Index(opts, "--"); i >= 0 {
+		opts, cmd = opts[:i], opts[i+1:]
+	}
+	daemon := slices.Contains(opts, "-d")
+	for _, line := range u.Service {
+		if strings.ContainsAny(line, "\n\r") {
+			return nil, fmt.Errorf("systemd: service directive %q spans lines", line)
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("[Unit]\n")
+	desc := cmp.Or(u.Description, strings.TrimSpace("nsjail "+strings.Join(cmd, " ")))
+	directive(&b, "Description", []string{strings.ReplaceAll(desc, "%", "%%")})
+	directive(&b, "Documentation", u.Documentation)
+	directive(&b, "After", u.After)
+	directive(&b, "Wants", u.Wants)
+	directive(&b, "Requires", u.Requires)
+
+	b.WriteString("\n[Service]\n")
+	if daemon {
+		b.WriteString("Type=forking\n")
+	} else {
+		b.WriteString("Type=exec\n")
+	}
+	b.WriteString("ExecStart=" + execLine(args) + "\n")
+	b.WriteString("Restart=" + cmp.Or(u.Restart, "on-failure") + "\n")
+	if u.RestartSec > 0 {
+		b.WriteString("RestartSec=" + seconds(u.RestartSec) + "\n")
+	}
+	if u.TimeoutStopSec > 0 {
+		b.WriteString("TimeoutStopSec=" + seconds(u.TimeoutStopSec) + "\n")
+	}
+	b.WriteString("KillMode=mixed\n")
+	b.WriteString("Delegate=yes\n")
+	if u.Hardening {
+		b.WriteString("ProtectKernelModules=yes\n")
+		b.WriteString("ProtectKernelLogs=yes\n")
+		b.WriteString("ProtectClock=yes\n")
+		b.WriteString("RestrictRealtime=yes\n")
+		b.WriteString("SystemCallArchitectures=native\n")
+	}
+	for _, line := range u.Service {
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString("\n[Install]\n")
+	wantedBy := u.WantedBy
+	if wantedBy == nil {
+		wantedBy = []string{"multi-user.target"}
+	}
+	directive(&b, "WantedBy", wantedBy)
+	return b.Bytes(), nil
+}
+
+// directive writes a directive listing values, unless there are none.
+func directive(b *bytes.Buffer, name string, values []string) {
+	if len(values) > 0 {
+		b.WriteString(name + "=" + strings.ReplaceAll(strings.Join(values, " "), "\n", " ") + "\n")
+	}
+}
+
+// execLine quotes args for ExecStart=, which splits words like a shell, expands specifiers introduced by
+// % and environment variables introduced by $.
+func execLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_./:=,+@-") == "" {
+		return arg
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range arg {
+		switch r {
+		case '"', '\\':
+			b.WriteString(`\` + string(r))
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '%':
+			b.WriteString("%%")
+		case '$':
+			b.WriteString("$$")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// seconds formats d as a systemd time span in seconds.
+func seconds(d time.Duration) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.3f", d.Seconds()), ".000")
+}
@@ -0,0 +1,261 @@
+package nsjail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TemplateCacheConfig configures a TemplateCache.
+type TemplateCacheConfig struct {
+	// Dir holds the templates. It is created if needed; templates found in it are reused.
+	Dir string
+	// MaxSize is the total size in bytes of the templates beyond which the least recently used ones are
+	// removed. Zero means no limit.
+	MaxSize int64
+	// MaxEntries is the number of templates beyond which the least recently used ones are removed. Zero
+	// means no limit.
+	MaxEntries int
+}
+
+// TemplateCache keeps expensive jail setup on disk, so it is done once rather than per jail: a template
+// is a directory built once per key, e.g. an extracted rootfs or a tree of binaries and their libraries,
+// that jails mount read-only with WithTemplate. Templates in use are leased and never evicted; leases are
+// only tracked within the process, so a directory must not be shared by caches evicting concurrently.
+// It is safe for concurrent use.
+//
+//	t, err := cache.Get("python-3.12@"+digest, func(dir string) error {
+//		_, err := nsjail.NewRootfs(nsjail.RootfsSpec{Dir: dir, Files: files, Copy: true})
+//		return err
+//	})
+//	defer t.Release()
+//	res, err := nsjail.New("/usr/bin/python3", "main.py").WithTemplate(t, "/").Run(ctx)
+type TemplateCache struct {
+	cfg TemplateCacheConfig
+
+	mu       sync.Mutex
+	entries  map[string]*templateEntry
+	building map[string]*templateBuild
+}
+
+type templateEntry struct {
+	id      string
+	size    int64
+	lastUse time.Time
+	leases  int
+}
+
+type templateBuild struct {
+	done chan struct{}
+	err  error
+}
+
+// Template is a leased template of a TemplateCache.
+type Template struct {
+	cache *TemplateCache
+	entry *templateEntry
+	once  sync.Once
+}
+
+// NewTemplateCache opens the cache in cfg.Dir.
+func NewTemplateCache(cfg TemplateCacheConfig) (*TemplateCache, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("nsjail: a template cache needs a directory")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &TemplateCache{cfg: cfg, entries: make(map[string]*templateEntry), building: make(map[string]*templateBuild)}
+	des, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, de := range des {
+		// Unfinished builds are named <id>.tmp-*.
+		if !de.IsDir() || strings.Contains(de.Name(), ".") {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		size, err := treeSize(c.dir(de.Name()))
+		if err != nil {
+			continue
+		}
+		c.entries[de.Name()] = &templateEntry{id: de.Name(), size: size, lastUse: info.ModTime()}
+	}
+	return c, nil
+}
+
+// Get returns the template for key, calling build with an empty directory to create it if it is not
+// cached. Concurrent calls for the same key share one build; a failed build is not cached. The template
+// stays cached at least until it is released.
+func (c *TemplateCache) Get(key string, build func(dir string) error) (*Template, error) {
+	id := templateID(key)
+	for {
+		c.mu.Lock()
+		if e, ok := c.entries[id]; ok {
+			e.leases++
+			e.lastUse = time.Now()
+			c.mu.Unlock()
+			// Persist the last use for caches opened later; the time is only a hint.
+			os.Chtimes(c.dir(id), time.Time{}, e.lastUse)
+			return &Template{cache: c, entry: e}, nil
+		}
+		if b, ok := c.building[id]; ok {
+			c.mu.Unlock()
+			<-b.done
+			if b.err != nil {
+				return nil, b.err
+			}
+			continue
+		}
+		b := &templateBuild{done: make(chan struct{})}
+		c.building[id] = b
+		c.mu.Unlock()
+
+		e, err := c.build(id, key, build)
+		c.mu.Lock()
+		delete(c.building, id)
+		b.err = err
+		close(b.done)
+		if err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+		e.leases++
+		c.entries[id] = e
+		c.evictLocked()
+		c.mu.Unlock()
+		return &Template{cache: c, entry: e}, nil
+	}
+}
+
+// build creates the template id in a temporary directory and renames it into place.
+func (c *TemplateCache) build(id, key string, build func(dir string) error) (*templateEntry, error) {
+	tmp, err := os.MkdirTemp(c.cfg.Dir, id+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	// The tree must be traversable by the jail's user.
+	os.Chmod(tmp, 0o755)
+	if err := build(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return nil, fmt.Errorf("nsjail: building template %q: %w", key, err)
+	}
+	size, err := treeSize(tmp)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, c.dir(id)); err != nil {
+		os.RemoveAll(tmp)
+		// Another process sharing the directory may have built it first.
+		if size, serr := treeSize(c.dir(id)); serr == nil {
+			return &templateEntry{id: id, size: size, lastUse: time.Now()}, nil
+		}
+		return nil, err
+	}
+	return &templateEntry{id: id, size: size, lastUse: time.Now()}, nil
+}
+
+// evictLocked removes unleased templates, least recently used first, until the cache is within its
+// limits. c.mu must be held.
+func (c *TemplateCache) evictLocked() {
+	var total int64
+	var idle []*templateEntry
+	for _, e := range c.entries {
+		total += e.size
+		if e.leases == 0 {
+			idle = append(idle, e)
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i].lastUse.Before(idle[j].lastUse) })
+	count := len(c.entries)
+	for _, e := range idle {
+		if (c.cfg.MaxSize <= 0 || total <= c.cfg.MaxSize) && (c.cfg.MaxEntries <= 0 || count <= c.cfg.MaxEntries) {
+			return
+		}
+		delete(c.entries, e.id)
+		os.RemoveAll(c.dir(e.id))
+		total -= e.size
+		count--
+	}
+}
+
+// Remove removes the template for key unless it is leased, and reports whether it was removed.
+func (c *TemplateCache) Remove(key string) bool {
+	id := templateID(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok || e.leases > 0 {
+		return false
+	}
+	delete(c.entries, id)
+	os.RemoveAll(c.dir(id))
+	return true
+}
+
+func (c *TemplateCache) dir(id string) string { return filepath.Join(c.cfg.Dir, id) }
+
+// templateID is the directory name of the template for key.
+func templateID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Dir returns the directory of the template on the host. It must not be modified.
+func (t *Template) Dir() string { return t.cache.dir(t.entry.id) }
+
+// Size returns the size of the files of the template in bytes.
+func (t *Template) Size() int64 { return t.entry.size }
+
+// Release returns the lease of the template, after which it may be evicted. Jails using it must have
+// exited. Releasing a template more than once has no effect.
+func (t *Template) Release() {
+	t.once.Do(func() {
+		c := t.cache
+		c.mu.Lock()
+		t.entry.leases--
+		c.evictLocked()
+		c.mu.Unlock()
+	})
+}
+
+// WithTemplate mounts the template t read-only at dst in the jail (-R), or uses it as the root of the jail
+// (-c) if dst is "/".
+func (n *NsJail) WithTemplate(t *Template, dst string) *NsJail {
+	if dst == "/" {
+		return n.WithChroot(t.Dir())
+	}
+	return n.AddBindRO(t.Dir(), dst)
+}
+
+// treeSize sums the sizes of the regular files below dir.
+func treeSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,41 @@
+package nsjail
+
+import "time"
+
+// Timing splits the wall time of a run into the overhead of the wrapper and the sandbox and the
+// runtime of the jailed program itself, so sandbox setup is not charged against a program's time limit.
+type Timing struct {
+	// WrapperSetup is the time spent in Start before the nsjail process was spawned.
+	WrapperSetup time.Duration
+	// Spawn is the part of WrapperSetup spent starting the nsjail process.
+	Spawn time.Duration
+	// SandboxSetup is the time nsjail took to set up the jail before executing the program.
+	SandboxSetup time.Duration
+	// Program is the runtime of the jailed program.
+	Program time.Duration
+	// Teardown is the time between the program exiting and nsjail being reaped.
+	Teardown time.Duration
+	// Cleanup is the time the wrapper spent releasing the resources of the jail after nsjail was reaped,
+	// e.g. removing cgroups and overlays and draining the log.
+	Cleanup time.Duration
+	// Exact reports whether the split was measured by the init shim (see WithInitShim). Otherwise
+	// SandboxSetup and Teardown are 0 and Program is the lifetime of the nsjail process.
+	Exact bool
+}
+
+func (j *Jail) timing(exited time.Time) Timing {
+	t := Timing{
+		WrapperSetup: j.started.Sub(j.startCalled),
+		Spawn:        j.started.Sub(j.spawned),
+		Program:      exited.Sub(j.started),
+		Cleanup:      j.cleanup,
+	}
+	if rep := j.shimReport; rep != nil {
+		// The shim reports wall-clock times, which are shared with the host.
+		t.SandboxSetup = max(rep.Start.Sub(j.started.Round(0)), 0)
+		t.Program = rep.Runtime
+		t.Teardown = max(exited.Round(0).Sub(rep.End), 0)
+		t.Exact = true
+	}
+	return t
+}
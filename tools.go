@@ -0,0 +1,42 @@
+package nsjail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// toolTimeout bounds each invocation of a host tool such as ip or wg.
+const toolTimeout = 10 * time.Second
+
+// runTool runs a host tool, feeding it stdin, and reports its stderr on failure.
+func runTool(stdin io.Reader, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), toolTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// inNetns runs a host tool inside the network namespace of pid.
+func inNetns(pid int, name string, args ...string) error {
+	return runTool(nil, "nsenter", append([]string{fmt.Sprintf("--net=/proc/%d/ns/net", pid), "--", name}, args...)...)
+}
+
+// uniqueName returns prefix followed by random hex digits, up to n characters in total.
+func uniqueName(prefix string, n int) string {
+	b := make([]byte, (n-len(prefix)+1)/2)
+	rand.Read(b)
+	return (prefix + hex.EncodeToString(b))[:n]
+}
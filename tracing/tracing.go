@@ -0,0 +1,139 @@
+// Package tracing records OpenTelemetry spans for jails run with nsjail-go, so sandbox latency can be
+// traced end-to-end:
+//
+//	t := tracing.New(nil) // the global TracerProvider
+//	res, err := t.Run(ctx, nsjail.New("/usr/bin/python3", "main.py"))
+//
+// Each run is a "nsjail.run" span, a child of the span in ctx, with the children "nsjail.build" (building
+// the configuration), "nsjail.spawn" (starting nsjail), "nsjail.execute" (the lifetime of nsjail) and
+// "nsjail.cleanup" (releasing the resources of the jail). With the init shim (see NsJail.WithInitShim)
+// "nsjail.execute" is split further into "nsjail.sandbox_setup", "nsjail.program" and "nsjail.teardown".
+package tracing
+
+import (
+	"context"
+	"time"
+
+	nsjail "github.com/OptimusePrime/nsjail-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/OptimusePrime/nsjail-go/tracing"
+
+// Tracer starts jails inside spans. It is safe for concurrent use.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New returns a tracer recording spans with tp, or with the global TracerProvider if tp is nil.
+func New(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// Start starts n like NsJail.Start. The spans of the run are ended once the jail finished, whether or
+// not Wait is called.
+func (t *Tracer) Start(ctx context.Context, n *nsjail.NsJail) (*nsjail.Jail, error) {
+	r, j, err := t.start(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		res, err := j.Wait()
+		t.finish(r, res, err)
+	}()
+	return j, nil
+}
+
+// Run runs n like NsJail.Run.
+func (t *Tracer) Run(ctx context.Context, n *nsjail.NsJail) (*nsjail.Result, error) {
+	r, j, err := t.start(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	res, err := j.Wait()
+	t.finish(r, res, err)
+	return res, err
+}
+
+// run is a traced run of a jail.
+type run struct {
+	ctx   context.Context
+	span  trace.Span
+	begin time.Time
+}
+
+func (t *Tracer) start(ctx context.Context, n *nsjail.NsJail) (run, *nsjail.Jail, error) {
+	r := run{begin: time.Now()}
+	r.ctx, r.span = t.tracer.Start(ctx, "nsjail.run", trace.WithTimestamp(r.begin), trace.WithAttributes(
+		attribute.String("nsjail.mode", string(n.Mode())),
+		attribute.Float64("nsjail.time_limit_seconds", n.TimeLimit().Seconds()),
+	))
+	j, err := n.Start(r.ctx)
+	if err != nil {
+		_, build := t.tracer.Start(r.ctx, "nsjail.build", trace.WithTimestamp(r.begin))
+		build.RecordError(err)
+		build.SetStatus(codes.Error, err.Error())
+		build.End()
+		r.span.RecordError(err)
+		r.span.SetStatus(codes.Error, err.Error())
+		r.span.End()
+		return r, nil, err
+	}
+	r.span.SetAttributes(attribute.Int("nsjail.pid", j.Pid()))
+	return r, j, nil
+}
+
+// finish records the phases of a finished run as children of its span, laid out from its beginning with
+// the durations measured by the jail, and ends the span.
+func (t *Tracer) finish(r run, res *nsjail.Result, err error) {
+	ctx, span := r.ctx, r.span
+	if res == nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return
+	}
+	tm := res.Timing
+	at := r.begin
+	// phase records a span of d from at and moves at to its end.
+	phase := func(ctx context.Context, name string, d time.Duration) {
+		_, s := t.tracer.Start(ctx, name, trace.WithTimestamp(at))
+		at = at.Add(d)
+		s.End(trace.WithTimestamp(at))
+	}
+	phase(ctx, "nsjail.build", tm.WrapperSetup-tm.Spawn)
+	phase(ctx, "nsjail.spawn", tm.Spawn)
+
+	execCtx, execute := t.tracer.Start(ctx, "nsjail.execute", trace.WithTimestamp(at))
+	end := at.Add(res.Duration)
+	if tm.Exact {
+		phase(execCtx, "nsjail.sandbox_setup", tm.SandboxSetup)
+		phase(execCtx, "nsjail.program", tm.Program)
+		phase(execCtx, "nsjail.teardown", end.Sub(at))
+	}
+	execute.End(trace.WithTimestamp(end))
+	at = end
+	phase(ctx, "nsjail.cleanup", tm.Cleanup)
+
+	span.SetAttributes(
+		attribute.String("nsjail.status", res.Status.String()),
+		attribute.Int("nsjail.exit_code", res.NormalizedCode),
+	)
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case res.Aborted != nil:
+		span.SetAttributes(attribute.String("nsjail.aborted", res.Aborted.Error()))
+		span.SetStatus(codes.Error, "aborted: "+res.Aborted.Error())
+	case res.Status != nsjail.StatusExited:
+		span.SetStatus(codes.Error, res.Status.String())
+	}
+	span.End(trace.WithTimestamp(at))
+}
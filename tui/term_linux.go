@@ -0,0 +1,30 @@
+package tui
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// cbreak switches the terminal f to unbuffered input without echo, keeping signals like Ctrl-C working.
+// It returns a function restoring the previous mode.
+func cbreak(f *os.File) (func(), error) {
+	var old syscall.Termios
+	if err := ioctl(f.Fd(), syscall.TCGETS, &old); err != nil {
+		return nil, err
+	}
+	mode := old
+	mode.Lflag &^= syscall.ICANON | syscall.ECHO
+	mode.Cc[syscall.VMIN], mode.Cc[syscall.VTIME] = 1, 0
+	if err := ioctl(f.Fd(), syscall.TCSETS, &mode); err != nil {
+		return nil, err
+	}
+	return func() { ioctl(f.Fd(), syscall.TCSETS, &old) }, nil
+}
+
+func ioctl(fd uintptr, req uintptr, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
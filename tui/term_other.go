@@ -0,0 +1,13 @@
+//go:build !linux
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+// cbreak is only implemented on Linux; elsewhere input stays line-buffered.
+func cbreak(f *os.File) (func(), error) {
+	return nil, errors.New("tui: unbuffered input is not supported on this platform")
+}
@@ -0,0 +1,294 @@
+// Package tui is a terminal monitor for jails during local development. It lists the jails registered
+// with a Monitor together with their live resource usage and recent output, and kills them on request:
+//
+//	m := tui.NewMonitor()
+//	j, err := nsjail.New("/usr/bin/python3", "job.py").
+//		WithCgroupMemMax(256 << 20).
+//		WithStdio(nil, m.Writer("job"), m.Writer("job")).
+//		Start(ctx)
+//	m.Watch("job", j)
+//	m.Run(ctx, os.Stdin, os.Stdout)
+//
+// Resource usage is read with Jail.Stats, which needs a cgroup limit on the jail.
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	nsjail "github.com/OptimusePrime/nsjail-go"
+)
+
+// ErrKilled is the reason recorded in Result.Aborted for jails killed from the monitor.
+var ErrKilled = errors.New("tui: killed from the monitor")
+
+// outputLines is the number of output lines kept per jail.
+const outputLines = 200
+
+// refreshInterval is how often the screen is redrawn.
+const refreshInterval = time.Second
+
+type entry struct {
+	name  string
+	jail  *nsjail.Jail
+	added time.Time
+	out   *lineBuffer
+}
+
+// Monitor tracks jails for display. Its methods are safe for concurrent use.
+type Monitor struct {
+	mu      sync.Mutex
+	entries []*entry
+	byName  map[string]*entry
+}
+
+// NewMonitor returns an empty monitor.
+func NewMonitor() *Monitor {
+	return &Monitor{byName: make(map[string]*entry)}
+}
+
+func (m *Monitor) entry(name string) *entry {
+	e, ok := m.byName[name]
+	if !ok {
+		e = &entry{name: name, added: time.Now(), out: &lineBuffer{max: outputLines}}
+		m.byName[name] = e
+		m.entries = append(m.entries, e)
+	}
+	return e
+}
+
+// Writer returns a writer collecting the recent output shown for name. Pass it to WithStdio, or combine it
+// with other writers using io.MultiWriter.
+func (m *Monitor) Writer(name string) io.Writer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.entry(name).out
+}
+
+// Watch registers the started jail j under name, replacing a jail registered before under that name.
+func (m *Monitor) Watch(name string, j *nsjail.Jail) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.entry(name)
+	e.jail, e.added = j, time.Now()
+}
+
+// Remove unregisters name.
+func (m *Monitor) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.byName[name]; ok {
+		delete(m.byName, name)
+		for i, x := range m.entries {
+			if x == e {
+				m.entries = append(m.entries[:i], m.entries[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (m *Monitor) snapshot() []*entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*entry(nil), m.entries...)
+}
+
+// Run draws the monitor on out until ctx is done or q is pressed, reading keys from in: up/down or k/j
+// select a jail, x kills it and d removes exited jails. A terminal in is switched to unbuffered input
+// while Run is active; elsewhere each key must be followed by Enter.
+func (m *Monitor) Run(ctx context.Context, in io.Reader, out io.Writer) error {
+	if f, ok := in.(*os.File); ok {
+		if restore, err := cbreak(f); err == nil {
+			defer restore()
+		}
+	}
+	fmt.Fprint(out, "\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\n")
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := in.Read(buf)
+			for _, b := range buf[:n] {
+				select {
+				case keys <- b:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	selected := 0
+	var esc []byte // pending escape sequence
+	tick := time.NewTicker(refreshInterval)
+	defer tick.Stop()
+	for {
+		entries := m.snapshot()
+		selected = max(min(selected, len(entries)-1), 0)
+		m.draw(out, entries, selected)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+		case b := <-keys:
+			if len(esc) > 0 || b == 0x1b {
+				esc = append(esc, b)
+				switch string(esc) {
+				case "\x1b[A":
+					selected--
+				case "\x1b[B":
+					selected++
+				case "\x1b", "\x1b[":
+					continue
+				}
+				esc = nil
+				continue
+			}
+			switch b {
+			case 'q':
+				return nil
+			case 'k':
+				selected--
+			case 'j':
+				selected++
+			case 'x':
+				if selected < len(entries) && entries[selected].jail != nil {
+					entries[selected].jail.Abort(ErrKilled)
+				}
+			case 'd':
+				for _, e := range entries {
+					if e.jail != nil && exited(e.jail) {
+						m.Remove(e.name)
+					}
+				}
+			}
+		}
+	}
+}
+
+func exited(j *nsjail.Jail) bool {
+	select {
+	case <-j.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// draw renders the jail table and the output of the selected jail.
+func (m *Monitor) draw(out io.Writer, entries []*entry, selected int) {
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&sb, "nsjail monitor  %s  [j/k] select  [x] kill  [d] remove exited  [q] quit\r\n\r\n",
+		time.Now().Format("15:04:05"))
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPID\tSTATE\tUPTIME\tMEM\tPEAK\tCPU\tPIDS")
+	for _, e := range entries {
+		fmt.Fprintln(tw, row(e))
+	}
+	tw.Flush()
+	for i, line := range strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n") {
+		if i > 0 && i-1 == selected {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		sb.WriteString(line + "\r\n")
+	}
+	if selected < len(entries) {
+		e := entries[selected]
+		fmt.Fprintf(&sb, "\r\n--- output of %s ---\r\n", e.name)
+		for _, line := range e.out.tail(20) {
+			sb.WriteString(line + "\r\n")
+		}
+	}
+	io.WriteString(out, sb.String())
+}
+
+func row(e *entry) string {
+	if e.jail == nil {
+		return e.name + "\t-\tpending\t-\t-\t-\t-\t-"
+	}
+	state, uptime := "running", time.Since(e.added)
+	if exited(e.jail) {
+		state = "exited"
+		if res, err := e.jail.Wait(); err != nil {
+			state = "error"
+		} else if res != nil {
+			state = fmt.Sprintf("%v (%d)", res.Status, res.NormalizedCode)
+			uptime = res.Duration
+		}
+	}
+	mem, peak, cpu, pids := "-", "-", "-", "-"
+	if u, err := e.jail.Stats(); err == nil {
+		mem, peak = bytesString(u.MemoryCurrent), bytesString(u.MemoryPeak)
+		cpu = u.CPU.Round(10 * time.Millisecond).String()
+		pids = fmt.Sprint(u.PidsCurrent)
+	}
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s", e.name, e.jail.Pid(), state,
+		uptime.Round(time.Second), mem, peak, cpu, pids)
+}
+
+func bytesString(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// lineBuffer keeps the last lines written to it.
+type lineBuffer struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial string
+}
+
+func (b *lineBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	parts := strings.Split(b.partial+string(p), "\n")
+	b.partial = parts[len(parts)-1]
+	for _, line := range parts[:len(parts)-1] {
+		// Control characters would garble the screen.
+		b.lines = append(b.lines, strings.Map(func(r rune) rune {
+			if r < 0x20 && r != '\t' || r == 0x7f {
+				return -1
+			}
+			return r
+		}, line))
+	}
+	if over := len(b.lines) - b.max; over > 0 {
+		b.lines = append(b.lines[:0], b.lines[over:]...)
+	}
+	return len(p), nil
+}
+
+// tail returns up to n of the last lines, including an unterminated one.
+func (b *lineBuffer) tail(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lines := b.lines
+	if b.partial != "" {
+		lines = append(lines[:len(lines):len(lines)], b.partial)
+	}
+	return lines[max(len(lines)-n, 0):]
+}
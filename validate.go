@@ -0,0 +1,33 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// fail records an invalid argument given to the builder method named method, or an invalid value of the
+// decoded key named method. The configuration is left
+// unchanged by the call, and the error is reported by Validate and by everything that builds the command.
+func (n *NsJail) fail(method, format string, args ...any) {
+	n.errs = append(n.errs, fmt.Errorf("nsjail: %s: %s", method, fmt.Sprintf(format, args...)))
+}
+
+// Validate reports everything wrong with the configuration that can be found without running it: the
+// invalid arguments given to builder methods, each naming the method, then the invalid command line,
+// capabilities, deadline, addresses, id mappings, mounts and overlay directories Exec would fail on. All
+// errors are joined rather than only the first being returned. Exec, Args, Start and Run fail with the
+// builder errors too.
+func (n *NsJail) Validate() error {
+	errs := slices.Clone(n.errs)
+	validators := []func() error{
+		n.validateCommand, n.validateCaps, n.validateDeadline, n.validateNet, n.validateIDMaps,
+		n.validateMounts, n.validateInitShim, n.validateFileAccess, n.validateOverlay,
+	}
+	for _, validate := range validators {
+		if err := validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
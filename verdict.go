@@ -0,0 +1,154 @@
+package nsjail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// VerdictKind classifies a finished run the way online judges report submissions.
+type VerdictKind int
+
+const (
+	// VerdictOK means the program exited with code 0 within its limits.
+	VerdictOK VerdictKind = iota
+	// VerdictTimeLimitExceeded means the program ran out of wall-clock or CPU time.
+	VerdictTimeLimitExceeded
+	// VerdictMemoryLimitExceeded means the program was killed for exceeding its memory limit.
+	VerdictMemoryLimitExceeded
+	// VerdictOutputLimitExceeded means the program wrote more output than allowed.
+	VerdictOutputLimitExceeded
+	// VerdictRuntimeError means the program was killed by a signal or exited with a non-zero code.
+	VerdictRuntimeError
+	// VerdictSystemError means the program did not run to a verdict of its own, e.g. because nsjail failed
+	// to set up the jail or the run was cancelled.
+	VerdictSystemError
+)
+
+func (k VerdictKind) String() string {
+	switch k {
+	case VerdictOK:
+		return "ok"
+	case VerdictTimeLimitExceeded:
+		return "time limit exceeded"
+	case VerdictMemoryLimitExceeded:
+		return "memory limit exceeded"
+	case VerdictOutputLimitExceeded:
+		return "output limit exceeded"
+	case VerdictRuntimeError:
+		return "runtime error"
+	case VerdictSystemError:
+		return "system error"
+	}
+	return fmt.Sprintf("VerdictKind(%d)", int(k))
+}
+
+// sigXCPU and sigXFSZ are the Linux values, which nsjail reports; not every platform defines them.
+const sigXCPU, sigXFSZ = syscall.Signal(0x18), syscall.Signal(0x19)
+
+// Verdict is the classification of a finished run, see NsJail.Verdict.
+type Verdict struct {
+	Kind VerdictKind
+	// Signal is the signal that killed the program, or 0. It is set for every kind the program was killed
+	// for, e.g. SIGKILL for VerdictTimeLimitExceeded.
+	Signal syscall.Signal
+	// ExitCode is the exit code of the program for VerdictRuntimeError without a signal.
+	ExitCode int
+	// Reason describes what the verdict is based on, e.g. "the OOM killer ran in the cgroup".
+	Reason string
+}
+
+func (v Verdict) String() string {
+	switch {
+	case v.Kind == VerdictRuntimeError && v.Signal != 0:
+		return fmt.Sprintf("%v: %v", v.Kind, v.Signal)
+	case v.Reason != "":
+		return fmt.Sprintf("%v: %s", v.Kind, v.Reason)
+	case v.Kind == VerdictRuntimeError:
+		return fmt.Sprintf("%v: exit code %d", v.Kind, v.ExitCode)
+	}
+	return v.Kind.String()
+}
+
+// Verdict classifies r, the result of running n, using its exit status, the usage sampled from the jail's
+// cgroup and the limits set on n. In order of precedence:
+//
+//   - nsjail failing to set up the jail, or the run being cancelled, is VerdictSystemError;
+//   - an OOM kill in the cgroup, or SIGKILL with the peak memory at the cgroup memory limit, is
+//     VerdictMemoryLimitExceeded;
+//   - output truncated by RunCaptured, SIGXFSZ from RLIMIT_FSIZE, or a limit of WithFileLimits is
+//     VerdictOutputLimitExceeded;
+//   - the time limit (-t), an expired context deadline, SIGXCPU, or SIGKILL with the CPU time used at
+//     RLIMIT_CPU is VerdictTimeLimitExceeded;
+//   - any other signal or non-zero exit code, or another reason to abort the jail, is VerdictRuntimeError.
+//
+// Memory and CPU time are only attributed with a cgroup, e.g. one set up by WithMemoryLimit: without
+// Result.Usage, a program killed by the kernel for them is a VerdictRuntimeError with SIGKILL. Exceeding
+// RLIMIT_AS makes allocations fail instead, which a program reports in its own way.
+func (n *NsJail) Verdict(r *Result) Verdict {
+	v := Verdict{}
+	if r.Status == StatusSignaled {
+		v.Signal = syscall.Signal(r.NormalizedCode - 128)
+	}
+	if r.Status == StatusTimeLimit {
+		v.Signal = syscall.SIGKILL
+	}
+	verdict := func(kind VerdictKind, reason string) Verdict {
+		v.Kind, v.Reason = kind, reason
+		return v
+	}
+
+	switch {
+	case r.Status == StatusSetupFailed:
+		return verdict(VerdictSystemError, "nsjail failed to set up the jail")
+	case errors.Is(r.Aborted, context.Canceled):
+		return verdict(VerdictSystemError, "the run was cancelled")
+	}
+
+	u := r.Usage
+	memMax := n.cgroupMemMax
+	if c := n.cgroupV2; c != nil && c.memoryMax > 0 {
+		memMax = c.memoryMax
+	}
+	switch {
+	case u != nil && u.OOMKills > 0:
+		return verdict(VerdictMemoryLimitExceeded, "the OOM killer ran in the cgroup")
+	case u != nil && v.Signal == syscall.SIGKILL && memMax > 0 && u.MemoryPeak >= memMax:
+		return verdict(VerdictMemoryLimitExceeded, fmt.Sprintf("the memory use reached the limit of %d bytes", memMax))
+	}
+
+	switch {
+	case r.StdoutTruncated || r.StderrTruncated:
+		return verdict(VerdictOutputLimitExceeded, "the captured output was truncated")
+	case v.Signal == sigXFSZ:
+		return verdict(VerdictOutputLimitExceeded, "a file reached RLIMIT_FSIZE")
+	case errors.Is(r.Aborted, ErrFileTooLarge), errors.Is(r.Aborted, ErrTooManyFiles):
+		return verdict(VerdictOutputLimitExceeded, r.Aborted.Error())
+	}
+
+	cpuMax, err := strconv.ParseUint(n.rlimitCpu, 10, 64)
+	switch {
+	case r.Status == StatusTimeLimit:
+		return verdict(VerdictTimeLimitExceeded, "the time limit was reached")
+	case errors.Is(r.Aborted, context.DeadlineExceeded):
+		return verdict(VerdictTimeLimitExceeded, "the context deadline expired")
+	case v.Signal == sigXCPU:
+		return verdict(VerdictTimeLimitExceeded, "a process reached RLIMIT_CPU")
+	case u != nil && v.Signal == syscall.SIGKILL && err == nil && u.CPU >= time.Duration(cpuMax)*time.Second:
+		return verdict(VerdictTimeLimitExceeded, "the CPU time used reached RLIMIT_CPU")
+	}
+
+	switch {
+	case r.Aborted != nil:
+		return verdict(VerdictRuntimeError, r.Aborted.Error())
+	case v.Signal != 0:
+		return verdict(VerdictRuntimeError, "")
+	case r.NormalizedCode != 0:
+		v.ExitCode = r.NormalizedCode
+		return verdict(VerdictRuntimeError, "")
+	}
+	return verdict(VerdictOK, "")
+}
@@ -0,0 +1,82 @@
+package nsjail
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestVerdict(t *testing.T) {
+	limited := New("/bin/true").WithMemoryLimit(64 << 20).WithRlimitCpu("2")
+	signaled := func(sig syscall.Signal) Result {
+		return Result{Status: StatusSignaled, NormalizedCode: 128 + int(sig)}
+	}
+	oom := &ResourceUsage{OOMKills: 1}
+	tests := []struct {
+		name   string
+		jail   *NsJail
+		result Result
+		want   VerdictKind
+		signal syscall.Signal
+	}{
+		{"ok", limited, Result{}, VerdictOK, 0},
+		{"exit code", limited, Result{Status: StatusExited, NormalizedCode: 3}, VerdictRuntimeError, 0},
+		{"signal", limited, signaled(syscall.SIGSEGV), VerdictRuntimeError, syscall.SIGSEGV},
+		{"aborted", limited, Result{Aborted: errors.New("watchdog")}, VerdictRuntimeError, 0},
+
+		{"time limit", limited, Result{Status: StatusTimeLimit}, VerdictTimeLimitExceeded, syscall.SIGKILL},
+		{"context deadline", limited, Result{Aborted: context.DeadlineExceeded}, VerdictTimeLimitExceeded, 0},
+		{"SIGXCPU", limited, signaled(sigXCPU), VerdictTimeLimitExceeded, sigXCPU},
+		{"CPU at RLIMIT_CPU", limited, Result{Status: StatusSignaled, NormalizedCode: 128 + 9,
+			Usage: &ResourceUsage{CPU: 2 * time.Second}}, VerdictTimeLimitExceeded, syscall.SIGKILL},
+		{"CPU below RLIMIT_CPU", limited, Result{Status: StatusSignaled, NormalizedCode: 128 + 9,
+			Usage: &ResourceUsage{CPU: time.Second}}, VerdictRuntimeError, syscall.SIGKILL},
+
+		{"truncated output", limited, Result{StdoutTruncated: true}, VerdictOutputLimitExceeded, 0},
+		{"SIGXFSZ", limited, signaled(sigXFSZ), VerdictOutputLimitExceeded, sigXFSZ},
+		{"file limit", limited, Result{Aborted: ErrTooManyFiles}, VerdictOutputLimitExceeded, 0},
+		{"output over time", limited, Result{Status: StatusTimeLimit, StderrTruncated: true},
+			VerdictOutputLimitExceeded, syscall.SIGKILL},
+
+		{"OOM kill", limited, Result{Status: StatusSignaled, NormalizedCode: 128 + 9, Usage: oom},
+			VerdictMemoryLimitExceeded, syscall.SIGKILL},
+		{"peak at memory limit", limited, Result{Status: StatusSignaled, NormalizedCode: 128 + 9,
+			Usage: &ResourceUsage{MemoryPeak: 64 << 20}}, VerdictMemoryLimitExceeded, syscall.SIGKILL},
+		{"peak without limit", New("/bin/true"), Result{Status: StatusSignaled, NormalizedCode: 128 + 9,
+			Usage: &ResourceUsage{MemoryPeak: 64 << 20}}, VerdictRuntimeError, syscall.SIGKILL},
+		{"memory over output", limited, Result{StdoutTruncated: true, Usage: oom}, VerdictMemoryLimitExceeded, 0},
+		{"memory over time", limited, Result{Status: StatusTimeLimit, Usage: oom},
+			VerdictMemoryLimitExceeded, syscall.SIGKILL},
+
+		{"setup failed", limited, Result{Status: StatusSetupFailed, Usage: oom}, VerdictSystemError, 0},
+		{"cancelled", limited, Result{Aborted: context.Canceled, StdoutTruncated: true}, VerdictSystemError, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.jail.Verdict(&tt.result)
+			if v.Kind != tt.want || v.Signal != tt.signal {
+				t.Errorf("Verdict = %v (signal %d), want %v (signal %d)", v, v.Signal, tt.want, tt.signal)
+			}
+		})
+	}
+}
+
+func TestVerdictString(t *testing.T) {
+	tests := []struct {
+		v    Verdict
+		want string
+	}{
+		{Verdict{Kind: VerdictOK}, "ok"},
+		{Verdict{Kind: VerdictRuntimeError, ExitCode: 3}, "runtime error: exit code 3"},
+		{Verdict{Kind: VerdictTimeLimitExceeded, Reason: "the time limit was reached"},
+			"time limit exceeded: the time limit was reached"},
+		{Verdict{Kind: VerdictKind(42)}, "VerdictKind(42)"},
+	}
+	for _, tt := range tests {
+		if got := tt.v.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
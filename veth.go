@@ -0,0 +1,130 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"time"
+)
+
+// VethConfig configures a veth pair connecting the jail to the host.
+type VethConfig struct {
+	// HostAddr is the address of the host end with the prefix of the link, e.g. 10.200.0.1/30. It is the
+	// default gateway of the jail.
+	HostAddr netip.Prefix
+	// JailAddr is the address of the jail end, in the prefix of HostAddr, e.g. 10.200.0.2/30.
+	JailAddr netip.Prefix
+	// Iface is the name of the interface in the jail. Defaults to eth0.
+	Iface string
+	// NAT masquerades the traffic of the jail leaving the host and lets the host forward it, so the jail
+	// reaches the host's networks. It enables IP forwarding on the host, which is left enabled.
+	NAT bool
+	// MTU of both ends. Defaults to the kernel's default.
+	MTU int
+}
+
+// WithVeth connects the jail to the host with a veth pair, an alternative to MACVLAN that works where the
+// host's interface does not accept extra MAC addresses, as on many cloud networks. The pair is created in
+// the host's network namespace and one end is moved into the jail with --iface_own; addresses and the
+// default route are configured right after the jail starts. Every jail needs its own addresses. Requires
+// root (CAP_NET_ADMIN), the ip and nsenter tools, iptables (or ip6tables) for NAT, and a network namespace
+// (no DisableCloneNewNet).
+func (n *NsJail) WithVeth(cfg VethConfig) *NsJail { n.veth = &cfg; return n.changed() }
+
+// useVeth creates the veth pair and hands one end to the jail.
+func (j *Jail) useVeth(n *NsJail, l *launch) error {
+	cfg := *n.veth
+	if n.cloneNewNetDisabled {
+		return errors.New("nsjail: veth requires a network namespace")
+	}
+	if !cfg.HostAddr.IsValid() || !cfg.JailAddr.IsValid() {
+		return errors.New("nsjail: veth requires the host and jail addresses")
+	}
+	if cfg.HostAddr.Addr() == cfg.JailAddr.Addr() || !cfg.HostAddr.Masked().Contains(cfg.JailAddr.Addr()) {
+		return fmt.Errorf("nsjail: veth jail address %s must be another address in %s", cfg.JailAddr.Addr(),
+			cfg.HostAddr.Masked())
+	}
+	if cfg.Iface == "" {
+		cfg.Iface = "eth0"
+	}
+
+	hostIface, jailIface := uniqueName("vjh", 15), uniqueName("vjj", 15)
+	if err := runTool(nil, "ip", "link", "add", "dev", hostIface, "type", "veth", "peer", "name", jailIface); err != nil {
+		return err
+	}
+	// Deleting either end removes the pair; it is also removed with the jail's namespace.
+	j.onClose(func() { runTool(nil, "ip", "link", "del", "dev", hostIface) })
+
+	if cfg.MTU > 0 {
+		for _, iface := range []string{hostIface, jailIface} {
+			if err := runTool(nil, "ip", "link", "set", "dev", iface, "mtu", strconv.Itoa(cfg.MTU)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := runTool(nil, "ip", "addr", "add", cfg.HostAddr.String(), "dev", hostIface); err != nil {
+		return err
+	}
+	if err := runTool(nil, "ip", "link", "set", "dev", hostIface, "up"); err != nil {
+		return err
+	}
+	if cfg.NAT {
+		if err := j.natVeth(hostIface, cfg.JailAddr.Addr()); err != nil {
+			return err
+		}
+	}
+
+	l.flags = append(l.flags, "--iface_own", jailIface)
+	j.onStarted(func() error { return configureVeth(j, jailIface, &cfg) })
+	return nil
+}
+
+// natVeth masquerades the traffic of addr leaving the host through another interface than hostIface.
+func (j *Jail) natVeth(hostIface string, addr netip.Addr) error {
+	tool, forwarding, src := "iptables", "/proc/sys/net/ipv4/ip_forward", addr.String()+"/32"
+	if addr.Is6() {
+		tool, forwarding, src = "ip6tables", "/proc/sys/net/ipv6/conf/all/forwarding", addr.String()+"/128"
+	}
+	if err := os.WriteFile(forwarding, []byte("1"), 0); err != nil {
+		return fmt.Errorf("nsjail: enabling IP forwarding: %w", err)
+	}
+	// Rules are inserted first so they take effect before restrictive FORWARD rules, e.g. Docker's.
+	rules := [][]string{
+		{"-t", "nat", "POSTROUTING", "-s", src, "!", "-o", hostIface, "-j", "MASQUERADE"},
+		{"-t", "filter", "FORWARD", "-i", hostIface, "-j", "ACCEPT"},
+		{"-t", "filter", "FORWARD", "-o", hostIface, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+	}
+	for _, r := range rules {
+		table, chain, spec := r[:2], r[2], r[3:]
+		if err := runTool(nil, tool, append(append(table, "-I", chain), spec...)...); err != nil {
+			return err
+		}
+		j.onClose(func() { runTool(nil, tool, append(append(table, "-D", chain), spec...)...) })
+	}
+	return nil
+}
+
+// configureVeth renames, addresses and routes the jail end inside the jail's network namespace.
+func configureVeth(j *Jail, iface string, cfg *VethConfig) error {
+	pid, err := j.jailPid(5 * time.Second)
+	if err != nil {
+		return fmt.Errorf("nsjail: configuring veth: %w", err)
+	}
+	family := "-4"
+	if cfg.JailAddr.Addr().Is6() {
+		family = "-6"
+	}
+	for _, args := range [][]string{
+		{"link", "set", "dev", iface, "name", cfg.Iface},
+		{"addr", "add", cfg.JailAddr.String(), "dev", cfg.Iface},
+		{"link", "set", "dev", cfg.Iface, "up"},
+		{family, "route", "replace", "default", "via", cfg.HostAddr.Addr().String(), "dev", cfg.Iface},
+	} {
+		if err := inNetns(pid, "ip", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
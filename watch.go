@@ -0,0 +1,88 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// ErrForbiddenFile is returned by the policy built with DenyFilePatterns when a matching file appears.
+var ErrForbiddenFile = errors.New("nsjail: forbidden file created")
+
+// FileOp describes the kind of change reported by a FileEvent.
+type FileOp uint8
+
+const (
+	// FileCreated reports a new file or directory, including one moved into the watched tree.
+	FileCreated FileOp = iota + 1
+	// FileModified reports a write to a file.
+	FileModified
+	// FileClosed reports that a file opened for writing was closed.
+	FileClosed
+	// FileRemoved reports a deleted file or directory, including one moved out of the watched tree.
+	FileRemoved
+)
+
+func (op FileOp) String() string {
+	switch op {
+	case FileCreated:
+		return "create"
+	case FileModified:
+		return "modify"
+	case FileClosed:
+		return "close"
+	case FileRemoved:
+		return "remove"
+	}
+	return fmt.Sprintf("FileOp(%d)", uint8(op))
+}
+
+// FileEvent is a change observed in a watched host directory.
+type FileEvent struct {
+	Path  string // Host path of the file
+	Rel   string // Path relative to the watched directory
+	Op    FileOp
+	IsDir bool
+	Time  time.Time
+}
+
+// FileEventFunc receives file events of a running jail. Returning an error aborts the jail.
+type FileEventFunc func(FileEvent) error
+
+type dirWatch struct {
+	dir string
+	fn  FileEventFunc
+}
+
+// WatchDir streams changes below the host directory dir to fn while the jail started with Start is running.
+// It is typically pointed at the host side of a read-write bind mount. Can be called multiple times.
+func (n *NsJail) WatchDir(dir string, fn FileEventFunc) *NsJail {
+	n.watches = append(n.watches, dirWatch{dir: dir, fn: fn})
+	return n.changed()
+}
+
+// DenyFilePatterns returns a FileEventFunc that rejects created files whose base name matches
+// any of the filepath.Match patterns, e.g. DenyFilePatterns("*.so", "*.exe").
+func DenyFilePatterns(patterns ...string) FileEventFunc {
+	return func(ev FileEvent) error {
+		if ev.Op != FileCreated || ev.IsDir {
+			return nil
+		}
+		name := filepath.Base(ev.Path)
+		for _, p := range patterns {
+			if ok, _ := filepath.Match(p, name); ok {
+				return fmt.Errorf("%w: %s matches %q", ErrForbiddenFile, ev.Rel, p)
+			}
+		}
+		return nil
+	}
+}
+
+func (j *Jail) forwardEvents(w *Watcher, fn FileEventFunc) {
+	for ev := range w.Events() {
+		if err := fn(ev); err != nil {
+			j.Abort(err)
+		}
+	}
+}
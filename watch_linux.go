@@ -0,0 +1,129 @@
+package nsjail
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_MOVED_TO | syscall.IN_MODIFY |
+	syscall.IN_CLOSE_WRITE | syscall.IN_DELETE | syscall.IN_MOVED_FROM
+
+// Watcher reports changes below a host directory using inotify. New subdirectories are watched as they appear.
+type Watcher struct {
+	root   string
+	fd     int
+	file   *os.File
+	events chan FileEvent
+
+	mu   sync.Mutex
+	dirs map[int32]string
+}
+
+// NewWatcher starts watching dir recursively.
+func NewWatcher(dir string) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_init1: %w", err)
+	}
+	w := &Watcher{
+		root:   dir,
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan FileEvent, 64),
+		dirs:   make(map[int32]string),
+	}
+	if err := w.addTree(dir); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+	go w.read()
+	return w, nil
+}
+
+// Events returns the channel events are delivered on. It is closed after Close.
+func (w *Watcher) Events() <-chan FileEvent { return w.events }
+
+// Close stops the watcher.
+func (w *Watcher) Close() error { return w.file.Close() }
+
+func (w *Watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// The directory may vanish between the event and the walk.
+			if path != dir && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, watchMask)
+		if err != nil {
+			return fmt.Errorf("inotify_add_watch %s: %w", path, err)
+		}
+		w.mu.Lock()
+		w.dirs[int32(wd)] = path
+		w.mu.Unlock()
+		return nil
+	})
+}
+
+func (w *Watcher) read() {
+	defer close(w.events)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(raw.Len)]
+			off += syscall.SizeofInotifyEvent + int(raw.Len)
+			w.handle(raw, string(bytes.TrimRight(name, "\x00")))
+		}
+	}
+}
+
+func (w *Watcher) handle(raw *syscall.InotifyEvent, name string) {
+	w.mu.Lock()
+	dir, ok := w.dirs[raw.Wd]
+	if raw.Mask&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, raw.Wd)
+	}
+	w.mu.Unlock()
+	if !ok || name == "" {
+		return
+	}
+
+	ev := FileEvent{
+		Path:  filepath.Join(dir, name),
+		IsDir: raw.Mask&syscall.IN_ISDIR != 0,
+		Time:  time.Now(),
+	}
+	ev.Rel, _ = filepath.Rel(w.root, ev.Path)
+	switch {
+	case raw.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		ev.Op = FileCreated
+		if ev.IsDir {
+			w.addTree(ev.Path)
+		}
+	case raw.Mask&syscall.IN_MODIFY != 0:
+		ev.Op = FileModified
+	case raw.Mask&syscall.IN_CLOSE_WRITE != 0:
+		ev.Op = FileClosed
+	case raw.Mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+		ev.Op = FileRemoved
+	default:
+		return
+	}
+	w.events <- ev
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package nsjail
+
+import "errors"
+
+// Watcher reports changes below a host directory. It is only implemented on Linux.
+type Watcher struct{}
+
+// NewWatcher always fails on platforms without inotify.
+func NewWatcher(dir string) (*Watcher, error) {
+	return nil, errors.New("nsjail: directory watching requires linux")
+}
+
+// Events returns a nil channel.
+func (w *Watcher) Events() <-chan FileEvent { return nil }
+
+// Close does nothing.
+func (w *Watcher) Close() error { return nil }
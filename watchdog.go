@@ -0,0 +1,233 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWatchdogLimit reports that a jail crossed a threshold of WithWatchdog.
+var ErrWatchdogLimit = errors.New("nsjail: watchdog limit exceeded")
+
+// Watchdog bounds the resources of a running jail that nsjail cannot limit itself. The usage of the jailed
+// processes is read from /proc, so it needs Linux but no cgroups, and is sampled, so a fast process may
+// overshoot by what it uses within an Interval. Output is counted as it is written.
+type Watchdog struct {
+	// MaxRSS is the resident memory of the jailed processes together, in bytes. Pages shared between
+	// processes are counted once per process. 0 means unlimited.
+	MaxRSS uint64
+	// MaxProcesses is the number of jailed processes. 0 means unlimited.
+	MaxProcesses int
+	// MaxCPU is the CPU time used by the jailed processes, including those that exited since they were
+	// sampled. 0 means unlimited.
+	MaxCPU time.Duration
+	// MaxDiskWrite is the bytes the jailed processes caused to be written to storage, including those that
+	// exited since they were sampled. Writes to tmpfs are not counted. 0 means unlimited.
+	MaxDiskWrite uint64
+	// MaxWorkspaceSize is the size of the files in the workspace of WithWorkspace together, in bytes, which
+	// is stored on the host disk unless WorkspaceOptions.Size mounts a tmpfs. 0 means unlimited.
+	MaxWorkspaceSize uint64
+	// MaxOutput is the bytes nsjail writes to stdout and stderr together. 0 means unlimited.
+	MaxOutput uint64
+	// Check is called with every sample, after the thresholds above were checked. Returning an error
+	// kills the jail with it.
+	Check func(WatchdogSample) error
+	// Interval between samples. Defaults to 250ms.
+	Interval time.Duration
+	// FlagOnly records the first violation in Result.Violations instead of killing the jail.
+	FlagOnly bool
+}
+
+// WatchdogSample is the usage of a running jail sampled by its watchdog, see Watchdog.
+type WatchdogSample struct {
+	// Elapsed is the time since the jail started.
+	Elapsed       time.Duration
+	RSS           uint64
+	Processes     int
+	CPU           time.Duration
+	DiskWrite     uint64
+	WorkspaceSize uint64
+	Output        uint64
+}
+
+// processUsage is the resource usage of a single process.
+type processUsage struct {
+	// start is when the process started, telling reused pids apart.
+	start   uint64
+	rss     uint64
+	cpu     time.Duration
+	written uint64
+}
+
+// WithWatchdog kills the jail started with Start once it crosses a threshold of w, see Watchdog. The
+// violation is recorded in Result.Aborted, wrapping ErrWatchdogLimit, or with Watchdog.FlagOnly in
+// Result.Violations.
+func (n *NsJail) WithWatchdog(w Watchdog) *NsJail {
+	if w.Interval < 0 {
+		n.fail("WithWatchdog", "negative interval %v", w.Interval)
+		return n.changed()
+	}
+	n.watchdog = &w
+	return n.changed()
+}
+
+// watchdog samples a running jail for WithWatchdog.
+type watchdog struct {
+	Watchdog
+	jail *Jail
+	// output counts the bytes written to stdout and stderr.
+	output atomic.Uint64
+	// seen holds the last usage of every process sampled, by pid.
+	seen map[int]processUsage
+	// exited adds up the CPU time and bytes written of processes no longer running.
+	exitedCPU     time.Duration
+	exitedWritten uint64
+	violated      sync.Once
+}
+
+// useWatchdog prepares the watchdog of n and wraps the streams nsjail writes to in counters.
+func (j *Jail) useWatchdog(n *NsJail, stdout, stderr io.Writer) (*watchdog, io.Writer, io.Writer, error) {
+	if n.watchdog.MaxWorkspaceSize > 0 && j.workspace == "" {
+		return nil, nil, nil, errors.New("nsjail: Watchdog.MaxWorkspaceSize requires WithWorkspace")
+	}
+	w := &watchdog{Watchdog: *n.watchdog, jail: j, seen: make(map[int]processUsage)}
+	count := func(dst io.Writer) io.Writer {
+		if dst == nil {
+			if w.MaxOutput == 0 {
+				return nil
+			}
+			dst = io.Discard
+		}
+		return &countingWriter{w: dst, count: w.countOutput}
+	}
+	return w, count(stdout), count(stderr), nil
+}
+
+// countingWriter passes writes on to w and reports their size to count.
+type countingWriter struct {
+	w     io.Writer
+	count func(int)
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count(n)
+	return n, err
+}
+
+func (w *watchdog) countOutput(n int) {
+	if total := w.output.Add(uint64(n)); w.MaxOutput > 0 && total > w.MaxOutput {
+		w.violate(fmt.Errorf("%w: wrote %d bytes of output, limit %d", ErrWatchdogLimit, total, w.MaxOutput))
+	}
+}
+
+// violate kills the jail with err, or records it with FlagOnly, once.
+func (w *watchdog) violate(err error) {
+	w.violated.Do(func() {
+		if w.FlagOnly {
+			w.jail.flag(err)
+		} else {
+			w.jail.Abort(err)
+		}
+	})
+}
+
+// run samples the jail until it exits or violates a limit.
+func (w *watchdog) run() {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = 250 * time.Millisecond
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.jail.done:
+			return
+		case <-t.C:
+		}
+		s, err := w.sample()
+		if err != nil {
+			// The jail exited while it was sampled.
+			return
+		}
+		if err := w.check(s); err != nil {
+			w.violate(err)
+			return
+		}
+	}
+}
+
+// sample reads the usage of the jailed processes.
+func (w *watchdog) sample() (WatchdogSample, error) {
+	s := WatchdogSample{Elapsed: time.Since(w.jail.started), Output: w.output.Load()}
+	pids, err := descendantPids(w.jail.Pid())
+	if err != nil {
+		return s, err
+	}
+	running := make(map[int]processUsage, len(pids))
+	for _, pid := range pids {
+		if u, err := readProcessUsage(pid); err == nil {
+			running[pid] = u
+		}
+	}
+	for pid, prev := range w.seen {
+		if u, ok := running[pid]; !ok || u.start != prev.start {
+			w.exitedCPU += prev.cpu
+			w.exitedWritten += prev.written
+		}
+	}
+	w.seen = running
+	s.Processes = len(running)
+	s.CPU, s.DiskWrite = w.exitedCPU, w.exitedWritten
+	for _, u := range running {
+		s.RSS += u.rss
+		s.CPU += u.cpu
+		s.DiskWrite += u.written
+	}
+	if w.MaxWorkspaceSize > 0 {
+		s.WorkspaceSize = dirSize(w.jail.workspace)
+	}
+	return s, nil
+}
+
+// check returns the first threshold s crosses.
+func (w *watchdog) check(s WatchdogSample) error {
+	switch {
+	case w.MaxRSS > 0 && s.RSS > w.MaxRSS:
+		return fmt.Errorf("%w: resident memory %d bytes, limit %d", ErrWatchdogLimit, s.RSS, w.MaxRSS)
+	case w.MaxProcesses > 0 && s.Processes > w.MaxProcesses:
+		return fmt.Errorf("%w: %d processes, limit %d", ErrWatchdogLimit, s.Processes, w.MaxProcesses)
+	case w.MaxCPU > 0 && s.CPU > w.MaxCPU:
+		return fmt.Errorf("%w: used %v of CPU time, limit %v", ErrWatchdogLimit, s.CPU, w.MaxCPU)
+	case w.MaxDiskWrite > 0 && s.DiskWrite > w.MaxDiskWrite:
+		return fmt.Errorf("%w: wrote %d bytes to disk, limit %d", ErrWatchdogLimit, s.DiskWrite, w.MaxDiskWrite)
+	case w.MaxWorkspaceSize > 0 && s.WorkspaceSize > w.MaxWorkspaceSize:
+		return fmt.Errorf("%w: workspace holds %d bytes, limit %d", ErrWatchdogLimit, s.WorkspaceSize,
+			w.MaxWorkspaceSize)
+	}
+	if w.Check != nil {
+		return w.Check(s)
+	}
+	return nil
+}
+
+// dirSize returns the size of the regular files below dir together.
+func dirSize(dir string) uint64 {
+	var size uint64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		// Entries disappearing during the walk are expected.
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += uint64(info.Size())
+			}
+		}
+		return nil
+	})
+	return size
+}
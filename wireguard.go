@@ -0,0 +1,125 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WireGuardPeer is a peer of the jail's WireGuard interface.
+type WireGuardPeer struct {
+	// PublicKey and PresharedKey are base64 keys as printed by wg. PresharedKey is optional.
+	PublicKey    string
+	PresharedKey string
+	// Endpoint is the host:port of the peer, reached from the host's network namespace.
+	Endpoint string
+	// AllowedIPs are routed through the tunnel inside the jail. Use 0.0.0.0/0 and ::/0 to force all egress through it.
+	AllowedIPs []netip.Prefix
+	// PersistentKeepalive sends keepalives at this interval if non-zero.
+	PersistentKeepalive time.Duration
+}
+
+// WireGuardConfig configures a WireGuard interface inside the jail.
+type WireGuardConfig struct {
+	// PrivateKey is the base64 private key of the jail.
+	PrivateKey string
+	// Addresses are assigned to the interface inside the jail.
+	Addresses []netip.Prefix
+	Peers     []WireGuardPeer
+	// MTU of the interface. Defaults to the kernel's default.
+	MTU int
+}
+
+// WithWireGuard gives the jail a WireGuard interface as its only route out. The interface is created in the
+// host's network namespace, where its encrypted traffic stays, and moved into the jail with --iface_own.
+// Addresses and routes are configured right after the jail starts; until then the jail has no egress.
+// Requires root (CAP_NET_ADMIN), the ip, wg and nsenter tools, and a network namespace (no DisableCloneNewNet).
+func (n *NsJail) WithWireGuard(cfg WireGuardConfig) *NsJail { n.wireGuard = &cfg; return n.changed() }
+
+// useWireGuard creates the interface and hands it to the jail.
+func (j *Jail) useWireGuard(n *NsJail, l *launch) error {
+	cfg := n.wireGuard
+	if n.cloneNewNetDisabled {
+		return errors.New("nsjail: WireGuard requires a network namespace")
+	}
+	if cfg.PrivateKey == "" || len(cfg.Peers) == 0 {
+		return errors.New("nsjail: WireGuard requires a private key and at least one peer")
+	}
+
+	iface := uniqueName("wgj", 15)
+	if err := runTool(nil, "ip", "link", "add", "dev", iface, "type", "wireguard"); err != nil {
+		return err
+	}
+	// The interface is destroyed with the jail's namespace; this only matters if it was never moved.
+	j.onClose(func() { runTool(nil, "ip", "link", "del", "dev", iface) })
+
+	if err := runTool(strings.NewReader(cfg.PrivateKey), "wg", "set", iface, "private-key", "/dev/stdin"); err != nil {
+		return err
+	}
+	for _, p := range cfg.Peers {
+		args := []string{"set", iface, "peer", p.PublicKey}
+		if p.Endpoint != "" {
+			args = append(args, "endpoint", p.Endpoint)
+		}
+		if len(p.AllowedIPs) > 0 {
+			args = append(args, "allowed-ips", joinPrefixes(p.AllowedIPs))
+		}
+		if p.PersistentKeepalive > 0 {
+			args = append(args, "persistent-keepalive", strconv.Itoa(int(p.PersistentKeepalive/time.Second)))
+		}
+		if p.PresharedKey != "" {
+			args = append(args, "preshared-key", "/dev/stdin")
+		}
+		if err := runTool(strings.NewReader(p.PresharedKey), "wg", args...); err != nil {
+			return err
+		}
+	}
+	if cfg.MTU > 0 {
+		if err := runTool(nil, "ip", "link", "set", "dev", iface, "mtu", strconv.Itoa(cfg.MTU)); err != nil {
+			return err
+		}
+	}
+
+	l.flags = append(l.flags, "--iface_own", iface)
+	j.onStarted(func() error { return configureWireGuard(j, iface, cfg) })
+	return nil
+}
+
+// configureWireGuard assigns addresses and routes inside the jail's network namespace.
+func configureWireGuard(j *Jail, iface string, cfg *WireGuardConfig) error {
+	pid, err := j.jailPid(5 * time.Second)
+	if err != nil {
+		return fmt.Errorf("nsjail: configuring WireGuard: %w", err)
+	}
+	for _, addr := range cfg.Addresses {
+		if err := inNetns(pid, "ip", "addr", "add", addr.String(), "dev", iface); err != nil {
+			return err
+		}
+	}
+	if err := inNetns(pid, "ip", "link", "set", "dev", iface, "up"); err != nil {
+		return err
+	}
+	for _, p := range cfg.Peers {
+		for _, prefix := range p.AllowedIPs {
+			family := "-4"
+			if prefix.Addr().Is6() {
+				family = "-6"
+			}
+			if err := inNetns(pid, "ip", family, "route", "replace", prefix.Masked().String(), "dev", iface); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func joinPrefixes(prefixes []netip.Prefix) string {
+	s := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		s[i] = p.String()
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,171 @@
+package nsjail
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// WorkspaceQuota selects how WorkspaceOptions.Size is enforced.
+type WorkspaceQuota uint8
+
+const (
+	// QuotaTmpfs mounts a tmpfs of the size on the workspace, so its contents live in memory. Mounting it
+	// needs CAP_SYS_ADMIN on the host.
+	QuotaTmpfs WorkspaceQuota = iota
+	// QuotaProject assigns the workspace a project quota of the size on the filesystem it is created on,
+	// which must be XFS or ext4 mounted with project quotas (prjquota). Needs Linux 5.14 or later and
+	// CAP_SYS_ADMIN on the host.
+	QuotaProject
+	// QuotaLoopback makes a file system of the size in a sparse image file in WorkspaceOptions.ImageDir and
+	// mounts it on the workspace through a loop device, a hard limit on disk that needs no support from the
+	// host filesystem, unlike QuotaProject, and no memory, unlike QuotaTmpfs. WorkspaceOptions.Filesystem
+	// selects it, and the matching mkfs must be installed. Needs CAP_SYS_ADMIN on the host.
+	QuotaLoopback
+)
+
+// defaultWorkspacePath is where the workspace is mounted in the jail unless WorkspaceOptions.Path is set.
+const defaultWorkspacePath = "/workspace"
+
+// defaultImageDir is where QuotaLoopback creates images unless WorkspaceOptions.ImageDir is set. Unlike
+// os.TempDir(), often a tmpfs, it is normally on disk.
+const defaultImageDir = "/var/tmp"
+
+// WorkspaceOptions configures the workspace created by WithWorkspace.
+type WorkspaceOptions struct {
+	// Path is where the workspace is mounted in the jail. Defaults to /workspace.
+	Path string
+	// Dir is the host directory the workspace is created in. Defaults to os.TempDir().
+	Dir string
+	// Size limits the contents of the workspace in bytes, enforced as selected by Quota. Zero means no limit.
+	Size  uint64
+	Quota WorkspaceQuota
+	// Filesystem is the file system QuotaLoopback makes, "ext4" or "xfs", which needs a Size of at least
+	// 300 MiB. Defaults to "ext4".
+	Filesystem string
+	// ImageDir is the host directory QuotaLoopback creates the image in, which should be on disk: in a
+	// tmpfs the image would take up memory as it fills. Defaults to /var/tmp.
+	ImageDir string
+	// Collect is called with the host path of the workspace once the jail exited and before the workspace
+	// is removed, e.g. to copy out results. Its error is returned by Jail.Wait and Run.
+	Collect func(dir string) error
+}
+
+// WithWorkspace gives the jail a writable scratch directory: Start and Run create an empty directory on
+// the host, bind-mount it read-write into the jail (at /workspace by default) and remove it once the jail
+// exited, after WorkspaceOptions.Collect ran, even if Collect panics. Jail.Workspace returns the host path
+// while the jail runs. String, Args and Exec, which create nothing, mount an empty tmpfs there instead.
+func (n *NsJail) WithWorkspace(opts WorkspaceOptions) *NsJail {
+	if opts.Path == "" {
+		opts.Path = defaultWorkspacePath
+	}
+	n.workspace = &opts
+	return n.changed()
+}
+
+// WithDiskQuota gives the jail a writable workspace of at most bytes on disk, as WithWorkspace with
+// QuotaLoopback does: writes beyond it fail with ENOSPC, however large the outputs, without using memory
+// as a tmpfs would. The image holding the contents is created in /var/tmp, or WorkspaceOptions.ImageDir of
+// an earlier WithWorkspace. If WithWorkspace was called before, its workspace is limited instead; otherwise
+// it is created in os.TempDir() and mounted at /workspace.
+func (n *NsJail) WithDiskQuota(bytes uint64) *NsJail {
+	if bytes == 0 {
+		n.fail("WithDiskQuota", "zero size")
+		return n.changed()
+	}
+	var ws WorkspaceOptions
+	if n.workspace != nil {
+		ws = *n.workspace
+	}
+	ws.Size, ws.Quota = bytes, QuotaLoopback
+	return n.WithWorkspace(ws)
+}
+
+// placeholderWorkspace returns a copy of n mounting an empty tmpfs in place of the workspace, which only
+// Start and Run create, so that String, Args and Exec render the jail.
+func (n *NsJail) placeholderWorkspace() *NsJail {
+	r := n.Clone()
+	r.workspace = nil
+	return r.AddTmpfsMount(n.workspace.Path)
+}
+
+// Workspace returns the host path of the workspace created for WithWorkspace, or "" if the jail has none.
+func (j *Jail) Workspace() string { return j.workspace }
+
+// createWorkspace returns a copy of n mounting a new workspace, the host path of the workspace and a
+// function removing it.
+func (n *NsJail) createWorkspace() (*NsJail, string, func() error, error) {
+	ws := n.workspace
+	if !path.IsAbs(ws.Path) {
+		return nil, "", nil, fmt.Errorf("nsjail: workspace path %q is not absolute", ws.Path)
+	}
+	var dir string
+	var err error
+	if n.runID != "" {
+		dir = filepath.Join(cmp.Or(ws.Dir, os.TempDir()), "nsjail-workspace-"+n.runID)
+		err = os.Mkdir(dir, 0o700)
+	} else {
+		dir, err = os.MkdirTemp(ws.Dir, "nsjail-workspace-*")
+	}
+	if err != nil {
+		return nil, "", nil, err
+	}
+	// The jailed user may be mapped to another host user.
+	if err := os.Chmod(dir, 0o777); err != nil {
+		os.RemoveAll(dir)
+		return nil, "", nil, err
+	}
+	release := func() error { return nil }
+	if ws.Size > 0 {
+		switch ws.Quota {
+		case QuotaTmpfs:
+			release, err = mountWorkspaceTmpfs(dir, ws.Size)
+		case QuotaProject:
+			release, err = setProjectQuota(dir, ws.Size)
+		case QuotaLoopback:
+			image := filepath.Join(cmp.Or(ws.ImageDir, defaultImageDir), filepath.Base(dir)+".img")
+			release, err = mountWorkspaceImage(dir, image, ws.Size, cmp.Or(ws.Filesystem, "ext4"))
+		default:
+			err = fmt.Errorf("unknown quota kind %d", ws.Quota)
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, "", nil, fmt.Errorf("nsjail: workspace quota: %w", err)
+		}
+	}
+	remove := func() error {
+		return errors.Join(release(), os.RemoveAll(dir))
+	}
+	c := n.Clone()
+	c.workspace = nil
+	c.AddBindRW(dir, ws.Path)
+	return c, dir, remove, nil
+}
+
+// useWorkspace creates the workspace of n for the jail and returns the copy of n mounting it.
+func (j *Jail) useWorkspace(n *NsJail) (*NsJail, error) {
+	resolved, dir, remove, err := n.createWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	collect := n.workspace.Collect
+	j.workspace = dir
+	j.onClose(func() {
+		defer func() {
+			if err := remove(); err != nil {
+				j.waitErr = errors.Join(j.waitErr, fmt.Errorf("nsjail: removing the workspace: %w", err))
+			}
+		}()
+		// Nothing to collect if nsjail never ran.
+		if collect == nil || j.proc == nil {
+			return
+		}
+		if err := collect(dir); err != nil {
+			j.waitErr = errors.Join(j.waitErr, fmt.Errorf("nsjail: collecting the workspace: %w", err))
+		}
+	})
+	return resolved, nil
+}
@@ -0,0 +1,210 @@
+package nsjail
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	fsIocFsgetxattr    = 0x801c581f
+	fsIocFssetxattr    = 0x401c5820
+	fsXflagProjinherit = 0x200
+
+	sysQuotactlFd = 443
+	// qSetquotaPrj is QCMD(Q_SETQUOTA, PRJQUOTA).
+	qSetquotaPrj = 0x800008<<8 | 2
+	qifBlimits   = 1
+	qifBlockSize = 1024
+
+	// projectIDBase keeps the project ids of workspaces clear of the ids assigned by administrators.
+	projectIDBase = 1 << 30
+)
+
+// fsxattr is struct fsxattr of linux/fs.h.
+type fsxattr struct {
+	xflags     uint32
+	extsize    uint32
+	nextents   uint32
+	projid     uint32
+	cowextsize uint32
+	pad        [8]byte
+}
+
+// ifDqblk is struct if_dqblk of linux/quota.h.
+type ifDqblk struct {
+	bhardlimit uint64
+	bsoftlimit uint64
+	curspace   uint64
+	ihardlimit uint64
+	isoftlimit uint64
+	curinodes  uint64
+	btime      uint64
+	itime      uint64
+	valid      uint32
+}
+
+// mountWorkspaceTmpfs mounts a tmpfs of size bytes on dir and returns a function unmounting it.
+func mountWorkspaceTmpfs(dir string, size uint64) (func() error, error) {
+	opts := fmt.Sprintf("size=%d,mode=0777", size)
+	if err := syscall.Mount("tmpfs", dir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, opts); err != nil {
+		return nil, fmt.Errorf("mounting tmpfs on %s: %w", dir, err)
+	}
+	return func() error { return unmountWorkspace(dir) }, nil
+}
+
+// setProjectQuota puts dir into a new project limited to size bytes and returns a function lifting the
+// limit again.
+func setProjectQuota(dir string, size uint64) (func() error, error) {
+	id := projectIDBase + rand.Uint32N(1<<31-projectIDBase)
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var attr fsxattr
+	if err := ioctlPtr(f.Fd(), fsIocFsgetxattr, unsafe.Pointer(&attr)); err != nil {
+		return nil, fmt.Errorf("reading the project of %s: %w", dir, err)
+	}
+	attr.projid = id
+	attr.xflags |= fsXflagProjinherit
+	if err := ioctlPtr(f.Fd(), fsIocFssetxattr, unsafe.Pointer(&attr)); err != nil {
+		return nil, fmt.Errorf("setting the project of %s: %w", dir, err)
+	}
+	if err := quotactlPrj(f, id, (size+qifBlockSize-1)/qifBlockSize); err != nil {
+		return nil, fmt.Errorf("setting the project quota of %s (is the filesystem mounted with prjquota?): %w", dir, err)
+	}
+	return func() error {
+		f, err := os.Open(dir)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := quotactlPrj(f, id, 0); err != nil {
+			return fmt.Errorf("lifting the project quota of %s: %w", dir, err)
+		}
+		return nil
+	}, nil
+}
+
+// mountWorkspaceImage makes a file system of type fsType in a new sparse image of size bytes at the path
+// image, mounts it on dir through a loop device and returns a function unmounting it and removing the image.
+func mountWorkspaceImage(dir, image string, size uint64, fsType string) (func() error, error) {
+	var mkfs []string
+	switch fsType {
+	case "ext4":
+		// No blocks are reserved for root, so the jail gets all of the size.
+		mkfs = []string{"mkfs.ext4", "-q", "-F", "-m", "0"}
+	case "xfs":
+		mkfs = []string{"mkfs.xfs", "-q", "-f"}
+	default:
+		return nil, fmt.Errorf("unsupported file system %q", fsType)
+	}
+	f, err := os.OpenFile(image, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := f.Truncate(int64(size)); err != nil {
+		os.Remove(image)
+		return nil, err
+	}
+	if out, err := exec.Command(mkfs[0], append(mkfs[1:], image)...).CombinedOutput(); err != nil {
+		os.Remove(image)
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", mkfs[0], err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", mkfs[0], err)
+	}
+	loop, err := attachLoop(f)
+	if err != nil {
+		os.Remove(image)
+		return nil, fmt.Errorf("attaching %s to a loop device: %w", image, err)
+	}
+	// The loop device detaches itself once it is closed here and unmounted.
+	defer loop.Close()
+	if err := syscall.Mount(loop.Name(), dir, fsType, syscall.MS_NOSUID|syscall.MS_NODEV, ""); err != nil {
+		os.Remove(image)
+		return nil, fmt.Errorf("mounting %s on %s: %w", image, dir, err)
+	}
+	release := func() error {
+		return errors.Join(unmountWorkspace(dir), os.Remove(image))
+	}
+	// The workspace starts empty and writable by the jailed user, like one without a quota.
+	os.Remove(filepath.Join(dir, "lost+found"))
+	if err := os.Chmod(dir, 0o777); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// unmountWorkspace detaches the file system mounted on dir for a quota.
+func unmountWorkspace(dir string) error {
+	if err := syscall.Unmount(dir, syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("unmounting %s: %w", dir, err)
+	}
+	return nil
+}
+
+// attachLoop attaches f to a free loop device, which detaches itself once it is closed and unused.
+func attachLoop(f *os.File) (*os.File, error) {
+	ctl, err := os.OpenFile("/dev/loop-control", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer ctl.Close()
+	for {
+		n, err := unix.IoctlRetInt(int(ctl.Fd()), unix.LOOP_CTL_GET_FREE)
+		if err != nil {
+			return nil, os.NewSyscallError("ioctl LOOP_CTL_GET_FREE", err)
+		}
+		loop, err := os.OpenFile(fmt.Sprintf("/dev/loop%d", n), os.O_RDWR, 0)
+		if err != nil {
+			return nil, err
+		}
+		err = unix.IoctlSetInt(int(loop.Fd()), unix.LOOP_SET_FD, int(f.Fd()))
+		if errors.Is(err, unix.EBUSY) {
+			// Another process took the device first.
+			loop.Close()
+			continue
+		}
+		if err != nil {
+			loop.Close()
+			return nil, os.NewSyscallError("ioctl LOOP_SET_FD", err)
+		}
+		info := unix.LoopInfo64{Flags: unix.LO_FLAGS_AUTOCLEAR}
+		copy(info.File_name[:], f.Name())
+		if err := unix.IoctlLoopSetStatus64(int(loop.Fd()), &info); err != nil {
+			unix.IoctlSetInt(int(loop.Fd()), unix.LOOP_CLR_FD, 0)
+			loop.Close()
+			return nil, os.NewSyscallError("ioctl LOOP_SET_STATUS64", err)
+		}
+		return loop, nil
+	}
+}
+
+// quotactlPrj sets the block limit of project id, in 1KiB blocks, on the filesystem of f. Zero lifts it.
+func quotactlPrj(f *os.File, id uint32, blocks uint64) error {
+	dq := ifDqblk{bhardlimit: blocks, valid: qifBlimits}
+	_, _, errno := syscall.Syscall6(sysQuotactlFd, f.Fd(), qSetquotaPrj, uintptr(id), uintptr(unsafe.Pointer(&dq)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func ioctlPtr(fd, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
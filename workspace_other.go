@@ -0,0 +1,17 @@
+//go:build !linux
+
+package nsjail
+
+import "errors"
+
+func mountWorkspaceTmpfs(dir string, size uint64) (func() error, error) {
+	return nil, errors.New("tmpfs workspaces need Linux")
+}
+
+func setProjectQuota(dir string, size uint64) (func() error, error) {
+	return nil, errors.New("project quotas need Linux")
+}
+
+func mountWorkspaceImage(dir, image string, size uint64, fsType string) (func() error, error) {
+	return nil, errors.New("loopback workspaces need Linux")
+}
@@ -0,0 +1,35 @@
+package nsjail
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWorkspacePlaceholder(t *testing.T) {
+	n := New("/bin/true").WithWorkspace(WorkspaceOptions{Path: "/out"})
+	if err := n.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	args, err := n.Args()
+	if err != nil {
+		t.Fatalf("Args: %v", err)
+	}
+	if i := slices.Index(args, "-T"); i < 0 || args[i+1] != "/out" {
+		t.Errorf("Args = %q, want a tmpfs at /out", args)
+	}
+	if _, err := n.Exec(); err != nil {
+		t.Errorf("Exec: %v", err)
+	}
+	if n.workspace == nil || len(n.tmpfsMounts) > 0 {
+		t.Errorf("Args changed the jail")
+	}
+	plan, err := n.MountPlan()
+	if err != nil {
+		t.Fatalf("MountPlan: %v", err)
+	}
+	for _, m := range plan {
+		if m.Dst == "/out" {
+			t.Errorf("MountPlan lists the workspace: %+v", m)
+		}
+	}
+}